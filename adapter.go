@@ -2,17 +2,17 @@ package eitcache
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
-// Adapter defines a cache backend.
+// Adapter defines a cache backend. Get returns ErrCacheMiss for missing
+// keys; adapters returning a nil payload and nil error are also treated
+// as a miss.
 type Adapter interface {
 	Get(ctx context.Context, key string) ([]byte, error)
 	Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error
@@ -26,6 +26,86 @@ type Adapter interface {
 	Close() error
 }
 
+// SetNXAdapter is implemented by adapters that can store a value only
+// if the key is absent, atomically.
+type SetNXAdapter interface {
+	SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error)
+}
+
+// CompareAndDeleteAdapter is implemented by adapters that can delete a
+// key only while it still holds an expected value, used to release locks.
+type CompareAndDeleteAdapter interface {
+	CompareAndDelete(ctx context.Context, key string, expected []byte) (bool, error)
+}
+
+// BatchEntry is one write in a SetBatch call.
+type BatchEntry struct {
+	Key   string
+	Value interface{}
+	TTL   time.Duration
+}
+
+// BatchSetAdapter is implemented by adapters that can write many keys
+// in one round trip.
+type BatchSetAdapter interface {
+	SetBatch(ctx context.Context, entries []BatchEntry) error
+}
+
+// MultiGetAdapter is implemented by adapters that can read many keys
+// in one round trip. Missing keys are omitted from the result.
+type MultiGetAdapter interface {
+	GetMulti(ctx context.Context, keys []string) (map[string][]byte, error)
+}
+
+// ValueAdapter is implemented by in-process adapters that can hold Go
+// values without serializing them. Manager uses it when StoresValues
+// reports true. GetValue returns ErrCacheMiss for missing keys and
+// RawValue for entries written with Set.
+type ValueAdapter interface {
+	StoresValues() bool
+	SetValue(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+	GetValue(ctx context.Context, key string) (interface{}, error)
+}
+
+// ScanAdapter is implemented by adapters that can enumerate keys
+// matching a glob pattern, where * matches any run of characters and ?
+// matches one character.
+type ScanAdapter interface {
+	Scan(ctx context.Context, pattern string) ([]string, error)
+}
+
+// IndexAdapter is implemented by adapters that can keep sets of keys,
+// such as a Redis set. AddToIndex adds keys to index and resets its TTL;
+// IndexMembers returns nil for a missing index.
+type IndexAdapter interface {
+	AddToIndex(ctx context.Context, index string, ttl time.Duration, keys ...string) error
+	IndexMembers(ctx context.Context, index string) ([]string, error)
+}
+
+// ErrScanUnsupported is returned by Manager.Scan for adapters that do
+// not implement ScanAdapter.
+var ErrScanUnsupported = errors.New("cache adapter does not support scan")
+
+// PoolStats reports connection pool usage. Hits, Misses and Timeouts
+// count connection checkouts since the pool was created.
+type PoolStats struct {
+	Hits       uint32 `json:"hits"`
+	Misses     uint32 `json:"misses"`
+	Timeouts   uint32 `json:"timeouts"`
+	TotalConns uint32 `json:"total_conns"`
+	IdleConns  uint32 `json:"idle_conns"`
+	StaleConns uint32 `json:"stale_conns"`
+}
+
+// PoolStatsAdapter is implemented by adapters backed by a connection pool.
+type PoolStatsAdapter interface {
+	PoolStats() PoolStats
+}
+
+// defaultRedisPrefix is prepended to Redis keys when CacheConfig.Prefix
+// is empty.
+const defaultRedisPrefix = "eit:cache:"
+
 // RedisCacheAdapter implements Adapter with Redis.
 type RedisCacheAdapter struct {
 	client *redis.Client
@@ -50,11 +130,13 @@ func NewRedisCacheAdapter(config *CacheConfig) (*RedisCacheAdapter, error) {
 	}
 
 	client := redis.NewClient(&redis.Options{
-		Addr:       addr,
-		Password:   config.Password,
-		DB:         config.DB,
-		MaxRetries: config.MaxRetries,
-		PoolSize:   poolSize,
+		Addr:         addr,
+		Password:     config.Password,
+		DB:           config.DB,
+		MaxRetries:   config.MaxRetries,
+		PoolSize:     poolSize,
+		ReadTimeout:  config.ReadTimeout,
+		WriteTimeout: config.WriteTimeout,
 	})
 
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
@@ -65,7 +147,7 @@ func NewRedisCacheAdapter(config *CacheConfig) (*RedisCacheAdapter, error) {
 
 	prefix := config.Prefix
 	if prefix == "" {
-		prefix = "eit:cache:"
+		prefix = defaultRedisPrefix
 	}
 
 	return &RedisCacheAdapter{
@@ -77,7 +159,7 @@ func NewRedisCacheAdapter(config *CacheConfig) (*RedisCacheAdapter, error) {
 
 // Set stores a value.
 func (r *RedisCacheAdapter) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
-	payload, err := json.Marshal(value)
+	payload, err := marshalAdapterValue(value)
 	if err != nil {
 		return fmt.Errorf("marshal value failed: %w", err)
 	}
@@ -86,18 +168,96 @@ func (r *RedisCacheAdapter) Set(ctx context.Context, key string, value interface
 		ttl = r.config.DefaultTTL
 	}
 
-	return r.client.Set(ctx, r.prefix+key, payload, ttl).Err()
+	return writeError(r.client.Set(ctx, r.prefix+key, payload, ttl).Err())
+}
+
+// writeError wraps Redis rejections of values over proto-max-bulk-len in
+// ErrEntryTooLarge.
+func writeError(err error) error {
+	var redisErr redis.Error
+	if errors.As(err, &redisErr) && strings.Contains(redisErr.Error(), "exceeds maximum") {
+		return fmt.Errorf("%w: %w", ErrEntryTooLarge, err)
+	}
+	return err
+}
+
+// SetNX stores a value only if key does not exist.
+func (r *RedisCacheAdapter) SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error) {
+	payload, err := marshalAdapterValue(value)
+	if err != nil {
+		return false, fmt.Errorf("marshal value failed: %w", err)
+	}
+
+	if ttl == 0 {
+		ttl = r.config.DefaultTTL
+	}
+
+	ok, err := r.client.SetNX(ctx, r.prefix+key, payload, ttl).Result()
+	return ok, writeError(err)
+}
+
+var compareAndDeleteScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// CompareAndDelete deletes key only if it holds expected.
+func (r *RedisCacheAdapter) CompareAndDelete(ctx context.Context, key string, expected []byte) (bool, error) {
+	n, err := compareAndDeleteScript.Run(ctx, r.client, []string{r.prefix + key}, expected).Int64()
+	return n > 0, err
 }
 
 // Get retrieves cached bytes.
 func (r *RedisCacheAdapter) Get(ctx context.Context, key string) ([]byte, error) {
 	data, err := r.client.Get(ctx, r.prefix+key).Bytes()
 	if err == redis.Nil {
-		return nil, nil
+		return nil, ErrCacheMiss
 	}
 	return data, err
 }
 
+// SetBatch writes entries in a single pipeline.
+func (r *RedisCacheAdapter) SetBatch(ctx context.Context, entries []BatchEntry) error {
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, entry := range entries {
+			payload, err := marshalAdapterValue(entry.Value)
+			if err != nil {
+				return fmt.Errorf("marshal value failed: %w", err)
+			}
+			ttl := entry.TTL
+			if ttl == 0 {
+				ttl = r.config.DefaultTTL
+			}
+			pipe.Set(ctx, r.prefix+entry.Key, payload, ttl)
+		}
+		return nil
+	})
+	return writeError(err)
+}
+
+// GetMulti retrieves many keys with MGET.
+func (r *RedisCacheAdapter) GetMulti(ctx context.Context, keys []string) (map[string][]byte, error) {
+	result := make(map[string][]byte, len(keys))
+	if len(keys) == 0 {
+		return result, nil
+	}
+	fullKeys := make([]string, 0, len(keys))
+	for _, k := range keys {
+		fullKeys = append(fullKeys, r.prefix+k)
+	}
+	values, err := r.client.MGet(ctx, fullKeys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range values {
+		if s, ok := v.(string); ok {
+			result[keys[i]] = []byte(s)
+		}
+	}
+	return result, nil
+}
+
 // Delete deletes keys.
 func (r *RedisCacheAdapter) Delete(ctx context.Context, keys ...string) error {
 	if len(keys) == 0 {
@@ -131,6 +291,50 @@ func (r *RedisCacheAdapter) DeletePattern(ctx context.Context, pattern string) (
 	return count, iter.Err()
 }
 
+// Scan lists keys matching pattern using SCAN, without the adapter prefix.
+func (r *RedisCacheAdapter) Scan(ctx context.Context, pattern string) ([]string, error) {
+	if pattern == "" {
+		pattern = "*"
+	}
+	iter := r.client.Scan(ctx, 0, r.prefix+pattern, 200).Iterator()
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, strings.TrimPrefix(iter.Val(), r.prefix))
+	}
+	return keys, iter.Err()
+}
+
+// AddToIndex adds keys to the Redis set index with SADD.
+func (r *RedisCacheAdapter) AddToIndex(ctx context.Context, index string, ttl time.Duration, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	if ttl == 0 {
+		ttl = r.config.DefaultTTL
+	}
+	members := make([]interface{}, len(keys))
+	for i, key := range keys {
+		members[i] = key
+	}
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SAdd(ctx, r.prefix+index, members...)
+		if ttl > 0 {
+			pipe.Expire(ctx, r.prefix+index, ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+// IndexMembers returns the members of the Redis set index.
+func (r *RedisCacheAdapter) IndexMembers(ctx context.Context, index string) ([]string, error) {
+	members, err := r.client.SMembers(ctx, r.prefix+index).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return members, err
+}
+
 // Exists checks if a key exists.
 func (r *RedisCacheAdapter) Exists(ctx context.Context, key string) (bool, error) {
 	val, err := r.client.Exists(ctx, r.prefix+key).Result()
@@ -160,9 +364,23 @@ func (r *RedisCacheAdapter) Stats(ctx context.Context) (map[string]interface{},
 	return map[string]interface{}{
 		"db_size":    count,
 		"redis_info": info,
+		"pool":       r.PoolStats(),
 	}, nil
 }
 
+// PoolStats returns the go-redis connection pool statistics.
+func (r *RedisCacheAdapter) PoolStats() PoolStats {
+	s := r.client.PoolStats()
+	return PoolStats{
+		Hits:       s.Hits,
+		Misses:     s.Misses,
+		Timeouts:   s.Timeouts,
+		TotalConns: s.TotalConns,
+		IdleConns:  s.IdleConns,
+		StaleConns: s.StaleConns,
+	}
+}
+
 // Ping checks redis health.
 func (r *RedisCacheAdapter) Ping(ctx context.Context) error {
 	return r.client.Ping(ctx).Err()
@@ -172,184 +390,3 @@ func (r *RedisCacheAdapter) Ping(ctx context.Context) error {
 func (r *RedisCacheAdapter) Close() error {
 	return r.client.Close()
 }
-
-type memoryEntry struct {
-	data     []byte
-	expireAt time.Time
-}
-
-// MemoryCacheAdapter implements Adapter with in-memory map.
-type MemoryCacheAdapter struct {
-	mu         sync.RWMutex
-	cache      map[string]*memoryEntry
-	defaultTTL time.Duration
-}
-
-// NewMemoryCacheAdapter creates a memory adapter.
-func NewMemoryCacheAdapter(defaultTTL time.Duration) *MemoryCacheAdapter {
-	return &MemoryCacheAdapter{
-		cache:      make(map[string]*memoryEntry),
-		defaultTTL: defaultTTL,
-	}
-}
-
-// Set stores a value in memory.
-func (m *MemoryCacheAdapter) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
-	_ = ctx
-	payload, err := json.Marshal(value)
-	if err != nil {
-		return fmt.Errorf("marshal value failed: %w", err)
-	}
-
-	if ttl == 0 {
-		ttl = m.defaultTTL
-	}
-
-	var expireAt time.Time
-	if ttl > 0 {
-		expireAt = time.Now().Add(ttl)
-	}
-
-	m.mu.Lock()
-	m.cache[key] = &memoryEntry{data: payload, expireAt: expireAt}
-	m.mu.Unlock()
-	return nil
-}
-
-// Get retrieves cached bytes.
-func (m *MemoryCacheAdapter) Get(ctx context.Context, key string) ([]byte, error) {
-	_ = ctx
-	m.mu.RLock()
-	entry, exists := m.cache[key]
-	m.mu.RUnlock()
-	if !exists {
-		return nil, nil
-	}
-
-	if !entry.expireAt.IsZero() && time.Now().After(entry.expireAt) {
-		m.mu.Lock()
-		delete(m.cache, key)
-		m.mu.Unlock()
-		return nil, nil
-	}
-
-	return entry.data, nil
-}
-
-// Delete removes keys.
-func (m *MemoryCacheAdapter) Delete(ctx context.Context, keys ...string) error {
-	_ = ctx
-	m.mu.Lock()
-	for _, k := range keys {
-		delete(m.cache, k)
-	}
-	m.mu.Unlock()
-	return nil
-}
-
-// DeletePattern deletes keys with a prefix pattern.
-func (m *MemoryCacheAdapter) DeletePattern(ctx context.Context, pattern string) (int64, error) {
-	_ = ctx
-	if pattern == "" {
-		return 0, nil
-	}
-	prefix := strings.TrimSuffix(pattern, "*")
-	var count int64
-	m.mu.Lock()
-	for k := range m.cache {
-		if strings.HasPrefix(k, prefix) {
-			delete(m.cache, k)
-			count++
-		}
-	}
-	m.mu.Unlock()
-	return count, nil
-}
-
-// Exists checks if a key exists.
-func (m *MemoryCacheAdapter) Exists(ctx context.Context, key string) (bool, error) {
-	_ = ctx
-	m.mu.RLock()
-	entry, exists := m.cache[key]
-	m.mu.RUnlock()
-	if !exists {
-		return false, nil
-	}
-	if !entry.expireAt.IsZero() && time.Now().After(entry.expireAt) {
-		return false, nil
-	}
-	return true, nil
-}
-
-// Incr increments a counter.
-func (m *MemoryCacheAdapter) Incr(ctx context.Context, key string) (int64, error) {
-	return m.addDelta(ctx, key, 1)
-}
-
-// Decr decrements a counter.
-func (m *MemoryCacheAdapter) Decr(ctx context.Context, key string) (int64, error) {
-	return m.addDelta(ctx, key, -1)
-}
-
-func (m *MemoryCacheAdapter) addDelta(ctx context.Context, key string, delta int64) (int64, error) {
-	_ = ctx
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	entry, exists := m.cache[key]
-	if exists && !entry.expireAt.IsZero() && time.Now().After(entry.expireAt) {
-		delete(m.cache, key)
-		exists = false
-	}
-
-	var current int64
-	if exists {
-		_ = json.Unmarshal(entry.data, &current)
-	}
-	current += delta
-
-	payload, err := json.Marshal(current)
-	if err != nil {
-		return 0, err
-	}
-
-	expireAt := time.Time{}
-	if exists {
-		expireAt = entry.expireAt
-	}
-	m.cache[key] = &memoryEntry{data: payload, expireAt: expireAt}
-	return current, nil
-}
-
-// Stats returns memory stats.
-func (m *MemoryCacheAdapter) Stats(ctx context.Context) (map[string]interface{}, error) {
-	_ = ctx
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
-	total := len(m.cache)
-	expired := 0
-	now := time.Now()
-	for _, entry := range m.cache {
-		if !entry.expireAt.IsZero() && now.After(entry.expireAt) {
-			expired++
-		}
-	}
-
-	return map[string]interface{}{
-		"total_items":   total,
-		"expired_items": expired,
-		"active_items":  total - expired,
-	}, nil
-}
-
-// Ping checks memory adapter health.
-func (m *MemoryCacheAdapter) Ping(ctx context.Context) error {
-	_ = ctx
-	return nil
-}
-
-// Close closes memory adapter.
-func (m *MemoryCacheAdapter) Close() error {
-	return nil
-}
@@ -45,11 +45,14 @@ func (a *AdvancedRedisCacheAdapter) GetWithMonitoring(ctx context.Context, key s
 func (a *AdvancedRedisCacheAdapter) SetWithMonitoring(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
 	start := time.Now()
 	err := a.RedisCacheAdapter.Set(ctx, key, value, ttl)
-	duration := time.Since(start)
-	if err == nil {
-		a.monitor.RecordHit(duration)
-	} else {
-		a.monitor.RecordMiss(duration)
+	if err != nil {
+		a.monitor.RecordSetError()
+		a.monitor.RecordError(err)
+		return err
+	}
+	a.monitor.RecordOp(OpLatencySet, time.Since(start))
+	if size := payloadSize(value); size >= 0 {
+		a.monitor.RecordSet(key, size)
 	}
-	return err
+	return nil
 }
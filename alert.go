@@ -0,0 +1,86 @@
+package eitcache
+
+import (
+	"sync"
+	"time"
+)
+
+// Alert rules.
+const (
+	AlertHitRatioBelow  = "hit_ratio_below"
+	AlertErrorRateAbove = "error_rate_above"
+)
+
+// AlertEvent is passed to alert callbacks when a rule starts firing and
+// again, with Firing false, when it resolves. Value is the windowed hit
+// ratio or error rate that triggered the change.
+type AlertEvent struct {
+	Rule      string
+	Threshold float64
+	Value     float64
+	Window    time.Duration
+	Firing    bool
+	Time      time.Time
+}
+
+// OnHitRatioBelow calls fn when the hit ratio over the trailing window
+// drops below threshold, and again when it recovers. Windows with no
+// hits or misses never fire. Rules are checked every tenth of the window,
+// between one and ten seconds, and only once a full window has passed
+// since registration. The returned function stops the rule.
+func (m *Monitor) OnHitRatioBelow(threshold float64, window time.Duration, fn func(AlertEvent)) (stop func()) {
+	return m.watch(AlertHitRatioBelow, threshold, window, fn, func(w WindowMetrics) (float64, bool) {
+		return w.HitRatio, w.HitCount+w.MissCount > 0 && w.HitRatio < threshold
+	})
+}
+
+// OnErrorRateAbove calls fn when errors per hit or miss over the trailing
+// window exceed threshold, and again when the rate recovers. It is checked
+// like OnHitRatioBelow.
+func (m *Monitor) OnErrorRateAbove(threshold float64, window time.Duration, fn func(AlertEvent)) (stop func()) {
+	return m.watch(AlertErrorRateAbove, threshold, window, fn, func(w WindowMetrics) (float64, bool) {
+		return w.ErrorRate, w.ErrorRate > threshold
+	})
+}
+
+func (m *Monitor) watch(rule string, threshold float64, window time.Duration, fn func(AlertEvent), breached func(WindowMetrics) (float64, bool)) func() {
+	if fn == nil || window < time.Second {
+		return func() {}
+	}
+	if window > windowSlots*time.Second {
+		window = windowSlots * time.Second
+	}
+	interval := window / 10
+	if interval < time.Second {
+		interval = time.Second
+	} else if interval > 10*time.Second {
+		interval = 10 * time.Second
+	}
+
+	clock := m.window.clockOrSystem()
+
+	done := make(chan struct{})
+	var once sync.Once
+	go func() {
+		start := clock.Now()
+		firing := false
+		for {
+			select {
+			case <-clock.After(interval):
+			case <-done:
+				return
+			}
+			now := clock.Now()
+			if now.Sub(start) < window {
+				continue
+			}
+			value, bad := breached(m.WindowStats(window))
+			if bad == firing {
+				continue
+			}
+			firing = bad
+			fn(AlertEvent{Rule: rule, Threshold: threshold, Value: value, Window: window, Firing: firing, Time: now})
+		}
+	}()
+	return func() { once.Do(func() { close(done) }) }
+}
@@ -0,0 +1,372 @@
+package eitcache
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultArenaSize is the total slab size of an arena adapter when
+	// CacheConfig.ArenaSize is unset.
+	DefaultArenaSize = 64 << 20
+	// DefaultArenaShards is the shard count when CacheConfig.ArenaShards
+	// is unset.
+	DefaultArenaShards = 16
+
+	// arenaHeaderSize covers the entry length (uint32), the expiration
+	// in Unix nanoseconds (int64) and the key length (uint16).
+	arenaHeaderSize = 14
+)
+
+// arenaShard stores entries back to back in a fixed ring buffer, evicting
+// the oldest entries when it fills up. Its index holds only integers, so
+// the garbage collector does not scan individual entries.
+type arenaShard struct {
+	mu      sync.Mutex
+	buf     []byte
+	index   map[uint64]uint32
+	head    int
+	tail    int
+	wrapAt  int
+	used    int
+	evicted int64
+}
+
+func newArenaShard(size int) *arenaShard {
+	return &arenaShard{buf: make([]byte, size), index: make(map[uint64]uint32), wrapAt: -1}
+}
+
+// alloc reserves n contiguous bytes, evicting from the head as needed.
+func (s *arenaShard) alloc(n int, evicted *[]string) int {
+	for {
+		if s.used == 0 {
+			s.head, s.tail, s.wrapAt = 0, 0, -1
+		}
+		if s.wrapAt < 0 {
+			if len(s.buf)-s.tail >= n {
+				off := s.tail
+				s.tail += n
+				s.used += n
+				return off
+			}
+			if s.head >= n {
+				s.wrapAt = s.tail
+				s.used += len(s.buf) - s.tail + n
+				s.tail = n
+				return 0
+			}
+		} else if s.head-s.tail >= n {
+			off := s.tail
+			s.tail += n
+			s.used += n
+			return off
+		}
+		s.evictHead(evicted)
+	}
+}
+
+// evictHead drops the oldest entry, reporting its key if still indexed.
+func (s *arenaShard) evictHead(evicted *[]string) {
+	if s.wrapAt >= 0 && s.head == s.wrapAt {
+		s.used -= len(s.buf) - s.wrapAt
+		s.head, s.wrapAt = 0, -1
+		return
+	}
+	n := int(binary.LittleEndian.Uint32(s.buf[s.head:]))
+	key := s.key(s.head)
+	h := arenaHash(key)
+	if off, ok := s.index[h]; ok && int(off) == s.head {
+		delete(s.index, h)
+		s.evicted++
+		*evicted = append(*evicted, key)
+	}
+	s.head += n
+	s.used -= n
+}
+
+func (s *arenaShard) key(off int) string {
+	keyLen := int(binary.LittleEndian.Uint16(s.buf[off+12:]))
+	return string(s.buf[off+arenaHeaderSize : off+arenaHeaderSize+keyLen])
+}
+
+func (s *arenaShard) set(key string, data []byte, expireAt int64) []string {
+	n := arenaHeaderSize + len(key) + len(data)
+	var evicted []string
+	off := s.alloc(n, &evicted)
+	entry := s.buf[off : off+n]
+	binary.LittleEndian.PutUint32(entry, uint32(n))
+	binary.LittleEndian.PutUint64(entry[4:], uint64(expireAt))
+	binary.LittleEndian.PutUint16(entry[12:], uint16(len(key)))
+	copy(entry[arenaHeaderSize:], key)
+	copy(entry[arenaHeaderSize+len(key):], data)
+	s.index[arenaHash(key)] = uint32(off)
+	return evicted
+}
+
+// lookup returns the offset of a live entry for key, dropping it from
+// the index if it expired.
+func (s *arenaShard) lookup(key string, now int64) (int, bool) {
+	h := arenaHash(key)
+	off, ok := s.index[h]
+	if !ok || s.key(int(off)) != key {
+		return 0, false
+	}
+	if expireAt := s.expireAt(int(off)); expireAt != 0 && now > expireAt {
+		delete(s.index, h)
+		return 0, false
+	}
+	return int(off), true
+}
+
+func (s *arenaShard) expireAt(off int) int64 {
+	return int64(binary.LittleEndian.Uint64(s.buf[off+4:]))
+}
+
+func (s *arenaShard) data(off int) []byte {
+	n := int(binary.LittleEndian.Uint32(s.buf[off:]))
+	keyLen := int(binary.LittleEndian.Uint16(s.buf[off+12:]))
+	return s.buf[off+arenaHeaderSize+keyLen : off+n]
+}
+
+func (s *arenaShard) remove(key string) {
+	h := arenaHash(key)
+	if off, ok := s.index[h]; ok && s.key(int(off)) == key {
+		delete(s.index, h)
+	}
+}
+
+// arenaHash is 64-bit FNV-1a, inlined to avoid allocating a hasher.
+func arenaHash(key string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= 1099511628211
+	}
+	return h
+}
+
+// ArenaCacheAdapter implements Adapter with pre-allocated byte slabs,
+// bigcache style: values live in per-shard ring buffers indexed by
+// offset, so millions of entries add no GC-scanned pointers. When a shard
+// is full the oldest entries are evicted, regardless of access.
+// Overwritten and deleted entries hold their space until evicted.
+type ArenaCacheAdapter struct {
+	shards     []*arenaShard
+	defaultTTL time.Duration
+	clock      Clock
+	onEvict    func(key string, size int)
+	mu         sync.RWMutex
+}
+
+// NewArenaCacheAdapter creates an arena adapter of size bytes split
+// across shards.
+func NewArenaCacheAdapter(size int64, shards int, defaultTTL time.Duration) *ArenaCacheAdapter {
+	if size <= 0 {
+		size = DefaultArenaSize
+	}
+	if shards <= 0 {
+		shards = DefaultArenaShards
+	}
+	a := &ArenaCacheAdapter{
+		shards:     make([]*arenaShard, shards),
+		defaultTTL: defaultTTL,
+		clock:      SystemClock,
+	}
+	for i := range a.shards {
+		a.shards[i] = newArenaShard(int(size / int64(shards)))
+	}
+	return a
+}
+
+// NewArenaCacheAdapterWithConfig creates an arena adapter from
+// config.ArenaSize and config.ArenaShards.
+func NewArenaCacheAdapterWithConfig(config *CacheConfig) (*ArenaCacheAdapter, error) {
+	a := NewArenaCacheAdapter(config.ArenaSize, config.ArenaShards, config.DefaultTTL)
+	a.clock = clockOrSystem(config.Clock)
+	return a, nil
+}
+
+func (a *ArenaCacheAdapter) shard(key string) *arenaShard {
+	return a.shards[arenaHash(key)%uint64(len(a.shards))]
+}
+
+func (a *ArenaCacheAdapter) expireAt(ttl time.Duration) int64 {
+	if ttl == 0 {
+		ttl = a.defaultTTL
+	}
+	if ttl > 0 {
+		return a.clock.Now().Add(ttl).UnixNano()
+	}
+	return 0
+}
+
+func (a *ArenaCacheAdapter) store(key string, data []byte, expireAt int64) error {
+	s := a.shard(key)
+	if len(key) > 0xFFFF || arenaHeaderSize+len(key)+len(data) > len(s.buf) {
+		return fmt.Errorf("%w: %d bytes exceed an arena shard", ErrEntryTooLarge, arenaHeaderSize+len(key)+len(data))
+	}
+	s.mu.Lock()
+	evicted := s.set(key, data, expireAt)
+	s.mu.Unlock()
+	a.notifyEvicted(evicted)
+	return nil
+}
+
+func (a *ArenaCacheAdapter) notifyEvicted(keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	a.mu.RLock()
+	onEvict := a.onEvict
+	a.mu.RUnlock()
+	if onEvict == nil {
+		return
+	}
+	for _, key := range keys {
+		onEvict(key, 0)
+	}
+}
+
+// SetEvictionCallback registers fn to be called when a live entry is
+// overwritten by the ring buffer. Sizes are not reported.
+func (a *ArenaCacheAdapter) SetEvictionCallback(fn func(key string, size int)) {
+	a.mu.Lock()
+	a.onEvict = fn
+	a.mu.Unlock()
+}
+
+// Set stores a value in the arena.
+func (a *ArenaCacheAdapter) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	_ = ctx
+	payload, err := marshalAdapterValue(value)
+	if err != nil {
+		return fmt.Errorf("marshal value failed: %w", err)
+	}
+	return a.store(key, payload, a.expireAt(ttl))
+}
+
+// Get returns a copy of the cached bytes.
+func (a *ArenaCacheAdapter) Get(ctx context.Context, key string) ([]byte, error) {
+	_ = ctx
+	s := a.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	off, ok := s.lookup(key, a.clock.Now().UnixNano())
+	if !ok {
+		return nil, ErrCacheMiss
+	}
+	return append([]byte(nil), s.data(off)...), nil
+}
+
+// Delete removes keys.
+func (a *ArenaCacheAdapter) Delete(ctx context.Context, keys ...string) error {
+	_ = ctx
+	for _, key := range keys {
+		s := a.shard(key)
+		s.mu.Lock()
+		s.remove(key)
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+// DeletePattern deletes keys with a prefix pattern.
+func (a *ArenaCacheAdapter) DeletePattern(ctx context.Context, pattern string) (int64, error) {
+	_ = ctx
+	if pattern == "" {
+		return 0, nil
+	}
+	prefix := strings.TrimSuffix(pattern, "*")
+	var count int64
+	for _, s := range a.shards {
+		s.mu.Lock()
+		for h, off := range s.index {
+			if strings.HasPrefix(s.key(int(off)), prefix) {
+				delete(s.index, h)
+				count++
+			}
+		}
+		s.mu.Unlock()
+	}
+	return count, nil
+}
+
+// Exists checks if a key exists.
+func (a *ArenaCacheAdapter) Exists(ctx context.Context, key string) (bool, error) {
+	_ = ctx
+	s := a.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.lookup(key, a.clock.Now().UnixNano())
+	return ok, nil
+}
+
+// Incr increments a counter.
+func (a *ArenaCacheAdapter) Incr(ctx context.Context, key string) (int64, error) {
+	return a.addDelta(ctx, key, 1)
+}
+
+// Decr decrements a counter.
+func (a *ArenaCacheAdapter) Decr(ctx context.Context, key string) (int64, error) {
+	return a.addDelta(ctx, key, -1)
+}
+
+func (a *ArenaCacheAdapter) addDelta(ctx context.Context, key string, delta int64) (int64, error) {
+	_ = ctx
+	s := a.shard(key)
+	s.mu.Lock()
+	var (
+		current  int64
+		expireAt int64
+	)
+	if off, ok := s.lookup(key, a.clock.Now().UnixNano()); ok {
+		_ = json.Unmarshal(s.data(off), &current)
+		expireAt = s.expireAt(off)
+	}
+	current += delta
+	payload, _ := json.Marshal(current)
+	evicted := s.set(key, payload, expireAt)
+	s.mu.Unlock()
+	a.notifyEvicted(evicted)
+	return current, nil
+}
+
+// Stats returns arena stats.
+func (a *ArenaCacheAdapter) Stats(ctx context.Context) (map[string]interface{}, error) {
+	_ = ctx
+	var (
+		items, used, capacity int
+		evicted               int64
+	)
+	for _, s := range a.shards {
+		s.mu.Lock()
+		items += len(s.index)
+		used += s.used
+		capacity += len(s.buf)
+		evicted += s.evicted
+		s.mu.Unlock()
+	}
+	return map[string]interface{}{
+		"total_items":    items,
+		"used_bytes":     used,
+		"capacity_bytes": capacity,
+		"shards":         len(a.shards),
+		"evictions":      evicted,
+	}, nil
+}
+
+// Ping checks arena adapter health.
+func (a *ArenaCacheAdapter) Ping(ctx context.Context) error {
+	_ = ctx
+	return nil
+}
+
+// Close closes the arena adapter.
+func (a *ArenaCacheAdapter) Close() error {
+	return nil
+}
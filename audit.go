@@ -0,0 +1,106 @@
+package eitcache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultAuditLogSize is the number of invalidations kept when
+// CacheConfig.AuditLogSize is unset.
+const DefaultAuditLogSize = 128
+
+// Audit operations.
+const (
+	AuditDelete        = "delete"
+	AuditDeletePattern = "delete_pattern"
+	AuditBumpEpoch     = "bump_epoch"
+)
+
+// AuditRecord describes one invalidation. Count is the number of keys
+// removed; it is zero for epoch bumps, which leave old keys to expire.
+type AuditRecord struct {
+	Time    time.Time `json:"time"`
+	Actor   string    `json:"actor,omitempty"`
+	Op      string    `json:"op"`
+	Keys    []string  `json:"keys,omitempty"`
+	Pattern string    `json:"pattern,omitempty"`
+	Count   int64     `json:"count"`
+}
+
+type actorKey struct{}
+
+// WithActor attributes invalidations made with ctx to actor, such as a
+// user name or service, in the audit log.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// auditLog keeps the most recent invalidations in a ring buffer.
+type auditLog struct {
+	mu      sync.Mutex
+	records []AuditRecord
+	next    int
+	full    bool
+	persist time.Duration
+	seq     uint64
+}
+
+func newAuditLog(size int, persist time.Duration) *auditLog {
+	if size <= 0 {
+		size = DefaultAuditLogSize
+	}
+	return &auditLog{records: make([]AuditRecord, size), persist: persist}
+}
+
+func (l *auditLog) add(record AuditRecord) uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.records[l.next] = record
+	l.next = (l.next + 1) % len(l.records)
+	if l.next == 0 {
+		l.full = true
+	}
+	l.seq++
+	return l.seq
+}
+
+func (l *auditLog) list() []AuditRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.full {
+		return append([]AuditRecord(nil), l.records[:l.next]...)
+	}
+	out := make([]AuditRecord, 0, len(l.records))
+	out = append(out, l.records[l.next:]...)
+	return append(out, l.records[:l.next]...)
+}
+
+// AuditLog returns recent invalidations, oldest first.
+func (m *Manager) AuditLog() []AuditRecord {
+	return m.audit.list()
+}
+
+// recordAudit logs an invalidation and, if CacheConfig.AuditPersistTTL is
+// set, writes it to the adapter under an "audit:" key.
+func (m *Manager) recordAudit(ctx context.Context, op string, keys []string, pattern string, count int64) {
+	record := AuditRecord{Time: time.Now(), Op: op, Keys: keys, Pattern: pattern, Count: count}
+	record.Actor, _ = ctx.Value(actorKey{}).(string)
+	seq := m.audit.add(record)
+	if m.audit.persist <= 0 {
+		return
+	}
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	key := m.key(fmt.Sprintf("audit:%d:%d", record.Time.UnixNano(), seq))
+	err = m.guard(ctx, m.writeTimeout, func(ctx context.Context) error {
+		return m.adapter.Set(ctx, key, RawValue(payload), m.audit.persist)
+	})
+	if err != nil {
+		m.reportError(ctx, OpSet, key, err)
+	}
+}
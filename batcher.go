@@ -0,0 +1,109 @@
+package eitcache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type batchResult[T any] struct {
+	value T
+	err   error
+}
+
+// Batcher coalesces individual loads arriving within a short window into
+// a single multi-get and one batched loader call, dataloader style.
+type Batcher[T any] struct {
+	manager  *Manager
+	window   time.Duration
+	maxBatch int
+	loader   func(keys []string) (map[string]T, error)
+	opts     []QueryOption
+
+	mu      sync.Mutex
+	pending map[string][]chan batchResult[T]
+	ctx     context.Context
+	timer   *time.Timer
+}
+
+// NewBatcher creates a batcher that waits up to window before loading.
+// Keys the loader does not return resolve to ErrNotFound.
+func NewBatcher[T any](manager *Manager, window time.Duration, loader func(keys []string) (map[string]T, error), opts ...QueryOption) *Batcher[T] {
+	if window <= 0 {
+		window = 2 * time.Millisecond
+	}
+	return &Batcher[T]{
+		manager: manager,
+		window:  window,
+		loader:  loader,
+		opts:    opts,
+		pending: make(map[string][]chan batchResult[T]),
+	}
+}
+
+// SetMaxBatch flushes a batch as soon as it holds n distinct keys.
+// Zero means no limit.
+func (b *Batcher[T]) SetMaxBatch(n int) {
+	b.mu.Lock()
+	b.maxBatch = n
+	b.mu.Unlock()
+}
+
+// Load queues key for the current batch and waits for its result.
+func (b *Batcher[T]) Load(ctx context.Context, key string) (T, error) {
+	var zero T
+	if b.manager == nil {
+		return zero, ErrManagerNil
+	}
+
+	ch := make(chan batchResult[T], 1)
+	b.mu.Lock()
+	if len(b.pending) == 0 {
+		b.ctx = context.WithoutCancel(ctx)
+		b.timer = time.AfterFunc(b.window, b.flush)
+	}
+	b.pending[key] = append(b.pending[key], ch)
+	if b.maxBatch > 0 && len(b.pending) >= b.maxBatch {
+		b.timer.Stop()
+		go b.flush()
+	}
+	b.mu.Unlock()
+
+	select {
+	case res := <-ch:
+		return res.value, res.err
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
+
+func (b *Batcher[T]) flush() {
+	b.mu.Lock()
+	pending := b.pending
+	ctx := b.ctx
+	b.pending = make(map[string][]chan batchResult[T])
+	b.mu.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(pending))
+	for key := range pending {
+		keys = append(keys, key)
+	}
+
+	values, err := QueryMany(ctx, b.manager, keys, b.loader, b.opts...)
+	for key, waiters := range pending {
+		res := batchResult[T]{err: err}
+		if err == nil {
+			value, ok := values[key]
+			res.value = value
+			if !ok {
+				res.err = ErrNotFound
+			}
+		}
+		for _, ch := range waiters {
+			ch <- res
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package eitcache
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// bigKeysTracked is the number of largest keys kept by the Monitor.
+const bigKeysTracked = 20
+
+// BigKey is a key and the size of its last written payload in bytes.
+type BigKey struct {
+	Key  string `json:"key"`
+	Size int    `json:"size"`
+}
+
+// RecordSet records the payload size of a successful write to key.
+func (m *Monitor) RecordSet(key string, size int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sizes.record(int64(size))
+	for i := range m.bigKeys {
+		if m.bigKeys[i].Key == key {
+			m.bigKeys[i].Size = size
+			return
+		}
+	}
+	if len(m.bigKeys) < bigKeysTracked {
+		m.bigKeys = append(m.bigKeys, BigKey{Key: key, Size: size})
+		return
+	}
+	smallest := 0
+	for i := range m.bigKeys {
+		if m.bigKeys[i].Size < m.bigKeys[smallest].Size {
+			smallest = i
+		}
+	}
+	if size > m.bigKeys[smallest].Size {
+		m.bigKeys[smallest] = BigKey{Key: key, Size: size}
+	}
+}
+
+// BigKeys returns up to n of the largest keys written, largest first.
+// A non-positive n returns every tracked key.
+func (m *Monitor) BigKeys(n int) []BigKey {
+	m.mu.RLock()
+	out := append([]BigKey(nil), m.bigKeys...)
+	m.mu.RUnlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Size != out[j].Size {
+			return out[i].Size > out[j].Size
+		}
+		return out[i].Key < out[j].Key
+	})
+	if n > 0 && n < len(out) {
+		out = out[:n]
+	}
+	return out
+}
+
+// SetBigKeyThreshold sets the payload size in bytes above which writes
+// are logged as big keys. Zero disables the warning.
+func (m *Manager) SetBigKeyThreshold(bytes int) {
+	m.update(func(s *managerSettings) { s.bigKeyThresh = bytes })
+}
+
+// recordSet records the write's size and latency, warns about big keys
+// and fires set hooks.
+func (m *Manager) recordSet(ctx context.Context, key string, elapsed time.Duration, size int) {
+	if m.monitor != nil {
+		m.monitor.RecordSet(key, size)
+		m.monitor.RecordOp(OpLatencySet, elapsed)
+	}
+	if threshold := m.current().bigKeyThresh; threshold > 0 && size > threshold {
+		m.log().Warn("cache big key", "key", key, "size", size, "threshold", threshold)
+	}
+	m.hooks.fire(ctx, hookSet, HookEvent{Key: key, Duration: elapsed, Size: size})
+}
@@ -0,0 +1,189 @@
+package eitcache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultBreakerCooldown is how long an open breaker bypasses the cache
+// before letting a probe request through.
+const DefaultBreakerCooldown = 5 * time.Second
+
+// ErrCircuitOpen is returned while the breaker bypasses the adapter.
+// Bypassed calls are counted in CacheMetrics.BreakerBypassCount rather
+// than reported as adapter errors.
+var ErrCircuitOpen = errors.New("cache circuit breaker is open")
+
+// BreakerState is the state of the adapter circuit breaker.
+type BreakerState int
+
+const (
+	// BreakerClosed passes every call to the adapter.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen bypasses the adapter until the cooldown elapses.
+	BreakerOpen
+	// BreakerHalfOpen lets a single probe through to test recovery.
+	BreakerHalfOpen
+)
+
+// String returns the state name.
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// circuitBreaker trips after threshold consecutive adapter failures.
+// A nil breaker allows every call.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	monitor   *Monitor
+	clock     Clock
+	state     BreakerState
+	failures  int
+	openedAt  time.Time
+	probing   bool
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration, monitor *Monitor, clock Clock) *circuitBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+	if cooldown <= 0 {
+		cooldown = DefaultBreakerCooldown
+	}
+	b := &circuitBreaker{threshold: threshold, cooldown: cooldown, monitor: monitor, clock: clockOrSystem(clock)}
+	if monitor != nil {
+		monitor.RecordBreakerState(BreakerClosed)
+	}
+	return b
+}
+
+// allow reports whether a call may reach the adapter.
+func (b *circuitBreaker) allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if b.clock.Now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.transition(BreakerHalfOpen)
+		b.probing = true
+		return true
+	case BreakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// record feeds the outcome of an allowed call back into the breaker.
+func (b *circuitBreaker) record(err error) {
+	if b == nil {
+		return
+	}
+	failed := err != nil && !errors.Is(err, context.Canceled)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerHalfOpen {
+		b.probing = false
+		if failed {
+			b.trip()
+		} else {
+			b.failures = 0
+			b.transition(BreakerClosed)
+		}
+		return
+	}
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == BreakerClosed && b.failures >= b.threshold {
+		b.trip()
+	}
+}
+
+func (b *circuitBreaker) trip() {
+	b.openedAt = b.clock.Now()
+	b.failures = 0
+	b.transition(BreakerOpen)
+}
+
+func (b *circuitBreaker) transition(state BreakerState) {
+	if b.state == state {
+		return
+	}
+	b.state = state
+	if b.monitor != nil {
+		b.monitor.RecordBreakerState(state)
+	}
+}
+
+func (b *circuitBreaker) current() BreakerState {
+	if b == nil {
+		return BreakerClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// guard runs fn against the adapter unless the breaker is open,
+// retrying transient errors per the retry policy. Each attempt is
+// bounded by timeout when positive.
+func (m *Manager) guard(ctx context.Context, timeout time.Duration, fn func(context.Context) error) error {
+	if !m.breaker.allow() {
+		return ErrCircuitOpen
+	}
+	retry := m.current().retry
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = m.attempt(ctx, timeout, fn)
+		if err == nil || attempt >= retry.attempts() || ctx.Err() != nil || !retry.retryable(err) {
+			break
+		}
+		if m.monitor != nil {
+			m.monitor.RecordRetry()
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(retry.delay(attempt)):
+		}
+	}
+	m.breaker.record(err)
+	return err
+}
+
+func (m *Manager) attempt(ctx context.Context, timeout time.Duration, fn func(context.Context) error) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return fn(ctx)
+}
+
+// BreakerState returns the adapter circuit breaker state. It is always
+// BreakerClosed when the breaker is disabled.
+func (m *Manager) BreakerState() BreakerState {
+	return m.breaker.current()
+}
@@ -0,0 +1,131 @@
+package eitcache
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// KeyCanonicalization controls how filter values are normalized before
+// they are written into generated cache keys, so logically equal filters
+// share an entry. NormalizeTypes treats numeric and boolean strings as
+// numbers and booleans, so 1 and "1" match; SortSlices ignores the order
+// of slice elements; FoldCase lowercases strings. The zero value keeps
+// values as they are.
+type KeyCanonicalization struct {
+	NormalizeTypes bool
+	SortSlices     bool
+	FoldCase       bool
+}
+
+// Canonicalize returns a copy of filters with every value normalized.
+// Pass the result to GenerateCacheKey and the other key generators.
+func (c KeyCanonicalization) Canonicalize(filters map[string]interface{}) map[string]interface{} {
+	if c == (KeyCanonicalization{}) || len(filters) == 0 {
+		return filters
+	}
+	out := make(map[string]interface{}, len(filters))
+	for k, v := range filters {
+		out[k] = c.value(v)
+	}
+	return out
+}
+
+// value normalizes v after a JSON round trip, which already unifies Go
+// integer and float types, structs and maps.
+func (c KeyCanonicalization) value(v interface{}) interface{} {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	var generic interface{}
+	if err := dec.Decode(&generic); err != nil {
+		return v
+	}
+	return c.walk(generic)
+}
+
+func (c KeyCanonicalization) walk(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		if c.FoldCase {
+			v = strings.ToLower(v)
+		}
+		if c.NormalizeTypes {
+			if v == "true" || v == "false" {
+				return v == "true"
+			}
+			if n, ok := canonicalNumber(v); ok {
+				return n
+			}
+		}
+		return v
+	case json.Number:
+		if c.NormalizeTypes {
+			if n, ok := canonicalNumber(v.String()); ok {
+				return n
+			}
+		}
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = c.walk(v[i])
+		}
+		if c.SortSlices {
+			encoded := make([]string, len(v))
+			for i := range v {
+				b, _ := json.Marshal(v[i])
+				encoded[i] = string(b)
+			}
+			sort.Sort(byEncoding{v, encoded})
+		}
+		return v
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = c.walk(v[k])
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// canonicalNumber parses s as a number, formatting integers exactly and
+// other values in their shortest form, so "1", "1.0" and "1e0" match.
+func canonicalNumber(s string) (json.Number, bool) {
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return json.Number(strconv.FormatInt(i, 10)), true
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || !json.Valid([]byte(s)) {
+		return "", false
+	}
+	if f == float64(int64(f)) && f >= -1<<53 && f <= 1<<53 {
+		return json.Number(strconv.FormatInt(int64(f), 10)), true
+	}
+	return json.Number(strconv.FormatFloat(f, 'g', -1, 64)), true
+}
+
+// byEncoding sorts values by their JSON encoding.
+type byEncoding struct {
+	values  []interface{}
+	encoded []string
+}
+
+func (s byEncoding) Len() int           { return len(s.values) }
+func (s byEncoding) Less(i, j int) bool { return s.encoded[i] < s.encoded[j] }
+func (s byEncoding) Swap(i, j int) {
+	s.values[i], s.values[j] = s.values[j], s.values[i]
+	s.encoded[i], s.encoded[j] = s.encoded[j], s.encoded[i]
+}
+
+// SetKeyCanonicalization sets how filter values are normalized in the keys
+// of paginated, keyset and count queries. Changing it changes generated
+// keys, so existing entries are no longer read.
+func (m *Manager) SetKeyCanonicalization(c KeyCanonicalization) {
+	m.update(func(s *managerSettings) { s.canon = c })
+}
@@ -0,0 +1,204 @@
+package eitcache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ChangeEvent is a row change read from a change data capture stream.
+// Op follows Debezium: "c" create, "u" update, "d" delete, "r" snapshot
+// read.
+type ChangeEvent struct {
+	Schema string
+	Table  string
+	Op     string
+	Before map[string]interface{}
+	After  map[string]interface{}
+}
+
+// CDCRule maps changes of Table to cache invalidations. Keys and Patterns
+// are templates whose {column} placeholders are filled from the changed
+// row; both the before and after images are used, so changing a column
+// that appears in a key also purges the old key. Resources are passed to
+// InvalidateCacheOnUpdate. Table may be qualified as "schema.table".
+type CDCRule struct {
+	Table     string
+	Keys      []string
+	Patterns  []string
+	Resources []string
+}
+
+// CDCInvalidator applies ChangeEvents to a Manager through a rule set.
+type CDCInvalidator struct {
+	manager *Manager
+	rules   []CDCRule
+}
+
+// NewCDCInvalidator creates an invalidator for rules.
+func NewCDCInvalidator(manager *Manager, rules ...CDCRule) *CDCInvalidator {
+	return &CDCInvalidator{manager: manager, rules: rules}
+}
+
+// Apply purges the cache entries that rules map event to, plus the
+// resources mapped to its table by CacheConfig.TableResources. Events of
+// other tables, including tombstones, are ignored.
+func (i *CDCInvalidator) Apply(ctx context.Context, event ChangeEvent) error {
+	if i.manager == nil {
+		return ErrManagerNil
+	}
+	var errs []error
+	for _, rule := range i.rules {
+		if !rule.matches(event) {
+			continue
+		}
+		if keys := expandTemplates(rule.Keys, event); len(keys) > 0 {
+			if err := i.manager.Delete(ctx, keys...); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		for _, pattern := range expandTemplates(rule.Patterns, event) {
+			if _, err := i.manager.DeletePattern(ctx, pattern); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		for _, resource := range expandTemplates(rule.Resources, event) {
+			if _, err := InvalidateCacheOnUpdate(ctx, i.manager, resource); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if event.Table != "" {
+		tables := i.manager.current().tables
+		resources, ok := tables[event.Schema+"."+event.Table]
+		if !ok {
+			resources = tables[event.Table]
+		}
+		for _, resource := range resources {
+			if _, err := InvalidateCacheOnUpdate(ctx, i.manager, resource); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r CDCRule) matches(event ChangeEvent) bool {
+	if event.Table == "" {
+		return false
+	}
+	return r.Table == event.Table || r.Table == event.Schema+"."+event.Table
+}
+
+// expandTemplates fills templates from the before and after rows,
+// dropping duplicates and templates that reference missing columns.
+func expandTemplates(templates []string, event ChangeEvent) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, tmpl := range templates {
+		for _, row := range []map[string]interface{}{event.Before, event.After} {
+			key, ok := expandTemplate(tmpl, row)
+			if ok && !seen[key] {
+				seen[key] = true
+				out = append(out, key)
+			}
+		}
+	}
+	return out
+}
+
+func expandTemplate(tmpl string, row map[string]interface{}) (string, bool) {
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(tmpl, '{')
+		if start < 0 {
+			b.WriteString(tmpl)
+			return b.String(), true
+		}
+		end := strings.IndexByte(tmpl[start:], '}')
+		if end < 0 {
+			b.WriteString(tmpl)
+			return b.String(), true
+		}
+		value, ok := row[tmpl[start+1:start+end]]
+		if !ok || value == nil {
+			return "", false
+		}
+		b.WriteString(tmpl[:start])
+		fmt.Fprint(&b, value)
+		tmpl = tmpl[start+end+1:]
+	}
+}
+
+// ParseDebeziumEvent decodes a Debezium JSON change event, with or
+// without the schema envelope. Tombstones decode to a zero ChangeEvent.
+func ParseDebeziumEvent(data []byte) (ChangeEvent, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return ChangeEvent{}, nil
+	}
+	type payload struct {
+		Before map[string]interface{} `json:"before"`
+		After  map[string]interface{} `json:"after"`
+		Op     string                 `json:"op"`
+		Source struct {
+			Schema string `json:"schema"`
+			Table  string `json:"table"`
+		} `json:"source"`
+	}
+	var envelope struct {
+		payload
+		Payload *payload `json:"payload"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&envelope); err != nil {
+		return ChangeEvent{}, fmt.Errorf("decode change event failed: %w", err)
+	}
+	p := envelope.payload
+	if envelope.Payload != nil {
+		p = *envelope.Payload
+	}
+	return ChangeEvent{
+		Schema: p.Source.Schema,
+		Table:  p.Source.Table,
+		Op:     p.Op,
+		Before: p.Before,
+		After:  p.After,
+	}, nil
+}
+
+// ChangeSource yields raw change events, typically from a Kafka consumer
+// group reading Debezium topics. ack commits the event's offset.
+type ChangeSource interface {
+	Next(ctx context.Context) (payload []byte, ack func() error, err error)
+}
+
+// Consume applies events from source until ctx is cancelled or source
+// fails. Events that fail to decode or apply are passed to onError, if
+// set, and still acknowledged: the entries they miss expire by TTL.
+func (i *CDCInvalidator) Consume(ctx context.Context, source ChangeSource, onError func(payload []byte, err error)) error {
+	for {
+		payload, ack, err := source.Next(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		event, err := ParseDebeziumEvent(payload)
+		if err == nil {
+			err = i.Apply(ctx, event)
+		}
+		if err != nil && onError != nil {
+			onError(payload, err)
+		}
+		if ack != nil {
+			if err := ack(); err != nil {
+				return err
+			}
+		}
+	}
+}
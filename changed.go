@@ -0,0 +1,42 @@
+package eitcache
+
+import "context"
+
+// pageHash decodes only the data hash of a cached page.
+type pageHash struct {
+	DataHash string `json:"data_hash"`
+}
+
+// HasChanged reports whether the cached page for resource, filters and
+// params no longer has currentHash as its DataHash, for cheap polling
+// endpoints. Only the hash is decoded, not the page data. A page that is
+// not cached is reported as changed, so the client refetches it.
+func HasChanged(
+	ctx context.Context,
+	manager *Manager,
+	resource string,
+	filters map[string]interface{},
+	params *PaginationParams,
+	currentHash string,
+) (bool, error) {
+	if manager == nil {
+		return false, ErrManagerNil
+	}
+	params = manager.NormalizePaginationParams(resource, params)
+	if err := manager.validateSort(resource, params); err != nil {
+		return false, err
+	}
+	key, _, err := manager.resourceKey(ctx, resource, filters, params)
+	if err != nil {
+		return false, err
+	}
+	data, err := manager.load(ctx, key)
+	if err != nil || data == nil {
+		return true, err
+	}
+	var cached pageHash
+	if err := manager.decode(data, &cached); err != nil {
+		return true, nil
+	}
+	return cached.DataHash != currentHash, nil
+}
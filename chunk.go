@@ -0,0 +1,140 @@
+package eitcache
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"time"
+)
+
+// chunkManifestMagic marks a manifest entry. The layout is:
+//
+//	magic(2) chunks(4) size(4) crc32(4)
+var chunkManifestMagic = []byte{0xEC, 0x43}
+
+const chunkManifestSize = 14
+
+type chunkManifest struct {
+	chunks uint32
+	size   uint32
+	sum    uint32
+}
+
+func (c chunkManifest) encode() []byte {
+	out := make([]byte, chunkManifestSize)
+	copy(out, chunkManifestMagic)
+	binary.BigEndian.PutUint32(out[2:], c.chunks)
+	binary.BigEndian.PutUint32(out[6:], c.size)
+	binary.BigEndian.PutUint32(out[10:], c.sum)
+	return out
+}
+
+func parseChunkManifest(data []byte) (chunkManifest, bool) {
+	if len(data) != chunkManifestSize || !bytes.HasPrefix(data, chunkManifestMagic) {
+		return chunkManifest{}, false
+	}
+	return chunkManifest{
+		chunks: binary.BigEndian.Uint32(data[2:]),
+		size:   binary.BigEndian.Uint32(data[6:]),
+		sum:    binary.BigEndian.Uint32(data[10:]),
+	}, true
+}
+
+// ChunkKey returns the key holding chunk n of a chunked entry.
+func ChunkKey(key string, n int) string {
+	return fmt.Sprintf("%s:chunk:%d", key, n)
+}
+
+// storePayload writes payload under the full key, chunking it when it
+// exceeds chunkSize. Chunks of a previous, larger chunked entry are
+// deleted once the new entry is written, so overwrites do not orphan them.
+func (m *Manager) storePayload(ctx context.Context, key string, payload []byte, ttl time.Duration) error {
+	size := m.current().chunkSize
+	if size <= 0 {
+		return m.adapter.Set(ctx, key, RawValue(payload), ttl)
+	}
+	var previous int
+	if data, err := m.adapter.Get(ctx, key); err == nil {
+		if manifest, ok := parseChunkManifest(data); ok {
+			previous = int(manifest.chunks)
+		}
+	}
+	var count int
+	var err error
+	if len(payload) > size {
+		count, err = m.storeChunked(ctx, key, payload, size, ttl)
+	} else {
+		err = m.adapter.Set(ctx, key, RawValue(payload), ttl)
+	}
+	if err != nil || previous <= count {
+		return err
+	}
+	stale := make([]string, 0, previous-count)
+	for n := count; n < previous; n++ {
+		stale = append(stale, ChunkKey(key, n))
+	}
+	return m.adapter.Delete(ctx, stale...)
+}
+
+// storeChunked splits payload into chunks of size, writing the chunks
+// before the manifest so readers never observe a manifest without its
+// parts. It returns the number of chunks.
+func (m *Manager) storeChunked(ctx context.Context, key string, payload []byte, size int, ttl time.Duration) (int, error) {
+	count := (len(payload) + size - 1) / size
+	for n := 0; n < count; n++ {
+		end := (n + 1) * size
+		if end > len(payload) {
+			end = len(payload)
+		}
+		if err := m.adapter.Set(ctx, ChunkKey(key, n), RawValue(payload[n*size:end]), ttl); err != nil {
+			return 0, fmt.Errorf("store chunk %d failed: %w", n, err)
+		}
+	}
+	manifest := chunkManifest{
+		chunks: uint32(count),
+		size:   uint32(len(payload)),
+		sum:    crc32.ChecksumIEEE(payload),
+	}
+	return count, m.adapter.Set(ctx, key, RawValue(manifest.encode()), ttl)
+}
+
+// loadChunked reassembles a chunked entry. A missing or mismatched
+// chunk is reported as a miss.
+func (m *Manager) loadChunked(ctx context.Context, key string, manifest chunkManifest) ([]byte, error) {
+	payload := make([]byte, 0, manifest.size)
+	for n := 0; n < int(manifest.chunks); n++ {
+		part, err := m.get(ctx, ChunkKey(key, n))
+		if err != nil {
+			return nil, err
+		}
+		if part == nil {
+			return nil, nil
+		}
+		payload = append(payload, part...)
+	}
+	if uint32(len(payload)) != manifest.size || crc32.ChecksumIEEE(payload) != manifest.sum {
+		return nil, nil
+	}
+	return payload, nil
+}
+
+// chunkKeys expands keys with the chunk keys of any chunked entries.
+func (m *Manager) chunkKeys(ctx context.Context, keys []string) []string {
+	expanded := append([]string(nil), keys...)
+	for _, key := range keys {
+		data, err := m.get(ctx, key)
+		if err != nil {
+			continue
+		}
+		manifest, ok := parseChunkManifest(data)
+		if !ok {
+			continue
+		}
+		for n := 0; n < int(manifest.chunks); n++ {
+			expanded = append(expanded, ChunkKey(key, n))
+		}
+	}
+	return expanded
+}
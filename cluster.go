@@ -0,0 +1,90 @@
+package eitcache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ClusterDeleteResult aggregates a ClusterDeletePattern across nodes,
+// keyed by node ID.
+type ClusterDeleteResult struct {
+	Total    int64
+	Nodes    map[string]int64
+	Failures map[string]error
+}
+
+// NodeID identifies this instance on the invalidation bus. It is empty
+// when CacheConfig.Invalidation is unset.
+func (m *Manager) NodeID() string {
+	if m.bus == nil {
+		return ""
+	}
+	return m.bus.origin
+}
+
+// ClusterDeletePattern deletes pattern locally and on every instance
+// subscribed to the invalidation bus, collecting their counts for up to
+// wait. Nodes that fail are reported in Failures; nodes that do not
+// answer in time are absent from the result. Without an invalidation bus
+// it behaves like DeletePattern.
+func (m *Manager) ClusterDeletePattern(ctx context.Context, pattern string, wait time.Duration) (*ClusterDeleteResult, error) {
+	result := &ClusterDeleteResult{Nodes: make(map[string]int64), Failures: make(map[string]error)}
+	if m.bus == nil {
+		n, err := m.DeletePattern(ctx, pattern)
+		result.Total = n
+		result.Nodes[""] = n
+		return result, err
+	}
+	if m.adapter == nil {
+		return nil, errors.New("cache adapter is nil")
+	}
+	if m.ReadOnly() {
+		return result, nil
+	}
+
+	id, acks := m.bus.request()
+	defer m.bus.release(id)
+	msg := InvalidationMessage{Patterns: []string{m.key(pattern)}}
+	request := msg
+	request.ID = id
+	if err := m.bus.publish(ctx, request); err != nil {
+		return nil, err
+	}
+
+	var n int64
+	err := m.awaitWrites(ctx, nil)
+	if err == nil {
+		err = m.guard(ctx, m.opTimeout, func(ctx context.Context) (err error) {
+			n, err = m.adapter.DeletePattern(ctx, m.key(pattern))
+			return err
+		})
+	}
+	result.Nodes[m.bus.origin] = n
+	result.Total = n
+	if err != nil {
+		result.Failures[m.bus.origin] = err
+	} else {
+		m.pages.forgetPattern(m.key(pattern))
+		m.scheduleDoubleDelete(msg)
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		select {
+		case ack := <-acks:
+			result.Nodes[ack.Origin] = ack.Count
+			result.Total += ack.Count
+			if ack.Error != "" {
+				result.Failures[ack.Origin] = errors.New(ack.Error)
+			}
+		case <-timer.C:
+			m.recordAudit(ctx, AuditDeletePattern, nil, pattern, result.Total)
+			return result, nil
+		case <-ctx.Done():
+			m.recordAudit(ctx, AuditDeletePattern, nil, pattern, result.Total)
+			return result, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package eitcache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec serializes cache values.
+type Codec interface {
+	Name() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// RawValue is a pre-encoded payload that adapters store as-is.
+type RawValue []byte
+
+// JSONCodec encodes values as JSON.
+type JSONCodec struct{}
+
+// Name returns the codec name.
+func (JSONCodec) Name() string { return "json" }
+
+// Marshal encodes v as JSON.
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal decodes JSON data into v.
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// GobCodec encodes values with encoding/gob.
+// Use it for Go-only caches: it keeps time.Time and int64 precision intact,
+// but payloads are not readable from other languages.
+type GobCodec struct{}
+
+// Name returns the codec name.
+func (GobCodec) Name() string { return "gob" }
+
+// Marshal encodes v with gob.
+func (GobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes gob data into v.
+func (GobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// ProtoCodec encodes proto.Message values with protobuf and
+// delegates everything else to Fallback.
+type ProtoCodec struct {
+	Fallback Codec
+}
+
+// NewProtoCodec creates a protobuf codec. A nil fallback uses DefaultCodec.
+func NewProtoCodec(fallback Codec) *ProtoCodec {
+	return &ProtoCodec{Fallback: fallback}
+}
+
+// Name returns the codec name.
+func (c *ProtoCodec) Name() string { return "protobuf" }
+
+// Marshal encodes v with protobuf if it is a proto.Message.
+func (c *ProtoCodec) Marshal(v interface{}) ([]byte, error) {
+	if msg, ok := v.(proto.Message); ok {
+		return proto.Marshal(msg)
+	}
+	return c.fallback().Marshal(v)
+}
+
+// Unmarshal decodes data into v with protobuf if v is a proto.Message
+// or a pointer to one, allocating the message as needed.
+func (c *ProtoCodec) Unmarshal(data []byte, v interface{}) error {
+	if msg, ok := v.(proto.Message); ok {
+		return proto.Unmarshal(data, msg)
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		elem := rv.Elem()
+		if elem.Kind() == reflect.Pointer && elem.Type().Implements(protoMessageType) {
+			msg := reflect.New(elem.Type().Elem())
+			if err := proto.Unmarshal(data, msg.Interface().(proto.Message)); err != nil {
+				return err
+			}
+			elem.Set(msg)
+			return nil
+		}
+	}
+	return c.fallback().Unmarshal(data, v)
+}
+
+var protoMessageType = reflect.TypeOf((*proto.Message)(nil)).Elem()
+
+// resolve returns the codec that actually encodes v.
+func (c *ProtoCodec) resolve(v interface{}) Codec {
+	if _, ok := v.(proto.Message); ok {
+		return c
+	}
+	return c.fallback()
+}
+
+func (c *ProtoCodec) fallback() Codec {
+	if c == nil || c.Fallback == nil {
+		return DefaultCodec
+	}
+	return c.Fallback
+}
+
+// DefaultCodec is used when no codec is configured.
+var DefaultCodec Codec = JSONCodec{}
+
+// Codec IDs recorded in the payload envelope.
+const (
+	// CodecIDDefault marks payloads encoded by an unregistered codec;
+	// readers decode them with their own manager codec.
+	CodecIDDefault byte = 0
+	CodecIDJSON    byte = 1
+	CodecIDGob     byte = 2
+	CodecIDProto   byte = 3
+
+	codecIDCustom byte = 0xFF
+)
+
+var codecs = struct {
+	mu     sync.RWMutex
+	byID   map[byte]Codec
+	byName map[string]byte
+}{
+	byID: map[byte]Codec{
+		CodecIDJSON:  JSONCodec{},
+		CodecIDGob:   GobCodec{},
+		CodecIDProto: NewProtoCodec(nil),
+	},
+	byName: map[string]byte{
+		"json":     CodecIDJSON,
+		"gob":      CodecIDGob,
+		"protobuf": CodecIDProto,
+	},
+}
+
+// RegisterCodec assigns an envelope ID to codec so that every node can
+// decode payloads written with it. IDs 1-3 are reserved for built-in codecs.
+func RegisterCodec(id byte, codec Codec) error {
+	if codec == nil {
+		return fmt.Errorf("codec is nil")
+	}
+	if id <= CodecIDProto || id == codecIDCustom {
+		return fmt.Errorf("codec id %d is reserved", id)
+	}
+	codecs.mu.Lock()
+	defer codecs.mu.Unlock()
+	if existing, ok := codecs.byID[id]; ok && existing.Name() != codec.Name() {
+		return fmt.Errorf("codec id %d already registered for %s", id, existing.Name())
+	}
+	codecs.byID[id] = codec
+	codecs.byName[codec.Name()] = id
+	return nil
+}
+
+func codecID(codec Codec) byte {
+	codecs.mu.RLock()
+	defer codecs.mu.RUnlock()
+	return codecs.byName[codec.Name()]
+}
+
+func codecByID(id byte) (Codec, bool) {
+	codecs.mu.RLock()
+	defer codecs.mu.RUnlock()
+	codec, ok := codecs.byID[id]
+	return codec, ok
+}
+
+func codecName(id byte) string {
+	switch id {
+	case CodecIDDefault:
+		return "default"
+	case codecIDCustom:
+		return "custom"
+	}
+	if codec, ok := codecByID(id); ok {
+		return codec.Name()
+	}
+	return fmt.Sprintf("codec(%d)", id)
+}
+
+// resolveCodec returns the codec that actually encodes v.
+func resolveCodec(codec Codec, v interface{}) Codec {
+	if r, ok := codec.(interface{ resolve(interface{}) Codec }); ok {
+		return r.resolve(v)
+	}
+	return codec
+}
+
+func marshalAdapterValue(value interface{}) ([]byte, error) {
+	if raw, ok := value.(RawValue); ok {
+		return raw, nil
+	}
+	return json.Marshal(value)
+}
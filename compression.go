@@ -1,8 +1,69 @@
 package eitcache
 
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// compressionMagic marks compressed payloads. It is followed by one
+// algorithm byte and the compressed body.
+var compressionMagic = []byte{0xEC, 0x5A}
+
+// CompressionAlgorithm selects the compression format.
+type CompressionAlgorithm byte
+
+const (
+	Gzip   CompressionAlgorithm = 1
+	Zstd   CompressionAlgorithm = 2
+	Snappy CompressionAlgorithm = 3
+)
+
+// DefaultCompressionThreshold is a reasonable Threshold for
+// NewCacheCompression: smaller payloads rarely shrink enough to pay off.
+const DefaultCompressionThreshold = 1024
+
+// String returns the algorithm name.
+func (a CompressionAlgorithm) String() string {
+	switch a {
+	case Gzip:
+		return "gzip"
+	case Zstd:
+		return "zstd"
+	case Snappy:
+		return "snappy"
+	default:
+		return fmt.Sprintf("compression(%d)", byte(a))
+	}
+}
+
+// ErrCorruptPayload indicates a compressed payload could not be decoded.
+var ErrCorruptPayload = errors.New("corrupt cache payload")
+
 // CacheCompression determines whether to compress cache payloads.
 type CacheCompression struct {
 	Threshold int
+	// Algorithm defaults to Gzip.
+	Algorithm CompressionAlgorithm
+	// Level is algorithm specific; zero selects the algorithm default
+	// (zstd level 1).
+	Level int
+	// Dictionary is raw zstd dictionary content shared by small,
+	// similar payloads. Readers must be configured with the same
+	// Dictionary and DictionaryID.
+	Dictionary   []byte
+	DictionaryID uint32
+
+	zstdOnce sync.Once
+	zstdEnc  *zstd.Encoder
+	zstdDec  *zstd.Decoder
+	zstdErr  error
 }
 
 // NewCacheCompression creates a compression policy.
@@ -10,6 +71,17 @@ func NewCacheCompression(threshold int) *CacheCompression {
 	return &CacheCompression{Threshold: threshold}
 }
 
+// NewZstdCompression creates a zstd compression policy.
+func NewZstdCompression(threshold int, level int) *CacheCompression {
+	return &CacheCompression{Threshold: threshold, Algorithm: Zstd, Level: level}
+}
+
+// NewSnappyCompression creates a snappy compression policy, trading
+// ratio for speed on latency-sensitive paths.
+func NewSnappyCompression(threshold int) *CacheCompression {
+	return &CacheCompression{Threshold: threshold, Algorithm: Snappy}
+}
+
 // ShouldCompress reports if data length exceeds threshold.
 func (c *CacheCompression) ShouldCompress(data []byte) bool {
 	if c == nil {
@@ -17,3 +89,155 @@ func (c *CacheCompression) ShouldCompress(data []byte) bool {
 	}
 	return len(data) > c.Threshold
 }
+
+// Compress compresses data when it exceeds the threshold.
+// Small payloads are returned unchanged.
+func (c *CacheCompression) Compress(data []byte) ([]byte, error) {
+	if !c.ShouldCompress(data) {
+		return data, nil
+	}
+	algo := c.algorithm()
+	header := append(append([]byte{}, compressionMagic...), byte(algo))
+	return c.compressBody(algo, header, data)
+}
+
+// Decompress restores a payload produced by Compress, using the
+// policy's zstd dictionary if one is configured.
+// Payloads without the compression header are returned unchanged.
+func (c *CacheCompression) Decompress(data []byte) ([]byte, error) {
+	if !IsCompressed(data) {
+		return data, nil
+	}
+	algo := CompressionAlgorithm(data[len(compressionMagic)])
+	return c.decompressBody(algo, data[len(compressionMagic)+1:])
+}
+
+func (c *CacheCompression) algorithm() CompressionAlgorithm {
+	if c == nil || c.Algorithm == 0 {
+		return Gzip
+	}
+	return c.Algorithm
+}
+
+// compress returns the compressed body and the algorithm used, or the
+// data unchanged and zero when it is below the threshold.
+func (c *CacheCompression) compress(data []byte) ([]byte, CompressionAlgorithm, error) {
+	if !c.ShouldCompress(data) {
+		return data, 0, nil
+	}
+	algo := c.algorithm()
+	body, err := c.compressBody(algo, nil, data)
+	return body, algo, err
+}
+
+// compressBody appends the compressed form of data to dst.
+func (c *CacheCompression) compressBody(algo CompressionAlgorithm, dst, data []byte) ([]byte, error) {
+	switch algo {
+	case Gzip:
+		buf := bytes.NewBuffer(dst)
+		level := gzip.DefaultCompression
+		if c != nil && c.Level != 0 {
+			level = c.Level
+		}
+		zw, err := gzip.NewWriterLevel(buf, level)
+		if err != nil {
+			return nil, fmt.Errorf("gzip compress failed: %w", err)
+		}
+		if _, err := zw.Write(data); err != nil {
+			return nil, fmt.Errorf("gzip compress failed: %w", err)
+		}
+		if err := zw.Close(); err != nil {
+			return nil, fmt.Errorf("gzip compress failed: %w", err)
+		}
+		return buf.Bytes(), nil
+	case Zstd:
+		if c == nil {
+			c = &CacheCompression{Algorithm: Zstd}
+		}
+		enc, _, err := c.zstdCodec()
+		if err != nil {
+			return nil, fmt.Errorf("zstd compress failed: %w", err)
+		}
+		return enc.EncodeAll(data, dst), nil
+	case Snappy:
+		return append(dst, snappy.Encode(nil, data)...), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression algorithm %s", algo)
+	}
+}
+
+func (c *CacheCompression) decompressBody(algo CompressionAlgorithm, body []byte) ([]byte, error) {
+	switch algo {
+	case Gzip:
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCorruptPayload, err)
+		}
+		defer zr.Close()
+		out, err := io.ReadAll(zr)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCorruptPayload, err)
+		}
+		return out, nil
+	case Zstd:
+		dec := defaultZstdDecoder
+		if c != nil && len(c.Dictionary) > 0 {
+			_, d, err := c.zstdCodec()
+			if err != nil {
+				return nil, err
+			}
+			dec = d
+		}
+		out, err := dec.DecodeAll(body, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCorruptPayload, err)
+		}
+		return out, nil
+	case Snappy:
+		out, err := snappy.Decode(nil, body)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCorruptPayload, err)
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown compression algorithm %d", ErrCorruptPayload, byte(algo))
+	}
+}
+
+// Decompress restores a payload produced by Compress without a dictionary.
+// Payloads without the compression header are returned unchanged.
+func Decompress(data []byte) ([]byte, error) {
+	return (*CacheCompression)(nil).Decompress(data)
+}
+
+func (c *CacheCompression) zstdCodec() (*zstd.Encoder, *zstd.Decoder, error) {
+	c.zstdOnce.Do(func() {
+		level := c.Level
+		if level <= 0 {
+			level = 1
+		}
+		encOpts := []zstd.EOption{zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level))}
+		decOpts := []zstd.DOption{zstd.WithDecoderConcurrency(0)}
+		if len(c.Dictionary) > 0 {
+			id := c.DictionaryID
+			if id == 0 {
+				id = 1
+			}
+			encOpts = append(encOpts, zstd.WithEncoderDictRaw(id, c.Dictionary))
+			decOpts = append(decOpts, zstd.WithDecoderDictRaw(id, c.Dictionary))
+		}
+		c.zstdEnc, c.zstdErr = zstd.NewWriter(nil, encOpts...)
+		if c.zstdErr != nil {
+			return
+		}
+		c.zstdDec, c.zstdErr = zstd.NewReader(nil, decOpts...)
+	})
+	return c.zstdEnc, c.zstdDec, c.zstdErr
+}
+
+var defaultZstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+
+// IsCompressed reports whether data carries the compression header.
+func IsCompressed(data []byte) bool {
+	return len(data) > len(compressionMagic) && bytes.HasPrefix(data, compressionMagic)
+}
@@ -0,0 +1,215 @@
+package eitcache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Duration is a time.Duration read from config files as "30s" or "5m".
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration string or a number of nanoseconds.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var n int64
+		if err := json.Unmarshal(data, &n); err != nil {
+			return fmt.Errorf("invalid duration %s", data)
+		}
+		*d = Duration(n)
+		return nil
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// UnmarshalYAML parses a duration string.
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	parsed, err := time.ParseDuration(node.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// FileConfig is the file form of several named caches.
+type FileConfig struct {
+	Caches map[string]CacheFileConfig `json:"caches" yaml:"caches"`
+}
+
+// CacheFileConfig describes one named cache in a config file.
+type CacheFileConfig struct {
+	Type        string                 `json:"type" yaml:"type"`
+	Addr        string                 `json:"addr" yaml:"addr"`
+	Password    string                 `json:"password" yaml:"password"`
+	DB          int                    `json:"db" yaml:"db"`
+	DefaultTTL  Duration               `json:"ttl" yaml:"ttl"`
+	MaxRetries  int                    `json:"max_retries" yaml:"max_retries"`
+	PoolSize    int                    `json:"pool_size" yaml:"pool_size"`
+	Prefix      string                 `json:"prefix" yaml:"prefix"`
+	TTLJitter   float64                `json:"ttl_jitter" yaml:"ttl_jitter"`
+	MaxEntries  int                    `json:"max_entries" yaml:"max_entries"`
+	MaxMemory   int64                  `json:"max_memory_bytes" yaml:"max_memory_bytes"`
+	Eviction    EvictionPolicy         `json:"eviction" yaml:"eviction"`
+	Snapshot    string                 `json:"snapshot_path" yaml:"snapshot_path"`
+	SnapshotInt Duration               `json:"snapshot_interval" yaml:"snapshot_interval"`
+	Restore     bool                   `json:"restore_on_start" yaml:"restore_on_start"`
+	Tables      map[string][]string    `json:"table_resources" yaml:"table_resources"`
+	TTLs        map[string]Duration    `json:"resource_ttls" yaml:"resource_ttls"`
+	Sorts       map[string][]string    `json:"sort_fields" yaml:"sort_fields"`
+	Compression *CompressionFileConfig `json:"compression" yaml:"compression"`
+}
+
+// CompressionFileConfig describes a compression policy in a config file.
+type CompressionFileConfig struct {
+	Algorithm string `json:"algorithm" yaml:"algorithm"`
+	Threshold int    `json:"threshold" yaml:"threshold"`
+	Level     int    `json:"level" yaml:"level"`
+}
+
+// CacheConfig converts the file entry into a CacheConfig.
+func (c CacheFileConfig) CacheConfig() (*CacheConfig, error) {
+	config := &CacheConfig{
+		Type:             c.Type,
+		Addr:             c.Addr,
+		Password:         c.Password,
+		DB:               c.DB,
+		DefaultTTL:       time.Duration(c.DefaultTTL),
+		MaxRetries:       c.MaxRetries,
+		PoolSize:         c.PoolSize,
+		Prefix:           c.Prefix,
+		TTLJitter:        c.TTLJitter,
+		MaxEntries:       c.MaxEntries,
+		MaxMemoryBytes:   c.MaxMemory,
+		EvictionPolicy:   c.Eviction,
+		SnapshotPath:     c.Snapshot,
+		SnapshotInterval: time.Duration(c.SnapshotInt),
+		RestoreOnStart:   c.Restore,
+		TableResources:   c.Tables,
+		SortFields:       c.Sorts,
+	}
+	if len(c.TTLs) > 0 {
+		config.ResourceTTLs = make(map[string]time.Duration, len(c.TTLs))
+		for prefix, ttl := range c.TTLs {
+			config.ResourceTTLs[prefix] = time.Duration(ttl)
+		}
+	}
+	if c.Compression != nil {
+		algo, err := ParseCompressionAlgorithm(c.Compression.Algorithm)
+		if err != nil {
+			return nil, err
+		}
+		config.Compression = &CacheCompression{
+			Threshold: c.Compression.Threshold,
+			Algorithm: algo,
+			Level:     c.Compression.Level,
+		}
+	}
+	return config, nil
+}
+
+// ParseCompressionAlgorithm parses "gzip", "zstd" or "snappy". An empty
+// name selects Gzip.
+func ParseCompressionAlgorithm(name string) (CompressionAlgorithm, error) {
+	switch strings.ToLower(name) {
+	case "", "gzip":
+		return Gzip, nil
+	case "zstd":
+		return Zstd, nil
+	case "snappy":
+		return Snappy, nil
+	default:
+		return 0, fmt.Errorf("unknown compression algorithm %q", name)
+	}
+}
+
+// ManagerSet holds managers created from a config file, keyed by name.
+type ManagerSet map[string]*Manager
+
+// Register adds every manager to the package registry.
+func (s ManagerSet) Register() error {
+	var errs []error
+	for _, name := range s.names() {
+		if err := Register(name, s[name]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes every manager in the set.
+func (s ManagerSet) Close() error {
+	var errs []error
+	for _, name := range s.names() {
+		if err := s[name].Close(); err != nil {
+			errs = append(errs, fmt.Errorf("close cache manager %q: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s ManagerSet) names() []string {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadConfig reads a YAML or JSON file defining named caches and creates
+// a manager for each. Files ending in .json are parsed as JSON, anything
+// else as YAML.
+func LoadConfig(path string) (ManagerSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read cache config: %w", err)
+	}
+	var file FileConfig
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &file)
+	} else {
+		err = yaml.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse cache config %s: %w", path, err)
+	}
+	return NewManagerSet(file)
+}
+
+// NewManagerSet creates a manager for each cache in file. If any manager
+// fails, the ones already created are closed.
+func NewManagerSet(file FileConfig) (ManagerSet, error) {
+	set := make(ManagerSet, len(file.Caches))
+	names := make([]string, 0, len(file.Caches))
+	for name := range file.Caches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		config, err := file.Caches[name].CacheConfig()
+		var m *Manager
+		if err == nil {
+			m, err = NewManager(config)
+		}
+		if err != nil {
+			_ = set.Close()
+			return nil, fmt.Errorf("cache %q: %w", name, err)
+		}
+		set[name] = m
+	}
+	return set, nil
+}
@@ -0,0 +1,127 @@
+package eitcache
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// GenerateCountCacheKey builds the cache key of a resource's total count
+// for filters, e.g. "articles:status:\"published\":count".
+func GenerateCountCacheKey(resource string, filters map[string]interface{}) string {
+	return countKey(resource, filterKeyParts(filters))
+}
+
+// countKey builds a GenerateCountCacheKey key from filter key parts.
+func countKey(resource string, filterParts []string) string {
+	parts := append([]string{resource}, filterParts...)
+	return strings.Join(append(parts, "count"), ":")
+}
+
+// SetCountTTL sets the TTL of totals cached by QueryCount. Zero uses the
+// resource TTL, like pages.
+func (m *Manager) SetCountTTL(ttl time.Duration) {
+	m.update(func(s *managerSettings) { s.countTTL = ttl })
+}
+
+// QueryCount returns the total row count for resource and filters,
+// caching it under its own key with CacheConfig.CountTTL, so page misses
+// do not re-run an expensive COUNT(*). Counts are cleared with the
+// resource's pages by InvalidateCacheOnUpdate and InvalidatePagesFor.
+func QueryCount(
+	ctx context.Context,
+	manager *Manager,
+	resource string,
+	filters map[string]interface{},
+	countFunc func() (int64, error),
+) (int64, error) {
+	if manager == nil {
+		return 0, ErrManagerNil
+	}
+	key, _ := manager.filterKey(filters, func(parts []string) string {
+		return countKey(resource, parts)
+	})
+	if manager.current().epochs {
+		epoch, err := manager.ResourceEpoch(ctx, resource)
+		if err != nil {
+			// Without the epoch a cached count may predate the last bump.
+			manager.reportError(ctx, OpGet, epochKey(resource), err)
+			return countFunc()
+		}
+		key = versionKey(resource, epoch, key)
+	}
+
+	if data, err := manager.load(ctx, key); err == nil && data != nil {
+		var total int64
+		if err := manager.decode(data, &total); err == nil {
+			return total, nil
+		}
+	}
+	return coalesce(manager, key, func() (int64, error) {
+		total, err := countFunc()
+		if err != nil {
+			return 0, err
+		}
+		ttl := manager.current().countTTL
+		if ttl == 0 {
+			ttl = manager.ttlFor(resource)
+		}
+		payload, err := manager.encode(total)
+		if err == nil {
+			err = manager.storePage(ctx, resource, key, filters, payload, jitterTTL(ttl, manager.current().ttlJitter))
+		}
+		if err != nil {
+			manager.reportError(ctx, OpSet, key, err)
+		}
+		return total, nil
+	})
+}
+
+// QueryWithPaginationCount is QueryWithPagination with the page and the
+// total loaded separately: pageFunc runs on page misses and the total
+// comes from QueryCount, so it keeps its own TTL. opts apply to the page.
+func QueryWithPaginationCount[T any](
+	ctx context.Context,
+	manager *Manager,
+	resource string,
+	filters map[string]interface{},
+	params *PaginationParams,
+	pageFunc func() ([]T, error),
+	countFunc func() (int64, error),
+	opts ...QueryOption,
+) (*PaginationResponse[T], error) {
+	if manager == nil {
+		return nil, ErrManagerNil
+	}
+	params = manager.NormalizePaginationParams(resource, params)
+	count := func() (int64, error) {
+		if !params.UseCache || !newQueryOptions(manager, opts).UseCache {
+			return countFunc()
+		}
+		return QueryCount(ctx, manager, resource, filters, countFunc)
+	}
+
+	counted := false
+	var total int64
+	resp, err := QueryWithPagination(ctx, manager, resource, filters, params, func() ([]T, int64, error) {
+		data, err := pageFunc()
+		if err != nil {
+			return nil, 0, err
+		}
+		total, err = count()
+		counted = err == nil
+		return data, total, err
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if !counted {
+		if total, err = count(); err != nil {
+			return nil, err
+		}
+	}
+	out := buildPaginationResponse(resp.Data, total, params, resp.CacheKey, resp.FromCache)
+	out.DataHash = resp.DataHash
+	out.OriginalKey = resp.OriginalKey
+	return out, nil
+}
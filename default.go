@@ -0,0 +1,106 @@
+package eitcache
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var defaultManager struct {
+	mu sync.Mutex
+	m  *Manager
+}
+
+// Default returns the package default manager, creating it from
+// ConfigFromEnv on first use.
+func Default() (*Manager, error) {
+	defaultManager.mu.Lock()
+	defer defaultManager.mu.Unlock()
+	if defaultManager.m != nil {
+		return defaultManager.m, nil
+	}
+	config, err := ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	m, err := NewManager(config)
+	if err != nil {
+		return nil, err
+	}
+	defaultManager.m = m
+	return m, nil
+}
+
+// SetDefault replaces the package default manager. The previous one is
+// not closed.
+func SetDefault(m *Manager) {
+	defaultManager.mu.Lock()
+	defaultManager.m = m
+	defaultManager.mu.Unlock()
+}
+
+// ConfigFromEnv builds a CacheConfig from EIT_CACHE_TYPE, EIT_CACHE_ADDR,
+// EIT_CACHE_PASSWORD, EIT_CACHE_DB, EIT_CACHE_DEFAULT_TTL,
+// EIT_CACHE_PREFIX and EIT_CACHE_POOL_SIZE. Unset variables keep their
+// zero value, which selects a memory cache.
+func ConfigFromEnv() (*CacheConfig, error) {
+	config := &CacheConfig{
+		Type:     os.Getenv("EIT_CACHE_TYPE"),
+		Addr:     os.Getenv("EIT_CACHE_ADDR"),
+		Password: os.Getenv("EIT_CACHE_PASSWORD"),
+		Prefix:   os.Getenv("EIT_CACHE_PREFIX"),
+	}
+	if v := os.Getenv("EIT_CACHE_DB"); v != "" {
+		db, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("parse EIT_CACHE_DB: %w", err)
+		}
+		config.DB = db
+	}
+	if v := os.Getenv("EIT_CACHE_POOL_SIZE"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("parse EIT_CACHE_POOL_SIZE: %w", err)
+		}
+		config.PoolSize = size
+	}
+	if v := os.Getenv("EIT_CACHE_DEFAULT_TTL"); v != "" {
+		ttl, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("parse EIT_CACHE_DEFAULT_TTL: %w", err)
+		}
+		config.DefaultTTL = ttl
+	}
+	return config, nil
+}
+
+// DefaultQuery runs Query against the default manager.
+func DefaultQuery[T any](ctx context.Context, key string, queryFunc func() (T, error), opts ...QueryOption) (T, error) {
+	m, err := Default()
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return Query(ctx, m, key, queryFunc, opts...)
+}
+
+// DefaultSet writes value to the default manager.
+func DefaultSet(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	m, err := Default()
+	if err != nil {
+		return err
+	}
+	return m.Set(ctx, key, value, ttl)
+}
+
+// DefaultGet reads key from the default manager into dest.
+func DefaultGet(ctx context.Context, key string, dest interface{}) (bool, error) {
+	m, err := Default()
+	if err != nil {
+		return false, err
+	}
+	return m.Get(ctx, key, dest)
+}
@@ -0,0 +1,92 @@
+package eitcache
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+type deferredKey struct{}
+
+// deferredInvalidation collects keys to delete once a transaction commits.
+type deferredInvalidation struct {
+	mu      sync.Mutex
+	pending []deferredDelete
+}
+
+type deferredDelete struct {
+	manager *Manager
+	keys    []string
+}
+
+// BeginInvalidation returns a context that collects DeferInvalidation
+// calls until CommitInvalidation or RollbackInvalidation. Pass it down
+// the database transaction; a context already collecting is returned
+// unchanged.
+func BeginInvalidation(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(deferredKey{}).(*deferredInvalidation); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, deferredKey{}, &deferredInvalidation{})
+}
+
+// DeferInvalidation deletes keys after the transaction carried by ctx
+// commits, so a rollback cannot leave the cache refilled with reverted
+// data. Without BeginInvalidation the keys are deleted immediately.
+func (m *Manager) DeferInvalidation(ctx context.Context, keys ...string) error {
+	d, ok := ctx.Value(deferredKey{}).(*deferredInvalidation)
+	if !ok {
+		return m.Delete(ctx, keys...)
+	}
+	d.mu.Lock()
+	d.pending = append(d.pending, deferredDelete{manager: m, keys: keys})
+	d.mu.Unlock()
+	return nil
+}
+
+// CommitInvalidation applies the invalidations deferred on ctx. Call it
+// after the database transaction commits.
+func CommitInvalidation(ctx context.Context) error {
+	d, ok := ctx.Value(deferredKey{}).(*deferredInvalidation)
+	if !ok {
+		return nil
+	}
+	d.mu.Lock()
+	pending := d.pending
+	d.pending = nil
+	d.mu.Unlock()
+
+	var errs []error
+	for _, p := range pending {
+		if p.manager == nil {
+			errs = append(errs, ErrManagerNil)
+			continue
+		}
+		errs = append(errs, p.manager.Delete(ctx, p.keys...))
+	}
+	return errors.Join(errs...)
+}
+
+// RollbackInvalidation drops the invalidations deferred on ctx.
+func RollbackInvalidation(ctx context.Context) {
+	if d, ok := ctx.Value(deferredKey{}).(*deferredInvalidation); ok {
+		d.mu.Lock()
+		d.pending = nil
+		d.mu.Unlock()
+	}
+}
+
+// WithInvalidation runs fn, typically wrapping a database transaction,
+// with a collecting context. Deferred invalidations are applied when fn
+// returns nil and dropped otherwise. Nested calls join the outermost one.
+func WithInvalidation(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(deferredKey{}).(*deferredInvalidation); ok {
+		return fn(ctx)
+	}
+	ctx = BeginInvalidation(ctx)
+	if err := fn(ctx); err != nil {
+		RollbackInvalidation(ctx)
+		return err
+	}
+	return CommitInvalidation(ctx)
+}
@@ -0,0 +1,71 @@
+package eitcache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// doubleDeleter schedules the second pass of delayed double deletes.
+type doubleDeleter struct {
+	mu      sync.Mutex
+	pending map[*time.Timer]func()
+	closed  bool
+}
+
+func newDoubleDeleter() *doubleDeleter {
+	return &doubleDeleter{pending: make(map[*time.Timer]func())}
+}
+
+func (d *doubleDeleter) schedule(delay time.Duration, fn func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		d.mu.Lock()
+		_, ok := d.pending[timer]
+		delete(d.pending, timer)
+		d.mu.Unlock()
+		if ok {
+			fn()
+		}
+	})
+	d.pending[timer] = fn
+}
+
+// close runs pending deletes immediately rather than dropping them.
+func (d *doubleDeleter) close() {
+	d.mu.Lock()
+	d.closed = true
+	pending := d.pending
+	d.pending = nil
+	d.mu.Unlock()
+	for timer, fn := range pending {
+		if timer.Stop() {
+			fn()
+		}
+	}
+}
+
+// SetDoubleDeleteDelay makes every Delete and DeletePattern repeat itself
+// after delay, purging entries that racing readers refilled with data
+// read before the database write became visible. Zero disables it.
+func (m *Manager) SetDoubleDeleteDelay(delay time.Duration) {
+	m.update(func(s *managerSettings) { s.doubleDelay = delay })
+}
+
+// scheduleDoubleDelete repeats an invalidation after the configured delay.
+func (m *Manager) scheduleDoubleDelete(msg InvalidationMessage) {
+	delay := m.current().doubleDelay
+	if delay <= 0 {
+		return
+	}
+	m.doubleDelete.schedule(delay, func() {
+		ctx := context.Background()
+		m.applyInvalidation(ctx, msg)
+		m.broadcast(ctx, msg)
+	})
+}
@@ -1,9 +1,30 @@
 package eitcache
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
 func TestMemoryCache(t *testing.T) {
@@ -143,3 +164,4750 @@ func TestMonitor(t *testing.T) {
 		t.Fatalf("expected 2/3 hit ratio, got %f", ratio)
 	}
 }
+
+func TestGobCodec(t *testing.T) {
+	manager, err := NewManager(&CacheConfig{
+		Type:       CacheTypeMemory,
+		DefaultTTL: 1 * time.Minute,
+		Codec:      GobCodec{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	ctx := context.Background()
+
+	type Event struct {
+		ID      int64
+		Created time.Time
+	}
+
+	event := Event{ID: 1<<62 + 1, Created: time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)}
+	if err := manager.Set(ctx, "event:1", event, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	var retrieved Event
+	hit, err := manager.Get(ctx, "event:1", &retrieved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hit || retrieved.ID != event.ID || !retrieved.Created.Equal(event.Created) {
+		t.Fatalf("gob round trip mismatch: %+v", retrieved)
+	}
+}
+
+func TestProtoCodec(t *testing.T) {
+	codec := NewProtoCodec(nil)
+
+	payload, err := codec.Marshal(wrapperspb.String("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := &wrapperspb.StringValue{}
+	if err := codec.Unmarshal(payload, msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.GetValue() != "hello" {
+		t.Fatalf("expected hello, got %q", msg.GetValue())
+	}
+
+	payload, err = codec.Marshal(map[string]int{"a": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payload) != `{"a":1}` {
+		t.Fatalf("expected JSON fallback, got %s", payload)
+	}
+}
+
+func TestMarshalerRegistry(t *testing.T) {
+	manager, err := NewManager(&CacheConfig{
+		Type:       CacheTypeMemory,
+		DefaultTTL: 1 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	ctx := context.Background()
+
+	type Article struct {
+		ID    int
+		Title string
+	}
+
+	err = RegisterMarshaler(manager.Marshalers(), func(a Article) ([]byte, error) {
+		return []byte(fmt.Sprintf("%d|%s", a.ID, a.Title)), nil
+	}, func(data []byte) (Article, error) {
+		var a Article
+		parts := strings.SplitN(string(data), "|", 2)
+		a.ID, _ = strconv.Atoi(parts[0])
+		a.Title = parts[1]
+		return a, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = RegisterMarshaler(manager.Marshalers(), func(s fmt.Stringer) ([]byte, error) {
+		return []byte(s.String()), nil
+	}, func([]byte) (fmt.Stringer, error) { return nil, nil })
+	if !errors.Is(err, ErrMarshalerInterface) {
+		t.Fatalf("expected ErrMarshalerInterface, got %v", err)
+	}
+
+	if err := manager.Set(ctx, "article:7", Article{ID: 7, Title: "Go"}, 0); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := manager.Adapter().Get(ctx, "article:7")
+	if info, _ := InspectPayload(raw); info.Codec != "custom" || !strings.HasSuffix(string(raw), "7|Go") {
+		t.Fatalf("expected custom encoding, got %q", raw)
+	}
+
+	result, err := Query(ctx, manager, "article:7", func() (Article, error) {
+		return Article{}, errors.New("loader should not run")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ID != 7 || result.Title != "Go" {
+		t.Fatalf("unexpected article: %+v", result)
+	}
+}
+
+func TestCompression(t *testing.T) {
+	manager, err := NewManager(&CacheConfig{
+		Type:        CacheTypeMemory,
+		DefaultTTL:  1 * time.Minute,
+		Compression: NewCacheCompression(128),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	ctx := context.Background()
+
+	page := strings.Repeat("compressible ", 200)
+	if err := manager.Set(ctx, "page:1", page, 0); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := manager.Adapter().Get(ctx, "page:1")
+	if info, _ := InspectPayload(raw); info.Compression != Gzip || len(raw) >= len(page) {
+		t.Fatalf("expected compressed payload, got %d bytes", len(raw))
+	}
+
+	var retrieved string
+	if hit, err := manager.Get(ctx, "page:1", &retrieved); err != nil || !hit {
+		t.Fatalf("expected hit, got %v %v", hit, err)
+	}
+	if retrieved != page {
+		t.Fatal("decompressed payload mismatch")
+	}
+
+	if err := manager.Set(ctx, "small", "tiny", 0); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ = manager.Adapter().Get(ctx, "small")
+	if info, _ := InspectPayload(raw); info.Compression != 0 {
+		t.Fatal("small payload should not be compressed")
+	}
+}
+
+func TestZstdCompression(t *testing.T) {
+	dict := []byte(strings.Repeat(`{"id":0,"title":"article","status":"published"}`, 8))
+	compression := NewZstdCompression(16, 1)
+	compression.Dictionary = dict
+
+	payload := []byte(`{"id":42,"title":"article","status":"published"}`)
+	compressed, err := compression.Compress(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsCompressed(compressed) {
+		t.Fatal("expected compressed payload")
+	}
+
+	restored, err := compression.Decompress(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(restored) != string(payload) {
+		t.Fatalf("zstd round trip mismatch: %s", restored)
+	}
+}
+
+func TestQueryWithSnappyCompression(t *testing.T) {
+	manager, err := NewManager(&CacheConfig{
+		Type:       CacheTypeMemory,
+		DefaultTTL: 1 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	ctx := context.Background()
+
+	body := strings.Repeat("snappy ", 500)
+	loader := func() (string, error) { return body, nil }
+	if _, err := Query(ctx, manager, "body:1", loader, WithCompression(Snappy)); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, _ := manager.Adapter().Get(ctx, "body:1")
+	if info, _ := InspectPayload(raw); info.Compression != Snappy {
+		t.Fatal("expected snappy-compressed payload")
+	}
+
+	result, err := Query(ctx, manager, "body:1", func() (string, error) {
+		return "", errors.New("loader should not run")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != body {
+		t.Fatal("snappy round trip mismatch")
+	}
+}
+
+func TestEncryptionKeyRotation(t *testing.T) {
+	keyring, err := NewKeyring("k1", []byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager, err := NewManager(&CacheConfig{
+		Type:       CacheTypeMemory,
+		DefaultTTL: 1 * time.Minute,
+		Encryption: keyring,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	ctx := context.Background()
+
+	if err := manager.Set(ctx, "secret:old", "written with k1", 0); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := manager.Adapter().Get(ctx, "secret:old")
+	if !IsEncrypted(raw) || strings.Contains(string(raw), "written with k1") {
+		t.Fatal("expected encrypted payload")
+	}
+
+	if err := keyring.Rotate("k2", []byte("fedcba9876543210fedcba9876543210")); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.Set(ctx, "secret:new", "written with k2", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	var value string
+	if hit, err := manager.Get(ctx, "secret:old", &value); err != nil || !hit || value != "written with k1" {
+		t.Fatalf("old entry unreadable after rotation: %v %v %q", hit, err, value)
+	}
+	if hit, err := manager.Get(ctx, "secret:new", &value); err != nil || !hit || value != "written with k2" {
+		t.Fatalf("new entry unreadable: %v %v %q", hit, err, value)
+	}
+
+	keyring.RemoveKey("k1")
+	if _, err := manager.Get(ctx, "secret:old", &value); !errors.Is(err, ErrUnknownKeyID) {
+		t.Fatalf("expected ErrUnknownKeyID, got %v", err)
+	}
+}
+
+func TestPayloadEnvelope(t *testing.T) {
+	ctx := context.Background()
+	adapter := NewMemoryCacheAdapter(time.Minute)
+
+	writer := NewManagerWithAdapter(adapter, time.Minute)
+	writer.SetCodec(GobCodec{})
+	if err := writer.Set(ctx, "profile:1", map[string]int{"age": 30}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, _ := adapter.Get(ctx, "profile:1")
+	info, err := InspectPayload(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Enveloped || info.Codec != "gob" {
+		t.Fatalf("unexpected payload info: %+v", info)
+	}
+
+	reader := NewManagerWithAdapter(adapter, time.Minute)
+	var profile map[string]int
+	if hit, err := reader.Get(ctx, "profile:1", &profile); err != nil || !hit || profile["age"] != 30 {
+		t.Fatalf("json reader could not decode gob entry: %v %v %v", hit, err, profile)
+	}
+
+	legacy := []byte{0xEC, 0x45, 9, 0, 1, 0, 0, 0, '{', '}'}
+	if err := adapter.Set(ctx, "future", RawValue(legacy), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.Get(ctx, "future", &profile); !errors.Is(err, ErrUnsupportedPayload) {
+		t.Fatalf("expected ErrUnsupportedPayload, got %v", err)
+	}
+}
+
+func TestChunkedStorage(t *testing.T) {
+	manager, err := NewManager(&CacheConfig{
+		Type:       CacheTypeMemory,
+		DefaultTTL: 1 * time.Minute,
+		ChunkSize:  64,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	ctx := context.Background()
+
+	large := strings.Repeat("0123456789", 30)
+	if err := manager.Set(ctx, "big", large, 0); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := manager.Exists(ctx, ChunkKey("big", 0)); !ok {
+		t.Fatal("expected chunk entries")
+	}
+
+	var retrieved string
+	if hit, err := manager.Get(ctx, "big", &retrieved); err != nil || !hit || retrieved != large {
+		t.Fatalf("chunked round trip failed: %v %v", hit, err)
+	}
+
+	keys := make([]string, 1, 4)
+	keys[0] = "big"
+	spare := keys[:2]
+	spare[1] = "untouched"
+	if err := manager.Delete(ctx, keys...); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := manager.Exists(ctx, ChunkKey("big", 0)); ok {
+		t.Fatal("expected chunks deleted with manifest")
+	}
+	if spare[1] != "untouched" {
+		t.Fatalf("expected the caller's keys left alone, got %q", spare[1])
+	}
+
+	manager.Set(ctx, "big", large, 0)
+	if err := manager.Set(ctx, "big", large[:100], 0); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := manager.Exists(ctx, ChunkKey("big", 2)); ok {
+		t.Fatal("expected chunks beyond a shorter overwrite deleted")
+	}
+	if err := manager.Set(ctx, "big", "small", 0); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := manager.Exists(ctx, ChunkKey("big", 0)); ok {
+		t.Fatal("expected chunks deleted by an unchunked overwrite")
+	}
+	if hit, _ := manager.Get(ctx, "big", &retrieved); !hit || retrieved != "small" {
+		t.Fatalf("expected overwritten value, got %q", retrieved)
+	}
+}
+
+func TestQuerySingleflight(t *testing.T) {
+	manager, err := NewManager(&CacheConfig{
+		Type:       CacheTypeMemory,
+		DefaultTTL: 1 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	ctx := context.Background()
+
+	var calls int32
+	release := make(chan struct{})
+	loader := func() (int, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return 42, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := Query(ctx, manager, "hot", loader); err != nil || v != 42 {
+				t.Errorf("unexpected result %d %v", v, err)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Fatalf("expected 1 loader call, got %d", calls)
+	}
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	manager, err := NewManager(&CacheConfig{
+		Type:       CacheTypeMemory,
+		DefaultTTL: 1 * time.Minute,
+		Clock:      clock,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	ctx := context.Background()
+
+	var version int32
+	refreshed := make(chan struct{}, 1)
+	loader := func() (int32, error) {
+		v := atomic.AddInt32(&version, 1)
+		if v > 1 {
+			refreshed <- struct{}{}
+		}
+		return v, nil
+	}
+
+	opts := []QueryOption{WithTTL(20 * time.Second), WithStaleWhileRevalidate(time.Minute)}
+	if v, err := Query(ctx, manager, "swr", loader, opts...); err != nil || v != 1 {
+		t.Fatalf("unexpected first result %d %v", v, err)
+	}
+
+	clock.Advance(30 * time.Second)
+	if v, err := Query(ctx, manager, "swr", loader, opts...); err != nil || v != 1 {
+		t.Fatalf("expected stale value 1, got %d %v", v, err)
+	}
+
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		t.Fatal("expected background refresh")
+	}
+	waitRefreshes(t, manager)
+	if v, err := Query(ctx, manager, "swr", loader, opts...); err != nil || v != 2 {
+		t.Fatalf("expected refreshed value 2, got %d %v", v, err)
+	}
+}
+
+// waitRefreshes blocks until the background refreshes queued on manager
+// have finished.
+func waitRefreshes(t *testing.T, manager *Manager) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		manager.refresher.mu.Lock()
+		n := len(manager.refresher.inflight)
+		manager.refresher.mu.Unlock()
+		if n == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("background refresh did not finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSoftTTL(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	manager, err := NewManager(&CacheConfig{
+		Type:       CacheTypeMemory,
+		DefaultTTL: 1 * time.Minute,
+		Clock:      clock,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	ctx := context.Background()
+
+	if err := manager.SetWithSoftTTL(ctx, "soft", "v1", 10*time.Second, 40*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	var value string
+	if state, err := manager.GetWithState(ctx, "soft", &value); err != nil || state != EntryFresh {
+		t.Fatalf("expected fresh, got %s %v", state, err)
+	}
+	clock.Advance(20 * time.Second)
+	if state, err := manager.GetWithState(ctx, "soft", &value); err != nil || state != EntryStale || value != "v1" {
+		t.Fatalf("expected stale v1, got %s %v %q", state, err, value)
+	}
+	clock.Advance(30 * time.Second)
+	if state, err := manager.GetWithState(ctx, "soft", &value); err != nil || state != EntryGone {
+		t.Fatalf("expected gone, got %s %v", state, err)
+	}
+
+	ticket := GenerateTicketWithClock(clock, "u1", time.Minute)
+	loader := func() (string, error) { return "v", nil }
+	if _, err := Query(ctx, manager, "ticketed", loader, WithTicket(ticket)); err != nil {
+		t.Fatalf("expected the ticket to be valid on the manager clock, got %v", err)
+	}
+	clock.Advance(2 * time.Minute)
+	if _, err := Query(ctx, manager, "ticketed", loader, WithTicket(ticket)); !errors.Is(err, ErrTicketExpired) {
+		t.Fatalf("expected the ticket to expire on the manager clock, got %v", err)
+	}
+}
+
+func TestTTLJitter(t *testing.T) {
+	ttl := 100 * time.Second
+	varied := false
+	for i := 0; i < 50; i++ {
+		got := jitterTTL(ttl, 0.1)
+		if got < 90*time.Second || got > 110*time.Second {
+			t.Fatalf("jittered ttl %v outside ±10%%", got)
+		}
+		if got != ttl {
+			varied = true
+		}
+	}
+	if !varied {
+		t.Fatal("expected jitter to vary ttl")
+	}
+	if got := jitterTTL(ttl, 0); got != ttl {
+		t.Fatalf("expected unchanged ttl, got %v", got)
+	}
+}
+
+func TestNegativeCache(t *testing.T) {
+	manager, err := NewManager(&CacheConfig{
+		Type:       CacheTypeMemory,
+		DefaultTTL: 1 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	ctx := context.Background()
+
+	type User struct {
+		ID int
+	}
+
+	var calls int
+	loader := func() (*User, error) {
+		calls++
+		return nil, ErrNotFound
+	}
+
+	if _, err := Query(ctx, manager, "user:404", loader, WithNegativeCache(time.Minute)); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := Query(ctx, manager, "user:404", loader, WithNegativeCache(time.Minute)); !errors.Is(err, ErrNotFoundCached) {
+		t.Fatalf("expected ErrNotFoundCached, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected 1 loader call, got %d", calls)
+	}
+}
+
+func TestErrorCache(t *testing.T) {
+	manager, err := NewManager(&CacheConfig{
+		Type:       CacheTypeMemory,
+		DefaultTTL: 1 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	ctx := context.Background()
+
+	errUpstream := errors.New("upstream unavailable")
+	RegisterCacheableError("upstream", errUpstream)
+
+	var calls int
+	loader := func() (string, error) {
+		calls++
+		return "", fmt.Errorf("fetch: %w", errUpstream)
+	}
+
+	if _, err := Query(ctx, manager, "flaky", loader, WithErrorCache(time.Minute)); !errors.Is(err, errUpstream) {
+		t.Fatalf("expected upstream error, got %v", err)
+	}
+	_, err = Query(ctx, manager, "flaky", loader, WithErrorCache(time.Minute))
+	var cachedErr *CachedError
+	if !errors.As(err, &cachedErr) || !errors.Is(err, errUpstream) {
+		t.Fatalf("expected cached upstream error, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected 1 loader call, got %d", calls)
+	}
+}
+
+func TestGetOrSet(t *testing.T) {
+	manager, err := NewManager(&CacheConfig{
+		Type:       CacheTypeMemory,
+		DefaultTTL: 1 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	ctx := context.Background()
+
+	first, err := GetOrSet(ctx, manager, "counter", 0, func() (int, error) { return 1, nil })
+	if err != nil || first != 1 {
+		t.Fatalf("unexpected first value %d %v", first, err)
+	}
+	second, err := GetOrSet(ctx, manager, "counter", 0, func() (int, error) { return 2, nil })
+	if err != nil || second != 1 {
+		t.Fatalf("expected first writer value 1, got %d %v", second, err)
+	}
+
+	adapter := manager.Adapter().(SetNXAdapter)
+	if ok, _ := adapter.SetNX(ctx, "counter", RawValue("3"), 0); ok {
+		t.Fatal("SetNX should not overwrite an existing key")
+	}
+}
+
+func TestGetOrSetWrites(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	var errs []string
+	manager, err := NewManager(&CacheConfig{
+		Type:       CacheTypeMemory,
+		DefaultTTL: time.Hour,
+		Clock:      clock,
+		OnError:    func(_ context.Context, op, key string, _ error) { errs = append(errs, op+" "+key) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+	ctx := context.Background()
+
+	var sets []string
+	manager.OnSet(func(_ context.Context, event HookEvent) { sets = append(sets, event.Key) })
+	if err := manager.ScheduleInvalidation(ctx, "promo", clock.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetOrSet(ctx, manager, "promo", time.Hour, func() (string, error) { return "v", nil }); err != nil {
+		t.Fatal(err)
+	}
+	adapter := manager.Adapter().(*MemoryCacheAdapter)
+	if info, ok := adapter.Inspect("promo"); !ok || info.ExpiresAt.After(clock.Now().Add(time.Minute)) {
+		t.Fatalf("expected the TTL to be clamped to the scheduled invalidation, got %v", info.ExpiresAt)
+	}
+	if len(sets) != 1 || sets[0] != "promo" {
+		t.Fatalf("expected GetOrSet to fire OnSet, got %v", sets)
+	}
+
+	_ = adapter.Set(ctx, "broken", RawValue("{bad"), 0)
+	value, err := GetOrSet(ctx, manager, "broken", 0, func() (string, error) { return "loaded", nil })
+	if err != nil || value != "loaded" {
+		t.Fatalf("expected the loaded value, got %q %v", value, err)
+	}
+	if len(errs) != 1 || errs[0] != OpGet+" broken" {
+		t.Fatalf("expected the failed re-read to be reported, got %v", errs)
+	}
+}
+
+func TestQueryMany(t *testing.T) {
+	manager, err := NewManager(&CacheConfig{
+		Type:       CacheTypeMemory,
+		DefaultTTL: 1 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	ctx := context.Background()
+
+	if err := manager.Set(ctx, "article:1", "cached one", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	var requested []string
+	loader := func(missing []string) (map[string]string, error) {
+		requested = append(requested, missing...)
+		out := make(map[string]string)
+		for _, key := range missing {
+			if key != "article:404" {
+				out[key] = "loaded " + key
+			}
+		}
+		return out, nil
+	}
+
+	keys := []string{"article:1", "article:2", "article:404"}
+	result, err := QueryMany(ctx, manager, keys, loader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result["article:1"] != "cached one" || result["article:2"] != "loaded article:2" || len(result) != 2 {
+		t.Fatalf("unexpected result: %v", result)
+	}
+	if len(requested) != 2 {
+		t.Fatalf("expected loader for 2 misses, got %v", requested)
+	}
+
+	requested = nil
+	if _, err := QueryMany(ctx, manager, keys, loader); err != nil {
+		t.Fatal(err)
+	}
+	if len(requested) != 1 || requested[0] != "article:404" {
+		t.Fatalf("expected only article:404 reloaded, got %v", requested)
+	}
+}
+
+func TestBatcher(t *testing.T) {
+	manager, err := NewManager(&CacheConfig{
+		Type:       CacheTypeMemory,
+		DefaultTTL: 1 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	ctx := context.Background()
+
+	var batches int32
+	batcher := NewBatcher(manager, 20*time.Millisecond, func(keys []string) (map[string]int, error) {
+		atomic.AddInt32(&batches, 1)
+		out := make(map[string]int, len(keys))
+		for _, key := range keys {
+			out[key] = len(key)
+		}
+		return out, nil
+	})
+
+	var wg sync.WaitGroup
+	for _, key := range []string{"a", "bb", "ccc", "bb"} {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			if v, err := batcher.Load(ctx, key); err != nil || v != len(key) {
+				t.Errorf("unexpected result for %s: %d %v", key, v, err)
+			}
+		}(key)
+	}
+	wg.Wait()
+
+	if batches != 1 {
+		t.Fatalf("expected 1 batched loader call, got %d", batches)
+	}
+}
+
+func TestRefreshAhead(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	manager, err := NewManager(&CacheConfig{
+		Type:       CacheTypeMemory,
+		DefaultTTL: 1 * time.Minute,
+		Clock:      clock,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	ctx := context.Background()
+
+	var version int32
+	loader := func() (int32, error) {
+		return atomic.AddInt32(&version, 1), nil
+	}
+	opts := []QueryOption{WithTTL(100 * time.Second), WithRefreshAhead(0.5)}
+
+	if v, _ := Query(ctx, manager, "ahead", loader, opts...); v != 1 {
+		t.Fatalf("expected 1, got %d", v)
+	}
+	if v, _ := Query(ctx, manager, "ahead", loader, opts...); v != 1 || atomic.LoadInt32(&version) != 1 {
+		t.Fatal("early hit should not refresh")
+	}
+
+	clock.Advance(60 * time.Second)
+	if v, _ := Query(ctx, manager, "ahead", loader, opts...); v != 1 {
+		t.Fatalf("expected cached 1 while refreshing, got %d", v)
+	}
+	waitRefreshes(t, manager)
+	if atomic.LoadInt32(&version) != 2 {
+		t.Fatal("expected background refresh before expiry")
+	}
+}
+
+func TestDistributedLock(t *testing.T) {
+	adapter := NewMemoryCacheAdapter(time.Minute)
+	nodeA := NewManagerWithAdapter(adapter, time.Minute)
+	nodeB := NewManagerWithAdapter(adapter, time.Minute)
+	defer nodeA.Close()
+
+	ctx := context.Background()
+
+	var calls int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	slowLoader := func() (string, error) {
+		atomic.AddInt32(&calls, 1)
+		close(started)
+		<-release
+		return "computed", nil
+	}
+
+	done := make(chan string)
+	go func() {
+		v, _ := Query(ctx, nodeA, "report", slowLoader, WithDistributedLock())
+		done <- v
+	}()
+	<-started
+
+	go func() {
+		time.Sleep(60 * time.Millisecond)
+		close(release)
+	}()
+	v, err := Query(ctx, nodeB, "report", func() (string, error) {
+		atomic.AddInt32(&calls, 1)
+		return "duplicate", nil
+	}, WithDistributedLock())
+	if err != nil || v != "computed" {
+		t.Fatalf("expected waiter to receive computed value, got %q %v", v, err)
+	}
+	if <-done != "computed" || atomic.LoadInt32(&calls) != 1 {
+		t.Fatalf("expected a single recompute, got %d", calls)
+	}
+	if ok, _ := adapter.Exists(ctx, LockKey("report")); ok {
+		t.Fatal("expected lock released")
+	}
+}
+
+func TestQueryWithCodec(t *testing.T) {
+	manager, err := NewManager(&CacheConfig{
+		Type:       CacheTypeMemory,
+		DefaultTTL: 1 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	ctx := context.Background()
+
+	msg, err := Query(ctx, manager, "greeting", func() (*wrapperspb.StringValue, error) {
+		return wrapperspb.String("hi"), nil
+	}, WithCodec(NewProtoCodec(nil)))
+	if err != nil || msg.GetValue() != "hi" {
+		t.Fatalf("unexpected result %v %v", msg, err)
+	}
+
+	raw, _ := manager.Adapter().Get(ctx, "greeting")
+	if info, _ := InspectPayload(raw); info.Codec != "protobuf" {
+		t.Fatalf("expected protobuf payload, got %+v", info)
+	}
+	wire, err := proto.Marshal(wrapperspb.String("hi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(raw, wire) {
+		t.Fatalf("expected the protobuf wire format %x in payload %x", wire, raw)
+	}
+	var target *wrapperspb.StringValue
+	if err := NewProtoCodec(nil).Unmarshal(wire, &target); err != nil || target.GetValue() != "hi" {
+		t.Fatalf("expected a message allocated behind the pointer, got %v %v", target, err)
+	}
+
+	cached, err := Query(ctx, manager, "greeting", func() (*wrapperspb.StringValue, error) {
+		return nil, errors.New("loader should not run")
+	}, WithCodec(NewProtoCodec(nil)))
+	if err != nil || cached.GetValue() != "hi" {
+		t.Fatalf("unexpected cached result %v %v", cached, err)
+	}
+}
+
+// tagCodec is an unregistered codec whose payloads JSONCodec cannot read.
+type tagCodec struct{}
+
+func (tagCodec) Name() string { return "tag" }
+
+func (tagCodec) Marshal(v interface{}) ([]byte, error) {
+	b, err := json.Marshal(v)
+	return append([]byte("tag:"), b...), err
+}
+
+func (tagCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(bytes.TrimPrefix(data, []byte("tag:")), v)
+}
+
+func TestQueryCodecReads(t *testing.T) {
+	adapter := NewMemoryCacheAdapter(time.Minute)
+	nodeA := NewManagerWithAdapter(adapter, time.Minute)
+	nodeB := NewManagerWithAdapter(adapter, time.Minute)
+	defer nodeA.Close()
+
+	ctx := context.Background()
+	if _, err := Query(ctx, nodeA, "article:1", func() (string, error) { return "one", nil }, WithCodec(tagCodec{})); err != nil {
+		t.Fatal(err)
+	}
+	result, err := QueryMany(ctx, nodeA, []string{"article:1"}, func(missing []string) (map[string]string, error) {
+		return nil, fmt.Errorf("loader should not run for %v", missing)
+	}, WithCodec(tagCodec{}))
+	if err != nil || result["article:1"] != "one" {
+		t.Fatalf("expected QueryMany to decode with the query codec, got %v %v", result, err)
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go func() {
+		Query(ctx, nodeA, "report", func() (string, error) {
+			close(started)
+			<-release
+			return "computed", nil
+		}, WithDistributedLock(), WithCodec(tagCodec{}))
+	}()
+	<-started
+	go func() {
+		time.Sleep(60 * time.Millisecond)
+		close(release)
+	}()
+	v, err := Query(ctx, nodeB, "report", func() (string, error) {
+		return "duplicate", nil
+	}, WithDistributedLock(), WithCodec(tagCodec{}))
+	if err != nil || v != "computed" {
+		t.Fatalf("expected lock waiter to decode with the query codec, got %q %v", v, err)
+	}
+}
+
+func TestSettersConcurrent(t *testing.T) {
+	manager, err := NewManager(&CacheConfig{Type: CacheTypeMemory, DefaultTTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			manager.SetCodec(JSONCodec{})
+			manager.SetCompression(NewCacheCompression(64))
+			manager.SetEncryption(nil)
+			manager.SetTTLJitter(0.1)
+			manager.SetResourceTTLs(map[string]time.Duration{"article:": time.Hour})
+			manager.SetErrorHandler(func(context.Context, string, string, error) {})
+			manager.SetLogger(slog.Default())
+			manager.SetStaleGrace(time.Minute)
+			manager.SetChunkSize(64)
+			manager.SetDoubleDeleteDelay(time.Hour)
+			manager.SetRetryPolicy(NewRetryPolicy(2, time.Millisecond))
+			manager.SetBigKeyThreshold(1 << 20)
+			manager.SetTracer(&recordingTracer{})
+			manager.SetSortFields(map[string][]string{"articles": {"title"}})
+			manager.SetPaginationDefaults(map[string]PaginationDefaults{"articles": {PageSize: 5}})
+			manager.SetResourceEpochs(i%2 == 0)
+			manager.SetTableResources(map[string][]string{"posts": {"articles"}})
+			manager.SetKeyHashThreshold(32)
+			manager.SetKeyCanonicalization(KeyCanonicalization{SortSlices: true})
+			manager.SetCountTTL(time.Minute)
+			manager.SetPageKeyIndex(i%2 == 1)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			manager.Set(ctx, "article:1", strings.Repeat("v", 100), 0)
+			var dest string
+			manager.Get(ctx, "article:1", &dest)
+			Query(ctx, manager, "article:2", func() (string, error) { return "v", nil })
+			manager.Delete(ctx, "article:1")
+			params := &PaginationParams{Page: 1, SortBy: "title", UseCache: true}
+			QueryWithPagination(ctx, manager, "articles", nil, params, func() ([]string, int64, error) { return nil, 0, nil })
+			manager.InvalidateTable(ctx, "posts")
+			QueryCount(ctx, manager, "articles", nil, func() (int64, error) { return 1, nil })
+		}
+	}()
+	wg.Wait()
+}
+
+func TestPerQueryCompression(t *testing.T) {
+	manager, err := NewManager(&CacheConfig{
+		Type:        CacheTypeMemory,
+		DefaultTTL:  1 * time.Minute,
+		Compression: NewCacheCompression(64),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	ctx := context.Background()
+
+	small := strings.Repeat("s", 32)
+	if _, err := Query(ctx, manager, "small", func() (string, error) { return small, nil }, WithCompression(Gzip)); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := manager.Adapter().Get(ctx, "small")
+	if info, _ := InspectPayload(raw); info.Compression != Gzip {
+		t.Fatal("expected WithCompression to ignore the manager threshold")
+	}
+
+	large := strings.Repeat("l", 512)
+	if _, err := Query(ctx, manager, "large", func() (string, error) { return large, nil }, WithNoCompression()); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ = manager.Adapter().Get(ctx, "large")
+	if info, _ := InspectPayload(raw); info.Compression != 0 {
+		t.Fatal("expected WithNoCompression to skip compression")
+	}
+
+	for key, want := range map[string]string{"small": small, "large": large} {
+		got, err := Query(ctx, manager, key, func() (string, error) {
+			return "", errors.New("loader should not run")
+		})
+		if err != nil || got != want {
+			t.Fatalf("unexpected cached %s: %v", key, err)
+		}
+	}
+}
+
+func TestStaleOnError(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	manager, err := NewManager(&CacheConfig{
+		Type:       CacheTypeMemory,
+		DefaultTTL: 1 * time.Minute,
+		StaleGrace: time.Minute,
+		Clock:      clock,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	ctx := context.Background()
+
+	if _, err := Query(ctx, manager, "page:home", func() (string, error) {
+		return "v1", nil
+	}, WithTTL(20*time.Second), WithStaleOnError()); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(40 * time.Second)
+
+	var served bool
+	value, err := Query(ctx, manager, "page:home", func() (string, error) {
+		return "", errors.New("database down")
+	}, WithTTL(20*time.Millisecond), WithStaleOnError(), WithServedStale(&served))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "v1" || !served {
+		t.Fatalf("expected stale v1, got %q served=%v", value, served)
+	}
+	if manager.Monitor().GetMetrics().StaleServeCount != 1 {
+		t.Fatal("expected stale serve to be recorded")
+	}
+
+	value, err = Query(ctx, manager, "page:home", func() (string, error) {
+		return "v2", nil
+	}, WithTTL(20*time.Millisecond), WithStaleOnError(), WithServedStale(&served))
+	if err != nil || value != "v2" || served {
+		t.Fatalf("expected fresh v2, got %q served=%v err=%v", value, served, err)
+	}
+
+	if _, err := Query(ctx, manager, "page:missing", func() (string, error) {
+		return "", errors.New("database down")
+	}, WithStaleOnError()); err == nil {
+		t.Fatal("expected error without a retained copy")
+	}
+}
+
+type flakyAdapter struct {
+	*MemoryCacheAdapter
+	failing atomic.Bool
+	calls   atomic.Int32
+}
+
+func (f *flakyAdapter) Get(ctx context.Context, key string) ([]byte, error) {
+	f.calls.Add(1)
+	if f.failing.Load() {
+		return nil, errors.New("connection timeout")
+	}
+	return f.MemoryCacheAdapter.Get(ctx, key)
+}
+
+func (f *flakyAdapter) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	f.calls.Add(1)
+	if f.failing.Load() {
+		return errors.New("connection timeout")
+	}
+	return f.MemoryCacheAdapter.Set(ctx, key, value, ttl)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	adapter := &flakyAdapter{MemoryCacheAdapter: NewMemoryCacheAdapter(time.Minute)}
+	clock := NewFakeClock(time.Unix(0, 0))
+	var reported []error
+	manager := newManager(adapter, &CacheConfig{
+		DefaultTTL:       time.Minute,
+		BreakerThreshold: 2,
+		BreakerCooldown:  30 * time.Second,
+		Clock:            clock,
+		OnError:          func(_ context.Context, _, _ string, err error) { reported = append(reported, err) },
+	})
+	defer manager.Close()
+
+	ctx := context.Background()
+	loader := func() (string, error) { return "value", nil }
+
+	adapter.failing.Store(true)
+	if _, err := Query(ctx, manager, "k", loader); err != nil {
+		t.Fatal(err)
+	}
+	if manager.BreakerState() != BreakerOpen {
+		t.Fatalf("expected open breaker, got %s", manager.BreakerState())
+	}
+
+	calls := adapter.calls.Load()
+	errs := len(reported)
+	if value, err := Query(ctx, manager, "k", loader); err != nil || value != "value" {
+		t.Fatalf("expected loader result while open, got %q %v", value, err)
+	}
+	if adapter.calls.Load() != calls {
+		t.Fatal("expected open breaker to bypass the adapter")
+	}
+	if len(reported) != errs {
+		t.Fatalf("expected bypasses not to be reported as errors, got %v", reported[errs:])
+	}
+	if metrics := manager.Monitor().GetMetrics(); metrics.BreakerBypassCount != 2 || metrics.ErrorsByType[ErrorConnection] != 0 {
+		t.Fatalf("expected bypasses counted apart from errors, got %+v", metrics)
+	}
+
+	adapter.failing.Store(false)
+	clock.Advance(29 * time.Second)
+	Query(ctx, manager, "k", loader)
+	if manager.BreakerState() != BreakerOpen {
+		t.Fatalf("expected the breaker to stay open during the cooldown, got %s", manager.BreakerState())
+	}
+	clock.Advance(time.Second)
+	if _, err := Query(ctx, manager, "k", loader); err != nil {
+		t.Fatal(err)
+	}
+	if manager.BreakerState() != BreakerClosed {
+		t.Fatalf("expected closed breaker after probe, got %s", manager.BreakerState())
+	}
+
+	metrics := manager.Monitor().GetMetrics()
+	if metrics.BreakerOpens != 1 || metrics.BreakerState != "closed" {
+		t.Fatalf("unexpected breaker metrics %+v", metrics)
+	}
+}
+
+func TestQueryAdapterErrors(t *testing.T) {
+	adapter := &flakyAdapter{MemoryCacheAdapter: NewMemoryCacheAdapter(time.Minute)}
+	var reported []string
+	manager := newManager(adapter, &CacheConfig{
+		DefaultTTL: time.Minute,
+		OnError: func(ctx context.Context, op, key string, err error) {
+			reported = append(reported, op+":"+key)
+		},
+	})
+	defer manager.Close()
+
+	ctx := context.Background()
+	loader := func() (string, error) { return "value", nil }
+
+	adapter.failing.Store(true)
+	if value, err := Query(ctx, manager, "k", loader); err != nil || value != "value" {
+		t.Fatalf("expected tolerated adapter errors, got %q %v", value, err)
+	}
+	if strings.Join(reported, ",") != "get:k,set:k" {
+		t.Fatalf("unexpected reported errors %v", reported)
+	}
+	metrics := manager.Monitor().GetMetrics()
+	if metrics.GetErrorCount != 1 || metrics.SetErrorCount != 1 {
+		t.Fatalf("unexpected error counters %+v", metrics)
+	}
+
+	if _, err := Query(ctx, manager, "k", loader, WithStrictCache()); err == nil {
+		t.Fatal("expected strict cache to propagate adapter error")
+	}
+}
+
+type slowAdapter struct {
+	*MemoryCacheAdapter
+}
+
+func (s *slowAdapter) Get(ctx context.Context, key string) ([]byte, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(time.Second):
+		return s.MemoryCacheAdapter.Get(ctx, key)
+	}
+}
+
+func TestOperationTimeouts(t *testing.T) {
+	manager := newManager(&slowAdapter{NewMemoryCacheAdapter(time.Minute)}, &CacheConfig{
+		DefaultTTL:  time.Minute,
+		ReadTimeout: 20 * time.Millisecond,
+	})
+	defer manager.Close()
+
+	start := time.Now()
+	value, err := Query(context.Background(), manager, "k", func() (string, error) {
+		return "value", nil
+	})
+	if err != nil || value != "value" {
+		t.Fatalf("expected loader result after read timeout, got %q %v", value, err)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatal("expected ReadTimeout to bound the adapter call")
+	}
+
+	_, err = Query(context.Background(), manager, "k", func() (string, error) {
+		return "value", nil
+	}, WithStrictCache())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
+
+type resetAdapter struct {
+	*MemoryCacheAdapter
+	failures atomic.Int32
+}
+
+func (r *resetAdapter) Get(ctx context.Context, key string) ([]byte, error) {
+	if r.failures.Add(-1) >= 0 {
+		return nil, fmt.Errorf("read tcp: %w", syscall.ECONNRESET)
+	}
+	return r.MemoryCacheAdapter.Get(ctx, key)
+}
+
+func TestRetryPolicy(t *testing.T) {
+	adapter := &resetAdapter{MemoryCacheAdapter: NewMemoryCacheAdapter(time.Minute)}
+	manager := newManager(adapter, &CacheConfig{
+		DefaultTTL: time.Minute,
+		Retry:      NewRetryPolicy(3, time.Millisecond),
+	})
+	defer manager.Close()
+
+	ctx := context.Background()
+	if err := manager.Set(ctx, "k", "value", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	adapter.failures.Store(2)
+	var value string
+	if hit, err := manager.Get(ctx, "k", &value); err != nil || !hit || value != "value" {
+		t.Fatalf("expected retried hit, got %v %v %q", hit, err, value)
+	}
+	if manager.Monitor().GetMetrics().RetryCount != 2 {
+		t.Fatalf("expected 2 retries, got %d", manager.Monitor().GetMetrics().RetryCount)
+	}
+
+	adapter.failures.Store(3)
+	if _, err := manager.Get(ctx, "k", &value); !errors.Is(err, syscall.ECONNRESET) {
+		t.Fatalf("expected error after exhausting attempts, got %v", err)
+	}
+
+	if DefaultRetryable(errors.New("WRONGTYPE Operation")) || !DefaultRetryable(errors.New("MOVED 3999 127.0.0.1:6381")) {
+		t.Fatal("unexpected retryable classification")
+	}
+}
+
+func TestLifecycleHooks(t *testing.T) {
+	manager, err := NewManager(&CacheConfig{
+		Type:       CacheTypeMemory,
+		DefaultTTL: 1 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	var mu sync.Mutex
+	events := make(map[string][]HookEvent)
+	record := func(name string) Hook {
+		return func(ctx context.Context, event HookEvent) {
+			mu.Lock()
+			events[name] = append(events[name], event)
+			mu.Unlock()
+		}
+	}
+	manager.OnHit(record("hit"))
+	manager.OnMiss(record("miss"))
+	manager.OnSet(record("set"))
+	manager.OnDelete(record("delete"))
+	manager.OnEvict(record("evict"))
+
+	ctx := context.Background()
+	loader := func() (string, error) { return "value", nil }
+	if _, err := Query(ctx, manager, "article:1", loader); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Query(ctx, manager, "article:1", loader); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.Delete(ctx, "article:1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.Set(ctx, "short", "value", 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	var dest string
+	if hit, _ := manager.Get(ctx, "short", &dest); hit {
+		t.Fatal("expected expired entry")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events["hit"]) != 1 || events["hit"][0].Key != "article:1" || events["hit"][0].Size == 0 {
+		t.Fatalf("unexpected hit events %+v", events["hit"])
+	}
+	if len(events["miss"]) != 2 || len(events["set"]) != 2 || len(events["delete"]) != 1 {
+		t.Fatalf("unexpected events %+v", events)
+	}
+	if len(events["evict"]) != 1 || events["evict"][0].Key != "short" {
+		t.Fatalf("unexpected evict events %+v", events["evict"])
+	}
+	if manager.Monitor().GetMetrics().EvictionCount != 1 {
+		t.Fatal("expected eviction to be counted")
+	}
+}
+
+func TestQueryUndecodableEntryIsMiss(t *testing.T) {
+	manager, err := NewManager(&CacheConfig{
+		Type:       CacheTypeMemory,
+		DefaultTTL: 1 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	var hits, misses int32
+	manager.OnHit(func(context.Context, HookEvent) { atomic.AddInt32(&hits, 1) })
+	manager.OnMiss(func(context.Context, HookEvent) { atomic.AddInt32(&misses, 1) })
+
+	ctx := context.Background()
+	if err := manager.Adapter().Set(ctx, "article:1", RawValue("not json"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	v, err := Query(ctx, manager, "article:1", func() (string, error) { return "loaded", nil })
+	if err != nil || v != "loaded" {
+		t.Fatalf("expected the loader result, got %q %v", v, err)
+	}
+	if atomic.LoadInt32(&hits) != 0 || atomic.LoadInt32(&misses) != 1 {
+		t.Fatalf("expected one miss and no hit, got %d hits and %d misses", hits, misses)
+	}
+	metrics := manager.Monitor().GetMetrics()
+	if metrics.HitCount != 0 || metrics.MissCount != 1 || metrics.ErrorsByType[ErrorSerialization] != 1 {
+		t.Fatalf("unexpected metrics %+v", metrics)
+	}
+}
+
+func TestErrCacheMiss(t *testing.T) {
+	manager, err := NewManager(&CacheConfig{
+		Type:       CacheTypeMemory,
+		DefaultTTL: 1 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	ctx := context.Background()
+
+	if _, err := manager.Adapter().Get(ctx, "missing"); !errors.Is(err, ErrCacheMiss) {
+		t.Fatalf("expected adapter ErrCacheMiss, got %v", err)
+	}
+	var value string
+	if err := manager.GetE(ctx, "missing", &value); !errors.Is(err, ErrCacheMiss) {
+		t.Fatalf("expected GetE ErrCacheMiss, got %v", err)
+	}
+
+	if err := manager.Adapter().Set(ctx, "empty", RawValue(nil), 0); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := manager.Adapter().Get(ctx, "empty"); err != nil || len(data) != 0 {
+		t.Fatalf("expected stored empty value, got %v %v", data, err)
+	}
+
+	if err := manager.Set(ctx, "present", "value", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.GetE(ctx, "present", &value); err != nil || value != "value" {
+		t.Fatalf("unexpected GetE result %q %v", value, err)
+	}
+}
+
+func TestManagerWithPrefix(t *testing.T) {
+	manager, err := NewManager(&CacheConfig{
+		Type:       CacheTypeMemory,
+		DefaultTTL: 1 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	ctx := context.Background()
+	articles := manager.WithPrefix("articles:")
+	users := manager.WithPrefix("users:")
+
+	if _, err := Query(ctx, articles, "1", func() (string, error) { return "article", nil }); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Set(ctx, "1", "user", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	var value string
+	if hit, _ := manager.Get(ctx, "articles:1", &value); !hit || value != "article" {
+		t.Fatalf("expected namespaced key in root manager, got %q", value)
+	}
+	if hit, _ := users.Get(ctx, "1", &value); !hit || value != "user" {
+		t.Fatalf("expected user value, got %q", value)
+	}
+
+	if n, err := articles.Clear(ctx); err != nil || n != 1 {
+		t.Fatalf("expected one cleared key, got %d %v", n, err)
+	}
+	if exists, _ := users.Exists(ctx, "1"); !exists {
+		t.Fatal("expected other namespace to survive Clear")
+	}
+	if articles.Close() != nil {
+		t.Fatal("expected view Close to be a no-op")
+	}
+	if err := users.Set(ctx, "2", "user", 0); err != nil {
+		t.Fatal("expected adapter to remain open after view Close")
+	}
+}
+
+func TestManagerRegistry(t *testing.T) {
+	sessions := NewManagerWithAdapter(NewMemoryCacheAdapter(time.Minute), time.Minute)
+	pages := NewManagerWithAdapter(NewMemoryCacheAdapter(time.Minute), time.Minute)
+	if err := Register("sessions", sessions); err != nil {
+		t.Fatal(err)
+	}
+	if err := Register("pages", pages); err != nil {
+		t.Fatal(err)
+	}
+	if err := Register("sessions", pages); err == nil {
+		t.Fatal("expected duplicate registration to fail")
+	}
+
+	if m, ok := Get("sessions"); !ok || m != sessions {
+		t.Fatal("expected registered sessions manager")
+	}
+	if names := Registered(); strings.Join(names, ",") != "pages,sessions" {
+		t.Fatalf("unexpected registered names %v", names)
+	}
+
+	if err := CloseAll(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := Get("sessions"); ok {
+		t.Fatal("expected CloseAll to unregister managers")
+	}
+}
+
+func TestDefaultManager(t *testing.T) {
+	t.Setenv("EIT_CACHE_TYPE", CacheTypeMemory)
+	t.Setenv("EIT_CACHE_DEFAULT_TTL", "30s")
+	SetDefault(nil)
+	defer SetDefault(nil)
+
+	m, err := Default()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if m.defaultTTL != 30*time.Second {
+		t.Fatalf("expected TTL from environment, got %s", m.defaultTTL)
+	}
+
+	ctx := context.Background()
+	if err := DefaultSet(ctx, "k", "v", 0); err != nil {
+		t.Fatal(err)
+	}
+	var value string
+	if hit, err := DefaultGet(ctx, "k", &value); err != nil || !hit || value != "v" {
+		t.Fatalf("unexpected default get %v %v %q", hit, err, value)
+	}
+	result, err := DefaultQuery(ctx, "k", func() (string, error) {
+		return "", errors.New("loader should not run")
+	})
+	if err != nil || result != "v" {
+		t.Fatalf("unexpected default query %q %v", result, err)
+	}
+
+	t.Setenv("EIT_CACHE_DB", "x")
+	if _, err := ConfigFromEnv(); err == nil {
+		t.Fatal("expected invalid EIT_CACHE_DB to fail")
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "cache.yaml")
+	if err := os.WriteFile(yamlPath, []byte(`
+caches:
+  pages:
+    type: memory
+    ttl: 5m
+    compression:
+      algorithm: zstd
+      threshold: 16
+  sessions:
+    type: memory
+    ttl: 30s
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	set, err := LoadConfig(yamlPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer set.Close()
+	if len(set) != 2 || set["pages"].defaultTTL != 5*time.Minute || set["sessions"].defaultTTL != 30*time.Second {
+		t.Fatalf("unexpected managers %+v", set)
+	}
+	if set["pages"].current().compression.algorithm() != Zstd {
+		t.Fatal("expected zstd compression for pages")
+	}
+
+	jsonPath := filepath.Join(dir, "cache.json")
+	if err := os.WriteFile(jsonPath, []byte(`{"caches":{"a":{"type":"memory","ttl":"1m"}}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	jsonSet, err := LoadConfig(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer jsonSet.Close()
+	if jsonSet["a"].defaultTTL != time.Minute {
+		t.Fatal("expected JSON ttl to be parsed")
+	}
+
+	badPath := filepath.Join(dir, "bad.yaml")
+	if err := os.WriteFile(badPath, []byte("caches:\n  x:\n    type: bogus\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(badPath); err == nil {
+		t.Fatal("expected invalid cache type to fail")
+	}
+}
+
+func TestCacheConfigValidate(t *testing.T) {
+	err := (&CacheConfig{
+		Type:       CacheTypeRedis,
+		Addr:       "localhost",
+		DefaultTTL: -time.Second,
+		PoolSize:   -1,
+		TTLJitter:  2,
+	}).Validate()
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) || len(configErr.Problems) != 4 {
+		t.Fatalf("expected 4 aggregated problems, got %v", err)
+	}
+
+	if _, err := NewManager(&CacheConfig{Type: "bogus"}); !errors.Is(err, ErrInvalidType) {
+		t.Fatalf("expected ErrInvalidType, got %v", err)
+	}
+	if err := (&CacheConfig{Type: CacheTypeRedis, Addr: "localhost:6379"}).Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadOnlyMode(t *testing.T) {
+	manager, err := NewManager(&CacheConfig{
+		Type:       CacheTypeMemory,
+		DefaultTTL: 1 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	ctx := context.Background()
+	if err := manager.Set(ctx, "k", "v1", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	manager.SetReadOnly(true)
+	view := manager.WithPrefix("ns:")
+	if !view.ReadOnly() {
+		t.Fatal("expected views to share read-only state")
+	}
+	if err := manager.Set(ctx, "k", "v2", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.Delete(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	value, err := Query(ctx, manager, "other", func() (string, error) { return "loaded", nil })
+	if err != nil || value != "loaded" {
+		t.Fatalf("expected loader result in read-only mode, got %q %v", value, err)
+	}
+	if exists, _ := manager.Exists(ctx, "other"); exists {
+		t.Fatal("expected Query not to write in read-only mode")
+	}
+
+	var cached string
+	if hit, _ := manager.Get(ctx, "k", &cached); !hit || cached != "v1" {
+		t.Fatalf("expected original value to be served, got %q", cached)
+	}
+
+	manager.SetReadOnly(false)
+	if err := manager.Set(ctx, "k", "v2", 0); err != nil {
+		t.Fatal(err)
+	}
+	if hit, _ := manager.Get(ctx, "k", &cached); !hit || cached != "v2" {
+		t.Fatal("expected writes after leaving read-only mode")
+	}
+}
+
+func TestWriteThrough(t *testing.T) {
+	manager, err := NewManager(&CacheConfig{
+		Type:       CacheTypeMemory,
+		DefaultTTL: 1 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	ctx := context.Background()
+	var persisted []string
+	persist := func(v string) error {
+		persisted = append(persisted, v)
+		return nil
+	}
+	if err := WriteThrough(ctx, manager, "article:1", "v1", 0, persist); err != nil {
+		t.Fatal(err)
+	}
+	var cached string
+	if hit, _ := manager.Get(ctx, "article:1", &cached); !hit || cached != "v1" || len(persisted) != 1 {
+		t.Fatalf("expected persisted and cached v1, got %q", cached)
+	}
+
+	dbErr := errors.New("db down")
+	failing := func(string) error { return dbErr }
+	if err := WriteThrough(ctx, manager, "article:1", "v2", 0, failing); !errors.Is(err, dbErr) {
+		t.Fatalf("expected persist error, got %v", err)
+	}
+	if hit, _ := manager.Get(ctx, "article:1", &cached); !hit || cached != "v1" {
+		t.Fatal("expected cache untouched when persist fails")
+	}
+
+	if err := WriteThrough(ctx, manager, "article:1", "v3", 0, failing, WithDeleteOnFailure()); !errors.Is(err, dbErr) {
+		t.Fatalf("expected persist error, got %v", err)
+	}
+	if hit, _ := manager.Get(ctx, "article:1", &cached); hit {
+		t.Fatal("expected key deleted on persist failure")
+	}
+}
+
+type batchCountingAdapter struct {
+	*MemoryCacheAdapter
+	batches atomic.Int32
+}
+
+func (b *batchCountingAdapter) SetBatch(ctx context.Context, entries []BatchEntry) error {
+	b.batches.Add(1)
+	return b.MemoryCacheAdapter.SetBatch(ctx, entries)
+}
+
+func TestWriteBehind(t *testing.T) {
+	adapter := &batchCountingAdapter{MemoryCacheAdapter: NewMemoryCacheAdapter(time.Minute)}
+	manager := newManager(adapter, &CacheConfig{
+		DefaultTTL: time.Minute,
+		WriteBehind: &WriteBehindConfig{
+			Workers:       1,
+			BatchSize:     50,
+			FlushInterval: 20 * time.Millisecond,
+		},
+	})
+
+	ctx := context.Background()
+	for i := 0; i < 100; i++ {
+		if err := manager.Set(ctx, "item:"+strconv.Itoa(i), i, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := manager.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var value int
+	if hit, _ := manager.Get(ctx, "item:99", &value); !hit || value != 99 {
+		t.Fatalf("expected flushed value, got %d", value)
+	}
+	if n := adapter.batches.Load(); n == 0 || n > 10 {
+		t.Fatalf("expected batched writes, got %d batches", n)
+	}
+
+	if err := manager.Set(ctx, "last", "value", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := adapter.MemoryCacheAdapter.Get(ctx, "last"); data == nil {
+		t.Fatal("expected Close to drain queued writes")
+	}
+	if err := manager.Set(ctx, "late", "value", 0); !errors.Is(err, ErrWriteBehindClosed) {
+		t.Fatalf("expected ErrWriteBehindClosed, got %v", err)
+	}
+}
+
+func TestWriteBehindDelete(t *testing.T) {
+	manager := newManager(NewMemoryCacheAdapter(time.Minute), &CacheConfig{
+		DefaultTTL: time.Minute,
+		WriteBehind: &WriteBehindConfig{
+			Workers:       2,
+			BatchSize:     100,
+			FlushInterval: time.Hour,
+		},
+	})
+	defer manager.Close()
+
+	ctx := context.Background()
+	var value string
+	_ = manager.Set(ctx, "k", "v", 0)
+	if err := manager.Delete(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	_ = manager.Flush(ctx)
+	if hit, _ := manager.Get(ctx, "k", &value); hit {
+		t.Fatalf("expected Delete to win over the queued Set, got %q", value)
+	}
+
+	_ = manager.Set(ctx, "page:1", "v", 0)
+	if _, err := manager.DeletePattern(ctx, "page:*"); err != nil {
+		t.Fatal(err)
+	}
+	_ = manager.Flush(ctx)
+	if hit, _ := manager.Get(ctx, "page:1", &value); hit {
+		t.Fatalf("expected DeletePattern to win over the queued Set, got %q", value)
+	}
+
+	_ = manager.Set(ctx, "k", "old", 0)
+	payload, _ := manager.encode("new")
+	if err := manager.store(ctx, "k", payload, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	_ = manager.Flush(ctx)
+	if hit, _ := manager.Get(ctx, "k", &value); !hit || value != "new" {
+		t.Fatalf("expected the later synchronous write to win, got %q", value)
+	}
+}
+
+func TestMemoryLRUEviction(t *testing.T) {
+	manager, err := NewManager(&CacheConfig{
+		Type:       CacheTypeMemory,
+		DefaultTTL: 1 * time.Minute,
+		MaxEntries: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	ctx := context.Background()
+	_ = manager.Set(ctx, "a", 1, 0)
+	_ = manager.Set(ctx, "b", 2, 0)
+	var value int
+	if hit, _ := manager.Get(ctx, "a", &value); !hit {
+		t.Fatal("expected a to be cached")
+	}
+	_ = manager.Set(ctx, "c", 3, 0)
+
+	if exists, _ := manager.Exists(ctx, "b"); exists {
+		t.Fatal("expected least recently used key b to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if exists, _ := manager.Exists(ctx, key); !exists {
+			t.Fatalf("expected %s to remain", key)
+		}
+	}
+	if manager.Monitor().GetMetrics().EvictionCount != 1 {
+		t.Fatal("expected eviction to be recorded")
+	}
+}
+
+func TestMemoryLFUEviction(t *testing.T) {
+	adapter, err := NewMemoryCacheAdapterWithConfig(&CacheConfig{
+		DefaultTTL:     time.Minute,
+		MaxEntries:     2,
+		EvictionPolicy: EvictionLFU,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	_ = adapter.Set(ctx, "hot", RawValue("1"), 0)
+	_ = adapter.Set(ctx, "warm", RawValue("2"), 0)
+	for i := 0; i < 3; i++ {
+		_, _ = adapter.Get(ctx, "hot")
+	}
+	_, _ = adapter.Get(ctx, "warm")
+	_ = adapter.Set(ctx, "cold", RawValue("3"), 0)
+	_ = adapter.Set(ctx, "colder", RawValue("4"), 0)
+
+	if exists, _ := adapter.Exists(ctx, "hot"); !exists {
+		t.Fatal("expected frequently used key to stay resident")
+	}
+	if exists, _ := adapter.Exists(ctx, "cold"); exists {
+		t.Fatal("expected least frequently used key to be evicted")
+	}
+
+	if _, err := NewManager(&CacheConfig{EvictionPolicy: "random"}); err == nil {
+		t.Fatal("expected unknown eviction policy to fail validation")
+	}
+}
+
+func TestMemoryMaxBytes(t *testing.T) {
+	adapter, err := NewMemoryCacheAdapterWithConfig(&CacheConfig{MaxMemoryBytes: 250})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	value := RawValue(strings.Repeat("x", 99))
+	for _, key := range []string{"a", "b", "c"} {
+		_ = adapter.Set(ctx, key, value, 0)
+	}
+
+	stats, _ := adapter.Stats(ctx)
+	if used := stats["used_bytes"].(int64); used != 200 {
+		t.Fatalf("expected 200 used bytes, got %d", used)
+	}
+	if exists, _ := adapter.Exists(ctx, "a"); exists {
+		t.Fatal("expected oldest key to be evicted over the byte limit")
+	}
+
+	_ = adapter.Delete(ctx, "b")
+	stats, _ = adapter.Stats(ctx)
+	if used := stats["used_bytes"].(int64); used != 100 {
+		t.Fatalf("expected 100 used bytes after delete, got %d", used)
+	}
+}
+
+func TestMemoryExpirationJanitor(t *testing.T) {
+	adapter, err := NewMemoryCacheAdapterWithConfig(&CacheConfig{CleanupInterval: 5 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer adapter.Close()
+
+	var evicted atomic.Int64
+	adapter.SetEvictionCallback(func(key string, size int) { evicted.Add(1) })
+
+	ctx := context.Background()
+	_ = adapter.Set(ctx, "short", RawValue("1"), 10*time.Millisecond)
+	_ = adapter.Set(ctx, "long", RawValue("2"), time.Hour)
+	_ = adapter.Set(ctx, "forever", RawValue("3"), -1)
+
+	deadline := time.Now().Add(time.Second)
+	for evicted.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	stats, _ := adapter.Stats(ctx)
+	if total := stats["total_items"].(int); total != 2 {
+		t.Fatalf("expected janitor to drop the expired entry, got %d items", total)
+	}
+	adapter.mu.RLock()
+	queued := len(adapter.expiries)
+	adapter.mu.RUnlock()
+	if queued != 1 {
+		t.Fatalf("expected only the long entry on the expiration heap, got %d", queued)
+	}
+}
+
+func TestMemoryOnEvict(t *testing.T) {
+	adapter := NewMemoryCacheAdapter(time.Minute)
+	adapter.SetMaxEntries(1)
+
+	var mu sync.Mutex
+	reasons := make(map[string]EvictReason)
+	var payload []byte
+	adapter.OnEvict(func(key string, value []byte, reason EvictReason) {
+		mu.Lock()
+		defer mu.Unlock()
+		reasons[key] = reason
+		if key == "first" {
+			payload = value
+		}
+	})
+
+	ctx := context.Background()
+	_ = adapter.Set(ctx, "first", RawValue("1"), 0)
+	_ = adapter.Set(ctx, "second", RawValue("2"), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	_, _ = adapter.Get(ctx, "second")
+
+	mu.Lock()
+	defer mu.Unlock()
+	if reasons["first"] != EvictCapacity || string(payload) != "1" {
+		t.Fatalf("expected capacity eviction with payload, got %v %q", reasons["first"], payload)
+	}
+	if reasons["second"] != EvictExpired {
+		t.Fatalf("expected expired eviction, got %v", reasons["second"])
+	}
+}
+
+func TestMemoryInspect(t *testing.T) {
+	adapter := NewMemoryCacheAdapter(time.Minute)
+	ctx := context.Background()
+	_ = adapter.Set(ctx, "small", RawValue("1"), 0)
+	_ = adapter.Set(ctx, "large", RawValue(strings.Repeat("x", 100)), -1)
+	for i := 0; i < 3; i++ {
+		_, _ = adapter.Get(ctx, "small")
+	}
+
+	info, ok := adapter.Inspect("small")
+	if !ok {
+		t.Fatal("expected entry info")
+	}
+	if info.Hits != 3 || info.LastAccess.IsZero() || info.CreatedAt.IsZero() {
+		t.Fatalf("unexpected access metadata: %+v", info)
+	}
+	if info.TTL <= 0 || info.TTL > time.Minute {
+		t.Fatalf("unexpected remaining ttl %s", info.TTL)
+	}
+	if info, _ := adapter.Inspect("large"); info.TTL != 0 || !info.ExpiresAt.IsZero() {
+		t.Fatalf("expected no expiration, got %+v", info)
+	}
+	if _, ok := adapter.Inspect("missing"); ok {
+		t.Fatal("expected missing key to report false")
+	}
+
+	if top := adapter.TopEntries(1, ByHits); len(top) != 1 || top[0].Key != "small" {
+		t.Fatalf("unexpected top entries by hits: %+v", top)
+	}
+	if top := adapter.TopEntries(5, BySize); len(top) != 2 || top[0].Key != "large" {
+		t.Fatalf("unexpected top entries by size: %+v", top)
+	}
+}
+
+func TestMemorySnapshot(t *testing.T) {
+	ctx := context.Background()
+	source := NewMemoryCacheAdapter(time.Minute)
+	_ = source.Set(ctx, "ttl", RawValue("1"), time.Hour)
+	_ = source.Set(ctx, "forever", RawValue("2"), -1)
+	_ = source.Set(ctx, "expiring", RawValue("3"), 20*time.Millisecond)
+
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+	source.SetSnapshotInterval(path, time.Hour, nil)
+	if err := source.Close(); err != nil {
+		t.Fatalf("close with snapshot failed: %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	restored := NewMemoryCacheAdapter(time.Minute)
+	if err := restored.LoadSnapshotFile(path); err != nil {
+		t.Fatalf("load snapshot failed: %v", err)
+	}
+	if data, err := restored.Get(ctx, "ttl"); err != nil || string(data) != "1" {
+		t.Fatalf("expected restored entry, got %q %v", data, err)
+	}
+	if info, _ := restored.Inspect("ttl"); info.TTL <= 59*time.Minute {
+		t.Fatalf("expected remaining ttl to be preserved, got %s", info.TTL)
+	}
+	if info, ok := restored.Inspect("forever"); !ok || !info.ExpiresAt.IsZero() {
+		t.Fatalf("expected non-expiring entry, got %+v %v", info, ok)
+	}
+	if _, ok := restored.Inspect("expiring"); ok {
+		t.Fatal("expected entry that expired after the snapshot to be skipped")
+	}
+}
+
+func TestManagerSnapshotRestore(t *testing.T) {
+	ctx := context.Background()
+	config := &CacheConfig{
+		Type:           CacheTypeMemory,
+		DefaultTTL:     time.Minute,
+		SnapshotPath:   filepath.Join(t.TempDir(), "cache.snapshot"),
+		RestoreOnStart: true,
+	}
+
+	first, err := NewManager(config)
+	if err != nil {
+		t.Fatalf("create manager without snapshot failed: %v", err)
+	}
+	if err := first.Set(ctx, "user:1", "alice", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	second, err := NewManager(config)
+	if err != nil {
+		t.Fatalf("restore manager failed: %v", err)
+	}
+	defer second.Close()
+	var name string
+	if found, err := second.Get(ctx, "user:1", &name); err != nil || !found || name != "alice" {
+		t.Fatalf("expected restored value, got %q %v %v", name, found, err)
+	}
+
+	if err := (&CacheConfig{RestoreOnStart: true}).Validate(); err == nil {
+		t.Fatal("expected restore without path to fail validation")
+	}
+}
+
+func TestScanKeys(t *testing.T) {
+	ctx := context.Background()
+	manager, err := NewManager(&CacheConfig{Type: CacheTypeMemory, DefaultTTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := manager.WithPrefix("users:")
+	_ = users.Set(ctx, "1", "a", 0)
+	_ = users.Set(ctx, "2", "b", 0)
+	_ = users.Set(ctx, "12", "c", 0)
+	_ = manager.Set(ctx, "orders:1", "d", 0)
+
+	keys, err := users.Scan(ctx, "1*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(keys, ",") != "1,12" {
+		t.Fatalf("unexpected scanned keys %v", keys)
+	}
+
+	adapter := manager.adapter.(*MemoryCacheAdapter)
+	if keys := adapter.Keys("users:?"); strings.Join(keys, ",") != "users:1,users:2" {
+		t.Fatalf("unexpected adapter keys %v", keys)
+	}
+	if keys := adapter.Keys(""); len(keys) != 4 {
+		t.Fatalf("expected every key, got %v", keys)
+	}
+}
+
+func TestMemoryStatsEstimation(t *testing.T) {
+	ctx := context.Background()
+	adapter := NewMemoryCacheAdapter(time.Minute)
+	_ = adapter.Set(ctx, "a", RawValue(strings.Repeat("x", 9)), 0)
+	_ = adapter.Set(ctx, "b", RawValue(strings.Repeat("x", 29)), 0)
+
+	stats, err := adapter.Stats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if avg := stats["avg_entry_bytes"].(int64); avg != 20 {
+		t.Fatalf("expected 20 average bytes, got %d", avg)
+	}
+	if est := stats["estimated_bytes"].(int64); est != 40+2*entryOverhead {
+		t.Fatalf("unexpected estimate %d", est)
+	}
+	largest := stats["largest_entries"].([]map[string]interface{})
+	if len(largest) != 2 || largest[0]["key"] != "b" {
+		t.Fatalf("unexpected largest entries %v", largest)
+	}
+}
+
+func TestFakeClock(t *testing.T) {
+	ctx := context.Background()
+	clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	adapter, err := NewMemoryCacheAdapterWithConfig(&CacheConfig{
+		DefaultTTL:      time.Minute,
+		CleanupInterval: 30 * time.Second,
+		Clock:           clock,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer adapter.Close()
+
+	evicted := make(chan string, 1)
+	adapter.OnEvict(func(key string, value []byte, reason EvictReason) { evicted <- key })
+	_ = adapter.Set(ctx, "k", RawValue("v"), 0)
+
+	clock.Advance(59 * time.Second)
+	if _, err := adapter.Get(ctx, "k"); err != nil {
+		t.Fatalf("expected entry before ttl, got %v", err)
+	}
+
+	for clock.Waiters() == 0 {
+		runtime.Gosched()
+	}
+	clock.Advance(time.Minute)
+	select {
+	case key := <-evicted:
+		if key != "k" {
+			t.Fatalf("unexpected evicted key %q", key)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected janitor to expire the entry")
+	}
+
+	ticket := GenerateTicketWithClock(clock, "u1", time.Minute)
+	clock.Advance(2 * time.Minute)
+	if err := ticket.ValidateWithClock(clock); !errors.Is(err, ErrTicketExpired) {
+		t.Fatalf("expected expired ticket, got %v", err)
+	}
+
+	strategy := NewSmartCacheStrategy(time.Minute)
+	strategy.SetClock(clock)
+	if strategy.ShouldRefresh() {
+		t.Fatal("expected no refresh right after update")
+	}
+	clock.Advance(31 * time.Second)
+	if !strategy.ShouldRefresh() {
+		t.Fatal("expected refresh after half the ttl")
+	}
+}
+
+func TestMemoryStoreValues(t *testing.T) {
+	type profile struct {
+		Name    string
+		Tags    []string
+		Updated time.Time
+	}
+
+	ctx := context.Background()
+	manager, err := NewManager(&CacheConfig{
+		Type:        CacheTypeMemory,
+		DefaultTTL:  time.Minute,
+		StoreValues: true,
+		CopyOnRead:  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updated := time.Date(2024, 1, 2, 3, 4, 5, 6, time.FixedZone("x", 3600))
+	calls := 0
+	load := func() (*profile, error) {
+		calls++
+		return &profile{Name: "alice", Tags: []string{"a"}, Updated: updated}, nil
+	}
+	first, err := Query(ctx, manager, "profile:1", load)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.Tags[0] = "mutated"
+
+	second, err := Query(ctx, manager, "profile:1", load)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected cached value, loader ran %d times", calls)
+	}
+	if second.Tags[0] != "a" {
+		t.Fatalf("expected copy on read to isolate cached value, got %v", second.Tags)
+	}
+	if !second.Updated.Equal(updated) || second.Updated.Location().String() != "x" {
+		t.Fatalf("expected time to keep its location, got %v", second.Updated)
+	}
+
+	if err := manager.Set(ctx, "count", 42, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := manager.Monitor().GetMetrics().OpLatency[OpLatencySet]; !ok {
+		t.Fatal("expected value-mode Set to record its latency")
+	}
+	var count int64
+	if found, err := manager.Get(ctx, "count", &count); err != nil || !found || count != 42 {
+		t.Fatalf("expected converted value, got %d %v %v", count, found, err)
+	}
+	data, err := manager.Adapter().Get(ctx, "count")
+	if err != nil || string(data) != "42" {
+		t.Fatalf("expected JSON bytes for value entry, got %q %v", data, err)
+	}
+
+	_, err = Query(ctx, manager, "missing", func() (*profile, error) { return nil, ErrNotFound }, WithNegativeCache(time.Minute))
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatal(err)
+	}
+	if _, err := Query(ctx, manager, "missing", load, WithNegativeCache(time.Minute)); !errors.Is(err, ErrNotFoundCached) {
+		t.Fatalf("expected negative cache marker in value mode, got %v", err)
+	}
+}
+
+func TestMemoryPolicyConcurrent(t *testing.T) {
+	ctx := context.Background()
+	adapter := NewMemoryCacheAdapter(time.Minute)
+	for i := 0; i < 10; i++ {
+		_ = adapter.Set(ctx, fmt.Sprintf("k%d", i), RawValue("1"), 0)
+	}
+	adapter.SetMaxEntries(20)
+
+	done := make(chan struct{})
+	switched := make(chan struct{})
+	go func() {
+		defer close(switched)
+		policies := []EvictionPolicy{EvictionLFU, EvictionARC, EvictionLRU}
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			_ = adapter.SetEvictionPolicy(policies[i%len(policies)])
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				_, _ = adapter.Get(ctx, fmt.Sprintf("k%d", j%10))
+				_, _ = adapter.GetMulti(ctx, []string{"k1", "k2"})
+			}
+		}()
+	}
+	wg.Wait()
+	close(done)
+	<-switched
+}
+
+func TestMemoryARCEviction(t *testing.T) {
+	ctx := context.Background()
+	adapter := NewMemoryCacheAdapter(time.Minute)
+	if err := adapter.SetEvictionPolicy(EvictionARC); err != nil {
+		t.Fatal(err)
+	}
+	adapter.SetMaxEntries(4)
+
+	for _, key := range []string{"hot1", "hot2"} {
+		_ = adapter.Set(ctx, key, RawValue("1"), 0)
+		_, _ = adapter.Get(ctx, key)
+	}
+	for i := 0; i < 20; i++ {
+		_ = adapter.Set(ctx, fmt.Sprintf("scan:%d", i), RawValue("1"), 0)
+	}
+
+	for _, key := range []string{"hot1", "hot2"} {
+		if exists, _ := adapter.Exists(ctx, key); !exists {
+			t.Fatalf("expected %s to survive the scan", key)
+		}
+	}
+
+	// A key evicted from the recent list comes back as frequent.
+	_ = adapter.Set(ctx, "scan:17", RawValue("1"), 0)
+	adapter.mu.RLock()
+	frequent := adapter.cache["scan:17"].frequent
+	adapter.mu.RUnlock()
+	if !frequent {
+		t.Fatal("expected ghost hit to promote the key")
+	}
+}
+
+func TestMemoryPinning(t *testing.T) {
+	ctx := context.Background()
+	manager, err := NewManager(&CacheConfig{
+		Type:       CacheTypeMemory,
+		DefaultTTL: time.Minute,
+		MaxEntries: 2,
+		Pinned:     []string{"config:*"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	adapter := manager.Adapter().(*MemoryCacheAdapter)
+
+	_ = manager.Set(ctx, "config:flags", "on", 0)
+	_ = manager.Set(ctx, "config:limits", "10", 0)
+	for i := 0; i < 5; i++ {
+		_ = manager.Set(ctx, fmt.Sprintf("item:%d", i), i, 0)
+	}
+	for _, key := range []string{"config:flags", "config:limits"} {
+		if exists, _ := manager.Exists(ctx, key); !exists {
+			t.Fatalf("expected pinned %s to survive eviction", key)
+		}
+	}
+
+	if _, err := manager.Clear(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if keys := adapter.Keys("*"); strings.Join(keys, ",") != "config:flags,config:limits" {
+		t.Fatalf("expected only pinned keys after Clear, got %v", keys)
+	}
+
+	adapter.Unpin("config:*")
+	if keys := adapter.Keys("*"); len(keys) != 2 {
+		t.Fatalf("expected unpinned keys to fit the limit, got %v", keys)
+	}
+	_ = manager.Set(ctx, "item:9", 9, 0)
+	if keys := adapter.Keys("config:*"); len(keys) != 1 {
+		t.Fatalf("expected unpinned keys to be evictable, got %v", keys)
+	}
+}
+
+func TestArenaAdapter(t *testing.T) {
+	ctx := context.Background()
+	clock := NewFakeClock(time.Now())
+	adapter, err := NewArenaCacheAdapterWithConfig(&CacheConfig{
+		ArenaSize:   256,
+		ArenaShards: 1,
+		DefaultTTL:  time.Minute,
+		Clock:       clock,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value := RawValue(strings.Repeat("v", 36))
+	for i := 0; i < 10; i++ {
+		if err := adapter.Set(ctx, fmt.Sprintf("key:%d", i), value, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := adapter.Get(ctx, "key:0"); !errors.Is(err, ErrCacheMiss) {
+		t.Fatalf("expected oldest entry to be evicted, got %v", err)
+	}
+	if data, err := adapter.Get(ctx, "key:9"); err != nil || string(data) != string(value) {
+		t.Fatalf("expected newest entry, got %q %v", data, err)
+	}
+
+	_ = adapter.Set(ctx, "key:9", RawValue("new"), 0)
+	if data, _ := adapter.Get(ctx, "key:9"); string(data) != "new" {
+		t.Fatalf("expected overwritten value, got %q", data)
+	}
+	if n, _ := adapter.Incr(ctx, "counter"); n != 1 {
+		t.Fatalf("expected counter 1, got %d", n)
+	}
+
+	_ = adapter.Set(ctx, "short", RawValue("x"), time.Second)
+	clock.Advance(2 * time.Second)
+	if exists, _ := adapter.Exists(ctx, "short"); exists {
+		t.Fatal("expected entry to expire")
+	}
+
+	if err := adapter.Set(ctx, "huge", RawValue(strings.Repeat("x", 300)), 0); !errors.Is(err, ErrEntryTooLarge) {
+		t.Fatalf("expected ErrEntryTooLarge, got %v", err)
+	}
+
+	manager, err := NewManager(&CacheConfig{Type: CacheTypeArena, ArenaSize: 1 << 20, DefaultTTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var name string
+	_ = manager.Set(ctx, "user:1", "alice", 0)
+	if found, err := manager.Get(ctx, "user:1", &name); err != nil || !found || name != "alice" {
+		t.Fatalf("expected arena round trip, got %q %v %v", name, found, err)
+	}
+	if n, _ := manager.DeletePattern(ctx, "user:*"); n != 1 {
+		t.Fatalf("expected one deleted key, got %d", n)
+	}
+}
+
+func TestResourceEpochs(t *testing.T) {
+	ctx := context.Background()
+	manager, err := NewManager(&CacheConfig{Type: CacheTypeMemory, DefaultTTL: time.Minute, ResourceEpochs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+	load := func() ([]string, int64, error) {
+		calls++
+		return []string{fmt.Sprintf("v%d", calls)}, 1, nil
+	}
+	first, err := QueryWithPagination(ctx, manager, "articles", nil, nil, load)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.CacheKey != "articles:v0:page:1:size:20" {
+		t.Fatalf("unexpected versioned key %q", first.CacheKey)
+	}
+	if resp, _ := QueryWithPagination(ctx, manager, "articles", nil, nil, load); !resp.FromCache {
+		t.Fatal("expected cached page before invalidation")
+	}
+
+	if n, err := InvalidateCacheOnUpdate(ctx, manager, "articles"); err != nil || n != 0 {
+		t.Fatalf("expected epoch bump, got %d %v", n, err)
+	}
+	if epoch, _ := manager.ResourceEpoch(ctx, "articles"); epoch != 1 {
+		t.Fatalf("expected epoch 1, got %d", epoch)
+	}
+	resp, err := QueryWithPagination(ctx, manager, "articles", nil, nil, load)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.FromCache || resp.Data[0] != "v2" || resp.CacheKey != "articles:v1:page:1:size:20" {
+		t.Fatalf("expected fresh page under new epoch, got %+v", resp)
+	}
+}
+
+func TestInvalidationBus(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	newNode := func() *Manager {
+		manager, err := NewManager(&CacheConfig{
+			Type:         CacheTypeMemory,
+			DefaultTTL:   time.Minute,
+			Invalidation: &InvalidationConfig{Addr: server.Addr()},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { manager.Close() })
+		return manager
+	}
+	a, b := newNode(), newNode()
+
+	for _, m := range []*Manager{a, b} {
+		for _, key := range []string{"user:1", "user:2", "post:1"} {
+			if err := m.Set(ctx, key, "v", 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	waitMissing := func(key string) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			ok, err := b.Exists(ctx, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ok {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("expected %s to be invalidated on the other node", key)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	if err := a.Delete(ctx, "user:1"); err != nil {
+		t.Fatal(err)
+	}
+	waitMissing("user:1")
+
+	if _, err := a.DeletePattern(ctx, "user:*"); err != nil {
+		t.Fatal(err)
+	}
+	waitMissing("user:2")
+	if ok, _ := b.Exists(ctx, "post:1"); !ok {
+		t.Fatal("expected unrelated key to survive")
+	}
+
+	if err := (&CacheConfig{Invalidation: &InvalidationConfig{Addr: "nohost"}}).Validate(); err == nil {
+		t.Fatal("expected invalid invalidation addr to fail validation")
+	}
+}
+
+type hubTransport struct {
+	hub      *transportHub
+	handlers int
+}
+
+type transportHub struct {
+	mu       sync.Mutex
+	handlers map[int]func([]byte)
+	next     int
+}
+
+func (t *hubTransport) Publish(ctx context.Context, payload []byte) error {
+	t.hub.mu.Lock()
+	handlers := make([]func([]byte), 0, len(t.hub.handlers))
+	for _, handler := range t.hub.handlers {
+		handlers = append(handlers, handler)
+	}
+	t.hub.mu.Unlock()
+	for _, handler := range handlers {
+		handler(payload)
+	}
+	return nil
+}
+
+func (t *hubTransport) Subscribe(handler func(payload []byte)) error {
+	t.hub.mu.Lock()
+	defer t.hub.mu.Unlock()
+	t.hub.next++
+	t.handlers = t.hub.next
+	t.hub.handlers[t.handlers] = handler
+	return nil
+}
+
+func (t *hubTransport) Close() error {
+	t.hub.mu.Lock()
+	defer t.hub.mu.Unlock()
+	delete(t.hub.handlers, t.handlers)
+	return nil
+}
+
+func TestInvalidationTransport(t *testing.T) {
+	ctx := context.Background()
+	hub := &transportHub{handlers: make(map[int]func([]byte))}
+	newNode := func() *Manager {
+		manager, err := NewManager(&CacheConfig{
+			Type:         CacheTypeMemory,
+			DefaultTTL:   time.Minute,
+			Invalidation: &InvalidationConfig{Transport: &hubTransport{hub: hub}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return manager
+	}
+	a, b := newNode(), newNode()
+	defer a.Close()
+
+	for _, m := range []*Manager{a, b} {
+		if err := m.Set(ctx, "article:1", "v", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.Delete(ctx, "article:1"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := b.Exists(ctx, "article:1"); ok {
+		t.Fatal("expected custom transport to invalidate the other node")
+	}
+
+	b.Close()
+	hub.mu.Lock()
+	subscribed := len(hub.handlers)
+	hub.mu.Unlock()
+	if subscribed != 1 {
+		t.Fatalf("expected closed manager to close its transport, %d subscribed", subscribed)
+	}
+}
+
+type sliceChangeSource struct {
+	events [][]byte
+	acked  int
+}
+
+func (s *sliceChangeSource) Next(ctx context.Context) ([]byte, func() error, error) {
+	if len(s.events) == 0 {
+		return nil, nil, io.EOF
+	}
+	payload := s.events[0]
+	s.events = s.events[1:]
+	return payload, func() error { s.acked++; return nil }, nil
+}
+
+func TestCDCInvalidation(t *testing.T) {
+	ctx := context.Background()
+	manager, err := NewManager(&CacheConfig{Type: CacheTypeMemory, DefaultTTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	for _, key := range []string{"user:42", "user:slug:alice", "user:slug:alicia", "users:page:1:size:20", "post:7"} {
+		if err := manager.Set(ctx, key, "v", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	invalidator := NewCDCInvalidator(manager, CDCRule{
+		Table:     "public.users",
+		Keys:      []string{"user:{id}", "user:slug:{slug}"},
+		Resources: []string{"users"},
+	})
+	source := &sliceChangeSource{events: [][]byte{
+		[]byte(`{"schema":{},"payload":{"op":"u","source":{"schema":"public","table":"users"},` +
+			`"before":{"id":42,"slug":"alice"},"after":{"id":42,"slug":"alicia"}}}`),
+		nil,
+		[]byte(`{"op":"c","source":{"table":"posts"},"after":{"id":7}}`),
+		[]byte(`not json`),
+	}}
+	var failed int
+	err = invalidator.Consume(ctx, source, func(payload []byte, err error) { failed++ })
+	if !errors.Is(err, io.EOF) {
+		t.Fatalf("expected source error to stop consuming, got %v", err)
+	}
+	if source.acked != 4 || failed != 1 {
+		t.Fatalf("expected 4 acked and 1 failed event, got %d and %d", source.acked, failed)
+	}
+
+	for key, want := range map[string]bool{
+		"user:42":              false,
+		"user:slug:alice":      false,
+		"user:slug:alicia":     false,
+		"users:page:1:size:20": false,
+		"post:7":               true,
+	} {
+		if ok, _ := manager.Exists(ctx, key); ok != want {
+			t.Fatalf("expected %s exists=%v", key, want)
+		}
+	}
+}
+
+func TestInvalidationHandler(t *testing.T) {
+	ctx := context.Background()
+	clock := NewFakeClock(time.Unix(1700000000, 0))
+	manager, err := NewManager(&CacheConfig{Type: CacheTypeMemory, DefaultTTL: time.Hour, Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+	for _, key := range []string{"page:home", "menu:main", "menu:footer", "articles:page:1:size:20", "user:1"} {
+		if err := manager.Set(ctx, key, "v", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	handler := InvalidationHandler(manager, "s3cret")
+	send := func(secret string, signedAt time.Time, body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/invalidate", strings.NewReader(body))
+		req.Header.Set(TimestampHeader, strconv.FormatInt(signedAt.Unix(), 10))
+		req.Header.Set(SignatureHeader, SignInvalidation(secret, signedAt, []byte(body)))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	body := `{"keys":["page:home"],"patterns":["menu:*"],"tags":["articles"]}`
+	signedAt := clock.Now()
+	if code := send("wrong", signedAt, body); code != http.StatusUnauthorized {
+		t.Fatalf("expected bad signature to be rejected, got %d", code)
+	}
+	clock.Advance(5*time.Minute + time.Second)
+	if code := send("s3cret", signedAt, body); code != http.StatusUnauthorized {
+		t.Fatalf("expected stale timestamp to be rejected, got %d", code)
+	}
+	if code := send("s3cret", clock.Now().Add(5*time.Minute+time.Second), body); code != http.StatusUnauthorized {
+		t.Fatalf("expected future timestamp to be rejected, got %d", code)
+	}
+	empty := InvalidationHandler(manager, "")
+	req := httptest.NewRequest(http.MethodPost, "/invalidate", strings.NewReader(body))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(clock.Now().Unix(), 10))
+	req.Header.Set(SignatureHeader, SignInvalidation("", clock.Now(), []byte(body)))
+	rec := httptest.NewRecorder()
+	empty.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected an empty secret to reject every request, got %d", rec.Code)
+	}
+	if ok, _ := manager.Exists(ctx, "page:home"); !ok {
+		t.Fatal("expected rejected requests to leave the cache intact")
+	}
+	if code := send("s3cret", clock.Now().Add(-5*time.Minute), body); code != http.StatusNoContent {
+		t.Fatalf("expected signed request to succeed, got %d", code)
+	}
+	for key, want := range map[string]bool{
+		"page:home":               false,
+		"menu:main":               false,
+		"menu:footer":             false,
+		"articles:page:1:size:20": false,
+		"user:1":                  true,
+	} {
+		if ok, _ := manager.Exists(ctx, key); ok != want {
+			t.Fatalf("expected %s exists=%v", key, want)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/invalidate", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected GET to be rejected, got %d", rec.Code)
+	}
+}
+
+func TestTableResources(t *testing.T) {
+	ctx := context.Background()
+	manager, err := NewManager(&CacheConfig{
+		Type:           CacheTypeMemory,
+		DefaultTTL:     time.Minute,
+		TableResources: map[string][]string{"users": {"users", "user_profiles", "team_members"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	seed := func() {
+		for _, key := range []string{"users:page:1", "user_profiles:page:1", "team_members:page:1", "posts:page:1"} {
+			if err := manager.Set(ctx, key, "v", 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	check := func(want map[string]bool) {
+		t.Helper()
+		for key, exists := range want {
+			if ok, _ := manager.Exists(ctx, key); ok != exists {
+				t.Fatalf("expected %s exists=%v", key, exists)
+			}
+		}
+	}
+
+	seed()
+	if err := manager.InvalidateTable(ctx, "users"); err != nil {
+		t.Fatal(err)
+	}
+	check(map[string]bool{"users:page:1": false, "user_profiles:page:1": false, "team_members:page:1": false, "posts:page:1": true})
+
+	if err := manager.InvalidateTable(ctx, "posts"); err != nil {
+		t.Fatal(err)
+	}
+	check(map[string]bool{"posts:page:1": false})
+
+	seed()
+	event, err := ParseDebeziumEvent([]byte(`{"op":"d","source":{"table":"users"},"before":{"id":1}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewCDCInvalidator(manager).Apply(ctx, event); err != nil {
+		t.Fatal(err)
+	}
+	check(map[string]bool{"users:page:1": false, "team_members:page:1": false, "posts:page:1": true})
+}
+
+func TestDeferInvalidation(t *testing.T) {
+	ctx := context.Background()
+	manager, err := NewManager(&CacheConfig{Type: CacheTypeMemory, DefaultTTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+	users := manager.WithPrefix("tenant-a")
+	for _, m := range []*Manager{manager, users} {
+		if err := m.Set(ctx, "user:1", "v", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rollback := errors.New("rollback")
+	err = WithInvalidation(ctx, func(ctx context.Context) error {
+		if err := manager.DeferInvalidation(ctx, "user:1"); err != nil {
+			t.Fatal(err)
+		}
+		return rollback
+	})
+	if !errors.Is(err, rollback) {
+		t.Fatalf("expected rollback error, got %v", err)
+	}
+	if ok, _ := manager.Exists(ctx, "user:1"); !ok {
+		t.Fatal("expected rolled back transaction to keep the cache")
+	}
+
+	err = WithInvalidation(ctx, func(ctx context.Context) error {
+		manager.DeferInvalidation(ctx, "user:1")
+		return WithInvalidation(ctx, func(ctx context.Context) error {
+			users.DeferInvalidation(ctx, "user:1")
+			if ok, _ := users.Exists(ctx, "user:1"); !ok {
+				t.Fatal("expected nested commit to wait for the outer transaction")
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []*Manager{manager, users} {
+		if ok, _ := m.Exists(ctx, "user:1"); ok {
+			t.Fatal("expected committed transaction to invalidate every manager")
+		}
+	}
+
+	manager.Set(ctx, "user:2", "v", 0)
+	if err := manager.DeferInvalidation(ctx, "user:2"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := manager.Exists(ctx, "user:2"); ok {
+		t.Fatal("expected invalidation outside a transaction to apply immediately")
+	}
+}
+
+func TestDoubleDelete(t *testing.T) {
+	ctx := context.Background()
+	manager, err := NewManager(&CacheConfig{
+		Type:         CacheTypeMemory,
+		DefaultTTL:   time.Minute,
+		DoubleDelete: 20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manager.Set(ctx, "article:1", "old", 0)
+	manager.Set(ctx, "article:2", "old", 0)
+	if err := manager.Delete(ctx, "article:1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.DeletePattern(ctx, "article:2"); err != nil {
+		t.Fatal(err)
+	}
+	// A racing reader refills the cache with pre-update data.
+	manager.Set(ctx, "article:1", "stale", 0)
+	manager.Set(ctx, "article:2", "stale", 0)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		one, _ := manager.Exists(ctx, "article:1")
+		two, _ := manager.Exists(ctx, "article:2")
+		if !one && !two {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected second delete to purge refilled entries")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	manager.SetDoubleDeleteDelay(time.Hour)
+	manager.Delete(ctx, "article:3")
+	manager.Set(ctx, "article:3", "stale", 0)
+	manager.doubleDelete.close()
+	if ok, _ := manager.Exists(ctx, "article:3"); ok {
+		t.Fatal("expected pending double delete to run on close")
+	}
+	manager.Close()
+}
+
+func TestAuditLog(t *testing.T) {
+	ctx := context.Background()
+	manager, err := NewManager(&CacheConfig{
+		Type:            CacheTypeMemory,
+		DefaultTTL:      time.Minute,
+		AuditLogSize:    2,
+		AuditPersistTTL: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	for _, key := range []string{"articles:1", "articles:2", "menu:main"} {
+		manager.Set(ctx, key, "v", 0)
+	}
+	admin := WithActor(ctx, "alice")
+	manager.Delete(ctx, "menu:main")
+	if _, err := manager.DeletePattern(admin, "articles:*"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.BumpEpoch(admin, "articles"); err != nil {
+		t.Fatal(err)
+	}
+
+	records := manager.AuditLog()
+	if len(records) != 2 {
+		t.Fatalf("expected ring buffer to keep 2 records, got %d", len(records))
+	}
+	purge := records[0]
+	if purge.Op != AuditDeletePattern || purge.Actor != "alice" || purge.Pattern != "articles:*" || purge.Count != 2 {
+		t.Fatalf("unexpected purge record %+v", purge)
+	}
+	if records[1].Op != AuditBumpEpoch || records[1].Pattern != "articles" {
+		t.Fatalf("unexpected epoch record %+v", records[1])
+	}
+
+	stats, err := manager.Stats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logged, ok := stats["audit_log"].([]AuditRecord); !ok || len(logged) != 2 {
+		t.Fatalf("expected audit log in stats, got %v", stats["audit_log"])
+	}
+	persisted, err := manager.Scan(ctx, "audit:*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(persisted) != 3 {
+		t.Fatalf("expected every record persisted to the adapter, got %v", persisted)
+	}
+}
+
+func TestClusterDeletePattern(t *testing.T) {
+	ctx := context.Background()
+	hub := &transportHub{handlers: make(map[int]func([]byte))}
+	var nodes []*Manager
+	for i := 0; i < 3; i++ {
+		manager, err := NewManager(&CacheConfig{
+			Type:         CacheTypeMemory,
+			DefaultTTL:   time.Minute,
+			Invalidation: &InvalidationConfig{Transport: &hubTransport{hub: hub}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer manager.Close()
+		for j := 0; j <= i; j++ {
+			manager.Set(ctx, fmt.Sprintf("articles:%d", j), "v", 0)
+		}
+		nodes = append(nodes, manager)
+	}
+
+	result, err := nodes[0].ClusterDeletePattern(ctx, "articles:*", 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Total != 6 || len(result.Nodes) != 3 || len(result.Failures) != 0 {
+		t.Fatalf("expected 6 keys from 3 nodes, got %+v", result)
+	}
+	if result.Nodes[nodes[2].NodeID()] != 3 {
+		t.Fatalf("expected per-node counts, got %v", result.Nodes)
+	}
+
+	standalone, err := NewManager(&CacheConfig{Type: CacheTypeMemory, DefaultTTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer standalone.Close()
+	standalone.Set(ctx, "articles:1", "v", 0)
+	result, err = standalone.ClusterDeletePattern(ctx, "articles:*", time.Second)
+	if err != nil || result.Total != 1 {
+		t.Fatalf("expected local delete without a bus, got %+v, %v", result, err)
+	}
+}
+
+func TestKeyspaceEvents(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	manager, err := NewManager(&CacheConfig{
+		Type:       CacheTypeMemory,
+		DefaultTTL: time.Minute,
+		Invalidation: &InvalidationConfig{
+			Addr:           server.Addr(),
+			KeyspaceEvents: true,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	for _, key := range []string{"article:1", "article:2", "article:3", "article:4"} {
+		manager.Set(ctx, key, "v", 0)
+	}
+	// Simulate notifications for keys deleted and expired directly in Redis.
+	// Keys outside the cache prefix or database are left alone.
+	server.Publish("__keyevent@3__:del", "eit:cache:article:3")
+	server.Publish("__keyevent@0__:del", "article:4")
+	server.Publish("__keyevent@0__:del", "eit:cache:article:1")
+	server.Publish("__keyevent@0__:expired", "eit:cache:article:2")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		one, _ := manager.Exists(ctx, "article:1")
+		two, _ := manager.Exists(ctx, "article:2")
+		if !one && !two {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected keyspace notifications to purge local entries")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	for _, key := range []string{"article:3", "article:4"} {
+		if ok, _ := manager.Exists(ctx, key); !ok {
+			t.Fatalf("expected %s to survive a notification for another key", key)
+		}
+	}
+}
+
+func TestResourceTTLs(t *testing.T) {
+	ctx := context.Background()
+	manager, err := NewManager(&CacheConfig{
+		Type:       CacheTypeMemory,
+		DefaultTTL: time.Minute,
+		ResourceTTLs: map[string]time.Duration{
+			"articles":        30 * time.Second,
+			"articles:pinned": time.Hour,
+			"countries":       24 * time.Hour,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+	adapter := manager.Adapter().(*MemoryCacheAdapter)
+
+	load := func() (string, error) { return "v", nil }
+	for _, key := range []string{"articles:1", "articles:pinned:1", "countries:all", "menu:main"} {
+		if _, err := Query(ctx, manager, key, load); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := Query(ctx, manager, "countries:eu", load, WithTTL(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := QueryWithPagination(ctx, manager, "articles", nil, &PaginationParams{Page: 1, PageSize: 10, UseCache: true},
+		func() ([]string, int64, error) { return []string{"a"}, 1, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, want := range map[string]time.Duration{
+		"articles:1":        30 * time.Second,
+		"articles:pinned:1": time.Hour,
+		"countries:all":     24 * time.Hour,
+		"menu:main":         time.Minute,
+		"countries:eu":      time.Second,
+		resp.CacheKey:       30 * time.Second,
+	} {
+		info, ok := adapter.Inspect(key)
+		if !ok {
+			t.Fatalf("expected %s to be cached", key)
+		}
+		if info.TTL > want || info.TTL < want-time.Second {
+			t.Fatalf("expected %s TTL near %s, got %s", key, want, info.TTL)
+		}
+	}
+}
+
+func TestInvalidateByIDs(t *testing.T) {
+	ctx := context.Background()
+	manager, err := NewManager(&CacheConfig{Type: CacheTypeMemory, DefaultTTL: time.Minute, ResourceEpochs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	for _, id := range []int{1, 2, 3} {
+		manager.Set(ctx, GenerateDetailKey("articles", id), "v", 0)
+	}
+	page := func() *PaginationResponse[string] {
+		resp, err := QueryWithPagination(ctx, manager, "articles", nil, &PaginationParams{Page: 1, PageSize: 10, UseCache: true},
+			func() ([]string, int64, error) { return []string{"a"}, 1, nil })
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	page()
+	if !page().FromCache {
+		t.Fatal("expected list page to be cached")
+	}
+
+	if err := InvalidateByIDs(ctx, manager, "articles", 1, "3"); err != nil {
+		t.Fatal(err)
+	}
+	for id, want := range map[int]bool{1: false, 2: true, 3: false} {
+		if ok, _ := manager.Exists(ctx, GenerateDetailKey("articles", id)); ok != want {
+			t.Fatalf("expected article %d exists=%v", id, want)
+		}
+	}
+	if page().FromCache {
+		t.Fatal("expected list pages to be invalidated")
+	}
+}
+
+func TestScheduledExpiry(t *testing.T) {
+	ctx := context.Background()
+	clock := NewFakeClock(time.Unix(1700000000, 0))
+	manager, err := NewManager(&CacheConfig{Type: CacheTypeMemory, DefaultTTL: time.Hour, TTLJitter: 0.5, Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+	adapter := manager.Adapter().(*MemoryCacheAdapter)
+
+	at := clock.Now().Add(10 * time.Minute)
+	if err := manager.SetWithExpireAt(ctx, "embargo:1", "v", at); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := adapter.Inspect("embargo:1"); !info.ExpiresAt.Equal(at) {
+		t.Fatalf("expected exact expiry at %s, got %s", at, info.ExpiresAt)
+	}
+	if err := manager.SetWithExpireAt(ctx, "embargo:1", "v", clock.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := manager.Exists(ctx, "embargo:1"); ok {
+		t.Fatal("expected past expiry to delete the key")
+	}
+
+	manager.Set(ctx, "article:1", "draft", 0)
+	if err := manager.ScheduleInvalidation(ctx, "article:1", clock.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Query(ctx, manager, "article:2", func() (string, error) { return "v", nil }); err != nil {
+		t.Fatal(err)
+	}
+	manager.ScheduleInvalidation(ctx, "article:2", clock.Now().Add(time.Hour))
+	manager.Set(ctx, "article:2", "refill", 0)
+	if info, _ := adapter.Inspect("article:2"); info.TTL > time.Hour {
+		t.Fatalf("expected refill capped by the scheduled invalidation, got %s", info.TTL)
+	}
+
+	clock.Advance(59 * time.Second)
+	if ok, _ := manager.Exists(ctx, "article:1"); !ok {
+		t.Fatal("expected the key to remain before its scheduled invalidation")
+	}
+	clock.Advance(time.Second)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if ok, _ := manager.Exists(ctx, "article:1"); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected scheduled invalidation to delete the key")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	clock.Advance(29 * time.Minute)
+	manager.Set(ctx, "article:2", "refill", 0)
+	if info, _ := adapter.Inspect("article:2"); info.TTL != 30*time.Minute {
+		t.Fatalf("expected refill capped to the remaining 30m, got %s", info.TTL)
+	}
+}
+
+func TestInvalidatePagesFor(t *testing.T) {
+	ctx := context.Background()
+	manager, err := NewManager(&CacheConfig{Type: CacheTypeMemory, DefaultTTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	params := &PaginationParams{Page: 1, PageSize: 10, UseCache: true}
+	load := func() ([]string, int64, error) { return []string{"a"}, 1, nil }
+	filterSets := []map[string]interface{}{
+		nil,
+		{"category": "news"},
+		{"category": "sports"},
+		{"category": "news", "status": []string{"draft", "review"}},
+		{"author": 7},
+	}
+	keys := make([]string, len(filterSets))
+	for i, filters := range filterSets {
+		resp, err := QueryWithPagination(ctx, manager, "articles", filters, params, load)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i] = resp.CacheKey
+	}
+
+	// A published news article moves out of the draft/review listing.
+	n, err := InvalidatePagesFor(ctx, manager, "articles",
+		map[string]interface{}{"category": "news", "status": "review", "author": 3},
+		map[string]interface{}{"category": "news", "status": "published", "author": 3},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("expected 3 affected pages, got %d", n)
+	}
+	for i, want := range []bool{false, false, true, false, true} {
+		if ok, _ := manager.Exists(ctx, keys[i]); ok != want {
+			t.Fatalf("expected page %v exists=%v", filterSets[i], want)
+		}
+	}
+}
+
+func TestPageIndexBounded(t *testing.T) {
+	ctx := context.Background()
+	clock := NewFakeClock(time.Unix(0, 0))
+	manager, err := NewManager(&CacheConfig{Type: CacheTypeMemory, DefaultTTL: time.Minute, Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	params := &PaginationParams{Page: 1, PageSize: 10, UseCache: true}
+	load := func() ([]string, int64, error) { return []string{"a"}, 1, nil }
+	filters := map[string]interface{}{"category": "news"}
+	if _, err := QueryWithPagination(ctx, manager, "articles", filters, params, load); err != nil {
+		t.Fatal(err)
+	}
+	filters["category"] = "sports"
+	if n, _ := InvalidatePagesFor(ctx, manager, "articles", map[string]interface{}{"category": "news"}); n != 1 {
+		t.Fatalf("expected the recorded filters to be a copy, got %d pages", n)
+	}
+
+	resp, _ := QueryWithPagination(ctx, manager, "articles", nil, params, load)
+	if err := manager.Delete(ctx, resp.CacheKey); err != nil {
+		t.Fatal(err)
+	}
+	if n := manager.pages.order.Len(); n != 0 {
+		t.Fatalf("expected Delete to forget the page, %d left", n)
+	}
+	_, _ = QueryWithPagination(ctx, manager, "articles", nil, params, load)
+	_, _ = manager.DeletePattern(ctx, "articles:")
+	if n := manager.pages.order.Len(); n != 0 {
+		t.Fatalf("expected DeletePattern to forget the page, %d left", n)
+	}
+	_, _ = QueryWithPagination(ctx, manager, "articles", nil, params, load)
+	clock.Advance(2 * time.Minute)
+	if n, _ := InvalidatePagesFor(ctx, manager, "articles", map[string]interface{}{}); n != 0 {
+		t.Fatalf("expected expired pages to be skipped, got %d", n)
+	}
+	if n := manager.pages.order.Len(); n != 0 {
+		t.Fatalf("expected expired pages to be forgotten, %d left", n)
+	}
+
+	index := newPageIndex()
+	index.limit = 2
+	for _, key := range []string{"a", "b", "c"} {
+		index.record("r", key, key, nil, time.Time{})
+	}
+	if keys := index.take("r", []map[string]interface{}{{}}, time.Time{}); len(keys) != 2 {
+		t.Fatalf("expected the index to keep the 2 most recent pages, got %v", keys)
+	}
+}
+
+type recordedSpan struct {
+	op, key string
+	info    SpanInfo
+}
+
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []recordedSpan
+}
+
+func (r *recordingTracer) Start(ctx context.Context, op, key string) (context.Context, func(SpanInfo)) {
+	return ctx, func(info SpanInfo) {
+		r.mu.Lock()
+		r.spans = append(r.spans, recordedSpan{op: op, key: key, info: info})
+		r.mu.Unlock()
+	}
+}
+
+func TestTracer(t *testing.T) {
+	ctx := context.Background()
+	tracer := &recordingTracer{}
+	manager, err := NewManager(&CacheConfig{Type: CacheTypeMemory, DefaultTTL: time.Minute, Tracer: tracer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	load := func() (string, error) { return "value", nil }
+	Query(ctx, manager, "article:1", load)
+	Query(ctx, manager, "article:1", load)
+	manager.Set(ctx, "article:2", "v", 0)
+	var dest string
+	manager.Get(ctx, "article:2", &dest)
+	manager.Get(ctx, "article:3", &dest)
+	manager.DeletePattern(ctx, "article:*")
+
+	want := []recordedSpan{
+		{op: "query", key: "article:1", info: SpanInfo{Backend: "memory", Size: 7}},
+		{op: "query", key: "article:1", info: SpanInfo{Backend: "memory", Hit: true, Size: 7}},
+		{op: "set", key: "article:2", info: SpanInfo{Backend: "memory", Size: 3}},
+		{op: "get", key: "article:2", info: SpanInfo{Backend: "memory", Hit: true, Size: 3}},
+		{op: "get", key: "article:3", info: SpanInfo{Backend: "memory"}},
+		{op: "delete_pattern", key: "article:*", info: SpanInfo{Backend: "memory", Count: 2}},
+	}
+	if len(tracer.spans) != len(want) {
+		t.Fatalf("expected %d spans, got %+v", len(want), tracer.spans)
+	}
+	for i, span := range tracer.spans {
+		if span.op != want[i].op || span.key != want[i].key || span.info.Backend != want[i].info.Backend ||
+			span.info.Hit != want[i].info.Hit || span.info.Count != want[i].info.Count || (want[i].info.Size > 0) != (span.info.Size > 0) {
+			t.Fatalf("span %d: expected %+v, got %+v", i, want[i], span)
+		}
+	}
+}
+
+func TestStatsHandler(t *testing.T) {
+	ctx := context.Background()
+	manager, err := NewManager(&CacheConfig{Type: CacheTypeMemory, DefaultTTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	var dest string
+	manager.Set(ctx, "article:1", "v", 0)
+	manager.Get(ctx, "article:1", &dest)
+
+	recorder := httptest.NewRecorder()
+	manager.WithPrefix("app:").StatsHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/internal/cache", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if ct := recorder.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+
+	var body struct {
+		Adapter map[string]interface{} `json:"adapter"`
+		Metrics map[string]interface{} `json:"metrics"`
+		Config  map[string]interface{} `json:"config"`
+		Uptime  float64                `json:"uptime_seconds"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Adapter == nil || body.Metrics == nil {
+		t.Fatalf("expected adapter stats and metrics, got %s", recorder.Body.String())
+	}
+	if body.Config["backend"] != "memory" || body.Config["namespace"] != "app:" || body.Config["default_ttl"] != "1m0s" {
+		t.Fatalf("unexpected config summary %+v", body.Config)
+	}
+	if body.Uptime < 0 {
+		t.Fatalf("unexpected uptime %v", body.Uptime)
+	}
+}
+
+func TestMonitorLatencyPercentiles(t *testing.T) {
+	monitor := NewMonitor()
+	for i := 1; i <= 100; i++ {
+		monitor.RecordHit(time.Duration(i) * time.Millisecond)
+	}
+	monitor.RecordMiss(2 * time.Second)
+
+	within := func(got, want time.Duration) bool {
+		return got >= want && got <= want+want/4
+	}
+	metrics := monitor.GetMetrics()
+	hit := metrics.HitLatency
+	if hit.Count != 100 || !within(hit.P50, 50*time.Millisecond) || !within(hit.P90, 90*time.Millisecond) ||
+		!within(hit.P99, 99*time.Millisecond) || hit.Max != 100*time.Millisecond {
+		t.Fatalf("unexpected hit latency %+v", hit)
+	}
+	if miss := metrics.MissLatency; miss.Count != 1 || miss.P50 != 2*time.Second || miss.Max != 2*time.Second {
+		t.Fatalf("unexpected miss latency %+v", miss)
+	}
+
+	monitor.Reset()
+	if metrics := monitor.GetMetrics(); metrics.HitLatency.Count != 0 || metrics.HitLatency.Max != 0 {
+		t.Fatalf("expected reset latency, got %+v", metrics.HitLatency)
+	}
+}
+
+func TestMonitorAverageWindow(t *testing.T) {
+	monitor := NewMonitor()
+	for i := 0; i < 1000; i++ {
+		monitor.RecordHit(time.Second)
+	}
+	if avg := monitor.GetMetrics().AvgResponseTime; avg != time.Second {
+		t.Fatalf("expected 1s average, got %s", avg)
+	}
+	for i := 0; i < 500; i++ {
+		monitor.RecordMiss(3 * time.Second)
+	}
+	if avg := monitor.GetMetrics().AvgResponseTime; avg != 2*time.Second {
+		t.Fatalf("expected 2s average over the last 1000, got %s", avg)
+	}
+	for i := 0; i < 1000; i++ {
+		monitor.RecordHit(time.Millisecond)
+	}
+	if avg := monitor.GetMetrics().AvgResponseTime; avg != time.Millisecond {
+		t.Fatalf("expected 1ms average after the window rolled, got %s", avg)
+	}
+}
+
+func TestMetricsByPrefix(t *testing.T) {
+	ctx := context.Background()
+	manager, err := NewManager(&CacheConfig{Type: CacheTypeMemory, DefaultTTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	load := func() (string, error) { return "value", nil }
+	for i := 0; i < 3; i++ {
+		Query(ctx, manager, "article:1", load)
+	}
+	Query(ctx, manager, "search:a", load)
+	Query(ctx, manager, "search:b", load)
+
+	byPrefix := manager.Monitor().GetMetricsByPrefix()
+	if article := byPrefix["article"]; article.HitCount != 2 || article.MissCount != 1 || article.HitLatency.Count != 2 {
+		t.Fatalf("unexpected article metrics %+v", article)
+	}
+	if search := byPrefix["search"]; search.HitCount != 0 || search.MissCount != 2 || search.HitRatio != 0 {
+		t.Fatalf("unexpected search metrics %+v", search)
+	}
+	if metrics := manager.Monitor().GetMetrics(); metrics.HitCount != 2 || metrics.MissCount != 3 {
+		t.Fatalf("expected global counts to include prefixed records, got %+v", metrics)
+	}
+
+	monitor := NewMonitor()
+	for i := 0; i < maxMetricPrefixes+10; i++ {
+		monitor.RecordKeyMiss(fmt.Sprintf("p%d:x", i), time.Millisecond)
+	}
+	byPrefix = monitor.GetMetricsByPrefix()
+	if len(byPrefix) != maxMetricPrefixes+1 || byPrefix[OtherPrefix].MissCount != 10 {
+		t.Fatalf("expected overflow prefixes under %s, got %d prefixes", OtherPrefix, len(byPrefix))
+	}
+}
+
+func TestHotKeys(t *testing.T) {
+	ctx := context.Background()
+	manager, err := NewManager(&CacheConfig{Type: CacheTypeMemory, DefaultTTL: time.Minute, HotKeys: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	load := func() (string, error) { return "value", nil }
+	for i := 0; i < 50; i++ {
+		Query(ctx, manager, "article:1", load)
+	}
+	for i := 0; i < 20; i++ {
+		Query(ctx, manager, "article:2", load)
+	}
+	for i := 0; i < 10; i++ {
+		Query(ctx, manager, fmt.Sprintf("cold:%d", i), load)
+	}
+
+	hot := manager.Monitor().HotKeys(2)
+	if len(hot) != 2 || hot[0].Key != "article:1" || hot[0].Count != 50 || hot[0].Error != 0 ||
+		hot[1].Key != "article:2" || hot[1].Count != 20 {
+		t.Fatalf("unexpected hot keys %+v", hot)
+	}
+	if all := manager.Monitor().HotKeys(0); len(all) != 3 {
+		t.Fatalf("expected capacity-bound hot keys, got %+v", all)
+	}
+
+	manager.Monitor().Reset()
+	if hot := manager.Monitor().HotKeys(0); len(hot) != 0 {
+		t.Fatalf("expected reset hot keys, got %+v", hot)
+	}
+	if NewMonitor().HotKeys(10) != nil {
+		t.Fatal("expected nil hot keys when tracking is disabled")
+	}
+	if err := (&CacheConfig{Type: CacheTypeMemory, HotKeySampleRate: 2}).Validate(); err == nil {
+		t.Fatal("expected invalid sample rate to fail validation")
+	}
+}
+
+func TestBigKeys(t *testing.T) {
+	ctx := context.Background()
+	var logs strings.Builder
+	manager, err := NewManager(&CacheConfig{
+		Type:            CacheTypeMemory,
+		DefaultTTL:      time.Minute,
+		BigKeyThreshold: 1000,
+		Logger:          slog.New(slog.NewTextHandler(&logs, nil)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	manager.Set(ctx, "small", "v", 0)
+	manager.Set(ctx, "large", strings.Repeat("x", 5000), 0)
+	manager.Set(ctx, "medium", strings.Repeat("x", 500), 0)
+
+	big := manager.Monitor().BigKeys(2)
+	if len(big) != 2 || big[0].Key != "large" || big[0].Size < 5000 || big[1].Key != "medium" {
+		t.Fatalf("unexpected big keys %+v", big)
+	}
+	if size := manager.Monitor().GetMetrics().PayloadSize; size.Count != 3 || size.Max != int64(big[0].Size) {
+		t.Fatalf("unexpected payload sizes %+v", size)
+	}
+	if !strings.Contains(logs.String(), "key=large") || strings.Contains(logs.String(), "medium") {
+		t.Fatalf("expected only the large key to be logged, got %q", logs.String())
+	}
+
+	stats, err := manager.Stats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := stats["big_keys"].([]BigKey); !ok {
+		t.Fatalf("expected big keys in stats, got %+v", stats)
+	}
+}
+
+func TestSlowLog(t *testing.T) {
+	ctx := context.Background()
+	manager, err := NewManager(&CacheConfig{Type: CacheTypeMemory, DefaultTTL: time.Minute, SlowLogThreshold: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	Query(ctx, manager, "report:1", func() (string, error) {
+		time.Sleep(20 * time.Millisecond)
+		return "value", nil
+	})
+	var dest string
+	manager.Get(ctx, "report:1", &dest)
+
+	entries := manager.SlowLog()
+	if len(entries) != 2 {
+		t.Fatalf("expected load and query entries, got %+v", entries)
+	}
+	if entries[0].Op != "load" || entries[1].Op != "query" {
+		t.Fatalf("unexpected slow log order %+v", entries)
+	}
+	for _, entry := range entries {
+		if entry.Key != "report:1" || entry.Backend != "memory" || entry.Duration < 20*time.Millisecond {
+			t.Fatalf("unexpected slow log entry %+v", entry)
+		}
+	}
+
+	manager.ResetSlowLog()
+	manager.SetSlowLogThreshold(0)
+	Query(ctx, manager, "report:2", func() (string, error) {
+		time.Sleep(20 * time.Millisecond)
+		return "value", nil
+	})
+	if entries := manager.SlowLog(); len(entries) != 0 {
+		t.Fatalf("expected disabled slow log, got %+v", entries)
+	}
+}
+
+// testRedisError is an error reply from the Redis server.
+type testRedisError string
+
+func (e testRedisError) Error() string { return string(e) }
+func (testRedisError) RedisError()     {}
+
+func TestErrorsByType(t *testing.T) {
+	oversized := writeError(testRedisError("ERR string exceeds maximum allowed size (proto-max-bulk-len)"))
+	cases := map[error]string{
+		context.DeadlineExceeded:                            ErrorTimeout,
+		fmt.Errorf("get: %w", ErrCircuitOpen):               ErrorConnection,
+		&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}: ErrorConnection,
+		ErrCorruptPayload:                                   ErrorSerialization,
+		ErrEntryTooLarge:                                    ErrorOversize,
+		oversized:                                           ErrorOversize,
+		errors.New("value exceeds maximum"):                 ErrorOther,
+		errors.New("boom"):                                  ErrorOther,
+	}
+	for err, want := range cases {
+		if got := ClassifyError(err); got != want {
+			t.Fatalf("ClassifyError(%v) = %q, want %q", err, got, want)
+		}
+	}
+
+	ctx := context.Background()
+	manager, err := NewManager(&CacheConfig{Type: CacheTypeMemory, DefaultTTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	Query(ctx, manager, "bad", func() (chan int, error) { return make(chan int), nil })
+	manager.reportError(ctx, OpGet, "slow", context.DeadlineExceeded)
+	manager.reportError(ctx, OpGet, "down", &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED})
+	manager.reportError(ctx, OpGet, "open", ErrCircuitOpen)
+
+	metrics := manager.Monitor().GetMetrics()
+	byType := metrics.ErrorsByType
+	if byType[ErrorSerialization] != 1 || byType[ErrorTimeout] != 1 || byType[ErrorConnection] != 1 {
+		t.Fatalf("unexpected error counters %+v", byType)
+	}
+	if metrics.BreakerBypassCount != 1 {
+		t.Fatalf("expected the breaker bypass to be counted apart, got %d", metrics.BreakerBypassCount)
+	}
+}
+
+func TestPoolStats(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	manager, err := NewManager(&CacheConfig{Type: CacheTypeRedis, Addr: server.Addr(), DefaultTTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	manager.Set(ctx, "article:1", "v", 0)
+	var dest string
+	manager.Get(ctx, "article:1", &dest)
+
+	pool, ok := manager.PoolStats()
+	if !ok || pool.TotalConns == 0 || pool.Hits+pool.Misses == 0 {
+		t.Fatalf("unexpected pool stats %+v (ok=%v)", pool, ok)
+	}
+	memory, err := NewManager(&CacheConfig{Type: CacheTypeMemory, DefaultTTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer memory.Close()
+	if _, ok := memory.PoolStats(); ok {
+		t.Fatal("expected no pool stats for the memory adapter")
+	}
+}
+
+func TestWindowStats(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1700000000, 0))
+	monitor := NewMonitor()
+	monitor.SetClock(clock)
+
+	for i := 0; i < 90; i++ {
+		monitor.RecordHit(time.Millisecond)
+	}
+	monitor.RecordMiss(time.Millisecond)
+	clock.Advance(10 * time.Minute)
+	for i := 0; i < 6; i++ {
+		monitor.RecordMiss(time.Millisecond)
+	}
+	monitor.RecordHit(time.Millisecond)
+	monitor.RecordHit(time.Millisecond)
+	monitor.RecordHit(time.Millisecond)
+
+	recent := monitor.WindowStats(time.Minute)
+	if recent.HitCount != 3 || recent.MissCount != 6 || recent.HitRatio != 1.0/3.0 || recent.Throughput != 9.0/60 {
+		t.Fatalf("unexpected 1m window %+v", recent)
+	}
+	windows := monitor.GetMetrics().Windows
+	if w := windows["1h"]; w.HitCount != 93 || w.MissCount != 7 {
+		t.Fatalf("unexpected 1h window %+v", w)
+	}
+	if w := windows["5m"]; w.HitCount != 3 {
+		t.Fatalf("unexpected 5m window %+v", w)
+	}
+
+	clock.Advance(2 * time.Hour)
+	if w := monitor.WindowStats(time.Hour); w.HitCount != 0 || w.MissCount != 0 {
+		t.Fatalf("expected empty window after two hours, got %+v", w)
+	}
+	if ratio := monitor.HitRatio(); ratio != 93.0/100.0 {
+		t.Fatalf("expected lifetime ratio to be kept, got %f", ratio)
+	}
+}
+
+func TestLogger(t *testing.T) {
+	var logs strings.Builder
+	manager, err := NewManager(&CacheConfig{Type: CacheTypeMemory, DefaultTTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+	manager.SetLogger(slog.New(slog.NewTextHandler(&logs, nil)))
+
+	warmer := NewCacheWarmer(manager, time.Minute)
+	job := WarmJobConfig{Loader: func(context.Context) (interface{}, error) {
+		return nil, errors.New("db down")
+	}}
+	warmer.run(context.Background(), "report:1", job)
+	if out := logs.String(); !strings.Contains(out, "level=WARN") || !strings.Contains(out, "key=report:1") ||
+		!strings.Contains(out, `error="db down"`) {
+		t.Fatalf("unexpected manager log output %q", out)
+	}
+
+	var own strings.Builder
+	warmer.SetLogger(slog.New(slog.NewTextHandler(&own, nil)))
+	warmer.run(context.Background(), "report:1", job)
+	if !strings.Contains(own.String(), "cache warmup job failed") {
+		t.Fatalf("expected warmer logger to be used, got %q", own.String())
+	}
+}
+
+func TestEvents(t *testing.T) {
+	ctx := context.Background()
+	manager, err := NewManager(&CacheConfig{Type: CacheTypeMemory, DefaultTTL: time.Minute, EventBuffer: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manager.Set(ctx, "ignored", "v", 0)
+	events := manager.Events()
+	if manager.Events() != events {
+		t.Fatal("expected Events to return the same channel")
+	}
+
+	manager.Set(ctx, "article:1", "v", 0)
+	var dest string
+	manager.Get(ctx, "article:1", &dest)
+	Query(ctx, manager, "article:2", func() (string, error) { return "v", nil })
+	manager.Delete(ctx, "article:1")
+	manager.reportError(ctx, OpGet, "article:3", errors.New("boom"))
+
+	var got []string
+	for len(events) > 0 {
+		event := <-events
+		got = append(got, event.Type+" "+event.Key)
+	}
+	want := []string{"set article:2", "delete article:1", "error article:3"}
+	if len(got) < len(want) || strings.Join(got[len(got)-len(want):], ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected events %v", got)
+	}
+	if len(got) != 4 || manager.EventsDropped() == 0 {
+		t.Fatalf("expected oldest events to be dropped, got %v (dropped %d)", got, manager.EventsDropped())
+	}
+
+	manager.Close()
+	if _, ok := <-events; ok {
+		t.Fatal("expected events channel to be closed")
+	}
+}
+
+func TestWindowConcurrent(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1700000000, 0))
+	monitor := NewMonitor()
+	monitor.SetClock(clock)
+	monitor.SetSampleRate(0.01)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				if i == 0 && j%100 == 0 {
+					clock.Advance(time.Second)
+				}
+				if j%4 == 0 {
+					monitor.RecordMiss(time.Millisecond)
+				} else {
+					monitor.RecordHit(time.Millisecond)
+				}
+				_ = monitor.WindowStats(time.Minute)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if w := monitor.WindowStats(time.Hour); w.HitCount != 6000 || w.MissCount != 2000 {
+		t.Fatalf("expected every concurrent hit and miss counted, got %+v", w)
+	}
+}
+
+func TestMetricSampling(t *testing.T) {
+	monitor := NewMonitor()
+	monitor.SetClock(NewFakeClock(time.Unix(1700000000, 0)))
+	monitor.SetSampleRate(0.1)
+	for i := 0; i < 10000; i++ {
+		monitor.RecordKeyHit("article:1", time.Millisecond)
+	}
+	monitor.RecordMiss(time.Millisecond)
+
+	metrics := monitor.GetMetrics()
+	if metrics.HitCount != 10000 || metrics.MissCount != 1 {
+		t.Fatalf("expected exact counts, got %d hits and %d misses", metrics.HitCount, metrics.MissCount)
+	}
+	if n := metrics.HitLatency.Count; n < 700 || n > 1300 {
+		t.Fatalf("expected about 1000 sampled hits, got %d", n)
+	}
+	if ratio := monitor.HitRatio(); ratio != 10000.0/10001.0 {
+		t.Fatalf("unexpected hit ratio %f", ratio)
+	}
+	if w := monitor.WindowStats(time.Minute); w.HitCount != 10000 || w.MissCount != 1 || w.Throughput != 10001.0/60 {
+		t.Fatalf("expected exact window counts, got %+v", w)
+	}
+
+	monitor.Reset()
+	monitor.SetSampleRate(0)
+	monitor.RecordHit(time.Millisecond)
+	if metrics := monitor.GetMetrics(); metrics.HitCount != 1 || metrics.HitLatency.Count != 1 {
+		t.Fatalf("expected every operation recorded after reset, got %+v", metrics)
+	}
+}
+
+func TestAlerts(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1700000000, 0))
+	monitor := NewMonitor()
+	monitor.SetClock(clock)
+
+	alerts := make(chan AlertEvent, 10)
+	stopRatio := monitor.OnHitRatioBelow(0.5, time.Minute, func(e AlertEvent) { alerts <- e })
+	stopErrors := monitor.OnErrorRateAbove(0.1, time.Minute, func(e AlertEvent) { alerts <- e })
+	defer stopErrors()
+
+	waiters := 2
+	tick := func() {
+		deadline := time.Now().Add(time.Second)
+		for clock.Waiters() < waiters {
+			if time.Now().After(deadline) {
+				t.Fatal("alert loops did not wait on the clock")
+			}
+			time.Sleep(time.Millisecond)
+		}
+		clock.Advance(6 * time.Second)
+	}
+
+	tick()
+	monitor.RecordMiss(time.Millisecond)
+	monitor.RecordMiss(time.Millisecond)
+	monitor.RecordHit(time.Millisecond)
+	for i := 0; i < 8; i++ {
+		tick()
+	}
+	select {
+	case e := <-alerts:
+		t.Fatalf("expected no alert before a full window, got %+v", e)
+	default:
+	}
+
+	tick()
+	select {
+	case e := <-alerts:
+		if e.Rule != AlertHitRatioBelow || !e.Firing || e.Value != 1.0/3.0 {
+			t.Fatalf("unexpected alert %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected hit ratio alert")
+	}
+
+	for i := 0; i < 10; i++ {
+		monitor.RecordHit(time.Millisecond)
+	}
+	tick()
+	select {
+	case e := <-alerts:
+		if e.Rule != AlertHitRatioBelow || e.Firing {
+			t.Fatalf("expected resolved hit ratio alert, got %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected hit ratio alert to resolve")
+	}
+
+	stopRatio()
+	tick()
+	waiters = 1
+	monitor.RecordError(context.DeadlineExceeded)
+	monitor.RecordError(context.DeadlineExceeded)
+	tick()
+	select {
+	case e := <-alerts:
+		if e.Rule != AlertErrorRateAbove || !e.Firing || e.Value != 2.0/10.0 {
+			t.Fatalf("unexpected error alert %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected error rate alert")
+	}
+}
+
+func TestAlertsSampled(t *testing.T) {
+	ctx := context.Background()
+	manager, err := NewManager(&CacheConfig{Type: CacheTypeMemory, DefaultTTL: time.Hour, MetricSampleRate: 0.1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+	clock := NewFakeClock(time.Unix(1700000000, 0))
+	monitor := manager.Monitor()
+	monitor.SetClock(clock)
+
+	alerts := make(chan AlertEvent, 10)
+	stop := monitor.OnErrorRateAbove(0.1, time.Minute, func(e AlertEvent) { alerts <- e })
+	defer stop()
+	wait := func() {
+		deadline := time.Now().Add(time.Second)
+		for clock.Waiters() < 1 {
+			if time.Now().After(deadline) {
+				t.Fatal("alert loop did not wait on the clock")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	tick := func() {
+		wait()
+		clock.Advance(6 * time.Second)
+	}
+
+	tick()
+	manager.Set(ctx, "article:1", "v", 0)
+	var dest string
+	for i := 0; i < 1000; i++ {
+		manager.Get(ctx, "article:1", &dest)
+	}
+	for i := 0; i < 20; i++ {
+		monitor.RecordError(context.DeadlineExceeded)
+	}
+	for i := 0; i < 9; i++ {
+		tick()
+	}
+	wait()
+	select {
+	case e := <-alerts:
+		t.Fatalf("expected no alert at a 2%% error rate, got %+v", e)
+	default:
+	}
+	if w := monitor.WindowStats(time.Minute); w.ErrorRate != 20.0/1000.0 {
+		t.Fatalf("expected error rate over every hit, got %+v", w)
+	}
+}
+
+func TestManagerOperationMetrics(t *testing.T) {
+	ctx := context.Background()
+	manager, err := NewManager(&CacheConfig{Type: CacheTypeMemory, DefaultTTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	manager.Set(ctx, "article:1", "v", 0)
+	var dest string
+	manager.Get(ctx, "article:1", &dest)
+	manager.Get(ctx, "article:2", &dest)
+	manager.Delete(ctx, "article:1")
+	manager.DeletePattern(ctx, "article:*")
+	if err := manager.Set(ctx, "bad", make(chan int), 0); err == nil {
+		t.Fatal("expected encoding a channel to fail")
+	}
+
+	metrics := manager.Monitor().GetMetrics()
+	if metrics.HitCount != 1 || metrics.MissCount != 1 {
+		t.Fatalf("expected Get to be counted, got %d hits and %d misses", metrics.HitCount, metrics.MissCount)
+	}
+	for _, op := range []string{OpLatencySet, OpLatencyDelete, OpLatencyDeletePattern} {
+		if metrics.OpLatency[op].Count != 1 {
+			t.Fatalf("expected one %s timing, got %+v", op, metrics.OpLatency)
+		}
+	}
+	if metrics.SetErrorCount != 1 || metrics.ErrorsByType[ErrorSerialization] != 1 {
+		t.Fatalf("expected the failed Set to be counted, got %+v", metrics)
+	}
+
+	adapter := NewMonitoredAdapter(NewMemoryCacheAdapter(time.Minute), nil)
+	defer adapter.Close()
+	adapter.Set(ctx, "k", []byte("value"), time.Minute)
+	adapter.Get(ctx, "k")
+	adapter.Get(ctx, "missing")
+	adapter.Delete(ctx, "k")
+	metrics = adapter.Monitor().GetMetrics()
+	if metrics.HitCount != 1 || metrics.MissCount != 1 || metrics.PayloadSize.Max != 5 || metrics.OpLatency[OpLatencyDelete].Count != 1 {
+		t.Fatalf("unexpected adapter metrics %+v", metrics)
+	}
+}
+
+func TestQueryWithKeyset(t *testing.T) {
+	ctx := context.Background()
+	manager, err := NewManager(&CacheConfig{Type: CacheTypeMemory, DefaultTTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	params := &KeysetParams{Columns: []string{"created_at", "id"}, After: []interface{}{"2024-01-01", 7}, Desc: true}
+	where, args := params.Where()
+	if where != "(created_at < ?) OR (created_at = ? AND id < ?)" || len(args) != 3 || args[2] != 7 {
+		t.Fatalf("unexpected where %q %v", where, args)
+	}
+	if order := params.OrderBy(); order != "created_at DESC, id DESC" {
+		t.Fatalf("unexpected order %q", order)
+	}
+	if _, err := NormalizeKeysetParams(&KeysetParams{Columns: []string{"id; DROP TABLE users"}}); !errors.Is(err, ErrInvalidKeysetColumn) {
+		t.Fatalf("expected invalid column error, got %v", err)
+	}
+
+	rows := []int{1, 2, 3, 4, 5}
+	calls := 0
+	query := func(p *KeysetParams) ([]int, error) {
+		calls++
+		var out []int
+		for _, id := range rows {
+			if len(p.After) == 0 || id > int(p.After[0].(float64)) {
+				out = append(out, id)
+			}
+			if len(out) == p.Limit() {
+				break
+			}
+		}
+		return out, nil
+	}
+	cursor := func(id int) []interface{} { return []interface{}{float64(id)} }
+
+	first, err := QueryWithKeyset(ctx, manager, "items", nil, &KeysetParams{Columns: []string{"id"}, PageSize: 2, UseCache: true}, query, cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first.Data) != 2 || !first.HasMore || first.NextCursor[0] != 2.0 || first.FromCache {
+		t.Fatalf("unexpected first page %+v", first)
+	}
+	cached, _ := QueryWithKeyset(ctx, manager, "items", nil, &KeysetParams{Columns: []string{"id"}, PageSize: 2, UseCache: true}, query, cursor)
+	if !cached.FromCache || calls != 1 || cached.NextCursor[0] != int64(2) {
+		t.Fatalf("expected cached first page, got %+v after %d calls", cached, calls)
+	}
+	if metrics := manager.Monitor().GetMetrics(); metrics.HitCount != 1 || metrics.MissCount != 1 {
+		t.Fatalf("expected one hit and one miss, got %d and %d", metrics.HitCount, metrics.MissCount)
+	}
+	last, _ := QueryWithKeyset(ctx, manager, "items", nil,
+		&KeysetParams{Columns: []string{"id"}, After: []interface{}{4.0}, PageSize: 2, UseCache: true}, query, cursor)
+	if len(last.Data) != 1 || last.Data[0] != 5 || last.HasMore || last.NextCursor != nil {
+		t.Fatalf("unexpected last page %+v", last)
+	}
+
+	InvalidateCacheOnUpdate(ctx, manager, "items")
+	QueryWithKeyset(ctx, manager, "items", nil, &KeysetParams{Columns: []string{"id"}, PageSize: 2, UseCache: true}, query, cursor)
+	if calls != 3 {
+		t.Fatalf("expected invalidation to clear keyset pages, got %d calls", calls)
+	}
+
+	const big = int64(1<<53 + 1)
+	bigQuery := func(*KeysetParams) ([]int64, error) { return []int64{big, big + 1}, nil }
+	bigCursor := func(id int64) []interface{} { return []interface{}{id} }
+	bigParams := &KeysetParams{Columns: []string{"id"}, PageSize: 1, UseCache: true}
+	QueryWithKeyset(ctx, manager, "big", nil, bigParams, bigQuery, bigCursor)
+	resp, err := QueryWithKeyset(ctx, manager, "big", nil, bigParams, bigQuery, bigCursor)
+	if err != nil || !resp.FromCache || resp.NextCursor[0] != big {
+		t.Fatalf("expected the cached cursor to keep %d, got %+v %v", big, resp, err)
+	}
+}
+
+func TestQueryCount(t *testing.T) {
+	ctx := context.Background()
+	clock := NewFakeClock(time.Now())
+	manager, err := NewManager(&CacheConfig{
+		Type:         CacheTypeMemory,
+		DefaultTTL:   time.Minute,
+		Clock:        clock,
+		ResourceTTLs: map[string]time.Duration{"articles": time.Minute},
+		CountTTL:     time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	counts, pages := 0, 0
+	countFunc := func() (int64, error) {
+		counts++
+		return 42, nil
+	}
+	pageFunc := func() ([]string, error) {
+		pages++
+		return []string{"a", "b"}, nil
+	}
+	filters := map[string]interface{}{"status": "published"}
+	params := func(page int) *PaginationParams {
+		return &PaginationParams{Page: page, PageSize: 2, UseCache: true}
+	}
+
+	resp, err := QueryWithPaginationCount(ctx, manager, "articles", filters, params(1), pageFunc, countFunc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Total != 42 || resp.TotalPages != 21 || resp.FromCache {
+		t.Fatalf("unexpected first response %+v", resp)
+	}
+	QueryWithPaginationCount(ctx, manager, "articles", filters, params(2), pageFunc, countFunc)
+	if pages != 2 || counts != 1 {
+		t.Fatalf("expected the count to be shared across pages, got %d pages and %d counts", pages, counts)
+	}
+
+	clock.Advance(2 * time.Minute)
+	resp, _ = QueryWithPaginationCount(ctx, manager, "articles", filters, params(1), pageFunc, countFunc)
+	if pages != 3 || counts != 1 || resp.Total != 42 {
+		t.Fatalf("expected page to expire before the count, got %d pages and %d counts", pages, counts)
+	}
+	if total, _ := QueryCount(ctx, manager, "articles", filters, countFunc); total != 42 || counts != 1 {
+		t.Fatalf("expected cached count, got %d after %d counts", total, counts)
+	}
+
+	InvalidateCacheOnUpdate(ctx, manager, "articles")
+	QueryCount(ctx, manager, "articles", filters, countFunc)
+	if counts != 2 {
+		t.Fatalf("expected invalidation to clear the count, got %d counts", counts)
+	}
+}
+
+func TestCountStoreErrors(t *testing.T) {
+	ctx := context.Background()
+	adapter := &flakyAdapter{MemoryCacheAdapter: NewMemoryCacheAdapter(time.Minute)}
+	var failed []string
+	manager := newManager(adapter, &CacheConfig{
+		DefaultTTL: time.Minute,
+		OnError: func(_ context.Context, op, key string, _ error) {
+			if op == OpSet {
+				failed = append(failed, key)
+			}
+		},
+	})
+	defer manager.Close()
+	adapter.failing.Store(true)
+
+	if total, err := QueryCount(ctx, manager, "articles", nil, func() (int64, error) { return 3, nil }); err != nil || total != 3 {
+		t.Fatalf("expected the count despite the failed write, got %d %v", total, err)
+	}
+	params := &KeysetParams{Columns: []string{"id"}, PageSize: 2, UseCache: true}
+	if _, err := QueryWithKeyset(ctx, manager, "items", nil, params, func(*KeysetParams) ([]int, error) {
+		return []int{1}, nil
+	}, func(id int) []interface{} { return []interface{}{id} }); err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 2 || failed[0] != GenerateCountCacheKey("articles", nil) {
+		t.Fatalf("expected both failed writes reported, got %v", failed)
+	}
+}
+
+func TestKeyCanonicalization(t *testing.T) {
+	canon := KeyCanonicalization{NormalizeTypes: true, SortSlices: true, FoldCase: true}
+	key := func(filters map[string]interface{}) string {
+		return GenerateCacheKey("articles", canon.Canonicalize(filters), nil)
+	}
+	a := key(map[string]interface{}{"author": 1, "tags": []string{"Go", "cache"}, "draft": false})
+	b := key(map[string]interface{}{"author": "1", "tags": []interface{}{"cache", "go"}, "draft": "false"})
+	if a != b {
+		t.Fatalf("expected equivalent filters to share a key:\n%s\n%s", a, b)
+	}
+	if key(map[string]interface{}{"score": "1.50"}) != key(map[string]interface{}{"score": 1.5}) {
+		t.Fatal("expected numeric strings to match numbers")
+	}
+	raw := map[string]interface{}{"author": "1"}
+	if (KeyCanonicalization{}).Canonicalize(raw)["author"] != "1" {
+		t.Fatal("expected the zero value to keep filters unchanged")
+	}
+	if GenerateCacheKey("articles", map[string]interface{}{"author": 1}, nil) == GenerateCacheKey("articles", raw, nil) {
+		t.Fatal("expected GenerateCacheKey alone to keep value types")
+	}
+
+	ctx := context.Background()
+	manager, err := NewManager(&CacheConfig{
+		Type:         CacheTypeMemory,
+		DefaultTTL:   time.Minute,
+		KeyCanonical: KeyCanonicalization{NormalizeTypes: true, SortSlices: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	calls := 0
+	query := func() ([]string, int64, error) {
+		calls++
+		return []string{"a"}, 1, nil
+	}
+	QueryWithPagination(ctx, manager, "articles", map[string]interface{}{"ids": []int{2, 1}}, nil, query)
+	resp, err := QueryWithPagination(ctx, manager, "articles", map[string]interface{}{"ids": []string{"1", "2"}}, nil, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.FromCache || calls != 1 {
+		t.Fatalf("expected canonical filters to hit the cached page, got %d calls", calls)
+	}
+}
+
+func TestKeyHashThreshold(t *testing.T) {
+	ctx := context.Background()
+	manager, err := NewManager(&CacheConfig{
+		Type:             CacheTypeMemory,
+		DefaultTTL:       time.Minute,
+		KeyHashThreshold: 32,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	calls := 0
+	query := func() ([]string, int64, error) {
+		calls++
+		return []string{"a"}, 1, nil
+	}
+	short := map[string]interface{}{"status": "published"}
+	resp, err := QueryWithPagination(ctx, manager, "articles", short, nil, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.CacheKey != GenerateCacheKey("articles", short, nil) || resp.OriginalKey != "" {
+		t.Fatalf("expected short filters to stay readable, got %q", resp.CacheKey)
+	}
+
+	long := map[string]interface{}{"status": "published", "author": "someone@example.com", "tags": []string{"go", "cache"}}
+	resp, err = QueryWithPagination(ctx, manager, "articles", long, &PaginationParams{Page: 2, PageSize: 10, UseCache: true}, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(resp.CacheKey, "articles:h:") || !strings.HasSuffix(resp.CacheKey, ":page:2:size:10") {
+		t.Fatalf("expected hashed filters with readable page, got %q", resp.CacheKey)
+	}
+	if strings.Contains(resp.CacheKey, "example.com") {
+		t.Fatalf("expected filter values out of the key, got %q", resp.CacheKey)
+	}
+	want := GenerateCacheKey("articles", long, &PaginationParams{Page: 2, PageSize: 10})
+	if resp.OriginalKey != want {
+		t.Fatalf("expected original key %q, got %q", want, resp.OriginalKey)
+	}
+	cached, _ := QueryWithPagination(ctx, manager, "articles", long, &PaginationParams{Page: 2, PageSize: 10, UseCache: true}, query)
+	if !cached.FromCache || cached.OriginalKey != want || calls != 2 {
+		t.Fatalf("expected cached page with original key, got %+v after %d calls", cached, calls)
+	}
+
+	counted, err := QueryWithPaginationCount(ctx, manager, "articles", long, &PaginationParams{Page: 2, PageSize: 10, UseCache: true},
+		func() ([]string, error) { return []string{"a"}, nil },
+		func() (int64, error) { return 1, nil })
+	if err != nil || counted.OriginalKey != want {
+		t.Fatalf("expected QueryWithPaginationCount to keep the original key, got %q %v", counted.OriginalKey, err)
+	}
+
+	InvalidateCacheOnUpdate(ctx, manager, "articles")
+	QueryWithPagination(ctx, manager, "articles", long, &PaginationParams{Page: 2, PageSize: 10, UseCache: true}, query)
+	if calls != 3 {
+		t.Fatalf("expected invalidation to clear hashed pages, got %d calls", calls)
+	}
+}
+
+func TestPageKeyIndex(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	for _, config := range []*CacheConfig{
+		{Type: CacheTypeMemory, DefaultTTL: time.Minute, PageKeyIndex: true},
+		{Type: CacheTypeRedis, Addr: server.Addr(), DefaultTTL: time.Minute, PageKeyIndex: true},
+	} {
+		manager, err := NewManager(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		calls := 0
+		query := func() ([]string, int64, error) {
+			calls++
+			return []string{"a"}, 1, nil
+		}
+		for page := 1; page <= 2; page++ {
+			QueryWithPagination(ctx, manager, "articles", nil, &PaginationParams{Page: page, UseCache: true}, query)
+		}
+		QueryCount(ctx, manager, "articles", nil, func() (int64, error) { return 1, nil })
+		if err := manager.Set(ctx, "articles:detail", "kept", time.Minute); err != nil {
+			t.Fatal(err)
+		}
+
+		n, err := InvalidateCacheOnUpdate(ctx, manager, "articles")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 3 {
+			t.Fatalf("%s: expected 3 indexed keys deleted, got %d", config.Type, n)
+		}
+		QueryWithPagination(ctx, manager, "articles", nil, &PaginationParams{Page: 1, UseCache: true}, query)
+		if calls != 3 {
+			t.Fatalf("%s: expected the page to be invalidated, got %d calls", config.Type, calls)
+		}
+		var detail string
+		if found, _ := manager.Get(ctx, "articles:detail", &detail); !found {
+			t.Fatalf("%s: expected keys outside the index to be kept", config.Type)
+		}
+		if n, _ := InvalidateCacheOnUpdate(ctx, manager, "articles"); n != 1 {
+			t.Fatalf("%s: expected the index to restart after invalidation, got %d", config.Type, n)
+		}
+		manager.Close()
+	}
+}
+
+func TestPageKeyIndexMissing(t *testing.T) {
+	ctx := context.Background()
+	manager, err := NewManager(&CacheConfig{
+		Type:         CacheTypeMemory,
+		DefaultTTL:   time.Minute,
+		MaxEntries:   3,
+		PageKeyIndex: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	calls := 0
+	query := func() ([]string, int64, error) {
+		calls++
+		return []string{"a"}, 1, nil
+	}
+	fill := func() {
+		for page := 1; page <= 2; page++ {
+			QueryWithPagination(ctx, manager, "articles", nil, &PaginationParams{Page: page, UseCache: true}, query)
+		}
+	}
+	fill()
+	fill()
+	_ = manager.Set(ctx, "other", 1, 0)
+	if n, err := InvalidateCacheOnUpdate(ctx, manager, "articles"); err != nil || n != 2 {
+		t.Fatalf("expected capacity eviction to keep the index, got %d, %v", n, err)
+	}
+
+	fill()
+	if err := manager.adapter.Delete(ctx, manager.key(pageIndexKey("articles"))); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := InvalidateCacheOnUpdate(ctx, manager, "articles"); err != nil || n != 2 {
+		t.Fatalf("expected a missing index to fall back to DeletePattern, got %d, %v", n, err)
+	}
+	calls = 0
+	fill()
+	if calls != 2 {
+		t.Fatalf("expected both pages to be invalidated, got %d calls", calls)
+	}
+}
+
+func TestPaginationSort(t *testing.T) {
+	ctx := context.Background()
+	manager, err := NewManager(&CacheConfig{
+		Type:       CacheTypeMemory,
+		DefaultTTL: time.Minute,
+		SortFields: map[string][]string{"articles": {"created_at", "title"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	var orders []string
+	query := func(params *PaginationParams) func() ([]string, int64, error) {
+		return func() ([]string, int64, error) {
+			orders = append(orders, params.OrderBy())
+			return []string{"a"}, 1, nil
+		}
+	}
+	byTitle := &PaginationParams{SortBy: "title", UseCache: true}
+	resp, err := QueryWithPagination(ctx, manager, "articles", nil, byTitle, query(byTitle))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.SortBy != "title" || resp.SortOrder != SortAsc || !strings.Contains(resp.CacheKey, ":sort:title:asc:") {
+		t.Fatalf("unexpected sorted response %+v", resp)
+	}
+	newest := &PaginationParams{SortBy: "created_at", SortOrder: "DESC", UseCache: true}
+	resp, _ = QueryWithPagination(ctx, manager, "articles", nil, newest, query(newest))
+	if resp.FromCache || resp.SortOrder != SortDesc {
+		t.Fatalf("expected differently sorted requests to use their own key, got %+v", resp)
+	}
+	if len(orders) != 2 || orders[0] != "title ASC" || orders[1] != "created_at DESC" {
+		t.Fatalf("unexpected ORDER BY clauses %v", orders)
+	}
+	if GenerateCacheKey("articles", nil, nil) != "articles:page:1:size:20" {
+		t.Fatal("expected unsorted keys to be unchanged")
+	}
+
+	for _, params := range []*PaginationParams{
+		{SortBy: "password"},
+		{SortBy: "title; DROP TABLE articles"},
+		{SortBy: "title", SortOrder: "sideways"},
+	} {
+		if _, err := QueryWithPagination(ctx, manager, "articles", nil, params, query(params)); !errors.Is(err, ErrInvalidSort) {
+			t.Fatalf("expected ErrInvalidSort for %+v, got %v", params, err)
+		}
+	}
+	if _, err := QueryWithPagination(ctx, manager, "users", nil, byTitle, query(byTitle)); !errors.Is(err, ErrInvalidSort) {
+		t.Fatalf("expected resources without sort fields to reject sorting, got %v", err)
+	}
+}
+
+func TestQueryWithPaginationOptions(t *testing.T) {
+	ctx := context.Background()
+	clock := NewFakeClock(time.Now())
+	manager, err := NewManager(&CacheConfig{Type: CacheTypeMemory, DefaultTTL: time.Minute, Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	calls := 0
+	query := func() ([]string, int64, error) {
+		calls++
+		return []string{"a"}, 1, nil
+	}
+	QueryWithPagination(ctx, manager, "articles", nil, nil, query, WithTTL(time.Hour))
+	clock.Advance(2 * time.Minute)
+	resp, err := QueryWithPagination(ctx, manager, "articles", nil, nil, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.FromCache || calls != 1 {
+		t.Fatalf("expected WithTTL to outlive the default TTL, got %d calls", calls)
+	}
+	metrics := manager.Monitor().GetMetrics()
+	if metrics.HitCount != 1 || metrics.MissCount != 1 {
+		t.Fatalf("expected one hit and one miss, got %d and %d", metrics.HitCount, metrics.MissCount)
+	}
+
+	resp, _ = QueryWithPagination(ctx, manager, "articles", nil, nil, query, WithNoCache())
+	if resp.FromCache || calls != 2 {
+		t.Fatalf("expected WithNoCache to bypass the cache, got %d calls", calls)
+	}
+
+	expired := &CacheTicket{Token: "t", ExpiresAt: time.Now().Add(-time.Minute)}
+	if _, err := QueryWithPagination(ctx, manager, "articles", nil, nil, query, WithTicket(expired)); !errors.Is(err, ErrTicketExpired) {
+		t.Fatalf("expected ErrTicketExpired, got %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected a rejected ticket to skip the loader, got %d calls", calls)
+	}
+}
+
+func TestIteratePages(t *testing.T) {
+	ctx := context.Background()
+	manager, err := NewManager(&CacheConfig{Type: CacheTypeMemory, DefaultTTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	rows := []int{1, 2, 3, 4, 5, 6, 7}
+	loads := 0
+	load := func(params *PaginationParams) ([]int, int64, error) {
+		loads++
+		start := (params.Page - 1) * params.PageSize
+		end := start + params.PageSize
+		if start > len(rows) {
+			start = len(rows)
+		}
+		if end > len(rows) {
+			end = len(rows)
+		}
+		return rows[start:end], int64(len(rows)), nil
+	}
+
+	collect := func() []int {
+		var all []int
+		for page, err := range IteratePages(ctx, manager, "numbers", nil, 3, load) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			all = append(all, page...)
+		}
+		return all
+	}
+	if got := collect(); fmt.Sprint(got) != fmt.Sprint(rows) || loads != 3 {
+		t.Fatalf("expected all rows in 3 loads, got %v after %d", got, loads)
+	}
+	if collect(); loads != 3 {
+		t.Fatalf("expected the second walk to be served from cache, got %d loads", loads)
+	}
+
+	pages := 0
+	for range IteratePages(ctx, manager, "numbers", nil, 3, load) {
+		pages++
+		break
+	}
+	if pages != 1 {
+		t.Fatalf("expected break to stop iteration, got %d pages", pages)
+	}
+
+	boom := errors.New("boom")
+	var got error
+	for _, err := range IteratePages(ctx, manager, "other", nil, 3, func(*PaginationParams) ([]int, int64, error) {
+		return nil, 0, boom
+	}) {
+		got = err
+	}
+	if !errors.Is(got, boom) {
+		t.Fatalf("expected loader error, got %v", got)
+	}
+
+	got = nil
+	for _, err := range IteratePages(ctx, nil, "numbers", nil, 3, load) {
+		got = err
+	}
+	if !errors.Is(got, ErrManagerNil) {
+		t.Fatalf("expected ErrManagerNil, got %v", got)
+	}
+}
+
+func TestPrefetchNextPage(t *testing.T) {
+	ctx := context.Background()
+	manager, err := NewManager(&CacheConfig{Type: CacheTypeMemory, DefaultTTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	var mu sync.Mutex
+	loaded := map[int]int{}
+	load := func(params *PaginationParams) ([]int, int64, error) {
+		mu.Lock()
+		loaded[params.Page]++
+		mu.Unlock()
+		return []int{params.Page}, 3, nil
+	}
+	loads := func(page int) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return loaded[page]
+	}
+	browse := func(page int) *PaginationResponse[int] {
+		params := &PaginationParams{Page: page, PageSize: 1, UseCache: true}
+		resp, err := QueryWithPagination(ctx, manager, "items", nil, params, func() ([]int, int64, error) {
+			return load(params)
+		}, WithPrefetchNext(load))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	waitFor := func(page int) {
+		deadline := time.Now().Add(time.Second)
+		for loads(page) == 0 {
+			if time.Now().After(deadline) {
+				t.Fatalf("page %d was not prefetched", page)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	browse(1)
+	waitFor(2)
+	if resp := browse(2); !resp.FromCache {
+		t.Fatal("expected the prefetched page to be served from cache")
+	}
+	waitFor(3)
+	if resp := browse(3); !resp.FromCache {
+		t.Fatal("expected the prefetch to follow sequential browsing")
+	}
+	browse(1)
+	time.Sleep(10 * time.Millisecond)
+	if loads(1) != 1 || loads(2) != 1 || loads(3) != 1 || loads(4) != 0 {
+		t.Fatalf("expected each page loaded once and none past the last, got %v", loaded)
+	}
+}
+
+func TestPaginationDefaults(t *testing.T) {
+	ctx := context.Background()
+	clock := NewFakeClock(time.Now())
+	manager, err := NewManager(&CacheConfig{
+		Type:       CacheTypeMemory,
+		DefaultTTL: time.Minute,
+		Clock:      clock,
+		PageDefaults: map[string]PaginationDefaults{
+			"exports": {PageSize: 500, MaxPageSize: 1000, TTL: time.Hour},
+			"live":    {NoCache: true},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	if params := manager.NormalizePaginationParams("exports", nil); params.PageSize != 500 || !params.UseCache {
+		t.Fatalf("unexpected exports defaults %+v", params)
+	}
+	if params := manager.NormalizePaginationParams("exports", &PaginationParams{PageSize: 5000}); params.PageSize != 1000 {
+		t.Fatalf("expected the resource maximum, got %d", params.PageSize)
+	}
+	if params := manager.NormalizePaginationParams("articles", &PaginationParams{PageSize: 5000}); params.PageSize != MaxPageSize {
+		t.Fatalf("expected the global maximum for other resources, got %d", params.PageSize)
+	}
+
+	calls := 0
+	query := func() ([]int, int64, error) {
+		calls++
+		return []int{1}, 1, nil
+	}
+	resp, err := QueryWithPagination(ctx, manager, "exports", nil, &PaginationParams{PageSize: 800, UseCache: true}, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.PageSize != 800 || !strings.HasSuffix(resp.CacheKey, ":size:800") {
+		t.Fatalf("expected a page size above the global maximum, got %+v", resp)
+	}
+	clock.Advance(2 * time.Minute)
+	if resp, _ = QueryWithPagination(ctx, manager, "exports", nil, &PaginationParams{PageSize: 800, UseCache: true}, query); !resp.FromCache || calls != 1 {
+		t.Fatalf("expected the resource TTL to keep the page, got %d calls", calls)
+	}
+
+	QueryWithPagination(ctx, manager, "live", nil, nil, query)
+	if resp, _ = QueryWithPagination(ctx, manager, "live", nil, &PaginationParams{UseCache: true}, query); resp.FromCache || calls != 3 {
+		t.Fatalf("expected NoCache to disable caching, got %d calls", calls)
+	}
+}
+
+func TestPaginationLinks(t *testing.T) {
+	params := &PaginationParams{Page: 2, PageSize: 10, LinkTemplate: "/articles?page={page}&size={page_size}"}
+	resp := BuildPaginationResponse([]int{1}, 35, params, "", false)
+	if !resp.HasNext || !resp.HasPrev {
+		t.Fatalf("expected a middle page to have both neighbours, got %+v", resp)
+	}
+	if resp.NextPageURL != "/articles?page=3&size=10" || resp.PrevPageURL != "/articles?page=1&size=10" {
+		t.Fatalf("unexpected links %q and %q", resp.NextPageURL, resp.PrevPageURL)
+	}
+
+	last := BuildPaginationResponse([]int{1}, 35, &PaginationParams{Page: 4, PageSize: 10}, "", false)
+	if last.HasNext || !last.HasPrev || last.NextPageURL != "" || last.PrevPageURL != "" {
+		t.Fatalf("unexpected last page metadata %+v", last)
+	}
+	if empty := BuildPaginationResponse([]int{}, 0, nil, "", false); empty.HasNext || empty.HasPrev {
+		t.Fatalf("expected no neighbours without results, got %+v", empty)
+	}
+
+	payload, _ := json.Marshal(resp)
+	if strings.Contains(string(payload), "{page}") || !strings.Contains(string(payload), `"has_next":true`) {
+		t.Fatalf("unexpected JSON %s", payload)
+	}
+}
+
+func TestHasChanged(t *testing.T) {
+	ctx := context.Background()
+	manager, err := NewManager(&CacheConfig{Type: CacheTypeMemory, DefaultTTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	filters := map[string]interface{}{"status": "published"}
+	if changed, err := HasChanged(ctx, manager, "articles", filters, nil, "anything"); err != nil || !changed {
+		t.Fatalf("expected an uncached page to be reported as changed, got %v, %v", changed, err)
+	}
+
+	rows := []string{"a", "b"}
+	query := func() ([]string, int64, error) { return rows, int64(len(rows)), nil }
+	resp, err := QueryWithPagination(ctx, manager, "articles", filters, nil, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := HasChanged(ctx, manager, "articles", filters, nil, resp.DataHash); err != nil || changed {
+		t.Fatalf("expected the fetched hash to be current, got %v, %v", changed, err)
+	}
+
+	InvalidateCacheOnUpdate(ctx, manager, "articles")
+	rows = []string{"a", "b", "c"}
+	QueryWithPagination(ctx, manager, "articles", filters, nil, query)
+	if changed, _ := HasChanged(ctx, manager, "articles", filters, nil, resp.DataHash); !changed {
+		t.Fatal("expected new data to be reported as changed")
+	}
+}
+
+func TestStreamPage(t *testing.T) {
+	ctx := context.Background()
+	manager, err := NewManager(&CacheConfig{Type: CacheTypeMemory, DefaultTTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	type row struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	rows := []row{{1, "a"}, {2, "<b>"}, {3, "c"}}
+	loads := 0
+	load := func(emit func(row) error) (int64, error) {
+		loads++
+		for _, r := range rows {
+			if err := emit(r); err != nil {
+				return 0, err
+			}
+		}
+		return 10, nil
+	}
+	params := func() *PaginationParams { return &PaginationParams{Page: 1, PageSize: 3, UseCache: true} }
+
+	stream := func() PaginationResponse[row] {
+		var buf bytes.Buffer
+		if err := StreamPage(ctx, manager, "rows", nil, params(), &buf, load); err != nil {
+			t.Fatal(err)
+		}
+		var resp PaginationResponse[row]
+		if err := json.Unmarshal(buf.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid JSON %s: %v", buf.Bytes(), err)
+		}
+		return resp
+	}
+	first := stream()
+	if first.FromCache || len(first.Data) != 3 || first.Total != 10 || first.TotalPages != 4 || !first.HasNext {
+		t.Fatalf("unexpected streamed page %+v", first)
+	}
+	if first.DataHash != GenerateDataHash(rows) {
+		t.Fatalf("expected the streamed hash to match GenerateDataHash")
+	}
+	second := stream()
+	if !second.FromCache || loads != 1 || fmt.Sprint(second.Data) != fmt.Sprint(rows) || second.DataHash != first.DataHash {
+		t.Fatalf("expected a cached stream, got %+v after %d loads", second, loads)
+	}
+
+	resp, err := QueryWithPagination(ctx, manager, "rows", nil, params(), func() ([]row, int64, error) {
+		t.Fatal("expected QueryWithPagination to read the streamed page")
+		return nil, 0, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.FromCache || fmt.Sprint(resp.Data) != fmt.Sprint(rows) || resp.DataHash != first.DataHash {
+		t.Fatalf("unexpected shared page %+v", resp)
+	}
+
+	boom := errors.New("boom")
+	err = StreamPage(ctx, manager, "other", nil, nil, io.Discard, func(emit func(row) error) (int64, error) {
+		return 0, boom
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected loader error, got %v", err)
+	}
+	if changed, _ := HasChanged(ctx, manager, "other", nil, nil, ""); !changed {
+		t.Fatal("expected a failed stream not to be cached")
+	}
+}
+
+func TestCacheWarmerShutdown(t *testing.T) {
+	ctx := context.Background()
+	manager, err := NewManager(&CacheConfig{Type: CacheTypeMemory, DefaultTTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	clock := NewFakeClock(time.Now())
+	warmer := NewCacheWarmer(manager, time.Minute)
+	warmer.SetClock(clock)
+	started := make(chan struct{})
+	canceled := make(chan error, 1)
+	warmer.AddJob("slow", func(ctx context.Context) (interface{}, error) {
+		close(started)
+		<-ctx.Done()
+		canceled <- ctx.Err()
+		return nil, ctx.Err()
+	})
+
+	warmer.Start(ctx)
+	warmer.Start(ctx)
+	for clock.Waiters() < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	if clock.Waiters() != 1 {
+		t.Fatalf("expected a second Start to be ignored, got %d waiters", clock.Waiters())
+	}
+	clock.Advance(time.Minute)
+	<-started
+
+	warmer.Stop()
+	warmer.Stop()
+	warmer.Wait()
+	if err := <-canceled; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected Stop to cancel the running job, got %v", err)
+	}
+	if found, _ := manager.Exists(ctx, "slow"); found {
+		t.Fatal("expected a canceled job not to be cached")
+	}
+	warmer.Start(ctx)
+	warmer.Wait()
+
+	parent, cancel := context.WithCancel(ctx)
+	other := NewCacheWarmer(manager, time.Minute)
+	other.Start(parent)
+	cancel()
+	other.Wait()
+}
+
+func TestCacheWarmerJobConfig(t *testing.T) {
+	ctx := context.Background()
+	clock := NewFakeClock(time.Now())
+	manager, err := NewManager(&CacheConfig{Type: CacheTypeMemory, DefaultTTL: time.Hour, Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	var fast, slow atomic.Int64
+	warmer := NewCacheWarmer(manager, time.Hour)
+	warmer.SetClock(clock)
+	if err := warmer.AddJobWithConfig("none", WarmJobConfig{Interval: time.Minute}); !errors.Is(err, ErrWarmJobLoader) {
+		t.Fatalf("expected ErrWarmJobLoader, got %v", err)
+	}
+	warmer.AddJobWithConfig("fast", WarmJobConfig{
+		Interval: time.Minute,
+		TTL:      30 * time.Second,
+		Loader: func(context.Context) (interface{}, error) {
+			return fast.Add(1), nil
+		},
+	})
+	warmer.AddJob("slow", func(context.Context) (interface{}, error) {
+		return slow.Add(1), nil
+	})
+	warmer.Start(ctx)
+	defer func() {
+		warmer.Stop()
+		warmer.Wait()
+	}()
+
+	advance := func(d time.Duration) {
+		for clock.Waiters() < 2 {
+			time.Sleep(time.Millisecond)
+		}
+		clock.Advance(d)
+	}
+	for i := 0; i < 3; i++ {
+		advance(time.Minute)
+	}
+	for clock.Waiters() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	if fast.Load() != 3 || slow.Load() != 0 {
+		t.Fatalf("expected 3 fast runs and no slow run, got %d and %d", fast.Load(), slow.Load())
+	}
+	if found, _ := manager.Exists(ctx, "fast"); !found {
+		t.Fatal("expected the fast job to be cached")
+	}
+	clock.Advance(40 * time.Second)
+	if found, _ := manager.Exists(ctx, "fast"); found {
+		t.Fatal("expected the fast job's TTL to expire its entry")
+	}
+}
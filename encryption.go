@@ -0,0 +1,160 @@
+package eitcache
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// encryptionMagic marks encrypted payloads. It is followed by the key ID
+// length, the key ID, the GCM nonce and the sealed body.
+var encryptionMagic = []byte{0xEC, 0x4B}
+
+var (
+	ErrUnknownKeyID    = errors.New("unknown encryption key id")
+	ErrInvalidKey      = errors.New("invalid encryption key")
+	ErrDecryptFailed   = errors.New("decrypt cache payload failed")
+	ErrKeyringNotReady = errors.New("keyring has no current key")
+)
+
+// Keyring holds the current encryption key and previous keys kept for
+// reading entries written before a rotation.
+type Keyring struct {
+	mu      sync.RWMutex
+	current string
+	keys    map[string]cipher.AEAD
+}
+
+// NewKeyring creates a keyring using key as the current AES key.
+// Key must be 16, 24 or 32 bytes.
+func NewKeyring(currentID string, key []byte) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string]cipher.AEAD)}
+	if err := k.Rotate(currentID, key); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// AddKey registers a previous key used only for decryption.
+func (k *Keyring) AddKey(id string, key []byte) error {
+	aead, err := newAEAD(id, key)
+	if err != nil {
+		return err
+	}
+	k.mu.Lock()
+	k.keys[id] = aead
+	k.mu.Unlock()
+	return nil
+}
+
+// Rotate registers key and makes it current. Older keys remain
+// available for decryption until removed.
+func (k *Keyring) Rotate(id string, key []byte) error {
+	aead, err := newAEAD(id, key)
+	if err != nil {
+		return err
+	}
+	k.mu.Lock()
+	k.keys[id] = aead
+	k.current = id
+	k.mu.Unlock()
+	return nil
+}
+
+// RemoveKey drops a retired key. The current key cannot be removed.
+func (k *Keyring) RemoveKey(id string) {
+	k.mu.Lock()
+	if id != k.current {
+		delete(k.keys, id)
+	}
+	k.mu.Unlock()
+}
+
+// CurrentKeyID returns the ID used for new payloads.
+func (k *Keyring) CurrentKeyID() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.current
+}
+
+// Encrypt seals data with the current key, embedding its ID.
+func (k *Keyring) Encrypt(data []byte) ([]byte, error) {
+	if k == nil {
+		return data, nil
+	}
+	k.mu.RLock()
+	id := k.current
+	aead := k.keys[id]
+	k.mu.RUnlock()
+	if aead == nil {
+		return nil, ErrKeyringNotReady
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generate nonce failed: %w", err)
+	}
+
+	out := make([]byte, 0, len(encryptionMagic)+1+len(id)+len(nonce)+len(data)+aead.Overhead())
+	out = append(out, encryptionMagic...)
+	out = append(out, byte(len(id)))
+	out = append(out, id...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, data, []byte(id)), nil
+}
+
+// Decrypt opens a payload produced by Encrypt with whichever key it
+// names. Payloads without the encryption header are returned unchanged.
+func (k *Keyring) Decrypt(data []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return data, nil
+	}
+	if k == nil {
+		return nil, ErrKeyringNotReady
+	}
+
+	rest := data[len(encryptionMagic):]
+	idLen := int(rest[0])
+	rest = rest[1:]
+	if len(rest) < idLen {
+		return nil, ErrDecryptFailed
+	}
+	id := string(rest[:idLen])
+	rest = rest[idLen:]
+
+	k.mu.RLock()
+	aead := k.keys[id]
+	k.mu.RUnlock()
+	if aead == nil {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKeyID, id)
+	}
+	if len(rest) < aead.NonceSize() {
+		return nil, ErrDecryptFailed
+	}
+	nonce, sealed := rest[:aead.NonceSize()], rest[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, sealed, []byte(id))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecryptFailed, err)
+	}
+	return plain, nil
+}
+
+// IsEncrypted reports whether data carries the encryption header.
+func IsEncrypted(data []byte) bool {
+	return len(data) > len(encryptionMagic) && bytes.HasPrefix(data, encryptionMagic)
+}
+
+func newAEAD(id string, key []byte) (cipher.AEAD, error) {
+	if id == "" || len(id) > 255 {
+		return nil, fmt.Errorf("%w: key id must be 1-255 bytes", ErrInvalidKey)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+	return cipher.NewGCM(block)
+}
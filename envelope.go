@@ -0,0 +1,135 @@
+package eitcache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// envelopeMagic marks enveloped payloads. The header layout is:
+//
+//	magic(2) version(1) flags(1) codec(1) compression(1) schema(2)
+//
+// followed by optional fields selected by flags, then the body.
+var envelopeMagic = []byte{0xEC, 0x45}
+
+const (
+	envelopeVersion    byte = 1
+	envelopeHeaderSize      = 8
+
+	// envelopeFlagFresh adds an 8-byte freshness deadline (unix nanos).
+	envelopeFlagFresh byte = 1 << 0
+	// envelopeFlagAbsent marks a negative-cache entry with an empty body.
+	envelopeFlagAbsent byte = 1 << 1
+	// envelopeFlagError marks a cached loader error; the body holds it.
+	envelopeFlagError byte = 1 << 2
+
+	envelopeKnownFlags = envelopeFlagFresh | envelopeFlagAbsent | envelopeFlagError
+)
+
+// ErrUnsupportedPayload is matched by every PayloadFormatError.
+var ErrUnsupportedPayload = errors.New("unsupported cache payload")
+
+// PayloadFormatError reports a stored payload this node cannot decode.
+type PayloadFormatError struct {
+	Reason string
+}
+
+func (e *PayloadFormatError) Error() string {
+	return "unsupported cache payload: " + e.Reason
+}
+
+// Unwrap allows errors.Is(err, ErrUnsupportedPayload).
+func (e *PayloadFormatError) Unwrap() error {
+	return ErrUnsupportedPayload
+}
+
+type envelopeHeader struct {
+	flags       byte
+	codec       byte
+	compression CompressionAlgorithm
+	schema      uint16
+	freshUntil  time.Time
+}
+
+func encodeEnvelope(h envelopeHeader, body []byte) []byte {
+	if !h.freshUntil.IsZero() {
+		h.flags |= envelopeFlagFresh
+	}
+	out := make([]byte, envelopeHeaderSize, envelopeHeaderSize+8+len(body))
+	copy(out, envelopeMagic)
+	out[2] = envelopeVersion
+	out[3] = h.flags
+	out[4] = h.codec
+	out[5] = byte(h.compression)
+	binary.BigEndian.PutUint16(out[6:], h.schema)
+	if h.flags&envelopeFlagFresh != 0 {
+		out = binary.BigEndian.AppendUint64(out, uint64(h.freshUntil.UnixNano()))
+	}
+	return append(out, body...)
+}
+
+func isEnvelope(data []byte) bool {
+	return len(data) >= envelopeHeaderSize && bytes.HasPrefix(data, envelopeMagic)
+}
+
+func decodeEnvelope(data []byte) (envelopeHeader, []byte, error) {
+	if data[2] != envelopeVersion {
+		return envelopeHeader{}, nil, &PayloadFormatError{Reason: fmt.Sprintf("envelope version %d", data[2])}
+	}
+	h := envelopeHeader{
+		flags:       data[3],
+		codec:       data[4],
+		compression: CompressionAlgorithm(data[5]),
+		schema:      binary.BigEndian.Uint16(data[6:]),
+	}
+	if h.flags&^envelopeKnownFlags != 0 {
+		return envelopeHeader{}, nil, &PayloadFormatError{Reason: fmt.Sprintf("unknown envelope flags %#x", h.flags)}
+	}
+	body := data[envelopeHeaderSize:]
+	if h.flags&envelopeFlagFresh != 0 {
+		if len(body) < 8 {
+			return envelopeHeader{}, nil, &PayloadFormatError{Reason: "truncated envelope"}
+		}
+		h.freshUntil = time.Unix(0, int64(binary.BigEndian.Uint64(body)))
+		body = body[8:]
+	}
+	return h, body, nil
+}
+
+// PayloadInfo describes a stored payload without decoding its value.
+type PayloadInfo struct {
+	Enveloped     bool                 `json:"enveloped"`
+	Encrypted     bool                 `json:"encrypted"`
+	Codec         string               `json:"codec"`
+	Compression   CompressionAlgorithm `json:"compression"`
+	SchemaVersion uint16               `json:"schema_version"`
+	Size          int                  `json:"size"`
+}
+
+// InspectPayload reports the envelope fields of a raw adapter payload.
+// Encrypted payloads only report Encrypted and Size.
+func InspectPayload(data []byte) (PayloadInfo, error) {
+	info := PayloadInfo{Size: len(data)}
+	if IsEncrypted(data) {
+		info.Encrypted = true
+		return info, nil
+	}
+	if !isEnvelope(data) {
+		if IsCompressed(data) {
+			info.Compression = CompressionAlgorithm(data[len(compressionMagic)])
+		}
+		return info, nil
+	}
+	h, _, err := decodeEnvelope(data)
+	if err != nil {
+		return info, err
+	}
+	info.Enveloped = true
+	info.Codec = codecName(h.codec)
+	info.Compression = h.compression
+	info.SchemaVersion = h.schema
+	return info, nil
+}
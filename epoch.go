@@ -0,0 +1,126 @@
+package eitcache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+)
+
+// epochKey holds the version counter of a resource.
+func epochKey(resource string) string {
+	return "epoch:" + resource
+}
+
+// SetResourceEpochs makes paginated queries mix a per-resource epoch
+// into their cache keys, so InvalidateCacheOnUpdate bumps the epoch in
+// O(1) instead of scanning and deleting keys. Entries of older epochs are
+// left to expire.
+func (m *Manager) SetResourceEpochs(enabled bool) {
+	m.update(func(s *managerSettings) { s.epochs = enabled })
+}
+
+// ResourceEpoch returns the current epoch of resource, zero if it was
+// never bumped.
+func (m *Manager) ResourceEpoch(ctx context.Context, resource string) (int64, error) {
+	if m.adapter == nil {
+		return 0, errors.New("cache adapter is nil")
+	}
+	var data []byte
+	err := m.guard(ctx, m.readTimeout, func(ctx context.Context) (err error) {
+		data, err = m.get(ctx, epochKey(resource))
+		return err
+	})
+	if err != nil || data == nil {
+		return 0, err
+	}
+	var epoch int64
+	if err := json.Unmarshal(data, &epoch); err != nil {
+		return 0, fmt.Errorf("decode epoch of %s failed: %w", resource, err)
+	}
+	return epoch, nil
+}
+
+// BumpEpoch advances the epoch of resource, invalidating every key
+// generated for the previous epoch. It returns the new epoch; while the
+// manager is read-only the epoch is left unchanged.
+func (m *Manager) BumpEpoch(ctx context.Context, resource string) (int64, error) {
+	if m.adapter == nil {
+		return 0, errors.New("cache adapter is nil")
+	}
+	if m.ReadOnly() {
+		return m.ResourceEpoch(ctx, resource)
+	}
+	var epoch int64
+	err := m.guard(ctx, m.writeTimeout, func(ctx context.Context) (err error) {
+		epoch, err = m.adapter.Incr(ctx, m.key(epochKey(resource)))
+		return err
+	})
+	if err == nil {
+		m.pages.forgetResource(m.key(resource))
+		m.recordAudit(ctx, AuditBumpEpoch, nil, resource, 0)
+	}
+	return epoch, err
+}
+
+// GenerateVersionedCacheKey builds a GenerateCacheKey key with the
+// resource epoch mixed in, e.g. "articles:v7:page:1:size:20".
+func GenerateVersionedCacheKey(resource string, epoch int64, filters map[string]interface{}, params *PaginationParams) string {
+	return versionKey(resource, epoch, GenerateCacheKey(resource, filters, params))
+}
+
+// versionKey inserts the resource epoch after the resource in key.
+func versionKey(resource string, epoch int64, key string) string {
+	return fmt.Sprintf("%s:v%d%s", resource, epoch, strings.TrimPrefix(key, resource))
+}
+
+// resourceKey returns the cache key of a paginated query, versioned by
+// the resource epoch when epochs are enabled, and the unhashed key when
+// its filters were hashed.
+func (m *Manager) resourceKey(ctx context.Context, resource string, filters map[string]interface{}, params *PaginationParams) (key, original string, err error) {
+	key, original = m.filterKey(filters, func(parts []string) string {
+		return pageKey(resource, parts, params)
+	})
+	if !m.current().epochs {
+		return key, original, nil
+	}
+	epoch, err := m.ResourceEpoch(ctx, resource)
+	if err != nil {
+		return "", "", err
+	}
+	if original != "" {
+		original = versionKey(resource, epoch, original)
+	}
+	return versionKey(resource, epoch, key), original, nil
+}
+
+// SetTableResources maps database tables to the cache resources their
+// writes affect, e.g. "users" to users, user_profiles and team_members.
+// It is consulted by InvalidateTable and CDCInvalidator.
+func (m *Manager) SetTableResources(tables map[string][]string) {
+	tables = maps.Clone(tables)
+	m.update(func(s *managerSettings) { s.tables = tables })
+}
+
+// TableResources returns the resources mapped to table, or table itself
+// when it has no mapping.
+func (m *Manager) TableResources(table string) []string {
+	if resources, ok := m.current().tables[table]; ok {
+		return resources
+	}
+	return []string{table}
+}
+
+// InvalidateTable invalidates every resource mapped to table with
+// InvalidateCacheOnUpdate.
+func (m *Manager) InvalidateTable(ctx context.Context, table string) error {
+	var errs []error
+	for _, resource := range m.TableResources(table) {
+		if _, err := InvalidateCacheOnUpdate(ctx, m, resource); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
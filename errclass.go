@@ -0,0 +1,94 @@
+package eitcache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"syscall"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Error categories counted in CacheMetrics.ErrorsByType.
+const (
+	ErrorTimeout       = "timeout"
+	ErrorConnection    = "connection"
+	ErrorSerialization = "serialization"
+	ErrorOversize      = "oversize"
+	ErrorOther         = "other"
+)
+
+// serializationError marks an encode or decode failure, since codec
+// errors carry no common type.
+type serializationError struct {
+	err error
+}
+
+func (e serializationError) Error() string { return e.err.Error() }
+func (e serializationError) Unwrap() error { return e.err }
+
+// ClassifyError returns the category of an adapter or codec error:
+// ErrorTimeout, ErrorConnection, ErrorSerialization, ErrorOversize or
+// ErrorOther. It returns "" for a nil error.
+func ClassifyError(err error) string {
+	if err == nil {
+		return ""
+	}
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded):
+		return ErrorTimeout
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorTimeout
+	case errors.As(err, new(serializationError)),
+		errors.As(err, new(*json.SyntaxError)),
+		errors.As(err, new(*json.UnmarshalTypeError)),
+		errors.As(err, new(*json.UnsupportedTypeError)),
+		errors.As(err, new(*json.UnsupportedValueError)),
+		errors.As(err, new(*json.MarshalerError)),
+		errors.Is(err, ErrCorruptPayload),
+		errors.Is(err, ErrUnsupportedPayload),
+		errors.Is(err, ErrDecryptFailed):
+		return ErrorSerialization
+	case errors.Is(err, ErrEntryTooLarge):
+		return ErrorOversize
+	case netErr != nil,
+		errors.Is(err, ErrCircuitOpen),
+		errors.Is(err, redis.ErrClosed),
+		errors.Is(err, io.EOF),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.EPIPE):
+		return ErrorConnection
+	default:
+		return ErrorOther
+	}
+}
+
+// RecordError counts an error under its ClassifyError category.
+func (m *Monitor) RecordError(err error) {
+	category := ClassifyError(err)
+	if category == "" {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.metrics.ErrorsByType == nil {
+		m.metrics.ErrorsByType = make(map[string]int64)
+	}
+	m.metrics.ErrorsByType[category]++
+	m.window.recordError()
+}
+
+// recordSerializationError counts a Query encode or decode failure, which
+// is tolerated without reaching the ErrorHandler.
+func (m *Manager) recordSerializationError(err error) {
+	if m.monitor != nil {
+		m.monitor.RecordError(serializationError{err})
+	}
+}
@@ -0,0 +1,74 @@
+package eitcache
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+// CachedError is returned when a cached loader error is hit.
+type CachedError struct {
+	Message string
+	// Err is the registered sentinel the original error matched, if any.
+	Err error
+}
+
+func (e *CachedError) Error() string {
+	return e.Message
+}
+
+// Unwrap allows errors.Is against the registered sentinel.
+func (e *CachedError) Unwrap() error {
+	return e.Err
+}
+
+var cacheableErrors = struct {
+	mu     sync.RWMutex
+	byName map[string]error
+	names  []string
+}{byName: make(map[string]error)}
+
+// RegisterCacheableError registers a sentinel so that cached errors
+// matching it still satisfy errors.Is(err, sentinel) when read back.
+// The name must be stable across nodes.
+func RegisterCacheableError(name string, sentinel error) {
+	if name == "" || sentinel == nil {
+		return
+	}
+	cacheableErrors.mu.Lock()
+	if _, ok := cacheableErrors.byName[name]; !ok {
+		cacheableErrors.names = append(cacheableErrors.names, name)
+	}
+	cacheableErrors.byName[name] = sentinel
+	cacheableErrors.mu.Unlock()
+}
+
+type cachedErrorPayload struct {
+	Message  string `json:"message"`
+	Sentinel string `json:"sentinel,omitempty"`
+}
+
+func encodeCachedError(err error) []byte {
+	payload := cachedErrorPayload{Message: err.Error()}
+	cacheableErrors.mu.RLock()
+	for _, name := range cacheableErrors.names {
+		if errors.Is(err, cacheableErrors.byName[name]) {
+			payload.Sentinel = name
+			break
+		}
+	}
+	cacheableErrors.mu.RUnlock()
+	data, _ := json.Marshal(payload)
+	return data
+}
+
+func decodeCachedError(data []byte) error {
+	var payload cachedErrorPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return &PayloadFormatError{Reason: "corrupt cached error"}
+	}
+	cacheableErrors.mu.RLock()
+	sentinel := cacheableErrors.byName[payload.Sentinel]
+	cacheableErrors.mu.RUnlock()
+	return &CachedError{Message: payload.Message, Err: sentinel}
+}
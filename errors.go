@@ -1,8 +1,59 @@
 package eitcache
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
 
 var (
 	ErrManagerNil  = errors.New("cache manager is nil")
 	ErrInvalidType = errors.New("invalid cache type")
+	// ErrNotFound can be returned by loaders to report a missing record.
+	ErrNotFound = errors.New("not found")
+	// ErrNotFoundCached is returned when a negative-cache marker is hit.
+	ErrNotFoundCached = fmt.Errorf("%w (cached)", ErrNotFound)
+	// ErrCacheMiss is returned by adapters and Manager.GetE for missing keys.
+	ErrCacheMiss = errors.New("cache miss")
+	// ErrEntryTooLarge is wrapped by adapter errors for entries over the
+	// backend's size limit.
+	ErrEntryTooLarge = errors.New("cache entry too large")
+)
+
+// Adapter operations reported to an ErrorHandler.
+const (
+	OpGet = "get"
+	OpSet = "set"
+	// OpDelete is only counted in the Monitor; delete errors are
+	// returned to the caller rather than passed to an ErrorHandler.
+	OpDelete = "delete"
+	// OpSnapshot reports failed periodic memory snapshots; the key is
+	// the snapshot path.
+	OpSnapshot = "snapshot"
+	// OpInvalidate reports failures to publish or apply cross-instance
+	// invalidations.
+	OpInvalidate = "invalidate"
 )
+
+// ErrorHandler receives adapter errors that Query tolerates by falling
+// back to the loader or skipping the write.
+type ErrorHandler func(ctx context.Context, op, key string, err error)
+
+// SetErrorHandler registers fn to receive tolerated adapter errors.
+func (m *Manager) SetErrorHandler(fn ErrorHandler) {
+	m.update(func(s *managerSettings) { s.onError = fn })
+}
+
+// reportError counts a tolerated adapter error and passes it to the
+// error handler. Calls bypassed by an open breaker are only counted.
+func (m *Manager) reportError(ctx context.Context, op, key string, err error) {
+	m.countError(op, err)
+	if errors.Is(err, ErrCircuitOpen) {
+		return
+	}
+	m.events.emit(CacheEvent{Type: EventError, Key: key, Time: time.Now(), Op: op, Err: err})
+	if onError := m.current().onError; onError != nil {
+		onError(ctx, op, key, err)
+	}
+}
@@ -0,0 +1,123 @@
+package eitcache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultEventBuffer is the Events channel capacity when
+// CacheConfig.EventBuffer is unset.
+const DefaultEventBuffer = 1024
+
+// Cache event types.
+const (
+	EventHit    = "hit"
+	EventMiss   = "miss"
+	EventSet    = "set"
+	EventDelete = "delete"
+	EventEvict  = "evict"
+	EventError  = "error"
+)
+
+// CacheEvent describes a cache operation sent on Manager.Events. Op and
+// Err are only set for error events; Size is zero when unknown.
+type CacheEvent struct {
+	Type     string
+	Key      string
+	Time     time.Time
+	Duration time.Duration
+	Size     int
+	Op       string
+	Err      error
+}
+
+var hookEventTypes = [hookKinds]string{
+	hookHit:    EventHit,
+	hookMiss:   EventMiss,
+	hookSet:    EventSet,
+	hookDelete: EventDelete,
+	hookEvict:  EventEvict,
+}
+
+// eventStream delivers events on a bounded channel, dropping the oldest
+// buffered event when it is full. It stays inactive until Events is
+// first called.
+type eventStream struct {
+	size    int
+	once    sync.Once
+	active  atomic.Bool
+	mu      sync.RWMutex
+	ch      chan CacheEvent
+	closed  bool
+	dropped atomic.Int64
+}
+
+func newEventStream(size int) *eventStream {
+	if size <= 0 {
+		size = DefaultEventBuffer
+	}
+	return &eventStream{size: size}
+}
+
+func (s *eventStream) channel() <-chan CacheEvent {
+	s.once.Do(func() {
+		s.mu.Lock()
+		s.ch = make(chan CacheEvent, s.size)
+		if s.closed {
+			close(s.ch)
+		}
+		s.mu.Unlock()
+		s.active.Store(true)
+	})
+	return s.ch
+}
+
+func (s *eventStream) emit(event CacheEvent) {
+	if !s.active.Load() {
+		return
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return
+	}
+	for {
+		select {
+		case s.ch <- event:
+			return
+		default:
+		}
+		select {
+		case <-s.ch:
+			s.dropped.Add(1)
+		default:
+		}
+	}
+}
+
+func (s *eventStream) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	if s.ch != nil {
+		close(s.ch)
+	}
+}
+
+// Events returns a channel of hit, miss, set, delete, evict and error
+// events. The channel holds CacheConfig.EventBuffer events; when it is
+// full the oldest is dropped. Events are only produced once Events has
+// been called, and the channel is closed by Close.
+func (m *Manager) Events() <-chan CacheEvent {
+	return m.events.channel()
+}
+
+// EventsDropped returns the number of events dropped because the Events
+// channel was full.
+func (m *Manager) EventsDropped() int64 {
+	return m.events.dropped.Load()
+}
@@ -51,4 +51,23 @@ func main() {
 	}
 
 	fmt.Printf("users: %+v\n", users)
+
+	page, err := eitcache.QueryWithKeyset(ctx, manager, "users", nil,
+		&eitcache.KeysetParams{Columns: []string{"id"}, PageSize: 1, UseCache: true},
+		func(p *eitcache.KeysetParams) ([]User, error) {
+			q := db.Order(p.OrderBy()).Limit(p.Limit())
+			if where, args := p.Where(); where != "" {
+				q = q.Where(where, args...)
+			}
+			var result []User
+			err := q.Find(&result).Error
+			return result, err
+		},
+		func(u User) []interface{} { return []interface{}{u.ID} },
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("first page: %+v, next cursor: %v\n", page.Data, page.NextCursor)
 }
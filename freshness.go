@@ -0,0 +1,74 @@
+package eitcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// EntryState classifies a cached entry by its freshness deadline.
+type EntryState int
+
+const (
+	// EntryGone means the entry is missing or past its hard TTL.
+	EntryGone EntryState = iota
+	// EntryFresh means the entry is within its soft TTL.
+	EntryFresh
+	// EntryStale means the entry is past its soft TTL but still retained.
+	EntryStale
+)
+
+// String returns the state name.
+func (s EntryState) String() string {
+	switch s {
+	case EntryFresh:
+		return "fresh"
+	case EntryStale:
+		return "stale"
+	default:
+		return "gone"
+	}
+}
+
+// SetWithSoftTTL stores value with a freshness deadline of softTTL,
+// retained by the backend until hardTTL.
+func (m *Manager) SetWithSoftTTL(ctx context.Context, key string, value interface{}, softTTL, hardTTL time.Duration) error {
+	if m.adapter == nil {
+		return errors.New("cache adapter is nil")
+	}
+	if softTTL <= 0 {
+		return fmt.Errorf("soft ttl must be positive")
+	}
+	if hardTTL < softTTL {
+		hardTTL = softTTL
+	}
+	payload, err := m.encodeWith(value, encodeOptions{
+		compression: m.current().compression,
+		freshUntil:  m.clock.Now().Add(softTTL),
+	})
+	if err != nil {
+		return err
+	}
+	return m.store(ctx, key, payload, hardTTL)
+}
+
+// GetWithState reads key into dest and reports whether it is fresh,
+// stale or gone. Entries written without a soft TTL are always fresh.
+func (m *Manager) GetWithState(ctx context.Context, key string, dest interface{}) (EntryState, error) {
+	if m.adapter == nil {
+		return EntryGone, errors.New("cache adapter is nil")
+	}
+	data, err := m.load(ctx, key)
+	if err != nil || data == nil {
+		return EntryGone, err
+	}
+	meta, err := m.decodeEntry(data, dest)
+	if err != nil {
+		return EntryGone, err
+	}
+	if meta.stale(m.clock.Now()) {
+		return EntryStale, nil
+	}
+	return EntryFresh, nil
+}
@@ -0,0 +1,113 @@
+package eitcache
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+// LatencySummary reports latency percentiles for hits or misses.
+type LatencySummary struct {
+	Count int64         `json:"count"`
+	P50   time.Duration `json:"p50"`
+	P90   time.Duration `json:"p90"`
+	P99   time.Duration `json:"p99"`
+	Max   time.Duration `json:"max"`
+}
+
+// SizeSummary reports payload size percentiles in bytes.
+type SizeSummary struct {
+	Count int64 `json:"count"`
+	P50   int64 `json:"p50"`
+	P90   int64 `json:"p90"`
+	P99   int64 `json:"p99"`
+	Max   int64 `json:"max"`
+}
+
+// Histogram buckets are linear below histLinear and split each power
+// of two into histSubBuckets above it, so percentiles are within 25%
+// of the true value.
+const (
+	histSubBits    = 2
+	histSubBuckets = 1 << histSubBits
+	histLinear     = 2 * histSubBuckets
+	histBuckets    = histLinear + (64-histSubBits-1)*histSubBuckets
+)
+
+// histogram is a fixed-size log-linear histogram of non-negative values.
+type histogram struct {
+	counts [histBuckets]int64
+	total  int64
+	max    int64
+}
+
+func (h *histogram) record(v int64) {
+	if v < 0 {
+		v = 0
+	}
+	h.counts[histBucket(uint64(v))]++
+	h.total++
+	if v > h.max {
+		h.max = v
+	}
+}
+
+func (h *histogram) quantile(q float64) int64 {
+	if h.total == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(q * float64(h.total)))
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for i, c := range h.counts {
+		seen += c
+		if seen >= rank {
+			if upper := int64(histUpper(i)); upper < h.max {
+				return upper
+			}
+			return h.max
+		}
+	}
+	return h.max
+}
+
+func (h *histogram) latency() LatencySummary {
+	return LatencySummary{
+		Count: h.total,
+		P50:   time.Duration(h.quantile(0.50)),
+		P90:   time.Duration(h.quantile(0.90)),
+		P99:   time.Duration(h.quantile(0.99)),
+		Max:   time.Duration(h.max),
+	}
+}
+
+func (h *histogram) sizes() SizeSummary {
+	return SizeSummary{
+		Count: h.total,
+		P50:   h.quantile(0.50),
+		P90:   h.quantile(0.90),
+		P99:   h.quantile(0.99),
+		Max:   h.max,
+	}
+}
+
+func histBucket(n uint64) int {
+	if n < histLinear {
+		return int(n)
+	}
+	exp := bits.Len64(n) - 1
+	sub := (n >> uint(exp-histSubBits)) & (histSubBuckets - 1)
+	return histLinear + (exp-histSubBits-1)*histSubBuckets + int(sub)
+}
+
+func histUpper(i int) uint64 {
+	if i < histLinear {
+		return uint64(i)
+	}
+	exp := (i-histLinear)/histSubBuckets + histSubBits + 1
+	sub := uint64((i - histLinear) % histSubBuckets)
+	width := uint64(1) << uint(exp-histSubBits)
+	return (histSubBuckets+sub)*width + width - 1
+}
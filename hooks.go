@@ -0,0 +1,121 @@
+package eitcache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// HookEvent describes a cache event passed to lifecycle hooks. Size is
+// the encoded payload size in bytes, or zero when unknown.
+type HookEvent struct {
+	Key      string
+	Duration time.Duration
+	Size     int
+}
+
+// Hook receives lifecycle events. Hooks run synchronously on the
+// goroutine that triggered the event and should return quickly.
+type Hook func(ctx context.Context, event HookEvent)
+
+type hookKind int
+
+const (
+	hookHit hookKind = iota
+	hookMiss
+	hookSet
+	hookDelete
+	hookEvict
+	hookKinds
+)
+
+type hookRegistry struct {
+	mu     sync.RWMutex
+	hooks  [hookKinds][]Hook
+	events *eventStream
+}
+
+func (h *hookRegistry) add(kind hookKind, hook Hook) {
+	if hook == nil {
+		return
+	}
+	h.mu.Lock()
+	h.hooks[kind] = append(h.hooks[kind], hook)
+	h.mu.Unlock()
+}
+
+func (h *hookRegistry) fire(ctx context.Context, kind hookKind, event HookEvent) {
+	if s := spanFrom(ctx); s != nil {
+		s.observe(kind, event)
+	}
+	if h.events != nil {
+		h.events.emit(CacheEvent{
+			Type:     hookEventTypes[kind],
+			Key:      event.Key,
+			Time:     time.Now(),
+			Duration: event.Duration,
+			Size:     event.Size,
+		})
+	}
+	h.mu.RLock()
+	hooks := h.hooks[kind]
+	h.mu.RUnlock()
+	for _, hook := range hooks {
+		hook(ctx, event)
+	}
+}
+
+// EvictionNotifier is implemented by adapters that can report entries
+// they drop on their own, such as expired in-memory entries.
+type EvictionNotifier interface {
+	SetEvictionCallback(fn func(key string, size int))
+}
+
+// OnHit registers a hook fired for every cache hit.
+func (m *Manager) OnHit(hook Hook) {
+	m.hooks.add(hookHit, hook)
+}
+
+// OnMiss registers a hook fired for every cache miss.
+func (m *Manager) OnMiss(hook Hook) {
+	m.hooks.add(hookMiss, hook)
+}
+
+// OnSet registers a hook fired after every successful write.
+func (m *Manager) OnSet(hook Hook) {
+	m.hooks.add(hookSet, hook)
+}
+
+// OnDelete registers a hook fired for every key removed through Delete.
+func (m *Manager) OnDelete(hook Hook) {
+	m.hooks.add(hookDelete, hook)
+}
+
+// OnEvict registers a hook fired when the adapter evicts an entry.
+// It only fires for adapters implementing EvictionNotifier.
+func (m *Manager) OnEvict(hook Hook) {
+	m.hooks.add(hookEvict, hook)
+}
+
+// recordHit updates the monitor and fires hit hooks.
+func (m *Manager) recordHit(ctx context.Context, key string, elapsed time.Duration, size int) {
+	if m.monitor != nil {
+		m.monitor.RecordKeyHit(key, elapsed)
+	}
+	m.hooks.fire(ctx, hookHit, HookEvent{Key: key, Duration: elapsed, Size: size})
+}
+
+// recordMiss updates the monitor and fires miss hooks.
+func (m *Manager) recordMiss(ctx context.Context, key string, elapsed time.Duration) {
+	if m.monitor != nil {
+		m.monitor.RecordKeyMiss(key, elapsed)
+	}
+	m.hooks.fire(ctx, hookMiss, HookEvent{Key: key, Duration: elapsed})
+}
+
+func (m *Manager) recordEviction(key string, size int) {
+	if m.monitor != nil {
+		m.monitor.RecordEviction(1)
+	}
+	m.hooks.fire(context.Background(), hookEvict, HookEvent{Key: key, Size: size})
+}
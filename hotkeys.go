@@ -0,0 +1,136 @@
+package eitcache
+
+import (
+	"container/heap"
+	"math/rand/v2"
+	"sort"
+)
+
+// HotKey is an estimated access count for a key. The true count lies
+// between Count-Error and Count.
+type HotKey struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+	Error int64  `json:"error"`
+}
+
+// hotKeyTracker implements the space-saving algorithm over a sample of
+// accesses, keeping at most capacity counters.
+type hotKeyTracker struct {
+	capacity int
+	rate     float64
+	index    map[string]*hotKeyEntry
+	entries  hotKeyHeap
+}
+
+type hotKeyEntry struct {
+	key   string
+	count int64
+	err   int64
+	pos   int
+}
+
+func newHotKeyTracker(capacity int, rate float64) *hotKeyTracker {
+	if rate <= 0 || rate > 1 {
+		rate = 1
+	}
+	return &hotKeyTracker{
+		capacity: capacity,
+		rate:     rate,
+		index:    make(map[string]*hotKeyEntry, capacity),
+	}
+}
+
+func (t *hotKeyTracker) record(key string) {
+	if t.rate < 1 && rand.Float64() >= t.rate {
+		return
+	}
+	if e, ok := t.index[key]; ok {
+		e.count++
+		heap.Fix(&t.entries, e.pos)
+		return
+	}
+	if len(t.entries) < t.capacity {
+		e := &hotKeyEntry{key: key, count: 1}
+		t.index[key] = e
+		heap.Push(&t.entries, e)
+		return
+	}
+	victim := t.entries[0]
+	delete(t.index, victim.key)
+	victim.key = key
+	victim.err = victim.count
+	victim.count++
+	t.index[key] = victim
+	heap.Fix(&t.entries, 0)
+}
+
+func (t *hotKeyTracker) top(n int) []HotKey {
+	out := make([]HotKey, 0, len(t.entries))
+	for _, e := range t.entries {
+		out = append(out, HotKey{
+			Key:   e.key,
+			Count: int64(float64(e.count) / t.rate),
+			Error: int64(float64(e.err) / t.rate),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Key < out[j].Key
+	})
+	if n > 0 && n < len(out) {
+		out = out[:n]
+	}
+	return out
+}
+
+type hotKeyHeap []*hotKeyEntry
+
+func (h hotKeyHeap) Len() int           { return len(h) }
+func (h hotKeyHeap) Less(i, j int) bool { return h[i].count < h[j].count }
+func (h hotKeyHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].pos = i
+	h[j].pos = j
+}
+
+func (h *hotKeyHeap) Push(x interface{}) {
+	e := x.(*hotKeyEntry)
+	e.pos = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *hotKeyHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+// TrackHotKeys enables top-N hot key tracking with room for capacity keys,
+// counting a sampleRate fraction of accesses (1 counts every access).
+// A capacity of zero or less disables tracking.
+func (m *Monitor) TrackHotKeys(capacity int, sampleRate float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if capacity <= 0 {
+		m.hotKeys = nil
+		return
+	}
+	m.hotKeys = newHotKeyTracker(capacity, sampleRate)
+}
+
+// HotKeys returns up to n of the most accessed keys, hottest first, with
+// counts scaled by the sample rate. It returns nil if tracking is off.
+func (m *Monitor) HotKeys(n int) []HotKey {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.hotKeys == nil {
+		return nil
+	}
+	return m.hotKeys.top(n)
+}
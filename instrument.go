@@ -0,0 +1,149 @@
+package eitcache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Operations timed by Monitor.RecordOp, reported in CacheMetrics.OpLatency.
+const (
+	OpLatencySet           = "set"
+	OpLatencyDelete        = "delete"
+	OpLatencyDeletePattern = "delete_pattern"
+)
+
+// RecordOp records the duration of a write or delete operation.
+func (m *Monitor) RecordOp(op string, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.ops == nil {
+		m.ops = make(map[string]*histogram)
+	}
+	h, ok := m.ops[op]
+	if !ok {
+		h = &histogram{}
+		m.ops[op] = h
+	}
+	h.record(int64(duration))
+}
+
+// countError updates the monitor for a failed operation without calling
+// the ErrorHandler, for errors already returned to the caller.
+func (m *Manager) countError(op string, err error) {
+	if m.monitor == nil || err == nil {
+		return
+	}
+	if errors.Is(err, ErrCircuitOpen) {
+		m.monitor.RecordBreakerBypass()
+		return
+	}
+	switch op {
+	case OpGet:
+		m.monitor.RecordGetError()
+	case OpSet:
+		m.monitor.RecordSetError()
+	}
+	m.monitor.RecordError(err)
+}
+
+// recordOp records an operation's duration in the monitor.
+func (m *Manager) recordOp(op string, start time.Time) {
+	if m.monitor != nil {
+		m.monitor.RecordOp(op, time.Since(start))
+	}
+}
+
+// MonitoredAdapter wraps an adapter used outside a Manager, recording
+// hits, misses, payload sizes, write and delete latency and errors in a
+// Monitor. Managers instrument their own operations, so do not pass a
+// MonitoredAdapter to one. Optional interfaces such as SetNXAdapter are
+// not forwarded.
+type MonitoredAdapter struct {
+	Adapter
+	monitor *Monitor
+}
+
+// NewMonitoredAdapter wraps adapter. A nil monitor creates a new one.
+func NewMonitoredAdapter(adapter Adapter, monitor *Monitor) *MonitoredAdapter {
+	if monitor == nil {
+		monitor = NewMonitor()
+	}
+	return &MonitoredAdapter{Adapter: adapter, monitor: monitor}
+}
+
+// Monitor returns the adapter's monitor.
+func (a *MonitoredAdapter) Monitor() *Monitor {
+	return a.monitor
+}
+
+// Get reads key and records a hit or miss.
+func (a *MonitoredAdapter) Get(ctx context.Context, key string) ([]byte, error) {
+	start := time.Now()
+	data, err := a.Adapter.Get(ctx, key)
+	switch {
+	case err == nil && data != nil:
+		a.monitor.RecordKeyHit(key, time.Since(start))
+	case err == nil || errors.Is(err, ErrCacheMiss):
+		a.monitor.RecordKeyMiss(key, time.Since(start))
+	default:
+		a.monitor.RecordGetError()
+		a.monitor.RecordError(err)
+	}
+	return data, err
+}
+
+// Set writes key and records its latency and payload size.
+func (a *MonitoredAdapter) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	start := time.Now()
+	err := a.Adapter.Set(ctx, key, value, ttl)
+	if err != nil {
+		a.monitor.RecordSetError()
+		a.monitor.RecordError(err)
+		return err
+	}
+	a.monitor.RecordOp(OpLatencySet, time.Since(start))
+	if size := payloadSize(value); size >= 0 {
+		a.monitor.RecordSet(key, size)
+	}
+	return nil
+}
+
+// Delete removes keys and records the latency.
+func (a *MonitoredAdapter) Delete(ctx context.Context, keys ...string) error {
+	start := time.Now()
+	err := a.Adapter.Delete(ctx, keys...)
+	if err != nil {
+		a.monitor.RecordError(err)
+		return err
+	}
+	a.monitor.RecordOp(OpLatencyDelete, time.Since(start))
+	return nil
+}
+
+// DeletePattern removes matching keys and records the latency.
+func (a *MonitoredAdapter) DeletePattern(ctx context.Context, pattern string) (int64, error) {
+	start := time.Now()
+	n, err := a.Adapter.DeletePattern(ctx, pattern)
+	if err != nil {
+		a.monitor.RecordError(err)
+		return n, err
+	}
+	a.monitor.RecordOp(OpLatencyDeletePattern, time.Since(start))
+	return n, nil
+}
+
+// payloadSize returns the byte length of raw values, or -1 if unknown.
+func payloadSize(value interface{}) int {
+	switch v := value.(type) {
+	case []byte:
+		return len(v)
+	case RawValue:
+		return len(v)
+	case string:
+		return len(v)
+	default:
+		return -1
+	}
+}
@@ -0,0 +1,266 @@
+package eitcache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultInvalidationChannel is the Redis channel used when
+// InvalidationConfig.Channel is empty.
+const DefaultInvalidationChannel = "eitcache:invalidate"
+
+// InvalidationConfig connects a Manager to a channel on which every
+// instance broadcasts its Delete and DeletePattern calls, so instances
+// with local memory caches purge the same keys. Transport overrides the
+// Redis pub/sub connection described by the other fields. KeyspaceEvents
+// also purges keys that Redis reports deleted or expired, so deletions
+// made directly in Redis reach local caches; the server must enable them
+// with notify-keyspace-events "Egx".
+type InvalidationConfig struct {
+	Addr           string
+	Password       string
+	DB             int
+	Channel        string
+	Transport      InvalidationTransport
+	KeyspaceEvents bool
+}
+
+// InvalidationTransport carries encoded InvalidationMessages between
+// instances. Subscribe is called once; handler must receive every
+// payload published by any instance, including this one, until Close.
+type InvalidationTransport interface {
+	Publish(ctx context.Context, payload []byte) error
+	Subscribe(handler func(payload []byte)) error
+	Close() error
+}
+
+// InvalidationMessage describes deletions broadcast to other instances.
+// Keys and patterns are full adapter keys, including any WithPrefix
+// namespace. A message with an ID asks every receiver to answer with an
+// acknowledgement carrying AckFor, the number of keys it removed and any
+// error.
+type InvalidationMessage struct {
+	Origin   string   `json:"origin"`
+	Keys     []string `json:"keys,omitempty"`
+	Patterns []string `json:"patterns,omitempty"`
+	ID       string   `json:"id,omitempty"`
+	AckFor   string   `json:"ack_for,omitempty"`
+	Count    int64    `json:"count,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// RedisInvalidationTransport implements InvalidationTransport with
+// Redis pub/sub.
+type RedisInvalidationTransport struct {
+	channel string
+	client  *redis.Client
+	pubsub  *redis.PubSub
+	done    chan struct{}
+}
+
+// NewRedisInvalidationTransport subscribes to config.Channel on the
+// Redis server at config.Addr.
+func NewRedisInvalidationTransport(config *InvalidationConfig) (*RedisInvalidationTransport, error) {
+	channel := config.Channel
+	if channel == "" {
+		channel = DefaultInvalidationChannel
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr:     config.Addr,
+		Password: config.Password,
+		DB:       config.DB,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	pubsub := client.Subscribe(ctx, channel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		client.Close()
+		return nil, fmt.Errorf("subscribe to invalidation channel failed: %w", err)
+	}
+	return &RedisInvalidationTransport{channel: channel, client: client, pubsub: pubsub}, nil
+}
+
+// Publish sends payload to the channel.
+func (t *RedisInvalidationTransport) Publish(ctx context.Context, payload []byte) error {
+	return t.client.Publish(ctx, t.channel, payload).Err()
+}
+
+// Subscribe delivers channel messages to handler on a background goroutine.
+func (t *RedisInvalidationTransport) Subscribe(handler func(payload []byte)) error {
+	if t.done != nil {
+		return errors.New("invalidation transport already subscribed")
+	}
+	t.done = make(chan struct{})
+	go func() {
+		defer close(t.done)
+		for msg := range t.pubsub.Channel() {
+			handler([]byte(msg.Payload))
+		}
+	}()
+	return nil
+}
+
+// Close unsubscribes and waits for the handler goroutine to exit.
+func (t *RedisInvalidationTransport) Close() error {
+	err := t.pubsub.Close()
+	if t.done != nil {
+		<-t.done
+	}
+	return errors.Join(err, t.client.Close())
+}
+
+type invalidationBus struct {
+	origin    string
+	transport InvalidationTransport
+
+	mu      sync.Mutex
+	seq     uint64
+	waiters map[string]chan InvalidationMessage
+}
+
+func newInvalidationBus(m *Manager, config *InvalidationConfig) (*invalidationBus, error) {
+	origin := make([]byte, 8)
+	if _, err := rand.Read(origin); err != nil {
+		return nil, fmt.Errorf("generate node id failed: %w", err)
+	}
+	transport := config.Transport
+	if transport == nil {
+		t, err := NewRedisInvalidationTransport(config)
+		if err != nil {
+			return nil, err
+		}
+		transport = t
+	}
+
+	b := &invalidationBus{
+		origin:    hex.EncodeToString(origin),
+		transport: transport,
+		waiters:   make(map[string]chan InvalidationMessage),
+	}
+	if err := transport.Subscribe(func(payload []byte) { b.receive(m, payload) }); err != nil {
+		transport.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *invalidationBus) receive(m *Manager, payload []byte) {
+	var msg InvalidationMessage
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		m.reportError(context.Background(), OpInvalidate, "", err)
+		return
+	}
+	if msg.Origin == b.origin {
+		return
+	}
+	if msg.AckFor != "" {
+		b.mu.Lock()
+		ch, ok := b.waiters[msg.AckFor]
+		b.mu.Unlock()
+		if ok {
+			select {
+			case ch <- msg:
+			default:
+			}
+		}
+		return
+	}
+	ctx := context.Background()
+	count, err := m.applyInvalidation(ctx, msg)
+	if msg.ID == "" {
+		return
+	}
+	ack := InvalidationMessage{AckFor: msg.ID, Count: count}
+	if err != nil {
+		ack.Error = err.Error()
+	}
+	if err := b.publish(ctx, ack); err != nil {
+		m.reportError(ctx, OpInvalidate, "", err)
+	}
+}
+
+// request registers a waiter for acknowledgements of a new message ID.
+func (b *invalidationBus) request() (string, chan InvalidationMessage) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq++
+	id := fmt.Sprintf("%s-%d", b.origin, b.seq)
+	ch := make(chan InvalidationMessage, 64)
+	b.waiters[id] = ch
+	return id, ch
+}
+
+func (b *invalidationBus) release(id string) {
+	b.mu.Lock()
+	delete(b.waiters, id)
+	b.mu.Unlock()
+}
+
+func (b *invalidationBus) publish(ctx context.Context, msg InvalidationMessage) error {
+	msg.Origin = b.origin
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return b.transport.Publish(ctx, payload)
+}
+
+func (b *invalidationBus) close() error {
+	return b.transport.Close()
+}
+
+// broadcast publishes deletions to other instances, reporting failures
+// to the error handler.
+func (m *Manager) broadcast(ctx context.Context, msg InvalidationMessage) {
+	if m.bus == nil {
+		return
+	}
+	if err := m.bus.publish(ctx, msg); err != nil {
+		m.reportError(ctx, OpInvalidate, "", err)
+	}
+}
+
+// applyInvalidation purges keys deleted by another instance, returning
+// the number of keys its patterns removed. It applies even while
+// read-only, since the data changed upstream.
+func (m *Manager) applyInvalidation(ctx context.Context, msg InvalidationMessage) (int64, error) {
+	var count int64
+	err := m.guard(ctx, m.opTimeout, func(ctx context.Context) error {
+		if len(msg.Keys) > 0 {
+			if err := m.awaitWrites(ctx, msg.Keys); err != nil {
+				return err
+			}
+			if err := m.adapter.Delete(ctx, msg.Keys...); err != nil {
+				return err
+			}
+			m.pages.forget(msg.Keys)
+		}
+		if len(msg.Patterns) > 0 {
+			if err := m.awaitWrites(ctx, nil); err != nil {
+				return err
+			}
+		}
+		for _, pattern := range msg.Patterns {
+			n, err := m.adapter.DeletePattern(ctx, pattern)
+			count += n
+			if err != nil {
+				return err
+			}
+			m.pages.forgetPattern(pattern)
+		}
+		return nil
+	})
+	if err != nil {
+		m.reportError(ctx, OpInvalidate, "", err)
+	}
+	return count, err
+}
@@ -0,0 +1,55 @@
+package eitcache
+
+import (
+	"context"
+	"iter"
+)
+
+// IteratePages yields every page of resource in order, each loaded
+// through QueryWithPagination so pages are served from and written to
+// the cache. queryFunc receives the page to load. Iteration stops after
+// the last page by total, on an empty page, when ctx is done, or after
+// yielding an error.
+//
+//	for rows, err := range eitcache.IteratePages(ctx, manager, "articles", filters, 500, load) {
+//		if err != nil {
+//			return err
+//		}
+//		export(rows)
+//	}
+func IteratePages[T any](
+	ctx context.Context,
+	manager *Manager,
+	resource string,
+	filters map[string]interface{},
+	pageSize int,
+	queryFunc func(params *PaginationParams) ([]T, int64, error),
+	opts ...QueryOption,
+) iter.Seq2[[]T, error] {
+	return func(yield func([]T, error) bool) {
+		if manager == nil {
+			yield(nil, ErrManagerNil)
+			return
+		}
+		for page := DefaultPage; ; page++ {
+			if err := ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+			params := manager.NormalizePaginationParams(resource, &PaginationParams{Page: page, PageSize: pageSize, UseCache: true})
+			resp, err := QueryWithPagination(ctx, manager, resource, filters, params, func() ([]T, int64, error) {
+				return queryFunc(params)
+			}, opts...)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if len(resp.Data) == 0 {
+				return
+			}
+			if !yield(resp.Data, nil) || page >= resp.TotalPages {
+				return
+			}
+		}
+	}
+}
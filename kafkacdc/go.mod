@@ -0,0 +1,24 @@
+module github.com/eit-cms/eit-cache/kafkacdc
+
+go 1.23
+
+replace (
+	github.com/eit-cms/eit-cache => ../
+	github.com/eit-cms/eit-db => ../../eit-db
+)
+
+require (
+	github.com/eit-cms/eit-cache v0.0.0-00010101000000-000000000000
+	github.com/segmentio/kafka-go v0.4.47
+)
+
+require (
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/klauspost/compress v1.18.0 // indirect
+	github.com/pierrec/lz4/v4 v4.1.15 // indirect
+	github.com/redis/go-redis/v9 v9.6.1 // indirect
+	golang.org/x/sync v0.10.0 // indirect
+	google.golang.org/protobuf v1.36.1 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
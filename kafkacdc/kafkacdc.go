@@ -0,0 +1,70 @@
+//go:build kafka
+
+// Package kafkacdc reads Debezium change events from Kafka, as an
+// eitcache.ChangeSource for CDCInvalidator.Consume. It is a separate
+// module built with the kafka build tag, so the core module does not
+// require kafka-go.
+package kafkacdc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Source implements eitcache.ChangeSource with a kafka-go consumer group
+// reader. Offsets are committed when the event is acknowledged, so
+// events are delivered at least once.
+type Source struct {
+	reader *kafka.Reader
+	owned  bool
+}
+
+// New creates a source on an existing reader, which must have a GroupID
+// for acknowledgements to commit offsets. Close leaves reader open.
+func New(reader *kafka.Reader) *Source {
+	return &Source{reader: reader}
+}
+
+// Dial creates a reader for topics in consumer group groupID on brokers.
+// Close also closes the reader.
+func Dial(brokers []string, groupID string, topics ...string) (*Source, error) {
+	if len(brokers) == 0 {
+		return nil, errors.New("kafka brokers are empty")
+	}
+	if groupID == "" {
+		return nil, errors.New("kafka consumer group is empty")
+	}
+	if len(topics) == 0 {
+		return nil, errors.New("kafka topics are empty")
+	}
+	s := New(kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     brokers,
+		GroupID:     groupID,
+		GroupTopics: topics,
+	}))
+	s.owned = true
+	return s, nil
+}
+
+// Next blocks until the next message is fetched, returning its value and
+// an ack that commits its offset.
+func (s *Source) Next(ctx context.Context) ([]byte, func() error, error) {
+	msg, err := s.reader.FetchMessage(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	ack := func() error {
+		return s.reader.CommitMessages(context.Background(), msg)
+	}
+	return msg.Value, ack, nil
+}
+
+// Close closes the reader if Dial opened it.
+func (s *Source) Close() error {
+	if !s.owned {
+		return nil
+	}
+	return s.reader.Close()
+}
@@ -0,0 +1,23 @@
+//go:build kafka
+
+package kafkacdc
+
+import (
+	"testing"
+
+	eitcache "github.com/eit-cms/eit-cache"
+)
+
+var _ eitcache.ChangeSource = (*Source)(nil)
+
+func TestDialValidation(t *testing.T) {
+	if _, err := Dial(nil, "cache", "db.articles"); err == nil {
+		t.Fatal("expected an error without brokers")
+	}
+	if _, err := Dial([]string{"localhost:9092"}, "", "db.articles"); err == nil {
+		t.Fatal("expected an error without a consumer group")
+	}
+	if _, err := Dial([]string{"localhost:9092"}, "cache"); err == nil {
+		t.Fatal("expected an error without topics")
+	}
+}
@@ -0,0 +1,34 @@
+package eitcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// SetKeyHashThreshold hashes the filter portion of paginated, keyset and
+// count keys when it is longer than n bytes, replacing it with "h:" and
+// its SHA-256, e.g. "articles:h:9f86...:page:1:size:20". The resource and
+// page remain readable and filter values stay out of the key space; the
+// unhashed key is kept in the cached page as OriginalKey. Zero disables
+// hashing.
+func (m *Manager) SetKeyHashThreshold(n int) {
+	m.update(func(s *managerSettings) { s.keyHashOver = n })
+}
+
+// filterKey builds a key with build from the canonical key parts of
+// filters. When the parts were hashed it also returns the unhashed key.
+func (m *Manager) filterKey(filters map[string]interface{}, build func(parts []string) string) (key, original string) {
+	settings := m.current()
+	parts := filterKeyParts(settings.canon.Canonicalize(filters))
+	threshold := settings.keyHashOver
+	if threshold <= 0 || len(parts) == 0 {
+		return build(parts), ""
+	}
+	joined := strings.Join(parts, ":")
+	if len(joined) <= threshold {
+		return build(parts), ""
+	}
+	sum := sha256.Sum256([]byte(joined))
+	return build([]string{"h", hex.EncodeToString(sum[:])}), build(parts)
+}
@@ -0,0 +1,290 @@
+package eitcache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ErrInvalidKeysetColumn is returned for keyset sort columns that are not
+// plain SQL identifiers.
+var ErrInvalidKeysetColumn = errors.New("invalid keyset column")
+
+var keysetColumn = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// KeysetParams defines keyset (seek) pagination. Columns are the sort
+// columns, the last of which must be unique, such as "created_at", "id".
+// After holds the last row's values for those columns and is empty for
+// the first page.
+type KeysetParams struct {
+	Columns  []string      `json:"columns"`
+	After    []interface{} `json:"after,omitempty"`
+	Desc     bool          `json:"desc"`
+	PageSize int           `json:"page_size"`
+	UseCache bool          `json:"use_cache"`
+}
+
+// NormalizeKeysetParams defaults and clamps PageSize like
+// NormalizePaginationParams, and validates the columns and cursor.
+func NormalizeKeysetParams(params *KeysetParams) (*KeysetParams, error) {
+	if params == nil || len(params.Columns) == 0 {
+		return nil, fmt.Errorf("%w: no sort columns", ErrInvalidKeysetColumn)
+	}
+	for _, column := range params.Columns {
+		if !keysetColumn.MatchString(column) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidKeysetColumn, column)
+		}
+	}
+	if len(params.After) > 0 && len(params.After) != len(params.Columns) {
+		return nil, fmt.Errorf("keyset cursor has %d values for %d columns", len(params.After), len(params.Columns))
+	}
+	if params.PageSize <= 0 {
+		params.PageSize = DefaultPageSize
+	}
+	if params.PageSize > MaxPageSize {
+		params.PageSize = MaxPageSize
+	}
+	return params, nil
+}
+
+// Where returns a condition selecting rows after the cursor, with "?"
+// placeholders, e.g. "(created_at > ?) OR (created_at = ? AND id > ?)".
+// It returns an empty condition for the first page.
+func (p *KeysetParams) Where() (string, []interface{}) {
+	if len(p.After) == 0 {
+		return "", nil
+	}
+	op := ">"
+	if p.Desc {
+		op = "<"
+	}
+	var (
+		terms []string
+		args  []interface{}
+	)
+	for i, column := range p.Columns {
+		conds := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			conds = append(conds, p.Columns[j]+" = ?")
+			args = append(args, p.After[j])
+		}
+		conds = append(conds, column+" "+op+" ?")
+		args = append(args, p.After[i])
+		terms = append(terms, "("+strings.Join(conds, " AND ")+")")
+	}
+	return strings.Join(terms, " OR "), args
+}
+
+// OrderBy returns the ORDER BY clause for the sort columns.
+func (p *KeysetParams) OrderBy() string {
+	dir := " ASC"
+	if p.Desc {
+		dir = " DESC"
+	}
+	order := make([]string, len(p.Columns))
+	for i, column := range p.Columns {
+		order[i] = column + dir
+	}
+	return strings.Join(order, ", ")
+}
+
+// Limit returns the number of rows to fetch: one more than PageSize, so
+// QueryWithKeyset can tell whether another page follows.
+func (p *KeysetParams) Limit() int {
+	return p.PageSize + 1
+}
+
+// GenerateKeysetCacheKey builds a cache key for a keyset page, e.g.
+// "articles:status:\"published\":keyset:created_at,id:desc:after:[...]:size:20".
+func GenerateKeysetCacheKey(resource string, filters map[string]interface{}, params *KeysetParams) string {
+	return keysetKey(resource, filterKeyParts(filters), params)
+}
+
+// keysetKey builds a GenerateKeysetCacheKey key from filter key parts.
+func keysetKey(resource string, filterParts []string, params *KeysetParams) string {
+	parts := append([]string{resource}, filterParts...)
+	dir := "asc"
+	if params.Desc {
+		dir = "desc"
+	}
+	after, _ := json.Marshal(params.After)
+	parts = append(parts, "keyset", strings.Join(params.Columns, ","), dir,
+		"after", string(after), "size", fmt.Sprintf("%d", params.PageSize))
+	return strings.Join(parts, ":")
+}
+
+// KeysetResponse is a page of keyset results. NextCursor holds the last
+// row's sort values to pass as After for the following page; it is nil
+// when HasMore is false. OriginalKey is the unhashed cache key when the
+// filters were hashed.
+type KeysetResponse[T any] struct {
+	Data        []T           `json:"data"`
+	PageSize    int           `json:"page_size"`
+	HasMore     bool          `json:"has_more"`
+	NextCursor  []interface{} `json:"next_cursor,omitempty"`
+	FromCache   bool          `json:"from_cache"`
+	CacheKey    string        `json:"cache_key"`
+	OriginalKey string        `json:"original_key,omitempty"`
+}
+
+// QueryWithKeyset executes a cached keyset-paginated query. queryFunc
+// should apply params.Where, params.OrderBy and params.Limit, for example
+// with GORM:
+//
+//	q := db.Order(params.OrderBy()).Limit(params.Limit())
+//	if where, args := params.Where(); where != "" {
+//		q = q.Where(where, args...)
+//	}
+//
+// cursor returns a row's values for params.Columns. Pages are stored
+// under the resource like QueryWithPagination pages, so they expire with
+// the resource TTL and are cleared by InvalidateCacheOnUpdate. On cache
+// hits, integral NextCursor numbers are returned as int64 and other
+// numbers as float64.
+func QueryWithKeyset[T any](
+	ctx context.Context,
+	manager *Manager,
+	resource string,
+	filters map[string]interface{},
+	params *KeysetParams,
+	queryFunc func(params *KeysetParams) ([]T, error),
+	cursor func(T) []interface{},
+) (*KeysetResponse[T], error) {
+	if manager == nil {
+		return nil, ErrManagerNil
+	}
+	params, err := NormalizeKeysetParams(params)
+	if err != nil {
+		return nil, err
+	}
+	key, original := manager.filterKey(filters, func(parts []string) string {
+		return keysetKey(resource, parts, params)
+	})
+	useCache := params.UseCache
+	if manager.current().epochs {
+		epoch, err := manager.ResourceEpoch(ctx, resource)
+		if err != nil {
+			// Without the epoch a cached page may predate the last bump.
+			manager.reportError(ctx, OpGet, epochKey(resource), err)
+			useCache = false
+		} else {
+			key = versionKey(resource, epoch, key)
+			if original != "" {
+				original = versionKey(resource, epoch, original)
+			}
+		}
+	}
+
+	load := func() (*KeysetResponse[T], error) {
+		rows, err := queryFunc(params)
+		if err != nil {
+			return nil, err
+		}
+		resp := &KeysetResponse[T]{Data: rows, PageSize: params.PageSize, CacheKey: key, OriginalKey: original}
+		if len(rows) > params.PageSize {
+			resp.Data = rows[:params.PageSize]
+			resp.HasMore = true
+			resp.NextCursor = cursor(resp.Data[len(resp.Data)-1])
+		}
+		return resp, nil
+	}
+
+	if !useCache {
+		return load()
+	}
+	start := time.Now()
+	data, err := manager.load(ctx, key)
+	elapsed := time.Since(start)
+	if err == nil && data != nil {
+		var cached keysetCacheItem[T]
+		if err := manager.decode(data, &cached); err == nil {
+			if next, err := decodeCursor(cached.NextCursor); err == nil {
+				manager.recordHit(ctx, key, elapsed, len(data))
+				return &KeysetResponse[T]{
+					Data:        cached.Data,
+					PageSize:    cached.PageSize,
+					HasMore:     cached.HasMore,
+					NextCursor:  next,
+					FromCache:   true,
+					CacheKey:    key,
+					OriginalKey: cached.OriginalKey,
+				}, nil
+			}
+		}
+	}
+	manager.recordMiss(ctx, key, elapsed)
+
+	resp, err := coalesce(manager, key, func() (*KeysetResponse[T], error) {
+		resp, err := load()
+		if err != nil {
+			return nil, err
+		}
+		item := keysetCacheItem[T]{Data: resp.Data, PageSize: resp.PageSize, HasMore: resp.HasMore, OriginalKey: resp.OriginalKey}
+		var payload RawValue
+		item.NextCursor, err = encodeCursor(resp.NextCursor)
+		if err == nil {
+			payload, err = manager.encode(item)
+		}
+		if err == nil {
+			err = manager.storePage(ctx, resource, key, filters, payload, jitterTTL(manager.ttlFor(resource), manager.current().ttlJitter))
+		}
+		if err != nil {
+			manager.reportError(ctx, OpSet, key, err)
+		}
+		return resp, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := *resp
+	return &out, nil
+}
+
+// keysetCacheItem is a cached keyset page. The cursor is kept as JSON
+// whatever the codec, so decodeCursor can read integers above 2^53
+// without rounding them through float64.
+type keysetCacheItem[T any] struct {
+	Data        []T    `json:"data"`
+	PageSize    int    `json:"page_size"`
+	HasMore     bool   `json:"has_more"`
+	NextCursor  string `json:"next_cursor,omitempty"`
+	OriginalKey string `json:"original_key,omitempty"`
+}
+
+func encodeCursor(values []interface{}) (string, error) {
+	if values == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(values)
+	return string(data), err
+}
+
+// decodeCursor reads a cursor stored by encodeCursor. Integral numbers
+// come back as int64 and other numbers as float64.
+func decodeCursor(data string) ([]interface{}, error) {
+	if data == "" {
+		return nil, nil
+	}
+	dec := json.NewDecoder(strings.NewReader(data))
+	dec.UseNumber()
+	var values []interface{}
+	if err := dec.Decode(&values); err != nil {
+		return nil, err
+	}
+	for i, v := range values {
+		n, ok := v.(json.Number)
+		if !ok {
+			continue
+		}
+		if i64, err := n.Int64(); err == nil {
+			values[i] = i64
+		} else if f, err := n.Float64(); err == nil {
+			values[i] = f
+		}
+	}
+	return values, nil
+}
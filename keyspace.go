@@ -0,0 +1,75 @@
+package eitcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// keyspaceChannels returns the Redis keyevent channels for deleted and
+// expired keys in db.
+func keyspaceChannels(db int) []string {
+	return []string{
+		fmt.Sprintf("__keyevent@%d__:del", db),
+		fmt.Sprintf("__keyevent@%d__:expired", db),
+	}
+}
+
+// keyspaceListener purges local entries for keys that Redis reports as
+// deleted or expired in the cache database, including deletions made
+// outside eitcache. Redis keys outside the cache prefix are ignored; the
+// prefix is stripped so the rest, including any WithPrefix namespace,
+// matches the keys of the local adapter.
+type keyspaceListener struct {
+	client *redis.Client
+	pubsub *redis.PubSub
+	done   chan struct{}
+}
+
+func newKeyspaceListener(m *Manager, config *CacheConfig) (*keyspaceListener, error) {
+	inv := config.Invalidation
+	client := redis.NewClient(&redis.Options{
+		Addr:     inv.Addr,
+		Password: inv.Password,
+		DB:       inv.DB,
+	})
+	prefix := config.Prefix
+	if prefix == "" {
+		prefix = defaultRedisPrefix
+	}
+	channels := keyspaceChannels(config.DB)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	pubsub := client.Subscribe(ctx, channels...)
+	for range channels {
+		if _, err := pubsub.Receive(ctx); err != nil {
+			pubsub.Close()
+			client.Close()
+			return nil, fmt.Errorf("subscribe to keyspace notifications failed: %w", err)
+		}
+	}
+
+	l := &keyspaceListener{client: client, pubsub: pubsub, done: make(chan struct{})}
+	go func() {
+		defer close(l.done)
+		for msg := range pubsub.Channel() {
+			key, ok := strings.CutPrefix(msg.Payload, prefix)
+			if !ok {
+				continue
+			}
+			// applyInvalidation reports failures to the error handler.
+			_, _ = m.applyInvalidation(context.Background(), InvalidationMessage{Keys: []string{key}})
+		}
+	}()
+	return l, nil
+}
+
+func (l *keyspaceListener) close() error {
+	err := l.pubsub.Close()
+	<-l.done
+	return errors.Join(err, l.client.Close())
+}
@@ -0,0 +1,98 @@
+package eitcache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+const (
+	DefaultLockTTL  = 10 * time.Second
+	DefaultLockWait = 2 * time.Second
+
+	lockPollInterval = 50 * time.Millisecond
+)
+
+// ErrLockUnsupported is returned when the adapter cannot provide SetNX.
+var ErrLockUnsupported = errors.New("adapter does not support distributed locks")
+
+// errReadOnly stops lock acquisition while the manager is read-only.
+var errReadOnly = errors.New("cache manager is read-only")
+
+// LockKey returns the key guarding recomputation of key.
+func LockKey(key string) string {
+	return key + ":lock"
+}
+
+// tryLock acquires the recompute lock for key and returns its token.
+func (m *Manager) tryLock(ctx context.Context, key string) (string, bool, error) {
+	if m.ReadOnly() {
+		return "", false, errReadOnly
+	}
+	nx, ok := m.adapter.(SetNXAdapter)
+	if !ok {
+		return "", false, ErrLockUnsupported
+	}
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", false, err
+	}
+	token := hex.EncodeToString(buf)
+	var acquired bool
+	err := m.guard(ctx, m.writeTimeout, func(ctx context.Context) (err error) {
+		acquired, err = nx.SetNX(ctx, m.key(LockKey(key)), RawValue(token), m.lockTTL)
+		return err
+	})
+	return token, acquired, err
+}
+
+// unlock releases the lock if this holder still owns it.
+func (m *Manager) unlock(ctx context.Context, key, token string) {
+	_ = m.guard(ctx, m.writeTimeout, func(ctx context.Context) error {
+		if cad, ok := m.adapter.(CompareAndDeleteAdapter); ok {
+			_, err := cad.CompareAndDelete(ctx, m.key(LockKey(key)), []byte(token))
+			return err
+		}
+		data, err := m.get(ctx, LockKey(key))
+		if err != nil || string(data) != token {
+			return err
+		}
+		return m.adapter.Delete(ctx, m.key(LockKey(key)))
+	})
+}
+
+// lockedLoad runs load while holding the distributed lock for key. When
+// another process holds the lock it waits up to lockWait for that
+// process to publish the value, decoded with codec, then falls back to
+// loading itself.
+func lockedLoad[T any](ctx context.Context, m *Manager, key string, codec Codec, load func() (T, error)) (T, error) {
+	token, acquired, err := m.tryLock(ctx, key)
+	if err != nil {
+		return load()
+	}
+	if acquired {
+		defer m.unlock(context.WithoutCancel(ctx), key, token)
+		return load()
+	}
+
+	deadline := m.clock.Now().Add(m.lockWait)
+	for m.clock.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		case <-m.clock.After(lockPollInterval):
+		}
+		data, err := m.load(ctx, key)
+		if err != nil || data == nil {
+			continue
+		}
+		var cached T
+		if meta, err := m.decodeEntryWith(data, &cached, codec); err == nil && !meta.stale(m.clock.Now()) {
+			return cached, nil
+		}
+	}
+	return load()
+}
@@ -0,0 +1,29 @@
+package eitcache
+
+import "log/slog"
+
+// Logger receives cache log messages with alternating key/value
+// arguments. *slog.Logger implements it.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// SetLogger sets the logger for cache messages. Nil selects
+// slog.Default().
+func (m *Manager) SetLogger(logger Logger) {
+	m.update(func(s *managerSettings) { s.logger = logger })
+}
+
+// log returns the manager's logger, defaulting to slog.Default().
+func (m *Manager) log() Logger {
+	if m == nil {
+		return slog.Default()
+	}
+	if logger := m.current().logger; logger != nil {
+		return logger
+	}
+	return slog.Default()
+}
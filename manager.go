@@ -2,33 +2,125 @@ package eitcache
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"golang.org/x/sync/singleflight"
 )
 
 const (
 	CacheTypeRedis  = "redis"
 	CacheTypeMemory = "memory"
+	CacheTypeArena  = "arena"
 )
 
+// DefaultStaleGrace is how long entries written with WithStaleOnError
+// are retained past their TTL.
+const DefaultStaleGrace = 10 * time.Minute
+
 // CacheConfig configures cache manager and adapter.
 type CacheConfig struct {
-	Type       string
-	Addr       string
-	Password   string
-	DB         int
-	DefaultTTL time.Duration
-	MaxRetries int
-	PoolSize   int
-	Prefix     string
+	Type             string
+	Addr             string
+	Password         string
+	DB               int
+	DefaultTTL       time.Duration
+	MaxRetries       int
+	PoolSize         int
+	Prefix           string
+	Codec            Codec
+	Marshalers       *MarshalerRegistry
+	Compression      *CacheCompression
+	Encryption       *Keyring
+	SchemaVersion    uint16
+	ChunkSize        int
+	TTLJitter        float64
+	RefreshWorkers   int
+	LockTTL          time.Duration
+	LockWait         time.Duration
+	StaleGrace       time.Duration
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	OnError          ErrorHandler
+	ReadTimeout      time.Duration
+	WriteTimeout     time.Duration
+	OpTimeout        time.Duration
+	Retry            *RetryPolicy
+	ReadOnly         bool
+	WriteBehind      *WriteBehindConfig
+	MaxEntries       int
+	MaxMemoryBytes   int64
+	EvictionPolicy   EvictionPolicy
+	CleanupInterval  time.Duration
+	SnapshotPath     string
+	SnapshotInterval time.Duration
+	RestoreOnStart   bool
+	Clock            Clock
+	StoreValues      bool
+	CopyOnRead       bool
+	Pinned           []string
+	ArenaSize        int64
+	ArenaShards      int
+	ResourceEpochs   bool
+	Invalidation     *InvalidationConfig
+	TableResources   map[string][]string
+	DoubleDelete     time.Duration
+	AuditLogSize     int
+	AuditPersistTTL  time.Duration
+	ResourceTTLs     map[string]time.Duration
+	Tracer           Tracer
+	HotKeys          int
+	HotKeySampleRate float64
+	BigKeyThreshold  int
+	SlowLogThreshold time.Duration
+	SlowLogSize      int
+	Logger           Logger
+	EventBuffer      int
+	MetricSampleRate float64
+	CountTTL         time.Duration
+	KeyCanonical     KeyCanonicalization
+	KeyHashThreshold int
+	PageKeyIndex     bool
+	SortFields       map[string][]string
+	PageDefaults     map[string]PaginationDefaults
 }
 
 // Manager orchestrates caching.
 type Manager struct {
-	adapter    Adapter
-	defaultTTL time.Duration
-	monitor    *Monitor
+	adapter       Adapter
+	values        ValueAdapter
+	defaultTTL    time.Duration
+	monitor       *Monitor
+	settings      *atomic.Pointer[managerSettings]
+	marshalers    *MarshalerRegistry
+	compressors   *sync.Map
+	schemaVersion uint16
+	refresher     *refreshPool
+	lockTTL       time.Duration
+	lockWait      time.Duration
+	breaker       *circuitBreaker
+	readTimeout   time.Duration
+	writeTimeout  time.Duration
+	opTimeout     time.Duration
+	hooks         *hookRegistry
+	readOnly      *atomic.Bool
+	writeBehind   *writeBehind
+	flight        *singleflight.Group
+	bus           *invalidationBus
+	keyspace      *keyspaceListener
+	doubleDelete  *doubleDeleter
+	audit         *auditLog
+	schedule      *invalidationSchedule
+	clock         Clock
+	pages         *pageIndex
+	slow          *slowLog
+	events        *eventStream
+	started       time.Time
+	namespace     string
+	view          bool
 }
 
 // NewManager creates a cache manager using CacheConfig.
@@ -36,15 +128,20 @@ func NewManager(config *CacheConfig) (*Manager, error) {
 	if config == nil {
 		config = &CacheConfig{Type: CacheTypeMemory}
 	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
 
 	var adapter Adapter
 	var err error
 
 	switch config.Type {
 	case "", CacheTypeMemory:
-		adapter = NewMemoryCacheAdapter(config.DefaultTTL)
+		adapter, err = NewMemoryCacheAdapterWithConfig(config)
 	case CacheTypeRedis:
 		adapter, err = NewRedisCacheAdapter(config)
+	case CacheTypeArena:
+		adapter, err = NewArenaCacheAdapterWithConfig(config)
 	default:
 		return nil, ErrInvalidType
 	}
@@ -53,20 +150,122 @@ func NewManager(config *CacheConfig) (*Manager, error) {
 		return nil, err
 	}
 
-	return &Manager{
-		adapter:    adapter,
-		defaultTTL: config.DefaultTTL,
-		monitor:    NewMonitor(),
-	}, nil
+	m := newManager(adapter, config)
+	if config.Invalidation != nil {
+		if m.bus, err = newInvalidationBus(m, config.Invalidation); err != nil {
+			adapter.Close()
+			return nil, err
+		}
+		if config.Invalidation.KeyspaceEvents {
+			if m.keyspace, err = newKeyspaceListener(m, config); err != nil {
+				m.bus.close()
+				adapter.Close()
+				return nil, err
+			}
+		}
+	}
+	return m, nil
 }
 
 // NewManagerWithAdapter creates a manager from an existing adapter.
 func NewManagerWithAdapter(adapter Adapter, defaultTTL time.Duration) *Manager {
-	return &Manager{
-		adapter:    adapter,
-		defaultTTL: defaultTTL,
-		monitor:    NewMonitor(),
+	return newManager(adapter, &CacheConfig{DefaultTTL: defaultTTL})
+}
+
+func newManager(adapter Adapter, config *CacheConfig) *Manager {
+	codec := config.Codec
+	if codec == nil {
+		codec = DefaultCodec
+	}
+	marshalers := config.Marshalers
+	if marshalers == nil {
+		marshalers = NewMarshalerRegistry()
+	}
+	lockTTL := config.LockTTL
+	if lockTTL <= 0 {
+		lockTTL = DefaultLockTTL
+	}
+	lockWait := config.LockWait
+	if lockWait <= 0 {
+		lockWait = DefaultLockWait
+	}
+	staleGrace := config.StaleGrace
+	if staleGrace <= 0 {
+		staleGrace = DefaultStaleGrace
+	}
+
+	readTimeout := config.ReadTimeout
+	if readTimeout <= 0 {
+		readTimeout = config.OpTimeout
 	}
+	writeTimeout := config.WriteTimeout
+	if writeTimeout <= 0 {
+		writeTimeout = config.OpTimeout
+	}
+	monitor := NewMonitor()
+	events := newEventStream(config.EventBuffer)
+	monitor.SetClock(config.Clock)
+	monitor.SetSampleRate(config.MetricSampleRate)
+	monitor.TrackHotKeys(config.HotKeys, config.HotKeySampleRate)
+
+	m := &Manager{
+		adapter:       adapter,
+		defaultTTL:    config.DefaultTTL,
+		monitor:       monitor,
+		marshalers:    marshalers,
+		schemaVersion: config.SchemaVersion,
+		refresher:     newRefreshPool(config.RefreshWorkers),
+		lockTTL:       lockTTL,
+		lockWait:      lockWait,
+		breaker:       newCircuitBreaker(config.BreakerThreshold, config.BreakerCooldown, monitor, config.Clock),
+		readTimeout:   readTimeout,
+		writeTimeout:  writeTimeout,
+		opTimeout:     config.OpTimeout,
+		hooks:         &hookRegistry{events: events},
+		events:        events,
+		readOnly:      &atomic.Bool{},
+		compressors:   &sync.Map{},
+		flight:        &singleflight.Group{},
+		doubleDelete:  newDoubleDeleter(),
+		audit:         newAuditLog(config.AuditLogSize, config.AuditPersistTTL),
+		schedule:      newInvalidationSchedule(),
+		clock:         clockOrSystem(config.Clock),
+		pages:         newPageIndex(),
+		slow:          newSlowLog(config.SlowLogSize, config.SlowLogThreshold),
+		started:       time.Now(),
+	}
+	m.settings = newSettingsPointer(&managerSettings{
+		codec:        codec,
+		compression:  config.Compression,
+		keyring:      config.Encryption,
+		ttlJitter:    config.TTLJitter,
+		resourceTTLs: config.ResourceTTLs,
+		onError:      config.OnError,
+		logger:       config.Logger,
+		staleGrace:   staleGrace,
+		chunkSize:    config.ChunkSize,
+		doubleDelay:  config.DoubleDelete,
+		retry:        config.Retry,
+		bigKeyThresh: config.BigKeyThreshold,
+		tracer:       config.Tracer,
+		sortFields:   config.SortFields,
+		pageDefaults: config.PageDefaults,
+		epochs:       config.ResourceEpochs,
+		tables:       config.TableResources,
+		keyHashOver:  config.KeyHashThreshold,
+		canon:        config.KeyCanonical,
+		countTTL:     config.CountTTL,
+		pageKeyIndex: config.PageKeyIndex,
+	})
+	m.readOnly.Store(config.ReadOnly)
+	if values, ok := adapter.(ValueAdapter); ok && values.StoresValues() {
+		m.values = values
+	}
+	m.writeBehind = newWriteBehind(m, config.WriteBehind)
+	if notifier, ok := adapter.(EvictionNotifier); ok {
+		notifier.SetEvictionCallback(m.recordEviction)
+	}
+	return m
 }
 
 // Adapter exposes the underlying adapter.
@@ -79,8 +278,88 @@ func (m *Manager) Monitor() *Monitor {
 	return m.monitor
 }
 
-// Close closes the adapter.
+// Codec returns the codec used to encode values.
+func (m *Manager) Codec() Codec {
+	return m.current().codec
+}
+
+// SetCodec replaces the codec used to encode values.
+func (m *Manager) SetCodec(codec Codec) {
+	if codec == nil {
+		codec = DefaultCodec
+	}
+	m.update(func(s *managerSettings) { s.codec = codec })
+}
+
+// Marshalers returns the per-type marshaler registry consulted before the codec.
+func (m *Manager) Marshalers() *MarshalerRegistry {
+	return m.marshalers
+}
+
+// SetCompression replaces the compression policy. Nil disables compression.
+func (m *Manager) SetCompression(compression *CacheCompression) {
+	m.update(func(s *managerSettings) { s.compression = compression })
+	m.compressors.Clear()
+}
+
+// SetEncryption replaces the keyring used to encrypt payloads. Nil disables encryption.
+func (m *Manager) SetEncryption(keyring *Keyring) {
+	m.update(func(s *managerSettings) { s.keyring = keyring })
+}
+
+// WithPrefix returns a view of m that prepends prefix to every key and
+// scopes DeletePattern and Clear to it. The view shares the adapter,
+// monitor, hooks and background workers with m; settings changed on the
+// view apply only to the view, and closing it is a no-op.
+func (m *Manager) WithPrefix(prefix string) *Manager {
+	view := *m
+	view.settings = newSettingsPointer(m.current())
+	view.namespace = m.namespace + prefix
+	view.view = true
+	return &view
+}
+
+// Prefix returns the namespace prepended to keys by WithPrefix views.
+func (m *Manager) Prefix() string {
+	return m.namespace
+}
+
+// key maps a caller key to the adapter key.
+func (m *Manager) key(key string) string {
+	return m.namespace + key
+}
+
+// SetReadOnly freezes the cache: writes and deletes become no-ops while
+// reads are still served. It applies to every WithPrefix view of m.
+func (m *Manager) SetReadOnly(readOnly bool) {
+	m.readOnly.Store(readOnly)
+}
+
+// ReadOnly reports whether writes are currently disabled.
+func (m *Manager) ReadOnly() bool {
+	return m.readOnly.Load()
+}
+
+// Close drains queued write-behind writes and closes the adapter.
+// Closing a WithPrefix view does nothing.
 func (m *Manager) Close() error {
+	if m.view {
+		return nil
+	}
+	if m.writeBehind != nil {
+		m.writeBehind.close()
+	}
+	m.refresher.close()
+	m.pages.reset()
+	m.doubleDelete.close()
+	m.schedule.close()
+	m.events.close()
+	if m.keyspace != nil {
+		m.keyspace.close()
+	}
+	if m.bus != nil {
+		m.bus.close()
+	}
 	if m.adapter == nil {
 		return nil
 	}
@@ -95,7 +374,41 @@ func (m *Manager) Set(ctx context.Context, key string, value interface{}, ttl ti
 	if ttl == 0 {
 		ttl = m.defaultTTL
 	}
-	return m.adapter.Set(ctx, key, value, ttl)
+	ctx, span := m.startSpan(ctx, "set", key)
+	err := m.set(ctx, key, value, jitterTTL(ttl, m.current().ttlJitter))
+	span.finish(err)
+	m.countError(OpSet, err)
+	return err
+}
+
+// set writes value with an exact ttl.
+func (m *Manager) set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	if m.adapter == nil {
+		return errors.New("cache adapter is nil")
+	}
+	ttl, ok := m.clampTTL(key, ttl)
+	if !ok {
+		return nil
+	}
+	if m.values != nil {
+		return m.storeValue(ctx, key, value, time.Time{}, ttl)
+	}
+	payload, err := m.encode(value)
+	if err != nil {
+		return err
+	}
+	if m.writeBehind != nil && !m.ReadOnly() {
+		queued, err := m.writeBehind.enqueue(ctx, writeBehindEntry{
+			key:     key,
+			fullKey: m.key(key),
+			payload: payload,
+			ttl:     ttl,
+		})
+		if queued || err != nil {
+			return err
+		}
+	}
+	return m.store(ctx, key, payload, ttl)
 }
 
 // Get reads data from cache into dest. Returns hit status.
@@ -103,22 +416,96 @@ func (m *Manager) Get(ctx context.Context, key string, dest interface{}) (bool,
 	if m.adapter == nil {
 		return false, errors.New("cache adapter is nil")
 	}
-	data, err := m.adapter.Get(ctx, key)
-	if err != nil || data == nil {
+	ctx, span := m.startSpan(ctx, "get", key)
+	hit, err := m.getInto(ctx, key, dest)
+	span.finish(err)
+	return hit, err
+}
+
+func (m *Manager) getInto(ctx context.Context, key string, dest interface{}) (bool, error) {
+	start := time.Now()
+	var (
+		stored *storedValue
+		data   []byte
+		err    error
+	)
+	if m.values != nil {
+		stored, data, err = m.loadValue(ctx, key)
+	} else {
+		data, err = m.load(ctx, key)
+	}
+	if err != nil || (data == nil && stored == nil) {
+		m.recordMiss(ctx, key, time.Since(start))
+		m.countError(OpGet, err)
 		return false, err
 	}
-	if err := json.Unmarshal(data, dest); err != nil {
+	if stored != nil {
+		err = m.assignValue(stored.Value, dest)
+	} else {
+		err = m.decode(data, dest)
+	}
+	if err != nil {
+		m.recordSerializationError(err)
 		return false, err
 	}
+	m.recordHit(ctx, key, time.Since(start), len(data))
 	return true, nil
 }
 
+// GetE reads data from cache into dest, returning ErrCacheMiss when the
+// key is absent.
+func (m *Manager) GetE(ctx context.Context, key string, dest interface{}) error {
+	hit, err := m.Get(ctx, key, dest)
+	if err == nil && !hit {
+		return ErrCacheMiss
+	}
+	return err
+}
+
 // Delete removes cached keys.
 func (m *Manager) Delete(ctx context.Context, keys ...string) error {
 	if m.adapter == nil {
 		return errors.New("cache adapter is nil")
 	}
-	return m.adapter.Delete(ctx, keys...)
+	if m.ReadOnly() {
+		return nil
+	}
+	start := time.Now()
+	full := make([]string, len(keys))
+	for i, key := range keys {
+		full[i] = m.key(key)
+	}
+	if err := m.awaitWrites(ctx, full); err != nil {
+		m.countError(OpDelete, err)
+		return err
+	}
+	var targets []string
+	err := m.guard(ctx, m.writeTimeout, func(ctx context.Context) error {
+		expanded := keys
+		if m.current().chunkSize > 0 {
+			expanded = m.chunkKeys(ctx, keys)
+		}
+		targets = make([]string, len(expanded))
+		for i, key := range expanded {
+			targets[i] = m.key(key)
+		}
+		return m.adapter.Delete(ctx, targets...)
+	})
+	if err == nil {
+		m.pages.forget(targets)
+		msg := InvalidationMessage{Keys: targets}
+		m.broadcast(ctx, msg)
+		m.scheduleDoubleDelete(msg)
+		m.recordAudit(ctx, AuditDelete, keys, "", int64(len(keys)))
+		elapsed := time.Since(start)
+		for _, key := range keys {
+			m.hooks.fire(ctx, hookDelete, HookEvent{Key: key, Duration: elapsed})
+		}
+		m.recordOp(OpLatencyDelete, start)
+	} else {
+		m.countError(OpDelete, err)
+	}
+	return err
 }
 
 // DeletePattern removes cached keys by prefix pattern.
@@ -126,7 +513,66 @@ func (m *Manager) DeletePattern(ctx context.Context, pattern string) (int64, err
 	if m.adapter == nil {
 		return 0, errors.New("cache adapter is nil")
 	}
-	return m.adapter.DeletePattern(ctx, pattern)
+	if m.ReadOnly() {
+		return 0, nil
+	}
+	ctx, span := m.startSpan(ctx, "delete_pattern", pattern)
+	start := time.Now()
+	var n int64
+	err := m.awaitWrites(ctx, nil)
+	if err == nil {
+		err = m.guard(ctx, m.opTimeout, func(ctx context.Context) (err error) {
+			n, err = m.adapter.DeletePattern(ctx, m.key(pattern))
+			return err
+		})
+	}
+	span.count(n)
+	span.finish(err)
+	if err == nil {
+		m.pages.forgetPattern(m.key(pattern))
+		msg := InvalidationMessage{Patterns: []string{m.key(pattern)}}
+		m.broadcast(ctx, msg)
+		m.scheduleDoubleDelete(msg)
+		m.recordAudit(ctx, AuditDeletePattern, nil, pattern, n)
+		m.recordOp(OpLatencyDeletePattern, start)
+	} else {
+		m.countError(OpDelete, err)
+	}
+	return n, err
+}
+
+// Scan lists keys in the manager's namespace matching the glob pattern,
+// with the namespace stripped. It returns ErrScanUnsupported if the
+// adapter cannot enumerate keys.
+func (m *Manager) Scan(ctx context.Context, pattern string) ([]string, error) {
+	if m.adapter == nil {
+		return nil, errors.New("cache adapter is nil")
+	}
+	scanner, ok := m.adapter.(ScanAdapter)
+	if !ok {
+		return nil, ErrScanUnsupported
+	}
+	if pattern == "" {
+		pattern = "*"
+	}
+	var keys []string
+	err := m.guard(ctx, m.opTimeout, func(ctx context.Context) (err error) {
+		keys, err = scanner.Scan(ctx, m.key(pattern))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, k := range keys {
+		keys[i] = strings.TrimPrefix(k, m.namespace)
+	}
+	return keys, nil
+}
+
+// Clear removes every key in the manager's namespace; on a manager
+// without a prefix that is every key under the adapter prefix.
+func (m *Manager) Clear(ctx context.Context) (int64, error) {
+	return m.DeletePattern(ctx, "*")
 }
 
 // Exists checks if a key exists.
@@ -134,7 +580,12 @@ func (m *Manager) Exists(ctx context.Context, key string) (bool, error) {
 	if m.adapter == nil {
 		return false, errors.New("cache adapter is nil")
 	}
-	return m.adapter.Exists(ctx, key)
+	var exists bool
+	err := m.guard(ctx, m.readTimeout, func(ctx context.Context) (err error) {
+		exists, err = m.adapter.Exists(ctx, m.key(key))
+		return err
+	})
+	return exists, err
 }
 
 // Stats returns adapter stats.
@@ -142,101 +593,57 @@ func (m *Manager) Stats(ctx context.Context) (map[string]interface{}, error) {
 	if m.adapter == nil {
 		return nil, errors.New("cache adapter is nil")
 	}
-	return m.adapter.Stats(ctx)
-}
-
-// Ping checks adapter health.
-func (m *Manager) Ping(ctx context.Context) error {
-	if m.adapter == nil {
-		return errors.New("cache adapter is nil")
+	var stats map[string]interface{}
+	err := m.guard(ctx, m.opTimeout, func(ctx context.Context) (err error) {
+		stats, err = m.adapter.Stats(ctx)
+		return err
+	})
+	if err == nil && stats != nil {
+		if records := m.AuditLog(); len(records) > 0 {
+			stats["audit_log"] = records
+		}
+		if m.monitor != nil {
+			if big := m.monitor.BigKeys(0); len(big) > 0 {
+				stats["big_keys"] = big
+				stats["payload_size"] = m.monitor.GetMetrics().PayloadSize
+			}
+		}
 	}
-	return m.adapter.Ping(ctx)
+	return stats, err
 }
 
-// QueryOptions controls Query behavior.
-type QueryOptions struct {
-	TTL      time.Duration
-	UseCache bool
-	Ticket   *CacheTicket
-}
-
-// QueryOption mutates QueryOptions.
-type QueryOption func(*QueryOptions)
-
-// WithTTL sets cache TTL for Query.
-func WithTTL(ttl time.Duration) QueryOption {
-	return func(o *QueryOptions) {
-		o.TTL = ttl
+// PoolStats returns the adapter's connection pool statistics. ok is
+// false for adapters that do not implement PoolStatsAdapter.
+func (m *Manager) PoolStats() (stats PoolStats, ok bool) {
+	p, ok := m.adapter.(PoolStatsAdapter)
+	if !ok {
+		return PoolStats{}, false
 	}
+	return p.PoolStats(), true
 }
 
-// WithNoCache disables cache for Query.
-func WithNoCache() QueryOption {
-	return func(o *QueryOptions) {
-		o.UseCache = false
+// Ping checks adapter health.
+func (m *Manager) Ping(ctx context.Context) error {
+	if m.adapter == nil {
+		return errors.New("cache adapter is nil")
 	}
+	return m.guard(ctx, m.opTimeout, m.adapter.Ping)
 }
 
-// WithTicket validates ticket before Query.
-func WithTicket(ticket *CacheTicket) QueryOption {
-	return func(o *QueryOptions) {
-		o.Ticket = ticket
+// SetStaleGrace sets how long WithStaleOnError entries are retained past
+// their TTL. Non-positive values restore DefaultStaleGrace.
+func (m *Manager) SetStaleGrace(grace time.Duration) {
+	if grace <= 0 {
+		grace = DefaultStaleGrace
 	}
+	m.update(func(s *managerSettings) { s.staleGrace = grace })
 }
 
-// Query runs a cached query with generic result.
-func Query[T any](ctx context.Context, manager *Manager, key string, queryFunc func() (T, error), opts ...QueryOption) (T, error) {
-	var zero T
-	if manager == nil {
-		return zero, ErrManagerNil
-	}
-	if manager.adapter == nil {
-		return zero, errors.New("cache adapter is nil")
-	}
-
-	options := &QueryOptions{
-		TTL:      manager.defaultTTL,
-		UseCache: true,
+// SetChunkSize sets the payload size above which values are split into
+// chunks. Zero disables chunking.
+func (m *Manager) SetChunkSize(size int) {
+	if size < 0 {
+		size = 0
 	}
-	for _, opt := range opts {
-		opt(options)
-	}
-
-	if options.Ticket != nil {
-		if err := options.Ticket.Validate(); err != nil {
-			return zero, err
-		}
-	}
-
-	if options.UseCache {
-		start := time.Now()
-		data, err := manager.adapter.Get(ctx, key)
-		elapsed := time.Since(start)
-		if err == nil && data != nil {
-			if manager.monitor != nil {
-				manager.monitor.RecordHit(elapsed)
-			}
-			var cached T
-			if err := json.Unmarshal(data, &cached); err == nil {
-				return cached, nil
-			}
-		} else if manager.monitor != nil {
-			manager.monitor.RecordMiss(elapsed)
-		}
-	}
-
-	result, err := queryFunc()
-	if err != nil {
-		return zero, err
-	}
-
-	if options.UseCache {
-		ttl := options.TTL
-		if ttl == 0 {
-			ttl = manager.defaultTTL
-		}
-		_ = manager.adapter.Set(ctx, key, result, ttl)
-	}
-
-	return result, nil
+	m.update(func(s *managerSettings) { s.chunkSize = size })
 }
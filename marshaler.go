@@ -0,0 +1,102 @@
+package eitcache
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+type typeMarshaler struct {
+	marshal   func(v interface{}) ([]byte, error)
+	unmarshal func(data []byte, dest interface{}) error
+}
+
+// MarshalerRegistry maps Go types to custom marshal functions that
+// take precedence over the manager codec.
+type MarshalerRegistry struct {
+	mu      sync.RWMutex
+	entries map[reflect.Type]typeMarshaler
+}
+
+// NewMarshalerRegistry creates an empty registry.
+func NewMarshalerRegistry() *MarshalerRegistry {
+	return &MarshalerRegistry{entries: make(map[reflect.Type]typeMarshaler)}
+}
+
+// ErrMarshalerInterface is returned by RegisterMarshaler for interface
+// types. Marshalers are matched by the stored value's dynamic type, which
+// is never an interface, so such a registration would never be used.
+var ErrMarshalerInterface = errors.New("marshaler type is an interface")
+
+// RegisterMarshaler registers custom marshal functions for type T, which
+// must be a concrete type.
+func RegisterMarshaler[T any](r *MarshalerRegistry, marshal func(T) ([]byte, error), unmarshal func([]byte) (T, error)) error {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if typ.Kind() == reflect.Interface {
+		return fmt.Errorf("%w: %s", ErrMarshalerInterface, typ)
+	}
+	if r == nil || marshal == nil || unmarshal == nil {
+		return nil
+	}
+	r.mu.Lock()
+	r.entries[typ] = typeMarshaler{
+		marshal: func(v interface{}) ([]byte, error) {
+			return marshal(v.(T))
+		},
+		unmarshal: func(data []byte, dest interface{}) error {
+			value, err := unmarshal(data)
+			if err != nil {
+				return err
+			}
+			*dest.(*T) = value
+			return nil
+		},
+	}
+	r.mu.Unlock()
+	return nil
+}
+
+// UnregisterMarshaler removes custom marshal functions for type T.
+func UnregisterMarshaler[T any](r *MarshalerRegistry) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	delete(r.entries, reflect.TypeOf((*T)(nil)).Elem())
+	r.mu.Unlock()
+}
+
+func (r *MarshalerRegistry) lookup(typ reflect.Type) (typeMarshaler, bool) {
+	if r == nil || typ == nil {
+		return typeMarshaler{}, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tm, ok := r.entries[typ]
+	return tm, ok
+}
+
+func (r *MarshalerRegistry) marshal(value interface{}) ([]byte, bool, error) {
+	tm, ok := r.lookup(reflect.TypeOf(value))
+	if !ok {
+		return nil, false, nil
+	}
+	payload, err := tm.marshal(value)
+	if err != nil {
+		return nil, true, fmt.Errorf("custom marshal failed: %w", err)
+	}
+	return payload, true, nil
+}
+
+func (r *MarshalerRegistry) unmarshal(data []byte, dest interface{}) (bool, error) {
+	typ := reflect.TypeOf(dest)
+	if typ == nil || typ.Kind() != reflect.Ptr {
+		return false, nil
+	}
+	tm, ok := r.lookup(typ.Elem())
+	if !ok {
+		return false, nil
+	}
+	return true, tm.unmarshal(data, dest)
+}
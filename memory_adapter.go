@@ -0,0 +1,1244 @@
+package eitcache
+
+import (
+	"bytes"
+	"container/heap"
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type memoryEntry struct {
+	key      string
+	data     []byte
+	value    interface{}
+	isValue  bool
+	expireAt time.Time
+	node     *list.Element
+	bucket   *list.Element
+	frequent bool
+	index    int
+
+	createdAt  time.Time
+	lastAccess atomic.Int64
+	hits       atomic.Int64
+}
+
+// bytes returns the stored payload, JSON-encoding entries held as values.
+func (e *memoryEntry) bytes() ([]byte, error) {
+	if !e.isValue {
+		return e.data, nil
+	}
+	return json.Marshal(e.value)
+}
+
+// touch records a read of the entry.
+func (e *memoryEntry) touch(now time.Time) {
+	e.lastAccess.Store(now.UnixNano())
+	e.hits.Add(1)
+}
+
+func (e *memoryEntry) info(now time.Time) EntryInfo {
+	info := EntryInfo{
+		Key:       e.key,
+		Size:      e.size(),
+		CreatedAt: e.createdAt,
+		Hits:      e.hits.Load(),
+		ExpiresAt: e.expireAt,
+	}
+	if last := e.lastAccess.Load(); last != 0 {
+		info.LastAccess = time.Unix(0, last)
+	}
+	if !e.expireAt.IsZero() {
+		info.TTL = e.expireAt.Sub(now)
+	}
+	return info
+}
+
+// size approximates the memory held by the entry.
+func (e *memoryEntry) size() int64 {
+	return int64(len(e.key) + len(e.data))
+}
+
+func (e *memoryEntry) expired(now time.Time) bool {
+	return !e.expireAt.IsZero() && now.After(e.expireAt)
+}
+
+// memoryIndex is a key set kept by AddToIndex. Index sets live outside
+// the entry map, so capacity eviction never drops them.
+type memoryIndex struct {
+	members  map[string]struct{}
+	expireAt time.Time
+}
+
+func (i *memoryIndex) expired(now time.Time) bool {
+	return !i.expireAt.IsZero() && now.After(i.expireAt)
+}
+
+// EntryInfo describes a memory adapter entry for debugging.
+type EntryInfo struct {
+	Key       string
+	Size      int64
+	CreatedAt time.Time
+	// LastAccess is zero if the entry was never read.
+	LastAccess time.Time
+	Hits       int64
+	// ExpiresAt and TTL are zero for entries without expiration.
+	ExpiresAt time.Time
+	TTL       time.Duration
+}
+
+// EntryOrder selects the ranking used by TopEntries.
+type EntryOrder int
+
+const (
+	ByHits EntryOrder = iota
+	BySize
+)
+
+// expiryHeap is a min-heap of entries ordered by expiration time.
+type expiryHeap []*memoryEntry
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].expireAt.Before(h[j].expireAt) }
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *expiryHeap) Push(x interface{}) {
+	e := x.(*memoryEntry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *expiryHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	e.index = -1
+	*h = old[:len(old)-1]
+	return e
+}
+
+// EvictReason tells why the memory adapter dropped an entry.
+type EvictReason int
+
+const (
+	// EvictCapacity means the entry was evicted to stay within
+	// MaxEntries or MaxMemoryBytes.
+	EvictCapacity EvictReason = iota + 1
+	// EvictExpired means the entry's TTL elapsed.
+	EvictExpired
+)
+
+// String returns the reason name.
+func (r EvictReason) String() string {
+	switch r {
+	case EvictCapacity:
+		return "capacity"
+	case EvictExpired:
+		return "expired"
+	default:
+		return fmt.Sprintf("evict_reason(%d)", int(r))
+	}
+}
+
+// DefaultCleanupInterval is how often NewMemoryCacheAdapterWithConfig
+// removes expired entries when CleanupInterval is unset.
+const DefaultCleanupInterval = time.Minute
+
+// DefaultSnapshotInterval is how often a configured SnapshotPath is
+// saved when SnapshotInterval is unset.
+const DefaultSnapshotInterval = 5 * time.Minute
+
+// evictionPolicy orders entries for capacity eviction. It is guarded by
+// the adapter lock.
+type evictionPolicy interface {
+	added(e *memoryEntry)
+	accessed(e *memoryEntry)
+	removed(e *memoryEntry)
+	victim() *memoryEntry
+}
+
+// lruPolicy evicts the least recently used entry.
+type lruPolicy struct {
+	order *list.List
+}
+
+func newLRUPolicy() *lruPolicy {
+	return &lruPolicy{order: list.New()}
+}
+
+func (p *lruPolicy) added(e *memoryEntry) {
+	e.node = p.order.PushFront(e)
+}
+
+func (p *lruPolicy) accessed(e *memoryEntry) {
+	p.order.MoveToFront(e.node)
+}
+
+func (p *lruPolicy) removed(e *memoryEntry) {
+	p.order.Remove(e.node)
+	e.node = nil
+}
+
+func (p *lruPolicy) victim() *memoryEntry {
+	if back := p.order.Back(); back != nil {
+		return back.Value.(*memoryEntry)
+	}
+	return nil
+}
+
+// EvictionPolicy selects which entry a bounded memory adapter evicts.
+type EvictionPolicy string
+
+const (
+	// EvictionLRU evicts the least recently used entry.
+	EvictionLRU EvictionPolicy = "lru"
+	// EvictionLFU evicts the least frequently used entry, breaking ties
+	// by recency. It keeps a skewed hot set resident better than LRU.
+	EvictionLFU EvictionPolicy = "lfu"
+	// EvictionARC balances recency and frequency adaptively, so one-off
+	// scans do not flush entries that are read repeatedly.
+	EvictionARC EvictionPolicy = "arc"
+)
+
+func newEvictionPolicy(kind EvictionPolicy) (evictionPolicy, error) {
+	switch kind {
+	case "", EvictionLRU:
+		return newLRUPolicy(), nil
+	case EvictionLFU:
+		return newLFUPolicy(), nil
+	case EvictionARC:
+		return newARCPolicy(), nil
+	default:
+		return nil, fmt.Errorf("unknown eviction policy %q", kind)
+	}
+}
+
+type lfuBucket struct {
+	count   int
+	entries *list.List
+}
+
+// lfuPolicy keeps entries in buckets of equal access count, ordered by
+// count, so every operation is O(1).
+type lfuPolicy struct {
+	buckets *list.List
+}
+
+func newLFUPolicy() *lfuPolicy {
+	return &lfuPolicy{buckets: list.New()}
+}
+
+func (p *lfuPolicy) added(e *memoryEntry) {
+	front := p.buckets.Front()
+	if front == nil || front.Value.(*lfuBucket).count != 1 {
+		front = p.buckets.PushFront(&lfuBucket{count: 1, entries: list.New()})
+	}
+	e.bucket = front
+	e.node = front.Value.(*lfuBucket).entries.PushFront(e)
+}
+
+func (p *lfuPolicy) accessed(e *memoryEntry) {
+	cur := e.bucket
+	count := cur.Value.(*lfuBucket).count + 1
+	next := cur.Next()
+	if next == nil || next.Value.(*lfuBucket).count != count {
+		next = p.buckets.InsertAfter(&lfuBucket{count: count, entries: list.New()}, cur)
+	}
+	p.detach(e)
+	e.bucket = next
+	e.node = next.Value.(*lfuBucket).entries.PushFront(e)
+}
+
+func (p *lfuPolicy) removed(e *memoryEntry) {
+	p.detach(e)
+	e.node, e.bucket = nil, nil
+}
+
+func (p *lfuPolicy) detach(e *memoryEntry) {
+	bucket := e.bucket.Value.(*lfuBucket)
+	bucket.entries.Remove(e.node)
+	if bucket.entries.Len() == 0 {
+		p.buckets.Remove(e.bucket)
+	}
+}
+
+func (p *lfuPolicy) victim() *memoryEntry {
+	front := p.buckets.Front()
+	if front == nil {
+		return nil
+	}
+	return front.Value.(*lfuBucket).entries.Back().Value.(*memoryEntry)
+}
+
+// ghostPolicy is implemented by policies that remember evicted keys.
+type ghostPolicy interface {
+	evicted(e *memoryEntry)
+}
+
+// arcPolicy implements Adaptive Replacement Cache. Resident entries live
+// in recent (seen once) or frequent (seen again); ghost lists remember
+// recently evicted keys from each, and hits on them shift the target
+// size of recent toward whichever list would have kept the entry.
+type arcPolicy struct {
+	recent, frequent *list.List
+	recentGhosts     *list.List
+	frequentGhosts   *list.List
+	ghosts           map[string]*list.Element
+	target           int
+}
+
+type arcGhost struct {
+	key      string
+	frequent bool
+}
+
+func newARCPolicy() *arcPolicy {
+	return &arcPolicy{
+		recent:         list.New(),
+		frequent:       list.New(),
+		recentGhosts:   list.New(),
+		frequentGhosts: list.New(),
+		ghosts:         make(map[string]*list.Element),
+	}
+}
+
+// capacity approximates the ARC cache size by the resident entry count,
+// which also works when the adapter is bounded by bytes.
+func (p *arcPolicy) capacity() int {
+	if n := p.recent.Len() + p.frequent.Len(); n > 0 {
+		return n
+	}
+	return 1
+}
+
+func (p *arcPolicy) added(e *memoryEntry) {
+	ghost, ok := p.ghosts[e.key]
+	if !ok {
+		e.frequent = false
+		e.node = p.recent.PushFront(e)
+		return
+	}
+
+	c := p.capacity()
+	if ghost.Value.(*arcGhost).frequent {
+		p.target -= max(p.recentGhosts.Len()/max(p.frequentGhosts.Len(), 1), 1)
+		p.target = max(p.target, 0)
+		p.frequentGhosts.Remove(ghost)
+	} else {
+		p.target += max(p.frequentGhosts.Len()/max(p.recentGhosts.Len(), 1), 1)
+		p.target = min(p.target, c)
+		p.recentGhosts.Remove(ghost)
+	}
+	delete(p.ghosts, e.key)
+	e.frequent = true
+	e.node = p.frequent.PushFront(e)
+}
+
+func (p *arcPolicy) accessed(e *memoryEntry) {
+	if e.frequent {
+		p.frequent.MoveToFront(e.node)
+		return
+	}
+	p.recent.Remove(e.node)
+	e.frequent = true
+	e.node = p.frequent.PushFront(e)
+}
+
+func (p *arcPolicy) removed(e *memoryEntry) {
+	if e.frequent {
+		p.frequent.Remove(e.node)
+	} else {
+		p.recent.Remove(e.node)
+	}
+	e.node = nil
+}
+
+func (p *arcPolicy) evicted(e *memoryEntry) {
+	ghosts := p.recentGhosts
+	if e.frequent {
+		ghosts = p.frequentGhosts
+	}
+	p.ghosts[e.key] = ghosts.PushFront(&arcGhost{key: e.key, frequent: e.frequent})
+	c := p.capacity()
+	for _, l := range []*list.List{p.recentGhosts, p.frequentGhosts} {
+		for l.Len() > c {
+			delete(p.ghosts, l.Remove(l.Back()).(*arcGhost).key)
+		}
+	}
+}
+
+func (p *arcPolicy) victim() *memoryEntry {
+	from := p.frequent
+	if p.recent.Len() > 0 && (p.recent.Len() > p.target || p.frequent.Len() == 0) {
+		from = p.recent
+	}
+	if back := from.Back(); back != nil {
+		return back.Value.(*memoryEntry)
+	}
+	return nil
+}
+
+// MemoryCacheAdapter implements Adapter with in-memory map.
+type MemoryCacheAdapter struct {
+	mu         sync.RWMutex
+	cache      map[string]*memoryEntry
+	indexes    map[string]*memoryIndex
+	defaultTTL time.Duration
+	clock      Clock
+	maxEntries int
+
+	storeValues bool
+	copyOnRead  bool
+	pinned      map[string]struct{}
+	maxBytes    int64
+	usedBytes   int64
+	policyKind  EvictionPolicy
+	policy      evictionPolicy
+	expiries    expiryHeap
+	onEvict     func(key string, size int)
+	listeners   []func(key string, value []byte, reason EvictReason)
+
+	janitorMu    sync.Mutex
+	stopJanitor  chan struct{}
+	stopSnapshot chan struct{}
+	snapshotPath string
+}
+
+// NewMemoryCacheAdapter creates a memory adapter.
+func NewMemoryCacheAdapter(defaultTTL time.Duration) *MemoryCacheAdapter {
+	return &MemoryCacheAdapter{
+		cache:      make(map[string]*memoryEntry),
+		indexes:    make(map[string]*memoryIndex),
+		defaultTTL: defaultTTL,
+		clock:      SystemClock,
+	}
+}
+
+// SetClock replaces the time source; call it before the adapter is used.
+// A nil clock restores SystemClock.
+func (m *MemoryCacheAdapter) SetClock(clock Clock) {
+	m.clock = clockOrSystem(clock)
+}
+
+// NewMemoryCacheAdapterWithConfig creates a memory adapter bounded by
+// config.MaxEntries and config.MaxMemoryBytes using config.EvictionPolicy.
+// With SnapshotPath set it persists periodically and, if RestoreOnStart
+// is set, starts from the previous snapshot when one exists.
+func NewMemoryCacheAdapterWithConfig(config *CacheConfig) (*MemoryCacheAdapter, error) {
+	m := NewMemoryCacheAdapter(config.DefaultTTL)
+	m.SetClock(config.Clock)
+	m.SetStoreValues(config.StoreValues, config.CopyOnRead)
+	m.Pin(config.Pinned...)
+	if err := m.SetEvictionPolicy(config.EvictionPolicy); err != nil {
+		return nil, err
+	}
+	m.SetMaxEntries(config.MaxEntries)
+	m.SetMaxMemoryBytes(config.MaxMemoryBytes)
+	interval := config.CleanupInterval
+	if interval == 0 {
+		interval = DefaultCleanupInterval
+	}
+	m.SetCleanupInterval(interval)
+
+	if path := config.SnapshotPath; path != "" {
+		if config.RestoreOnStart {
+			if err := m.LoadSnapshotFile(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				m.Close()
+				return nil, fmt.Errorf("restore snapshot failed: %w", err)
+			}
+		}
+		interval := config.SnapshotInterval
+		if interval == 0 {
+			interval = DefaultSnapshotInterval
+		}
+		var onError func(error)
+		if handler := config.OnError; handler != nil {
+			onError = func(err error) { handler(context.Background(), OpSnapshot, path, err) }
+		}
+		m.SetSnapshotInterval(path, interval, onError)
+	}
+	return m, nil
+}
+
+// SetCleanupInterval starts a background janitor that removes expired
+// entries every d; entries are otherwise only dropped when read. A
+// non-positive d stops the janitor. Close stops it as well.
+func (m *MemoryCacheAdapter) SetCleanupInterval(d time.Duration) {
+	m.janitorMu.Lock()
+	defer m.janitorMu.Unlock()
+	if m.stopJanitor != nil {
+		close(m.stopJanitor)
+		m.stopJanitor = nil
+	}
+	if d <= 0 {
+		return
+	}
+	m.stopJanitor = every(m.clock, d, func() { m.deleteExpired(m.clock.Now()) })
+}
+
+// every runs fn every d on clock until the returned channel is closed.
+func every(clock Clock, d time.Duration, fn func()) chan struct{} {
+	stop := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-clock.After(d):
+				fn()
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return stop
+}
+
+// deleteExpired pops due entries off the expiration heap, so the cost
+// is proportional to the number of expired entries rather than the map.
+func (m *MemoryCacheAdapter) deleteExpired(now time.Time) {
+	var expired []*memoryEntry
+	m.mu.Lock()
+	for len(m.expiries) > 0 && m.expiries[0].expired(now) {
+		entry := m.expiries[0]
+		m.removeLocked(entry)
+		expired = append(expired, entry)
+	}
+	for name, index := range m.indexes {
+		if index.expired(now) {
+			delete(m.indexes, name)
+		}
+	}
+	m.mu.Unlock()
+	m.notifyEvicted(expired, EvictExpired)
+}
+
+// SetMaxEntries bounds the number of entries, evicting by the eviction
+// policy (LRU by default) beyond n. Zero means unbounded.
+func (m *MemoryCacheAdapter) SetMaxEntries(n int) {
+	if n < 0 {
+		n = 0
+	}
+	m.mu.Lock()
+	m.maxEntries = n
+	evicted := m.boundLocked()
+	m.mu.Unlock()
+	m.notifyEvicted(evicted, EvictCapacity)
+}
+
+// SetMaxMemoryBytes bounds the total size of stored keys and payloads,
+// evicting by the eviction policy beyond n bytes. Zero means unbounded.
+func (m *MemoryCacheAdapter) SetMaxMemoryBytes(n int64) {
+	if n < 0 {
+		n = 0
+	}
+	m.mu.Lock()
+	m.maxBytes = n
+	evicted := m.boundLocked()
+	m.mu.Unlock()
+	m.notifyEvicted(evicted, EvictCapacity)
+}
+
+// boundLocked starts tracking entries once a capacity limit is set and
+// evicts down to it.
+func (m *MemoryCacheAdapter) boundLocked() []*memoryEntry {
+	if (m.maxEntries > 0 || m.maxBytes > 0) && m.policy == nil {
+		m.policy, _ = newEvictionPolicy(m.policyKind)
+		m.trackAllLocked()
+	}
+	return m.evictLocked()
+}
+
+// SetEvictionPolicy selects the eviction policy. Switching policies
+// restarts access tracking for existing entries.
+func (m *MemoryCacheAdapter) SetEvictionPolicy(kind EvictionPolicy) error {
+	policy, err := newEvictionPolicy(kind)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.policyKind = kind
+	if m.policy != nil {
+		m.policy = policy
+		m.trackAllLocked()
+	}
+	return nil
+}
+
+func (m *MemoryCacheAdapter) trackAllLocked() {
+	for _, entry := range m.cache {
+		entry.node, entry.bucket, entry.frequent = nil, nil, false
+		if !m.pinnedLocked(entry.key) {
+			m.policy.added(entry)
+		}
+	}
+}
+
+// Pin protects keys from capacity eviction and from DeletePattern, and
+// so Manager.Clear; a pattern ending in * pins every key with that
+// prefix. Pinned entries still expire and can be removed with Delete.
+func (m *MemoryCacheAdapter) Pin(patterns ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.pinned == nil {
+		m.pinned = make(map[string]struct{})
+	}
+	for _, p := range patterns {
+		m.pinned[p] = struct{}{}
+	}
+	if m.policy == nil {
+		return
+	}
+	for _, entry := range m.cache {
+		if entry.node != nil && m.pinnedLocked(entry.key) {
+			m.policy.removed(entry)
+			entry.node, entry.bucket, entry.frequent = nil, nil, false
+		}
+	}
+}
+
+// Unpin removes patterns registered with Pin, making matching entries
+// evictable again.
+func (m *MemoryCacheAdapter) Unpin(patterns ...string) {
+	m.mu.Lock()
+	for _, p := range patterns {
+		delete(m.pinned, p)
+	}
+	var evicted []*memoryEntry
+	if m.policy != nil {
+		for _, entry := range m.cache {
+			if entry.node == nil && !m.pinnedLocked(entry.key) {
+				m.policy.added(entry)
+			}
+		}
+		evicted = m.evictLocked()
+	}
+	m.mu.Unlock()
+	m.notifyEvicted(evicted, EvictCapacity)
+}
+
+func (m *MemoryCacheAdapter) pinnedLocked(key string) bool {
+	if len(m.pinned) == 0 {
+		return false
+	}
+	if _, ok := m.pinned[key]; ok {
+		return true
+	}
+	for p := range m.pinned {
+		if strings.HasSuffix(p, "*") && strings.HasPrefix(key, p[:len(p)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// storeLocked inserts or replaces key and returns entries evicted to
+// make room.
+func (m *MemoryCacheAdapter) storeLocked(key string, data []byte, expireAt time.Time) []*memoryEntry {
+	return m.insertLocked(&memoryEntry{key: key, data: data, expireAt: expireAt})
+}
+
+func (m *MemoryCacheAdapter) insertLocked(entry *memoryEntry) []*memoryEntry {
+	if old, ok := m.cache[entry.key]; ok {
+		m.removeLocked(old)
+	}
+	entry.index = -1
+	entry.createdAt = m.clock.Now()
+	m.cache[entry.key] = entry
+	m.usedBytes += entry.size()
+	if !entry.expireAt.IsZero() {
+		heap.Push(&m.expiries, entry)
+	}
+	if m.policy != nil && !m.pinnedLocked(entry.key) {
+		m.policy.added(entry)
+	}
+	return m.evictLocked()
+}
+
+func (m *MemoryCacheAdapter) removeLocked(entry *memoryEntry) {
+	delete(m.cache, entry.key)
+	m.usedBytes -= entry.size()
+	if entry.index >= 0 {
+		heap.Remove(&m.expiries, entry.index)
+	}
+	if m.policy != nil && entry.node != nil {
+		m.policy.removed(entry)
+	}
+}
+
+func (m *MemoryCacheAdapter) evictLocked() []*memoryEntry {
+	if m.policy == nil {
+		return nil
+	}
+	var evicted []*memoryEntry
+	for (m.maxEntries > 0 && len(m.cache) > m.maxEntries) || (m.maxBytes > 0 && m.usedBytes > m.maxBytes) {
+		victim := m.policy.victim()
+		if victim == nil {
+			break
+		}
+		m.removeLocked(victim)
+		if ghosts, ok := m.policy.(ghostPolicy); ok {
+			ghosts.evicted(victim)
+		}
+		evicted = append(evicted, victim)
+	}
+	return evicted
+}
+
+func (m *MemoryCacheAdapter) notifyEvicted(evicted []*memoryEntry, reason EvictReason) {
+	if len(evicted) == 0 {
+		return
+	}
+	m.mu.RLock()
+	onEvict := m.onEvict
+	listeners := m.listeners
+	m.mu.RUnlock()
+	for _, entry := range evicted {
+		if onEvict != nil {
+			onEvict(entry.key, len(entry.data))
+		}
+		for _, fn := range listeners {
+			fn(entry.key, entry.data, reason)
+		}
+	}
+}
+
+func (m *MemoryCacheAdapter) expireAt(ttl time.Duration, now time.Time) time.Time {
+	if ttl == 0 {
+		ttl = m.defaultTTL
+	}
+	if ttl > 0 {
+		return now.Add(ttl)
+	}
+	return time.Time{}
+}
+
+// Set stores a value in memory.
+func (m *MemoryCacheAdapter) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	_ = ctx
+	payload, err := marshalAdapterValue(value)
+	if err != nil {
+		return fmt.Errorf("marshal value failed: %w", err)
+	}
+	expireAt := m.expireAt(ttl, m.clock.Now())
+
+	m.mu.Lock()
+	evicted := m.storeLocked(key, payload, expireAt)
+	m.mu.Unlock()
+	m.notifyEvicted(evicted, EvictCapacity)
+	return nil
+}
+
+// SetNX stores a value only if key does not exist or has expired.
+func (m *MemoryCacheAdapter) SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error) {
+	_ = ctx
+	payload, err := marshalAdapterValue(value)
+	if err != nil {
+		return false, fmt.Errorf("marshal value failed: %w", err)
+	}
+	now := m.clock.Now()
+	expireAt := m.expireAt(ttl, now)
+
+	m.mu.Lock()
+	if entry, exists := m.cache[key]; exists && !entry.expired(now) {
+		m.mu.Unlock()
+		return false, nil
+	}
+	evicted := m.storeLocked(key, payload, expireAt)
+	m.mu.Unlock()
+	m.notifyEvicted(evicted, EvictCapacity)
+	return true, nil
+}
+
+// CompareAndDelete deletes key only if it holds expected.
+func (m *MemoryCacheAdapter) CompareAndDelete(ctx context.Context, key string, expected []byte) (bool, error) {
+	_ = ctx
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, exists := m.cache[key]
+	if !exists || !bytes.Equal(entry.data, expected) {
+		return false, nil
+	}
+	m.removeLocked(entry)
+	return true, nil
+}
+
+// Get retrieves cached bytes. Entries stored with SetValue are returned
+// JSON-encoded.
+func (m *MemoryCacheAdapter) Get(ctx context.Context, key string) ([]byte, error) {
+	_ = ctx
+	entry, err := m.lookup(key)
+	if err != nil {
+		return nil, err
+	}
+	return entry.bytes()
+}
+
+// lookup returns the live entry for key, recording the access.
+func (m *MemoryCacheAdapter) lookup(key string) (*memoryEntry, error) {
+	m.mu.RLock()
+	entry, exists := m.cache[key]
+	tracked := m.policy != nil
+	m.mu.RUnlock()
+	if !exists {
+		return nil, ErrCacheMiss
+	}
+
+	now := m.clock.Now()
+	if entry.expired(now) {
+		m.mu.Lock()
+		evicted := m.cache[key] == entry
+		if evicted {
+			m.removeLocked(entry)
+		}
+		m.mu.Unlock()
+		if evicted {
+			m.notifyEvicted([]*memoryEntry{entry}, EvictExpired)
+		}
+		return nil, ErrCacheMiss
+	}
+
+	if tracked {
+		m.mu.Lock()
+		if m.policy != nil && m.cache[key] == entry && entry.node != nil {
+			m.policy.accessed(entry)
+		}
+		m.mu.Unlock()
+	}
+	entry.touch(now)
+	return entry, nil
+}
+
+// SetStoreValues switches the adapter to holding Go values as-is for
+// Manager, skipping serialization. With copyOnRead, values are deep
+// copied on SetValue and GetValue so callers cannot mutate the cached
+// value; such values must not contain cycles. Value entries count only their key toward
+// MaxMemoryBytes and are left out of snapshots.
+func (m *MemoryCacheAdapter) SetStoreValues(enabled, copyOnRead bool) {
+	m.mu.Lock()
+	m.storeValues = enabled
+	m.copyOnRead = copyOnRead
+	m.mu.Unlock()
+}
+
+// StoresValues implements ValueAdapter.
+func (m *MemoryCacheAdapter) StoresValues() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.storeValues
+}
+
+// SetValue stores value without serializing it.
+func (m *MemoryCacheAdapter) SetValue(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	_ = ctx
+	m.mu.Lock()
+	if m.copyOnRead {
+		value = deepCopy(value)
+	}
+	evicted := m.insertLocked(&memoryEntry{
+		key:      key,
+		value:    value,
+		isValue:  true,
+		expireAt: m.expireAt(ttl, m.clock.Now()),
+	})
+	m.mu.Unlock()
+	m.notifyEvicted(evicted, EvictCapacity)
+	return nil
+}
+
+// GetValue returns a value stored with SetValue, or the payload of a
+// byte entry as RawValue.
+func (m *MemoryCacheAdapter) GetValue(ctx context.Context, key string) (interface{}, error) {
+	_ = ctx
+	entry, err := m.lookup(key)
+	if err != nil {
+		return nil, err
+	}
+	if !entry.isValue {
+		return RawValue(entry.data), nil
+	}
+	m.mu.RLock()
+	copyOnRead := m.copyOnRead
+	m.mu.RUnlock()
+	if copyOnRead {
+		return deepCopy(entry.value), nil
+	}
+	return entry.value, nil
+}
+
+// Inspect returns metadata for key, or false if it is missing or expired.
+func (m *MemoryCacheAdapter) Inspect(key string) (EntryInfo, bool) {
+	now := m.clock.Now()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	entry, exists := m.cache[key]
+	if !exists || entry.expired(now) {
+		return EntryInfo{}, false
+	}
+	return entry.info(now), true
+}
+
+// TopEntries returns up to n live entries ranked by order, highest first.
+func (m *MemoryCacheAdapter) TopEntries(n int, order EntryOrder) []EntryInfo {
+	if n <= 0 {
+		return nil
+	}
+	now := m.clock.Now()
+	m.mu.RLock()
+	infos := make([]EntryInfo, 0, len(m.cache))
+	for _, entry := range m.cache {
+		if !entry.expired(now) {
+			infos = append(infos, entry.info(now))
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(infos, func(i, j int) bool {
+		if order == BySize {
+			return infos[i].Size > infos[j].Size
+		}
+		return infos[i].Hits > infos[j].Hits
+	})
+	if len(infos) > n {
+		infos = infos[:n]
+	}
+	return infos
+}
+
+// SetEvictionCallback registers fn to be called when an entry is
+// dropped because it expired or the adapter ran out of capacity.
+func (m *MemoryCacheAdapter) SetEvictionCallback(fn func(key string, size int)) {
+	m.mu.Lock()
+	m.onEvict = fn
+	m.mu.Unlock()
+}
+
+// OnEvict registers fn to receive every entry the adapter drops on its
+// own, with the payload and the reason. Callbacks run synchronously
+// after the adapter lock is released, so they may call back into it.
+func (m *MemoryCacheAdapter) OnEvict(fn func(key string, value []byte, reason EvictReason)) {
+	if fn == nil {
+		return
+	}
+	m.mu.Lock()
+	m.listeners = append(m.listeners[:len(m.listeners):len(m.listeners)], fn)
+	m.mu.Unlock()
+}
+
+// SetBatch writes entries under a single lock.
+func (m *MemoryCacheAdapter) SetBatch(ctx context.Context, entries []BatchEntry) error {
+	_ = ctx
+	now := m.clock.Now()
+	payloads := make([][]byte, len(entries))
+	for i, entry := range entries {
+		payload, err := marshalAdapterValue(entry.Value)
+		if err != nil {
+			return fmt.Errorf("marshal value failed: %w", err)
+		}
+		payloads[i] = payload
+	}
+
+	var evicted []*memoryEntry
+	m.mu.Lock()
+	for i, entry := range entries {
+		evicted = append(evicted, m.storeLocked(entry.Key, payloads[i], m.expireAt(entry.TTL, now))...)
+	}
+	m.mu.Unlock()
+	m.notifyEvicted(evicted, EvictCapacity)
+	return nil
+}
+
+// GetMulti retrieves many keys under a single lock.
+func (m *MemoryCacheAdapter) GetMulti(ctx context.Context, keys []string) (map[string][]byte, error) {
+	_ = ctx
+	result := make(map[string][]byte, len(keys))
+	now := m.clock.Now()
+	// Recording accesses changes the policy, so it needs the write lock;
+	// without a policy, reads share the read lock.
+	m.mu.RLock()
+	if m.policy == nil {
+		defer m.mu.RUnlock()
+	} else {
+		m.mu.RUnlock()
+		m.mu.Lock()
+		defer m.mu.Unlock()
+	}
+	for _, k := range keys {
+		entry, exists := m.cache[k]
+		if !exists || entry.expired(now) {
+			continue
+		}
+		if m.policy != nil && entry.node != nil {
+			m.policy.accessed(entry)
+		}
+		data, err := entry.bytes()
+		if err != nil {
+			continue
+		}
+		entry.touch(now)
+		result[k] = data
+	}
+	return result, nil
+}
+
+// Delete removes keys.
+func (m *MemoryCacheAdapter) Delete(ctx context.Context, keys ...string) error {
+	_ = ctx
+	m.mu.Lock()
+	for _, k := range keys {
+		if entry, ok := m.cache[k]; ok {
+			m.removeLocked(entry)
+		}
+		delete(m.indexes, k)
+	}
+	m.mu.Unlock()
+	return nil
+}
+
+// DeletePattern deletes keys with a prefix pattern, skipping pinned keys.
+func (m *MemoryCacheAdapter) DeletePattern(ctx context.Context, pattern string) (int64, error) {
+	_ = ctx
+	if pattern == "" {
+		return 0, nil
+	}
+	prefix := strings.TrimSuffix(pattern, "*")
+	var count int64
+	m.mu.Lock()
+	for k, entry := range m.cache {
+		if strings.HasPrefix(k, prefix) && !m.pinnedLocked(k) {
+			m.removeLocked(entry)
+			count++
+		}
+	}
+	for name := range m.indexes {
+		if strings.HasPrefix(name, prefix) && !m.pinnedLocked(name) {
+			delete(m.indexes, name)
+			count++
+		}
+	}
+	m.mu.Unlock()
+	return count, nil
+}
+
+// Keys returns live keys matching the glob pattern, sorted. An empty
+// pattern matches every key.
+func (m *MemoryCacheAdapter) Keys(pattern string) []string {
+	if pattern == "" {
+		pattern = "*"
+	}
+	now := m.clock.Now()
+	var keys []string
+	m.mu.RLock()
+	for k, entry := range m.cache {
+		if !entry.expired(now) && globMatch(pattern, k) {
+			keys = append(keys, k)
+		}
+	}
+	m.mu.RUnlock()
+	sort.Strings(keys)
+	return keys
+}
+
+// Scan implements ScanAdapter.
+func (m *MemoryCacheAdapter) Scan(ctx context.Context, pattern string) ([]string, error) {
+	_ = ctx
+	return m.Keys(pattern), nil
+}
+
+// globMatch reports whether key matches pattern, where * matches any
+// run of characters and ? matches exactly one.
+func globMatch(pattern, key string) bool {
+	p, k := 0, 0
+	star, mark := -1, 0
+	for k < len(key) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == key[k]):
+			p++
+			k++
+		case p < len(pattern) && pattern[p] == '*':
+			star, mark = p, k
+			p++
+		case star >= 0:
+			mark++
+			p, k = star+1, mark
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// AddToIndex adds keys to the set index and resets its TTL.
+func (m *MemoryCacheAdapter) AddToIndex(ctx context.Context, index string, ttl time.Duration, keys ...string) error {
+	_ = ctx
+	if len(keys) == 0 {
+		return nil
+	}
+	now := m.clock.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	set, ok := m.indexes[index]
+	if !ok || set.expired(now) {
+		set = &memoryIndex{members: make(map[string]struct{}, len(keys))}
+		m.indexes[index] = set
+	}
+	for _, key := range keys {
+		set.members[key] = struct{}{}
+	}
+	set.expireAt = m.expireAt(ttl, now)
+	return nil
+}
+
+// IndexMembers returns the keys added to index, sorted.
+func (m *MemoryCacheAdapter) IndexMembers(ctx context.Context, index string) ([]string, error) {
+	_ = ctx
+	now := m.clock.Now()
+	m.mu.RLock()
+	set, ok := m.indexes[index]
+	if !ok || set.expired(now) {
+		m.mu.RUnlock()
+		return nil, nil
+	}
+	members := make([]string, 0, len(set.members))
+	for key := range set.members {
+		members = append(members, key)
+	}
+	m.mu.RUnlock()
+	sort.Strings(members)
+	return members, nil
+}
+
+// Exists checks if a key exists.
+func (m *MemoryCacheAdapter) Exists(ctx context.Context, key string) (bool, error) {
+	_ = ctx
+	m.mu.RLock()
+	entry, exists := m.cache[key]
+	m.mu.RUnlock()
+	if !exists {
+		return false, nil
+	}
+	return !entry.expired(m.clock.Now()), nil
+}
+
+// Incr increments a counter.
+func (m *MemoryCacheAdapter) Incr(ctx context.Context, key string) (int64, error) {
+	return m.addDelta(ctx, key, 1)
+}
+
+// Decr decrements a counter.
+func (m *MemoryCacheAdapter) Decr(ctx context.Context, key string) (int64, error) {
+	return m.addDelta(ctx, key, -1)
+}
+
+func (m *MemoryCacheAdapter) addDelta(ctx context.Context, key string, delta int64) (int64, error) {
+	_ = ctx
+	m.mu.Lock()
+	entry, exists := m.cache[key]
+	if exists && entry.expired(m.clock.Now()) {
+		m.removeLocked(entry)
+		exists = false
+	}
+
+	var current int64
+	if exists {
+		if data, err := entry.bytes(); err == nil {
+			_ = json.Unmarshal(data, &current)
+		}
+	}
+	current += delta
+
+	payload, err := json.Marshal(current)
+	if err != nil {
+		m.mu.Unlock()
+		return 0, err
+	}
+
+	expireAt := time.Time{}
+	if exists {
+		expireAt = entry.expireAt
+	}
+	evicted := m.storeLocked(key, payload, expireAt)
+	m.mu.Unlock()
+	m.notifyEvicted(evicted, EvictCapacity)
+	return current, nil
+}
+
+// entryOverhead approximates the per-entry bookkeeping cost beyond the
+// key and payload: the entry struct, map slot and policy list element.
+const entryOverhead = 160
+
+// statsLargestEntries is how many of the largest entries Stats lists.
+const statsLargestEntries = 5
+
+// Stats returns memory stats, including an estimate of memory used.
+func (m *MemoryCacheAdapter) Stats(ctx context.Context) (map[string]interface{}, error) {
+	_ = ctx
+	m.mu.RLock()
+	total := len(m.cache)
+	expired := 0
+	now := m.clock.Now()
+	for _, entry := range m.cache {
+		if entry.expired(now) {
+			expired++
+		}
+	}
+	used := m.usedBytes
+	maxEntries, maxBytes := m.maxEntries, m.maxBytes
+	m.mu.RUnlock()
+
+	var avg int64
+	if total > 0 {
+		avg = used / int64(total)
+	}
+	largest := make([]map[string]interface{}, 0, statsLargestEntries)
+	for _, info := range m.TopEntries(statsLargestEntries, BySize) {
+		largest = append(largest, map[string]interface{}{"key": info.Key, "size": info.Size})
+	}
+
+	return map[string]interface{}{
+		"total_items":     total,
+		"expired_items":   expired,
+		"active_items":    total - expired,
+		"max_entries":     maxEntries,
+		"used_bytes":      used,
+		"max_bytes":       maxBytes,
+		"estimated_bytes": used + int64(total)*entryOverhead,
+		"avg_entry_bytes": avg,
+		"largest_entries": largest,
+	}, nil
+}
+
+// Ping checks memory adapter health.
+func (m *MemoryCacheAdapter) Ping(ctx context.Context) error {
+	_ = ctx
+	return nil
+}
+
+// Close stops the cleanup janitor and periodic snapshots, writing a
+// final snapshot if one is scheduled.
+func (m *MemoryCacheAdapter) Close() error {
+	m.SetCleanupInterval(0)
+	m.janitorMu.Lock()
+	path := m.snapshotPath
+	m.janitorMu.Unlock()
+	if path == "" {
+		return nil
+	}
+	m.SetSnapshotInterval("", 0, nil)
+	return m.SaveSnapshotFile(path)
+}
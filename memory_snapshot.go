@@ -0,0 +1,131 @@
+package eitcache
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const snapshotVersion = 1
+
+type snapshot struct {
+	Version int
+	Entries []snapshotEntry
+}
+
+// snapshotEntry stores expiration as wall-clock Unix nanoseconds so TTLs
+// keep counting down across restarts. Zero means no expiration.
+type snapshotEntry struct {
+	Key      string
+	Data     []byte
+	ExpireAt int64
+}
+
+// SaveSnapshot writes all live byte entries to w. Entries stored with
+// SetValue are skipped.
+func (m *MemoryCacheAdapter) SaveSnapshot(w io.Writer) error {
+	now := m.clock.Now()
+	m.mu.RLock()
+	snap := snapshot{Version: snapshotVersion, Entries: make([]snapshotEntry, 0, len(m.cache))}
+	for _, entry := range m.cache {
+		if entry.expired(now) || entry.isValue {
+			continue
+		}
+		record := snapshotEntry{Key: entry.key, Data: entry.data}
+		if !entry.expireAt.IsZero() {
+			record.ExpireAt = entry.expireAt.UnixNano()
+		}
+		snap.Entries = append(snap.Entries, record)
+	}
+	m.mu.RUnlock()
+
+	if err := gob.NewEncoder(w).Encode(&snap); err != nil {
+		return fmt.Errorf("encode snapshot failed: %w", err)
+	}
+	return nil
+}
+
+// LoadSnapshot restores entries written by SaveSnapshot, overwriting
+// keys that already exist. Entries that expired meanwhile are skipped.
+func (m *MemoryCacheAdapter) LoadSnapshot(r io.Reader) error {
+	var snap snapshot
+	if err := gob.NewDecoder(r).Decode(&snap); err != nil {
+		return fmt.Errorf("decode snapshot failed: %w", err)
+	}
+	if snap.Version != snapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d", snap.Version)
+	}
+
+	now := m.clock.Now()
+	var evicted []*memoryEntry
+	m.mu.Lock()
+	for _, record := range snap.Entries {
+		var expireAt time.Time
+		if record.ExpireAt != 0 {
+			expireAt = time.Unix(0, record.ExpireAt)
+			if !expireAt.After(now) {
+				continue
+			}
+		}
+		evicted = append(evicted, m.storeLocked(record.Key, record.Data, expireAt)...)
+	}
+	m.mu.Unlock()
+	m.notifyEvicted(evicted, EvictCapacity)
+	return nil
+}
+
+// SaveSnapshotFile atomically replaces path with a snapshot.
+func (m *MemoryCacheAdapter) SaveSnapshotFile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("create snapshot failed: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := m.SaveSnapshot(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write snapshot failed: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("write snapshot failed: %w", err)
+	}
+	return nil
+}
+
+// LoadSnapshotFile restores a snapshot saved with SaveSnapshotFile.
+func (m *MemoryCacheAdapter) LoadSnapshotFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return m.LoadSnapshot(f)
+}
+
+// SetSnapshotInterval saves a snapshot to path every d and once more on
+// Close. Failed saves are passed to onError if it is not nil. An empty
+// path or non-positive d stops periodic snapshots.
+func (m *MemoryCacheAdapter) SetSnapshotInterval(path string, d time.Duration, onError func(error)) {
+	m.janitorMu.Lock()
+	defer m.janitorMu.Unlock()
+	if m.stopSnapshot != nil {
+		close(m.stopSnapshot)
+		m.stopSnapshot = nil
+	}
+	m.snapshotPath = ""
+	if path == "" || d <= 0 {
+		return
+	}
+	m.snapshotPath = path
+	m.stopSnapshot = every(m.clock, d, func() {
+		if err := m.SaveSnapshotFile(path); err != nil && onError != nil {
+			onError(err)
+		}
+	})
+}
@@ -2,16 +2,31 @@ package eitcache
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // CacheMetrics stores cache metrics.
 type CacheMetrics struct {
-	HitCount        int64         `json:"hit_count"`
-	MissCount       int64         `json:"miss_count"`
-	EvictionCount   int64         `json:"eviction_count"`
-	LastUpdate      time.Time     `json:"last_update"`
-	AvgResponseTime time.Duration `json:"avg_response_time"`
+	HitCount           int64                     `json:"hit_count"`
+	MissCount          int64                     `json:"miss_count"`
+	EvictionCount      int64                     `json:"eviction_count"`
+	StaleServeCount    int64                     `json:"stale_serve_count"`
+	BreakerState       string                    `json:"breaker_state,omitempty"`
+	BreakerOpens       int64                     `json:"breaker_opens"`
+	BreakerBypassCount int64                     `json:"breaker_bypass_count"`
+	GetErrorCount      int64                     `json:"get_error_count"`
+	SetErrorCount      int64                     `json:"set_error_count"`
+	RetryCount         int64                     `json:"retry_count"`
+	WriteBehindDrop    int64                     `json:"write_behind_drop"`
+	LastUpdate         time.Time                 `json:"last_update"`
+	AvgResponseTime    time.Duration             `json:"avg_response_time"`
+	HitLatency         LatencySummary            `json:"hit_latency"`
+	MissLatency        LatencySummary            `json:"miss_latency"`
+	PayloadSize        SizeSummary               `json:"payload_size"`
+	ErrorsByType       map[string]int64          `json:"errors_by_type,omitempty"`
+	Windows            map[string]WindowMetrics  `json:"windows,omitempty"`
+	OpLatency          map[string]LatencySummary `json:"op_latency,omitempty"`
 }
 
 // Monitor tracks cache performance metrics.
@@ -19,33 +34,57 @@ type Monitor struct {
 	mu       sync.RWMutex
 	metrics  *CacheMetrics
 	tracker  []time.Duration
+	next     int
+	sum      time.Duration
 	maxTrack int
+	hits     histogram
+	misses   histogram
+	prefixes map[string]*prefixStats
+	hotKeys  *hotKeyTracker
+	sizes    histogram
+	bigKeys  []BigKey
+	window   rollingWindow
+	ops      map[string]*histogram
+
+	sampleRate      atomic.Uint64
+	unsampledHits   atomic.Int64
+	unsampledMisses atomic.Int64
 }
 
 // NewMonitor creates a cache monitor.
 func NewMonitor() *Monitor {
 	return &Monitor{
-		metrics: &CacheMetrics{LastUpdate: time.Now()},
-		tracker: make([]time.Duration, 0, 256),
+		metrics:  &CacheMetrics{LastUpdate: time.Now()},
+		tracker:  make([]time.Duration, 0, 256),
 		maxTrack: 1000,
 	}
 }
 
 // RecordHit records a cache hit and its duration.
 func (m *Monitor) RecordHit(duration time.Duration) {
+	m.window.record(true)
+	if m.skip(true) {
+		return
+	}
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	m.metrics.HitCount++
+	m.hits.record(int64(duration))
 	m.track(duration)
 }
 
 // RecordMiss records a cache miss and its duration.
 func (m *Monitor) RecordMiss(duration time.Duration) {
+	m.window.record(false)
+	if m.skip(false) {
+		return
+	}
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	m.metrics.MissCount++
+	m.misses.record(int64(duration))
 	m.track(duration)
 }
 
@@ -57,16 +96,76 @@ func (m *Monitor) RecordEviction(count int64) {
 	m.metrics.EvictionCount += count
 }
 
+// RecordGetError records a failed adapter read.
+func (m *Monitor) RecordGetError() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.metrics.GetErrorCount++
+}
+
+// RecordSetError records a failed adapter write.
+func (m *Monitor) RecordSetError() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.metrics.SetErrorCount++
+}
+
+// RecordRetry records a retried adapter call.
+func (m *Monitor) RecordRetry() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.metrics.RetryCount++
+}
+
+// RecordWriteBehindDrop records a write discarded by a full write-behind queue.
+func (m *Monitor) RecordWriteBehindDrop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.metrics.WriteBehindDrop++
+}
+
+// RecordStaleServe records a stale entry served because its loader failed.
+func (m *Monitor) RecordStaleServe() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.metrics.StaleServeCount++
+}
+
+// RecordBreakerBypass records an adapter call skipped by an open breaker.
+func (m *Monitor) RecordBreakerBypass() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.metrics.BreakerBypassCount++
+}
+
+// RecordBreakerState records a circuit breaker transition.
+func (m *Monitor) RecordBreakerState(state BreakerState) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.metrics.BreakerState = state.String()
+	if state == BreakerOpen {
+		m.metrics.BreakerOpens++
+	}
+}
+
 // HitRatio returns cache hit ratio.
 func (m *Monitor) HitRatio() float64 {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	total := m.metrics.HitCount + m.metrics.MissCount
+	hits := m.metrics.HitCount + m.unsampledHits.Load()
+	total := hits + m.metrics.MissCount + m.unsampledMisses.Load()
 	if total == 0 {
 		return 0
 	}
-	return float64(m.metrics.HitCount) / float64(total)
+	return float64(hits) / float64(total)
 }
 
 // GetMetrics returns a snapshot of metrics.
@@ -75,6 +174,24 @@ func (m *Monitor) GetMetrics() CacheMetrics {
 	defer m.mu.RUnlock()
 
 	cp := *m.metrics
+	cp.HitCount += m.unsampledHits.Load()
+	cp.MissCount += m.unsampledMisses.Load()
+	if m.metrics.ErrorsByType != nil {
+		cp.ErrorsByType = make(map[string]int64, len(m.metrics.ErrorsByType))
+		for category, n := range m.metrics.ErrorsByType {
+			cp.ErrorsByType[category] = n
+		}
+	}
+	cp.HitLatency = m.hits.latency()
+	cp.MissLatency = m.misses.latency()
+	cp.PayloadSize = m.sizes.sizes()
+	if m.ops != nil {
+		cp.OpLatency = make(map[string]LatencySummary, len(m.ops))
+		for op, h := range m.ops {
+			cp.OpLatency[op] = h.latency()
+		}
+	}
+	cp.Windows = m.window.snapshot()
 	return cp
 }
 
@@ -85,20 +202,34 @@ func (m *Monitor) Reset() {
 
 	m.metrics = &CacheMetrics{LastUpdate: time.Now()}
 	m.tracker = make([]time.Duration, 0, m.maxTrack)
+	m.next = 0
+	m.sum = 0
+	m.hits = histogram{}
+	m.misses = histogram{}
+	m.prefixes = nil
+	m.sizes = histogram{}
+	m.bigKeys = nil
+	m.window.reset()
+	m.ops = nil
+	m.unsampledHits.Store(0)
+	m.unsampledMisses.Store(0)
+	if m.hotKeys != nil {
+		m.hotKeys = newHotKeyTracker(m.hotKeys.capacity, m.hotKeys.rate)
+	}
 }
 
+// track keeps a ring of the last maxTrack durations and a running sum,
+// so the average is updated in constant time.
 func (m *Monitor) track(duration time.Duration) {
-	m.tracker = append(m.tracker, duration)
-	if len(m.tracker) > m.maxTrack {
-		m.tracker = m.tracker[1:]
+	if len(m.tracker) < m.maxTrack {
+		m.tracker = append(m.tracker, duration)
+	} else {
+		m.sum -= m.tracker[m.next]
+		m.tracker[m.next] = duration
+		m.next = (m.next + 1) % m.maxTrack
 	}
+	m.sum += duration
 
-	var total time.Duration
-	for _, d := range m.tracker {
-		total += d
-	}
-	if len(m.tracker) > 0 {
-		m.metrics.AvgResponseTime = total / time.Duration(len(m.tracker))
-	}
+	m.metrics.AvgResponseTime = m.sum / time.Duration(len(m.tracker))
 	m.metrics.LastUpdate = time.Now()
 }
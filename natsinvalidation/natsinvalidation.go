@@ -0,0 +1,76 @@
+// Package natsinvalidation carries eitcache invalidations over NATS, for
+// deployments without Redis that already run a NATS server.
+package natsinvalidation
+
+import (
+	"context"
+	"errors"
+
+	"github.com/nats-io/nats.go"
+)
+
+// DefaultSubject is the subject used when New is given an empty one.
+const DefaultSubject = "eitcache.invalidate"
+
+// Transport implements eitcache.InvalidationTransport with core NATS
+// publish/subscribe. Delivery is at most once, like Redis pub/sub.
+type Transport struct {
+	conn    *nats.Conn
+	subject string
+	sub     *nats.Subscription
+	owned   bool
+}
+
+// New creates a transport on an existing connection. Close unsubscribes
+// but leaves conn open.
+func New(conn *nats.Conn, subject string) *Transport {
+	if subject == "" {
+		subject = DefaultSubject
+	}
+	return &Transport{conn: conn, subject: subject}
+}
+
+// Dial connects to the NATS server at url. Close also closes the
+// connection.
+func Dial(url, subject string, opts ...nats.Option) (*Transport, error) {
+	conn, err := nats.Connect(url, opts...)
+	if err != nil {
+		return nil, err
+	}
+	t := New(conn, subject)
+	t.owned = true
+	return t, nil
+}
+
+// Publish sends payload on the subject.
+func (t *Transport) Publish(ctx context.Context, payload []byte) error {
+	_ = ctx
+	return t.conn.Publish(t.subject, payload)
+}
+
+// Subscribe delivers subject messages to handler.
+func (t *Transport) Subscribe(handler func(payload []byte)) error {
+	if t.sub != nil {
+		return errors.New("invalidation transport already subscribed")
+	}
+	sub, err := t.conn.Subscribe(t.subject, func(msg *nats.Msg) {
+		handler(msg.Data)
+	})
+	if err != nil {
+		return err
+	}
+	t.sub = sub
+	return t.conn.Flush()
+}
+
+// Close unsubscribes, closing the connection if Dial opened it.
+func (t *Transport) Close() error {
+	var err error
+	if t.sub != nil {
+		err = t.sub.Unsubscribe()
+	}
+	if t.owned {
+		t.conn.Close()
+	}
+	return err
+}
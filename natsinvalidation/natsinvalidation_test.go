@@ -0,0 +1,218 @@
+package natsinvalidation
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// testServer speaks the subset of the NATS client protocol the transport
+// uses: CONNECT, PING, SUB, UNSUB and PUB with exact subjects.
+type testServer struct {
+	ln net.Listener
+
+	mu   sync.Mutex
+	subs map[*testSub]struct{}
+}
+
+type testSub struct {
+	conn    *testConn
+	subject string
+	sid     string
+}
+
+// testConn serializes writes from the connection's own loop and from
+// publishers on other connections.
+type testConn struct {
+	net.Conn
+	mu sync.Mutex
+}
+
+func (c *testConn) write(data string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	io.WriteString(c, data)
+}
+
+func startTestServer(t *testing.T) *testServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testServer{ln: ln, subs: make(map[*testSub]struct{})}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(&testConn{Conn: conn})
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *testServer) url() string {
+	return "nats://" + s.ln.Addr().String()
+}
+
+// subscriptions returns the number of live subscriptions.
+func (s *testServer) subscriptions() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.subs)
+}
+
+func (s *testServer) serve(conn *testConn) {
+	defer conn.Close()
+	conn.write(`INFO {"server_id":"test","version":"2.10.0","proto":1,"max_payload":1048576}` + "\r\n")
+
+	owned := make(map[string]*testSub)
+	defer func() {
+		s.mu.Lock()
+		for _, sub := range owned {
+			delete(s.subs, sub)
+		}
+		s.mu.Unlock()
+	}()
+
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "PING":
+			conn.write("PONG\r\n")
+		case "SUB":
+			sub := &testSub{conn: conn, subject: fields[1], sid: fields[len(fields)-1]}
+			owned[sub.sid] = sub
+			s.mu.Lock()
+			s.subs[sub] = struct{}{}
+			s.mu.Unlock()
+		case "UNSUB":
+			if sub, ok := owned[fields[1]]; ok {
+				delete(owned, fields[1])
+				s.mu.Lock()
+				delete(s.subs, sub)
+				s.mu.Unlock()
+			}
+		case "PUB":
+			size, _ := strconv.Atoi(fields[len(fields)-1])
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return
+			}
+			s.mu.Lock()
+			for sub := range s.subs {
+				if sub.subject == fields[1] {
+					sub.conn.write(fmt.Sprintf("MSG %s %s %d\r\n%s", sub.subject, sub.sid, size, payload))
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+func TestTransport(t *testing.T) {
+	server := startTestServer(t)
+
+	a, err := Dial(server.url(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	b, err := Dial(server.url(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(chan string, 4)
+	if err := a.Subscribe(func(payload []byte) { received <- "a:" + string(payload) }); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Subscribe(func(payload []byte) { received <- "b:" + string(payload) }); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Subscribe(func([]byte) {}); err == nil {
+		t.Fatal("expected a second Subscribe to fail")
+	}
+
+	if err := a.Publish(context.Background(), []byte("purge")); err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]bool{}
+	for len(got) < 2 {
+		select {
+		case msg := <-received:
+			got[msg] = true
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected both subscribers to receive the payload, got %v", got)
+		}
+	}
+	if !got["a:purge"] || !got["b:purge"] {
+		t.Fatalf("unexpected deliveries %v", got)
+	}
+
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !b.conn.IsClosed() {
+		t.Fatal("expected Close to close a dialled connection")
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for server.subscriptions() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected only a's subscription left, got %d", server.subscriptions())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	a.Publish(context.Background(), []byte("again"))
+	select {
+	case msg := <-received:
+		if msg != "a:again" {
+			t.Fatalf("expected only a to receive after b closed, got %q", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a to keep receiving")
+	}
+}
+
+func TestTransportSharedConnection(t *testing.T) {
+	server := startTestServer(t)
+	conn, err := nats.Connect(server.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	transport := New(conn, "custom.subject")
+	if transport.subject != "custom.subject" {
+		t.Fatalf("unexpected subject %q", transport.subject)
+	}
+	if err := transport.Subscribe(func([]byte) {}); err != nil {
+		t.Fatal(err)
+	}
+	if err := transport.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if conn.IsClosed() {
+		t.Fatal("expected Close to leave a shared connection open")
+	}
+}
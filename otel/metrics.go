@@ -0,0 +1,117 @@
+// Package otel publishes eitcache metrics and traces through
+// OpenTelemetry.
+package otel
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	eitcache "github.com/eit-cms/eit-cache"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// ScopeName is the instrumentation scope of eitcache meters and tracers.
+const ScopeName = "github.com/eit-cms/eit-cache"
+
+// statsTimeout bounds the adapter Stats call made on each collection.
+const statsTimeout = time.Second
+
+// Metrics holds the instruments registered for a Manager.
+type Metrics struct {
+	registration metric.Registration
+}
+
+// RegisterMetrics publishes manager metrics to provider: hit and miss
+// counts, hit ratio, backend errors, key count, connection pool usage and
+// operation latency.
+// Latency is recorded through lifecycle hooks from the time of the call.
+func RegisterMetrics(manager *eitcache.Manager, provider metric.MeterProvider) (*Metrics, error) {
+	if manager == nil {
+		return nil, eitcache.ErrManagerNil
+	}
+	meter := provider.Meter(ScopeName)
+
+	hits, err1 := meter.Int64ObservableCounter("eitcache.hits", metric.WithDescription("Cache hits."))
+	misses, err2 := meter.Int64ObservableCounter("eitcache.misses", metric.WithDescription("Cache misses."))
+	ratio, err3 := meter.Float64ObservableGauge("eitcache.hit_ratio", metric.WithDescription("Hits divided by hits and misses."))
+	backendErrors, err4 := meter.Int64ObservableCounter("eitcache.backend.errors", metric.WithDescription("Adapter errors by operation."))
+	keys, err5 := meter.Int64ObservableGauge("eitcache.keys", metric.WithDescription("Entries held by the adapter."))
+	latency, err6 := meter.Float64Histogram("eitcache.operation.duration",
+		metric.WithDescription("Cache operation latency."), metric.WithUnit("s"))
+	poolCheckouts, err7 := meter.Int64ObservableCounter("eitcache.pool.checkouts",
+		metric.WithDescription("Connection pool checkouts by result: hit, miss or timeout."))
+	poolConns, err8 := meter.Int64ObservableGauge("eitcache.pool.connections",
+		metric.WithDescription("Connection pool connections by state: total, idle or stale."))
+	if err := errors.Join(err1, err2, err3, err4, err5, err6, err7, err8); err != nil {
+		return nil, err
+	}
+
+	getAttr := metric.WithAttributes(attribute.String("cache.operation", "get"))
+	setAttr := metric.WithAttributes(attribute.String("cache.operation", "set"))
+	registration, err := meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		monitor := manager.Monitor()
+		m := monitor.GetMetrics()
+		o.ObserveInt64(hits, m.HitCount)
+		o.ObserveInt64(misses, m.MissCount)
+		o.ObserveFloat64(ratio, monitor.HitRatio())
+		o.ObserveInt64(backendErrors, m.GetErrorCount, getAttr)
+		o.ObserveInt64(backendErrors, m.SetErrorCount, setAttr)
+		if pool, ok := manager.PoolStats(); ok {
+			observePool := func(inst metric.Int64Observable, v uint32, key, value string) {
+				o.ObserveInt64(inst, int64(v), metric.WithAttributes(attribute.String(key, value)))
+			}
+			observePool(poolCheckouts, pool.Hits, "result", "hit")
+			observePool(poolCheckouts, pool.Misses, "result", "miss")
+			observePool(poolCheckouts, pool.Timeouts, "result", "timeout")
+			observePool(poolConns, pool.TotalConns, "state", "total")
+			observePool(poolConns, pool.IdleConns, "state", "idle")
+			observePool(poolConns, pool.StaleConns, "state", "stale")
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, statsTimeout)
+		defer cancel()
+		if stats, err := manager.Stats(ctx); err == nil {
+			if n, ok := keyCount(stats); ok {
+				o.ObserveInt64(keys, n)
+			}
+		}
+		return nil
+	}, hits, misses, ratio, backendErrors, keys, poolCheckouts, poolConns)
+	if err != nil {
+		return nil, err
+	}
+
+	observe := func(op string) eitcache.Hook {
+		attrs := metric.WithAttributes(attribute.String("cache.operation", op))
+		return func(ctx context.Context, event eitcache.HookEvent) {
+			latency.Record(ctx, event.Duration.Seconds(), attrs)
+		}
+	}
+	manager.OnHit(observe("get"))
+	manager.OnMiss(observe("get"))
+	manager.OnSet(observe("set"))
+	manager.OnDelete(observe("delete"))
+	return &Metrics{registration: registration}, nil
+}
+
+// Unregister stops the observable instruments. Latency hooks stay
+// registered on the manager but record into a meter nobody reads.
+func (m *Metrics) Unregister() error {
+	return m.registration.Unregister()
+}
+
+// keyCount reads the entry count reported by the memory, arena and
+// Redis adapters.
+func keyCount(stats map[string]interface{}) (int64, bool) {
+	for _, name := range []string{"total_items", "db_size"} {
+		switch n := stats[name].(type) {
+		case int:
+			return int64(n), true
+		case int64:
+			return n, true
+		}
+	}
+	return 0, false
+}
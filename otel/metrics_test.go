@@ -0,0 +1,110 @@
+package otel
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	eitcache "github.com/eit-cms/eit-cache"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestRegisterMetrics(t *testing.T) {
+	manager, err := eitcache.NewManager(&eitcache.CacheConfig{Type: eitcache.CacheTypeMemory, DefaultTTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer provider.Shutdown(context.Background())
+
+	metrics, err := RegisterMetrics(manager, provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	manager.Set(ctx, "article:1", "v", 0)
+	var dest string
+	manager.Get(ctx, "article:1", &dest)
+	manager.Get(ctx, "article:2", &dest)
+	manager.Get(ctx, "article:3", &dest)
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	if len(rm.ScopeMetrics) != 1 || rm.ScopeMetrics[0].Scope.Name != ScopeName {
+		t.Fatalf("expected one %s scope, got %+v", ScopeName, rm.ScopeMetrics)
+	}
+	collected := make(map[string]metricdata.Aggregation)
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		collected[m.Name] = m.Data
+	}
+
+	intValue := func(name string) int64 {
+		t.Helper()
+		var points []metricdata.DataPoint[int64]
+		switch data := collected[name].(type) {
+		case metricdata.Sum[int64]:
+			points = data.DataPoints
+		case metricdata.Gauge[int64]:
+			points = data.DataPoints
+		default:
+			t.Fatalf("unexpected %s data %T", name, data)
+		}
+		if len(points) != 1 {
+			t.Fatalf("expected one %s point, got %+v", name, points)
+		}
+		return points[0].Value
+	}
+	if n := intValue("eitcache.hits"); n != 1 {
+		t.Fatalf("expected 1 hit, got %d", n)
+	}
+	if n := intValue("eitcache.misses"); n != 2 {
+		t.Fatalf("expected 2 misses, got %d", n)
+	}
+	if n := intValue("eitcache.keys"); n != 1 {
+		t.Fatalf("expected 1 key, got %d", n)
+	}
+	ratio, ok := collected["eitcache.hit_ratio"].(metricdata.Gauge[float64])
+	if !ok || len(ratio.DataPoints) != 1 || ratio.DataPoints[0].Value != 1.0/3.0 {
+		t.Fatalf("unexpected hit ratio %+v", collected["eitcache.hit_ratio"])
+	}
+	errs, ok := collected["eitcache.backend.errors"].(metricdata.Sum[int64])
+	if !ok || len(errs.DataPoints) != 2 {
+		t.Fatalf("expected get and set error series, got %+v", collected["eitcache.backend.errors"])
+	}
+
+	latency, ok := collected["eitcache.operation.duration"].(metricdata.Histogram[float64])
+	if !ok {
+		t.Fatalf("unexpected latency data %T", collected["eitcache.operation.duration"])
+	}
+	counts := make(map[string]uint64)
+	for _, p := range latency.DataPoints {
+		op, _ := p.Attributes.Value(attribute.Key("cache.operation"))
+		counts[op.AsString()] = p.Count
+	}
+	if counts["get"] != 3 || counts["set"] != 1 {
+		t.Fatalf("unexpected latency counts %v", counts)
+	}
+	if _, ok := collected["eitcache.pool.connections"]; ok {
+		t.Fatal("expected no pool metrics for the memory adapter")
+	}
+
+	if err := metrics.Unregister(); err != nil {
+		t.Fatal(err)
+	}
+	rm = metricdata.ResourceMetrics{}
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		if m.Name == "eitcache.hits" {
+			t.Fatal("expected observable instruments unregistered")
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package otel
+
+import (
+	"context"
+
+	eitcache "github.com/eit-cms/eit-cache"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracer implements eitcache.Tracer with OpenTelemetry spans named
+// "eitcache.<op>", carrying the key, backend, hit and payload size.
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+// NewTracer creates a tracer from provider. Pass it to
+// CacheConfig.Tracer or Manager.SetTracer.
+func NewTracer(provider trace.TracerProvider) *Tracer {
+	return &Tracer{tracer: provider.Tracer(ScopeName)}
+}
+
+// Start implements eitcache.Tracer.
+func (t *Tracer) Start(ctx context.Context, op, key string) (context.Context, func(eitcache.SpanInfo)) {
+	ctx, span := t.tracer.Start(ctx, "eitcache."+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("cache.operation", op),
+			attribute.String("cache.key", key),
+		),
+	)
+	return ctx, func(info eitcache.SpanInfo) {
+		attrs := []attribute.KeyValue{attribute.String("cache.backend", info.Backend)}
+		switch op {
+		case "get", "query":
+			attrs = append(attrs, attribute.Bool("cache.hit", info.Hit))
+		case "delete_pattern":
+			attrs = append(attrs, attribute.Int64("cache.deleted", info.Count))
+		}
+		if info.Size > 0 {
+			attrs = append(attrs, attribute.Int("cache.payload_size", info.Size))
+		}
+		span.SetAttributes(attrs...)
+		if info.Err != nil {
+			span.RecordError(info.Err)
+			span.SetStatus(codes.Error, info.Err.Error())
+		}
+		span.End()
+	}
+}
@@ -0,0 +1,31 @@
+package eitcache
+
+import (
+	"maps"
+	"time"
+)
+
+// PaginationDefaults overrides the pagination defaults of one resource.
+// PageSize replaces DefaultPageSize for requests without one, MaxPageSize
+// replaces MaxPageSize, TTL is used for pages when no WithTTL is given,
+// and NoCache disables caching of the resource's pages. Zero values keep
+// the package defaults.
+type PaginationDefaults struct {
+	PageSize    int
+	MaxPageSize int
+	TTL         time.Duration
+	NoCache     bool
+}
+
+// SetPaginationDefaults sets per-resource pagination defaults, e.g. a
+// page size of 50 and a maximum of 1000 for "exports".
+func (m *Manager) SetPaginationDefaults(defaults map[string]PaginationDefaults) {
+	defaults = maps.Clone(defaults)
+	m.update(func(s *managerSettings) { s.pageDefaults = defaults })
+}
+
+// NormalizePaginationParams normalizes params like the package function,
+// using the defaults registered for resource.
+func (m *Manager) NormalizePaginationParams(resource string, params *PaginationParams) *PaginationParams {
+	return normalizePagination(params, m.current().pageDefaults[resource])
+}
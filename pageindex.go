@@ -0,0 +1,258 @@
+package eitcache
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"maps"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxIndexedPages bounds the pages a manager remembers for
+// InvalidatePagesFor; beyond it the least recently written are forgotten.
+const maxIndexedPages = 10000
+
+// pageIndex records the filters of cached pages by namespaced resource,
+// so an item change invalidates only the pages that could list it.
+// Entries are forgotten when their page expires, is deleted, or falls
+// out of the most recent maxIndexedPages.
+type pageIndex struct {
+	mu    sync.Mutex
+	limit int
+	order *list.List
+	pages map[string]map[string]*list.Element
+}
+
+type indexedPage struct {
+	resource string
+	key      string
+	fullKey  string
+	filters  map[string]interface{}
+	expireAt time.Time
+}
+
+func newPageIndex() *pageIndex {
+	return &pageIndex{
+		limit: maxIndexedPages,
+		order: list.New(),
+		pages: make(map[string]map[string]*list.Element),
+	}
+}
+
+func (p *pageIndex) record(resource, key, fullKey string, filters map[string]interface{}, expireAt time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pages, ok := p.pages[resource]
+	if !ok {
+		pages = make(map[string]*list.Element)
+		p.pages[resource] = pages
+	}
+	page := &indexedPage{resource: resource, key: key, fullKey: fullKey, filters: maps.Clone(filters), expireAt: expireAt}
+	if elem, ok := pages[fullKey]; ok {
+		elem.Value = page
+		p.order.MoveToFront(elem)
+		return
+	}
+	pages[fullKey] = p.order.PushFront(page)
+	for p.order.Len() > p.limit {
+		p.removeLocked(p.order.Back())
+	}
+}
+
+func (p *pageIndex) removeLocked(elem *list.Element) {
+	page := p.order.Remove(elem).(*indexedPage)
+	pages := p.pages[page.resource]
+	delete(pages, page.fullKey)
+	if len(pages) == 0 {
+		delete(p.pages, page.resource)
+	}
+}
+
+// take removes and returns the live pages of resource whose filters
+// could match any of items.
+func (p *pageIndex) take(resource string, items []map[string]interface{}, now time.Time) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var keys []string
+	for _, elem := range p.pages[resource] {
+		page := elem.Value.(*indexedPage)
+		if !page.expireAt.IsZero() && now.After(page.expireAt) {
+			p.removeLocked(elem)
+			continue
+		}
+		for _, item := range items {
+			if filtersMatch(page.filters, item) {
+				keys = append(keys, page.key)
+				p.removeLocked(elem)
+				break
+			}
+		}
+	}
+	return keys
+}
+
+// forget drops the pages stored under fullKeys.
+func (p *pageIndex) forget(fullKeys []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, fullKey := range fullKeys {
+		for _, pages := range p.pages {
+			if elem, ok := pages[fullKey]; ok {
+				p.removeLocked(elem)
+				break
+			}
+		}
+	}
+}
+
+// forgetPattern drops the pages whose full key matches pattern, read as
+// a prefix when it has no wildcard, like the adapters' DeletePattern.
+func (p *pageIndex) forgetPattern(pattern string) {
+	if !strings.Contains(pattern, "*") {
+		pattern += "*"
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for elem := p.order.Front(); elem != nil; {
+		next := elem.Next()
+		if globMatch(pattern, elem.Value.(*indexedPage).fullKey) {
+			p.removeLocked(elem)
+		}
+		elem = next
+	}
+}
+
+// forgetResource drops every page of resource.
+func (p *pageIndex) forgetResource(resource string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, elem := range p.pages[resource] {
+		p.removeLocked(elem)
+	}
+}
+
+// reset forgets every page.
+func (p *pageIndex) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.order.Init()
+	p.pages = make(map[string]map[string]*list.Element)
+}
+
+// filtersMatch reports whether a page with filters could contain item.
+// Filters on fields item lacks are assumed to match; slice filters match
+// any of their elements.
+func filtersMatch(filters, item map[string]interface{}) bool {
+	for field, want := range filters {
+		got, ok := item[field]
+		if !ok {
+			continue
+		}
+		if !filterValueMatch(want, got) {
+			return false
+		}
+	}
+	return true
+}
+
+func filterValueMatch(want, got interface{}) bool {
+	if rv := reflect.ValueOf(want); rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8 {
+		for i := 0; i < rv.Len(); i++ {
+			if filterValueMatch(rv.Index(i).Interface(), got) {
+				return true
+			}
+		}
+		return false
+	}
+	a, errA := json.Marshal(want)
+	b, errB := json.Marshal(got)
+	return errA == nil && errB == nil && string(a) == string(b)
+}
+
+// InvalidatePagesFor deletes the cached pages of resource whose filters
+// could contain any of items, typically the before and after state of a
+// changed row, and returns how many it deleted. Only pages written by
+// QueryWithPagination through this manager are known.
+func InvalidatePagesFor(ctx context.Context, manager *Manager, resource string, items ...map[string]interface{}) (int, error) {
+	if manager == nil {
+		return 0, ErrManagerNil
+	}
+	keys := manager.pages.take(manager.key(resource), items, manager.clock.Now())
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	return len(keys), manager.Delete(ctx, keys...)
+}
+
+// pageIndexKey is the key of the index listing resource's cached pages.
+func pageIndexKey(resource string) string {
+	return "pages:" + resource
+}
+
+// SetPageKeyIndex makes paginated, keyset and count queries add their
+// keys to a per-resource index in adapters implementing IndexAdapter, so
+// InvalidateCacheOnUpdate deletes exactly those keys instead of scanning
+// with DeletePattern. Other keys under the resource, such as details,
+// are then no longer cleared by it.
+func (m *Manager) SetPageKeyIndex(enabled bool) {
+	m.update(func(s *managerSettings) { s.pageKeyIndex = enabled })
+}
+
+// storePage caches a page of resource and records it for
+// InvalidatePagesFor and the page key index. Index errors are reported
+// rather than returned.
+func (m *Manager) storePage(ctx context.Context, resource, key string, filters map[string]interface{}, payload RawValue, ttl time.Duration) error {
+	if err := m.store(ctx, key, payload, ttl); err != nil {
+		return err
+	}
+	var expireAt time.Time
+	if ttl > 0 {
+		expireAt = m.clock.Now().Add(ttl)
+	}
+	m.pages.record(m.key(resource), key, m.key(key), filters, expireAt)
+
+	index, ok := m.adapter.(IndexAdapter)
+	if !m.current().pageKeyIndex || !ok || m.ReadOnly() {
+		return nil
+	}
+	if ttl > 0 {
+		// Outlive every listed page, including jittered ones.
+		ttl *= 2
+	}
+	err := m.guard(ctx, m.writeTimeout, func(ctx context.Context) error {
+		return index.AddToIndex(ctx, m.key(pageIndexKey(resource)), ttl, key)
+	})
+	if err != nil {
+		m.reportError(ctx, OpSet, pageIndexKey(resource), err)
+	}
+	return nil
+}
+
+// deleteIndexedPages deletes the pages listed in resource's page key
+// index and the index itself. ok is false when the index is not in use
+// or is missing, for example after the adapter evicted or expired it,
+// so the caller falls back to DeletePattern.
+func (m *Manager) deleteIndexedPages(ctx context.Context, resource string) (n int64, ok bool, err error) {
+	index, ok := m.adapter.(IndexAdapter)
+	if !m.current().pageKeyIndex || !ok {
+		return 0, false, nil
+	}
+	var keys []string
+	err = m.guard(ctx, m.readTimeout, func(ctx context.Context) (err error) {
+		keys, err = index.IndexMembers(ctx, m.key(pageIndexKey(resource)))
+		return err
+	})
+	if err != nil {
+		return 0, true, err
+	}
+	if len(keys) == 0 {
+		return 0, false, nil
+	}
+	if err := m.Delete(ctx, append(keys, pageIndexKey(resource))...); err != nil {
+		return 0, true, err
+	}
+	return int64(len(keys)), true, nil
+}
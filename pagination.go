@@ -7,7 +7,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 const (
@@ -16,30 +18,60 @@ const (
 	MaxPageSize     = 200
 )
 
-// PaginationParams defines pagination options.
+// PaginationParams defines pagination options. SortBy must be listed for
+// the resource with Manager.SetSortFields; SortOrder is SortAsc or
+// SortDesc. LinkTemplate, e.g. "/articles?page={page}&size={page_size}",
+// fills the page URLs of the response.
 type PaginationParams struct {
-	Page     int  `json:"page"`
-	PageSize int  `json:"page_size"`
-	UseCache bool `json:"use_cache"`
+	Page         int    `json:"page"`
+	PageSize     int    `json:"page_size"`
+	UseCache     bool   `json:"use_cache"`
+	SortBy       string `json:"sort_by,omitempty"`
+	SortOrder    string `json:"sort_order,omitempty"`
+	LinkTemplate string `json:"-"`
 }
 
 // NormalizePaginationParams returns normalized params.
 func NormalizePaginationParams(params *PaginationParams) *PaginationParams {
+	return normalizePagination(params, PaginationDefaults{})
+}
+
+// normalizePagination normalizes params, using defaults for the unset
+// page size and limits.
+func normalizePagination(params *PaginationParams, defaults PaginationDefaults) *PaginationParams {
+	maxSize := defaults.MaxPageSize
+	if maxSize <= 0 {
+		maxSize = MaxPageSize
+	}
+	size := defaults.PageSize
+	if size <= 0 {
+		size = DefaultPageSize
+	}
+	if size > maxSize {
+		size = maxSize
+	}
 	if params == nil {
 		return &PaginationParams{
 			Page:     DefaultPage,
-			PageSize: DefaultPageSize,
-			UseCache: true,
+			PageSize: size,
+			UseCache: !defaults.NoCache,
 		}
 	}
 	if params.Page <= 0 {
 		params.Page = DefaultPage
 	}
 	if params.PageSize <= 0 {
-		params.PageSize = DefaultPageSize
+		params.PageSize = size
+	}
+	if params.PageSize > maxSize {
+		params.PageSize = maxSize
 	}
-	if params.PageSize > MaxPageSize {
-		params.PageSize = MaxPageSize
+	if defaults.NoCache {
+		params.UseCache = false
+	}
+	params.SortOrder = strings.ToLower(params.SortOrder)
+	if params.SortBy != "" && params.SortOrder == "" {
+		params.SortOrder = SortAsc
 	}
 	return params
 }
@@ -54,19 +86,35 @@ type PaginationResponse[T any] struct {
 	FromCache  bool   `json:"from_cache"`
 	CacheKey   string `json:"cache_key"`
 	DataHash   string `json:"data_hash"`
+	SortBy     string `json:"sort_by,omitempty"`
+	SortOrder  string `json:"sort_order,omitempty"`
+	HasNext    bool   `json:"has_next"`
+	HasPrev    bool   `json:"has_prev"`
+	// NextPageURL and PrevPageURL are set from LinkTemplate when the
+	// page exists.
+	NextPageURL string `json:"next_page_url,omitempty"`
+	PrevPageURL string `json:"prev_page_url,omitempty"`
+	// OriginalKey is the unhashed cache key when the filters were hashed.
+	OriginalKey string `json:"original_key,omitempty"`
 }
 
 type paginationCacheItem[T any] struct {
-	Data     []T   `json:"data"`
-	Total    int64 `json:"total"`
-	DataHash string `json:"data_hash"`
+	Data        []T    `json:"data"`
+	Total       int64  `json:"total"`
+	DataHash    string `json:"data_hash"`
+	OriginalKey string `json:"original_key,omitempty"`
 }
 
 // BuildPaginationResponse builds response with computed fields.
 func BuildPaginationResponse[T any](data []T, total int64, params *PaginationParams, cacheKey string, fromCache bool) *PaginationResponse[T] {
-	params = NormalizePaginationParams(params)
+	return buildPaginationResponse(data, total, NormalizePaginationParams(params), cacheKey, fromCache)
+}
+
+// buildPaginationResponse is BuildPaginationResponse for normalized
+// params.
+func buildPaginationResponse[T any](data []T, total int64, params *PaginationParams, cacheKey string, fromCache bool) *PaginationResponse[T] {
 	pages := int((total + int64(params.PageSize) - 1) / int64(params.PageSize))
-	return &PaginationResponse[T]{
+	resp := &PaginationResponse[T]{
 		Data:       data,
 		Total:      total,
 		Page:       params.Page,
@@ -75,30 +123,62 @@ func BuildPaginationResponse[T any](data []T, total int64, params *PaginationPar
 		FromCache:  fromCache,
 		CacheKey:   cacheKey,
 		DataHash:   GenerateDataHash(data),
+		SortBy:     params.SortBy,
+		SortOrder:  params.SortOrder,
+		HasNext:    params.Page < pages,
+		HasPrev:    params.Page > 1,
+	}
+	if params.LinkTemplate != "" {
+		if resp.HasNext {
+			resp.NextPageURL = pageURL(params.LinkTemplate, params.Page+1, params.PageSize)
+		}
+		if resp.HasPrev {
+			resp.PrevPageURL = pageURL(params.LinkTemplate, params.Page-1, params.PageSize)
+		}
 	}
+	return resp
+}
+
+// pageURL expands the {page} and {page_size} placeholders of template.
+func pageURL(template string, page, pageSize int) string {
+	return strings.NewReplacer(
+		"{page}", strconv.Itoa(page),
+		"{page_size}", strconv.Itoa(pageSize),
+	).Replace(template)
 }
 
 // GenerateCacheKey builds a stable cache key with filters and pagination.
 func GenerateCacheKey(resource string, filters map[string]interface{}, params *PaginationParams) string {
-	params = NormalizePaginationParams(params)
-	parts := []string{resource}
+	return pageKey(resource, filterKeyParts(filters), NormalizePaginationParams(params))
+}
 
-	if len(filters) > 0 {
-		keys := make([]string, 0, len(filters))
-		for k := range filters {
-			keys = append(keys, k)
-		}
-		sort.Strings(keys)
-		for _, k := range keys {
-			val, _ := json.Marshal(filters[k])
-			parts = append(parts, k, string(val))
-		}
+// pageKey builds a GenerateCacheKey key from filter key parts and
+// normalized params.
+func pageKey(resource string, filterParts []string, params *PaginationParams) string {
+	parts := append([]string{resource}, filterParts...)
+	if params.SortBy != "" {
+		parts = append(parts, "sort", params.SortBy, params.SortOrder)
 	}
-
 	parts = append(parts, "page", fmt.Sprintf("%d", params.Page), "size", fmt.Sprintf("%d", params.PageSize))
 	return strings.Join(parts, ":")
 }
 
+// filterKeyParts returns alternating filter names and JSON values,
+// sorted by name.
+func filterKeyParts(filters map[string]interface{}) []string {
+	keys := make([]string, 0, len(filters))
+	for k := range filters {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, 2*len(keys))
+	for _, k := range keys {
+		val, _ := json.Marshal(filters[k])
+		parts = append(parts, k, string(val))
+	}
+	return parts
+}
+
 // GenerateDataHash hashes data for comparison.
 func GenerateDataHash(data interface{}) string {
 	payload, _ := json.Marshal(data)
@@ -106,7 +186,10 @@ func GenerateDataHash(data interface{}) string {
 	return hex.EncodeToString(sum[:])
 }
 
-// QueryWithPagination executes a cached paginated query.
+// QueryWithPagination executes a cached paginated query. It accepts the
+// Query options WithTTL, WithNoCache, WithTicket, WithTTLJitter,
+// WithCodec, WithCompression, WithNoCompression, WithStrictCache and
+// WithPrefetchNext; other options are ignored. Hits and misses are recorded in the Monitor.
 func QueryWithPagination[T any](
 	ctx context.Context,
 	manager *Manager,
@@ -114,42 +197,124 @@ func QueryWithPagination[T any](
 	filters map[string]interface{},
 	params *PaginationParams,
 	queryFunc func() ([]T, int64, error),
+	opts ...QueryOption,
 ) (*PaginationResponse[T], error) {
 	if manager == nil {
 		return nil, ErrManagerNil
 	}
-	params = NormalizePaginationParams(params)
-	key := GenerateCacheKey(resource, filters, params)
+	params = manager.NormalizePaginationParams(resource, params)
+	if err := manager.validateSort(resource, params); err != nil {
+		return nil, err
+	}
+	options := newQueryOptions(manager, opts)
+	if options.Ticket != nil {
+		if err := options.Ticket.ValidateWithClock(manager.clock); err != nil {
+			return nil, err
+		}
+	}
+	useCache := params.UseCache && options.UseCache
+	key, original, err := manager.resourceKey(ctx, resource, filters, params)
+	if err != nil {
+		// Without the epoch a cached page may predate the last bump.
+		manager.reportError(ctx, OpGet, epochKey(resource), err)
+		data, total, err := queryFunc()
+		if err != nil {
+			return nil, err
+		}
+		key, original = manager.filterKey(filters, func(parts []string) string {
+			return pageKey(resource, parts, params)
+		})
+		resp := buildPaginationResponse(data, total, params, key, false)
+		resp.OriginalKey = original
+		return resp, nil
+	}
 
-	if params.UseCache {
-		data, err := manager.adapter.Get(ctx, key)
+	if useCache {
+		start := time.Now()
+		data, err := manager.load(ctx, key)
+		elapsed := time.Since(start)
 		if err == nil && data != nil {
 			var cached paginationCacheItem[T]
-			if err := json.Unmarshal(data, &cached); err == nil {
-				resp := BuildPaginationResponse(cached.Data, cached.Total, params, key, true)
+			if _, err := manager.decodeEntryWith(data, &cached, options.codec(manager)); err == nil {
+				manager.recordHit(ctx, key, elapsed, len(data))
+				resp := buildPaginationResponse(cached.Data, cached.Total, params, key, true)
 				resp.DataHash = cached.DataHash
+				resp.OriginalKey = cached.OriginalKey
+				if options.prefetch != nil && params.Page < resp.TotalPages {
+					options.prefetch(ctx, manager, resource, filters, params, options)
+				}
 				return resp, nil
 			}
 		}
+		manager.recordMiss(ctx, key, elapsed)
 	}
 
-	data, total, err := queryFunc()
+	if !useCache {
+		data, total, err := queryFunc()
+		if err != nil {
+			return nil, err
+		}
+		resp := buildPaginationResponse(data, total, params, key, false)
+		resp.OriginalKey = original
+		return resp, nil
+	}
+
+	item, err := coalesce(manager, key, func() (paginationCacheItem[T], error) {
+		data, total, err := queryFunc()
+		if err != nil {
+			return paginationCacheItem[T]{}, err
+		}
+		item := paginationCacheItem[T]{
+			Data:        data,
+			Total:       total,
+			DataHash:    GenerateDataHash(data),
+			OriginalKey: original,
+		}
+		if err := storePaginationItem(ctx, manager, resource, key, filters, item, options); err != nil && options.StrictCache {
+			return item, err
+		}
+		return item, nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	resp := BuildPaginationResponse(data, total, params, key, false)
-	if params.UseCache {
-		_ = manager.adapter.Set(ctx, key, paginationCacheItem[T]{
-			Data:     data,
-			Total:    total,
-			DataHash: resp.DataHash,
-		}, manager.defaultTTL)
+	resp := buildPaginationResponse(item.Data, item.Total, params, key, false)
+	resp.DataHash = item.DataHash
+	resp.OriginalKey = item.OriginalKey
+	if options.prefetch != nil && params.Page < resp.TotalPages {
+		options.prefetch(ctx, manager, resource, filters, params, options)
 	}
-
 	return resp, nil
 }
 
+// storePaginationItem caches a loaded page with the TTL and encoding of
+// options, reporting failures.
+func storePaginationItem(
+	ctx context.Context,
+	manager *Manager,
+	resource, key string,
+	filters map[string]interface{},
+	item interface{},
+	options *QueryOptions,
+) error {
+	ttl := options.TTL
+	if ttl == 0 {
+		ttl = manager.current().pageDefaults[resource].TTL
+	}
+	if ttl == 0 {
+		ttl = manager.ttlFor(resource)
+	}
+	payload, err := manager.encodeWith(item, options.encoding(manager))
+	if err == nil {
+		err = manager.storePage(ctx, resource, key, filters, payload, jitterTTL(ttl, options.TTLJitter))
+	}
+	if err != nil {
+		manager.reportError(ctx, OpSet, key, err)
+	}
+	return err
+}
+
 // QueryWithCache is a helper for cached pagination queries.
 func QueryWithCache[T any](
 	ctx context.Context,
@@ -158,18 +323,55 @@ func QueryWithCache[T any](
 	filters map[string]interface{},
 	params *PaginationParams,
 	queryFunc func() ([]T, int64, error),
+	opts ...QueryOption,
 ) (*PaginationResponse[T], error) {
 	if manager == nil {
 		return nil, ErrManagerNil
 	}
-	return QueryWithPagination(ctx, manager, resource, filters, params, queryFunc)
+	return QueryWithPagination(ctx, manager, resource, filters, params, queryFunc, opts...)
 }
 
-// InvalidateCacheOnUpdate clears cache entries for a resource.
+// InvalidateCacheOnUpdate clears cache entries for a resource. With
+// resource epochs enabled it bumps the epoch instead and returns 0; with
+// the page key index it deletes only the indexed pages.
 func InvalidateCacheOnUpdate(ctx context.Context, manager *Manager, resource string) (int64, error) {
 	if manager == nil {
 		return 0, ErrManagerNil
 	}
+	if manager.current().epochs {
+		_, err := manager.BumpEpoch(ctx, resource)
+		return 0, err
+	}
+	if n, ok, err := manager.deleteIndexedPages(ctx, resource); ok {
+		return n, err
+	}
 	pattern := resource + ":"
 	return manager.DeletePattern(ctx, pattern)
 }
+
+// GenerateDetailKey returns the cache key of a single resource entry,
+// such as "articles:id:42".
+func GenerateDetailKey(resource string, id interface{}) string {
+	return fmt.Sprintf("%s:id:%v", resource, id)
+}
+
+// InvalidateByIDs deletes the detail entries of ids, as keyed by
+// GenerateDetailKey, and invalidates the resource's list pages with
+// InvalidateCacheOnUpdate. Without resource epochs that clears every key
+// under the resource, including other details.
+func InvalidateByIDs(ctx context.Context, manager *Manager, resource string, ids ...interface{}) error {
+	if manager == nil {
+		return ErrManagerNil
+	}
+	if len(ids) > 0 {
+		keys := make([]string, len(ids))
+		for i, id := range ids {
+			keys[i] = GenerateDetailKey(resource, id)
+		}
+		if err := manager.Delete(ctx, keys...); err != nil {
+			return err
+		}
+	}
+	_, err := InvalidateCacheOnUpdate(ctx, manager, resource)
+	return err
+}
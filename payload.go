@@ -0,0 +1,274 @@
+package eitcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// store writes an encoded payload, chunking it when it exceeds chunkSize.
+// It does nothing while the manager is read-only. While write-behind has
+// writes queued for key, the payload is queued behind them.
+func (m *Manager) store(ctx context.Context, key string, payload RawValue, ttl time.Duration) error {
+	if m.ReadOnly() {
+		return nil
+	}
+	ttl, ok := m.clampTTL(key, ttl)
+	if !ok {
+		return nil
+	}
+	if m.writeBehind != nil {
+		queued, err := m.writeBehind.enqueueOrdered(ctx, writeBehindEntry{
+			key:     key,
+			fullKey: m.key(key),
+			payload: payload,
+			ttl:     ttl,
+		})
+		if queued || err != nil {
+			return err
+		}
+	}
+	start := time.Now()
+	err := m.guard(ctx, m.writeTimeout, func(ctx context.Context) error {
+		return m.storePayload(ctx, m.key(key), payload, ttl)
+	})
+	if err == nil {
+		m.recordSet(ctx, key, time.Since(start), len(payload))
+	}
+	return err
+}
+
+// get reads key from the adapter, reporting a miss as a nil payload.
+func (m *Manager) get(ctx context.Context, key string) ([]byte, error) {
+	data, err := m.adapter.Get(ctx, m.key(key))
+	if errors.Is(err, ErrCacheMiss) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// load reads an encoded payload, reassembling chunked entries.
+func (m *Manager) load(ctx context.Context, key string) ([]byte, error) {
+	var data []byte
+	err := m.guard(ctx, m.readTimeout, func(ctx context.Context) (err error) {
+		data, err = m.get(ctx, key)
+		if err != nil || data == nil {
+			return err
+		}
+		if manifest, ok := parseChunkManifest(data); ok {
+			data, err = m.loadChunked(ctx, key, manifest)
+		}
+		return err
+	})
+	return data, err
+}
+
+// encodeOptions overrides manager defaults for a single write.
+type encodeOptions struct {
+	codec       Codec
+	compression *CacheCompression
+	freshUntil  time.Time
+}
+
+// loadMany reads many encoded payloads, using GetMulti when the adapter
+// supports it. Missing keys are omitted.
+func (m *Manager) loadMany(ctx context.Context, keys []string) (map[string][]byte, error) {
+	var found map[string][]byte
+	err := m.guard(ctx, m.readTimeout, func(ctx context.Context) (err error) {
+		if mg, ok := m.adapter.(MultiGetAdapter); ok {
+			if m.namespace == "" {
+				found, err = mg.GetMulti(ctx, keys)
+				return err
+			}
+			full := make([]string, len(keys))
+			for i, key := range keys {
+				full[i] = m.key(key)
+			}
+			values, err := mg.GetMulti(ctx, full)
+			if err != nil {
+				return err
+			}
+			found = make(map[string][]byte, len(values))
+			for i, key := range keys {
+				if data, ok := values[full[i]]; ok {
+					found[key] = data
+				}
+			}
+			return nil
+		}
+		found = make(map[string][]byte, len(keys))
+		for _, key := range keys {
+			data, err := m.get(ctx, key)
+			if err != nil {
+				return err
+			}
+			if data != nil {
+				found[key] = data
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for key, data := range found {
+		manifest, ok := parseChunkManifest(data)
+		if !ok {
+			continue
+		}
+		err := m.guard(ctx, m.readTimeout, func(ctx context.Context) (err error) {
+			data, err = m.loadChunked(ctx, key, manifest)
+			return err
+		})
+		if err != nil || data == nil {
+			delete(found, key)
+			continue
+		}
+		found[key] = data
+	}
+	return found, nil
+}
+
+func (m *Manager) encode(value interface{}) (RawValue, error) {
+	return m.encodeWith(value, encodeOptions{compression: m.current().compression})
+}
+
+func (m *Manager) encodeWith(value interface{}, opts encodeOptions) (RawValue, error) {
+	header := envelopeHeader{codec: codecIDCustom, schema: m.schemaVersion, freshUntil: opts.freshUntil}
+	payload, ok, err := m.marshalers.marshal(value)
+	if !ok {
+		codec := opts.codec
+		if codec == nil {
+			codec = m.current().codec
+		}
+		codec = resolveCodec(codec, value)
+		header.codec = codecID(codec)
+		payload, err = codec.Marshal(value)
+		if err != nil {
+			err = fmt.Errorf("marshal value failed: %w", err)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	payload, header.compression, err = opts.compression.compress(payload)
+	if err != nil {
+		return nil, err
+	}
+	payload, err = m.current().keyring.Encrypt(encodeEnvelope(header, payload))
+	if err != nil {
+		return nil, err
+	}
+	return RawValue(payload), nil
+}
+
+// encodeMarker builds an envelope carrying only flags and body, used for
+// negative-cache and error-cache entries.
+func (m *Manager) encodeMarker(flags byte, body []byte) (RawValue, error) {
+	header := envelopeHeader{flags: flags, schema: m.schemaVersion}
+	payload, err := m.current().keyring.Encrypt(encodeEnvelope(header, body))
+	if err != nil {
+		return nil, err
+	}
+	return RawValue(payload), nil
+}
+
+// entryMeta carries envelope metadata of a decoded entry.
+type entryMeta struct {
+	freshUntil time.Time
+}
+
+// stale reports whether the entry is past its freshness deadline.
+func (e entryMeta) stale(now time.Time) bool {
+	return !e.freshUntil.IsZero() && now.After(e.freshUntil)
+}
+
+func (m *Manager) decode(data []byte, dest interface{}) error {
+	_, err := m.decodeEntry(data, dest)
+	return err
+}
+
+func (m *Manager) decodeEntry(data []byte, dest interface{}) (entryMeta, error) {
+	return m.decodeEntryWith(data, dest, m.current().codec)
+}
+
+// decodeEntryWith decodes data, using codec for payloads whose envelope
+// does not name a registered codec.
+func (m *Manager) decodeEntryWith(data []byte, dest interface{}, codec Codec) (entryMeta, error) {
+	var meta entryMeta
+	settings := m.current()
+	data, err := settings.keyring.Decrypt(data)
+	if err != nil {
+		return meta, err
+	}
+	if !isEnvelope(data) {
+		return meta, m.decodeLegacy(data, dest, codec)
+	}
+
+	header, body, err := decodeEnvelope(data)
+	if err != nil {
+		return meta, err
+	}
+	meta.freshUntil = header.freshUntil
+	if header.schema != m.schemaVersion {
+		return meta, &PayloadFormatError{Reason: fmt.Sprintf("schema version %d, want %d", header.schema, m.schemaVersion)}
+	}
+	if header.flags&envelopeFlagAbsent != 0 {
+		return meta, ErrNotFoundCached
+	}
+	if header.flags&envelopeFlagError != 0 {
+		return meta, decodeCachedError(body)
+	}
+	if header.compression != 0 {
+		if body, err = settings.compression.decompressBody(header.compression, body); err != nil {
+			return meta, err
+		}
+	}
+
+	switch header.codec {
+	case codecIDCustom:
+		ok, err := m.marshalers.unmarshal(body, dest)
+		if !ok {
+			return meta, &PayloadFormatError{Reason: fmt.Sprintf("no marshaler registered for %T", dest)}
+		}
+		return meta, err
+	case CodecIDDefault:
+		return meta, codec.Unmarshal(body, dest)
+	default:
+		registered, ok := codecByID(header.codec)
+		if !ok {
+			return meta, &PayloadFormatError{Reason: fmt.Sprintf("unknown codec id %d", header.codec)}
+		}
+		return meta, registered.Unmarshal(body, dest)
+	}
+}
+
+// decodeLegacy reads payloads written before the envelope was introduced.
+func (m *Manager) decodeLegacy(data []byte, dest interface{}, codec Codec) error {
+	data, err := m.current().compression.Decompress(data)
+	if err != nil {
+		return err
+	}
+	if ok, err := m.marshalers.unmarshal(data, dest); ok {
+		return err
+	}
+	return codec.Unmarshal(data, dest)
+}
+
+// compressionFor returns a policy that always compresses with algo,
+// sharing the manager's level and dictionary when it uses the same algorithm.
+func (m *Manager) compressionFor(algo CompressionAlgorithm) *CacheCompression {
+	if c, ok := m.compressors.Load(algo); ok {
+		return c.(*CacheCompression)
+	}
+	c := &CacheCompression{Algorithm: algo}
+	if current := m.current().compression; current != nil && current.algorithm() == algo {
+		c.Level = current.Level
+		c.Dictionary = current.Dictionary
+		c.DictionaryID = current.DictionaryID
+	}
+	actual, _ := m.compressors.LoadOrStore(algo, c)
+	return actual.(*CacheCompression)
+}
@@ -0,0 +1,50 @@
+package eitcache
+
+import "context"
+
+// WithPrefetchNext makes QueryWithPagination load the following page in
+// the background after serving a page that has one, so sequential
+// browsing almost always hits the cache. load fetches the page described
+// by params and must return the same type as the query. Prefetches run on
+// the background refresh pool, at most once per key at a time, skip pages
+// already cached, and are dropped when the pool is busy.
+func WithPrefetchNext[T any](load func(params *PaginationParams) ([]T, int64, error)) QueryOption {
+	return func(o *QueryOptions) {
+		o.prefetch = func(ctx context.Context, manager *Manager, resource string, filters map[string]interface{}, params *PaginationParams, options *QueryOptions) {
+			prefetchPage(ctx, manager, resource, filters, params, options, load)
+		}
+	}
+}
+
+// prefetchPage schedules loading the page after params.
+func prefetchPage[T any](
+	ctx context.Context,
+	manager *Manager,
+	resource string,
+	filters map[string]interface{},
+	params *PaginationParams,
+	options *QueryOptions,
+	load func(params *PaginationParams) ([]T, int64, error),
+) {
+	next := *params
+	next.Page++
+	key, original, err := manager.resourceKey(ctx, resource, filters, &next)
+	if err != nil {
+		return
+	}
+	manager.revalidate(ctx, key, func(ctx context.Context) error {
+		if cached, err := manager.Exists(ctx, key); err != nil || cached {
+			return err
+		}
+		data, total, err := load(&next)
+		if err != nil {
+			return err
+		}
+		return storePaginationItem(ctx, manager, resource, key, filters, paginationCacheItem[T]{
+			Data:        data,
+			Total:       total,
+			DataHash:    GenerateDataHash(data),
+			OriginalKey: original,
+		}, options)
+	})
+}
@@ -0,0 +1,115 @@
+package eitcache
+
+import (
+	"strings"
+	"time"
+)
+
+// maxMetricPrefixes caps the number of prefixes tracked by the Monitor;
+// hits and misses for further prefixes are counted under OtherPrefix.
+const maxMetricPrefixes = 256
+
+// OtherPrefix collects per-prefix metrics once maxMetricPrefixes is reached.
+const OtherPrefix = "_other"
+
+// PrefixMetrics holds hit/miss counts and latency for one key prefix.
+type PrefixMetrics struct {
+	HitCount    int64          `json:"hit_count"`
+	MissCount   int64          `json:"miss_count"`
+	HitRatio    float64        `json:"hit_ratio"`
+	HitLatency  LatencySummary `json:"hit_latency"`
+	MissLatency LatencySummary `json:"miss_latency"`
+}
+
+type prefixStats struct {
+	hits   histogram
+	misses histogram
+}
+
+// KeyPrefix returns the part of key before the first colon, or the whole
+// key if it has none.
+func KeyPrefix(key string) string {
+	if i := strings.IndexByte(key, ':'); i >= 0 {
+		return key[:i]
+	}
+	return key
+}
+
+// RecordKeyHit records a cache hit for key, updating both the global and
+// the per-prefix metrics.
+func (m *Monitor) RecordKeyHit(key string, duration time.Duration) {
+	m.window.record(true)
+	if m.skip(true) {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.metrics.HitCount++
+	m.hits.record(int64(duration))
+	m.prefixStats(key).hits.record(int64(duration))
+	if m.hotKeys != nil {
+		m.hotKeys.record(key)
+	}
+	m.track(duration)
+}
+
+// RecordKeyMiss records a cache miss for key, updating both the global
+// and the per-prefix metrics.
+func (m *Monitor) RecordKeyMiss(key string, duration time.Duration) {
+	m.window.record(false)
+	if m.skip(false) {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.metrics.MissCount++
+	m.misses.record(int64(duration))
+	m.prefixStats(key).misses.record(int64(duration))
+	if m.hotKeys != nil {
+		m.hotKeys.record(key)
+	}
+	m.track(duration)
+}
+
+// GetMetricsByPrefix returns a snapshot of metrics keyed by KeyPrefix.
+func (m *Monitor) GetMetricsByPrefix() map[string]PrefixMetrics {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	out := make(map[string]PrefixMetrics, len(m.prefixes))
+	for prefix, stats := range m.prefixes {
+		metrics := PrefixMetrics{
+			HitCount:    stats.hits.total,
+			MissCount:   stats.misses.total,
+			HitLatency:  stats.hits.latency(),
+			MissLatency: stats.misses.latency(),
+		}
+		if total := metrics.HitCount + metrics.MissCount; total > 0 {
+			metrics.HitRatio = float64(metrics.HitCount) / float64(total)
+		}
+		out[prefix] = metrics
+	}
+	return out
+}
+
+func (m *Monitor) prefixStats(key string) *prefixStats {
+	prefix := KeyPrefix(key)
+	if m.prefixes == nil {
+		m.prefixes = make(map[string]*prefixStats)
+	}
+	stats, ok := m.prefixes[prefix]
+	if ok {
+		return stats
+	}
+	if len(m.prefixes) >= maxMetricPrefixes {
+		prefix = OtherPrefix
+		if stats, ok = m.prefixes[prefix]; ok {
+			return stats
+		}
+	}
+	stats = &prefixStats{}
+	m.prefixes[prefix] = stats
+	return stats
+}
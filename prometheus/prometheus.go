@@ -0,0 +1,168 @@
+// Package prometheus exposes eitcache metrics as a Prometheus collector.
+//
+//	collector := prometheus.NewCollector(manager)
+//	registry.MustRegister(collector)
+package prometheus
+
+import (
+	"context"
+	"strings"
+
+	eitcache "github.com/eit-cms/eit-cache"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// PrefixFunc maps a cache key to the prefix label. Keep its range small:
+// every distinct prefix is a separate time series.
+type PrefixFunc func(key string) string
+
+// FirstSegment labels keys by the part before the first colon, so
+// "articles:page:1" is labeled "articles".
+func FirstSegment(key string) string {
+	if i := strings.IndexByte(key, ':'); i >= 0 {
+		return key[:i]
+	}
+	return key
+}
+
+// Option configures a Collector.
+type Option func(*Collector)
+
+// WithNamespace sets the metric namespace. The default is "eitcache".
+func WithNamespace(namespace string) Option {
+	return func(c *Collector) {
+		c.namespace = namespace
+	}
+}
+
+// WithPrefixFunc sets how keys map to the prefix label. The default is
+// FirstSegment.
+func WithPrefixFunc(fn PrefixFunc) Option {
+	return func(c *Collector) {
+		c.prefix = fn
+	}
+}
+
+// WithBuckets sets the latency histogram buckets in seconds.
+func WithBuckets(buckets []float64) Option {
+	return func(c *Collector) {
+		c.buckets = buckets
+	}
+}
+
+// Collector implements prometheus.Collector for a Manager. Totals come
+// from the manager's Monitor; per-prefix requests and latency histograms
+// are recorded through lifecycle hooks from the time it is created.
+type Collector struct {
+	manager   *eitcache.Manager
+	namespace string
+	prefix    PrefixFunc
+	buckets   []float64
+
+	hits        *prom.Desc
+	misses      *prom.Desc
+	evictions   *prom.Desc
+	staleServes *prom.Desc
+	errors      *prom.Desc
+	retries     *prom.Desc
+	hitRatio    *prom.Desc
+	poolHits    *prom.Desc
+	poolMisses  *prom.Desc
+	poolTimeout *prom.Desc
+	poolConns   *prom.Desc
+
+	requests *prom.CounterVec
+	latency  *prom.HistogramVec
+}
+
+// NewCollector creates a collector and registers its hooks on manager.
+func NewCollector(manager *eitcache.Manager, opts ...Option) *Collector {
+	c := &Collector{
+		manager:   manager,
+		namespace: "eitcache",
+		prefix:    FirstSegment,
+		buckets:   prom.ExponentialBuckets(0.0001, 4, 8),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	desc := func(name, help string, labels ...string) *prom.Desc {
+		return prom.NewDesc(prom.BuildFQName(c.namespace, "", name), help, labels, nil)
+	}
+	c.hits = desc("hits_total", "Cache hits.")
+	c.misses = desc("misses_total", "Cache misses.")
+	c.evictions = desc("evictions_total", "Entries evicted by the adapter.")
+	c.staleServes = desc("stale_serves_total", "Stale entries served.")
+	c.errors = desc("errors_total", "Adapter errors by operation.", "op")
+	c.retries = desc("retries_total", "Adapter calls retried.")
+	c.hitRatio = desc("hit_ratio", "Hits divided by hits and misses.")
+	c.poolHits = desc("pool_hits_total", "Connections reused from the pool.")
+	c.poolMisses = desc("pool_misses_total", "Connections opened because the pool had none free.")
+	c.poolTimeout = desc("pool_timeouts_total", "Waits for a pool connection that timed out.")
+	c.poolConns = desc("pool_connections", "Pool connections by state.", "state")
+	c.requests = prom.NewCounterVec(prom.CounterOpts{
+		Namespace: c.namespace,
+		Name:      "prefix_requests_total",
+		Help:      "Cache operations by key prefix and result.",
+	}, []string{"prefix", "result"})
+	c.latency = prom.NewHistogramVec(prom.HistogramOpts{
+		Namespace: c.namespace,
+		Name:      "operation_duration_seconds",
+		Help:      "Cache operation latency by key prefix.",
+		Buckets:   c.buckets,
+	}, []string{"op", "prefix"})
+
+	observe := func(op, result string) eitcache.Hook {
+		return func(ctx context.Context, event eitcache.HookEvent) {
+			prefix := c.prefix(event.Key)
+			c.requests.WithLabelValues(prefix, result).Inc()
+			c.latency.WithLabelValues(op, prefix).Observe(event.Duration.Seconds())
+		}
+	}
+	manager.OnHit(observe("get", "hit"))
+	manager.OnMiss(observe("get", "miss"))
+	manager.OnSet(observe("set", "set"))
+	manager.OnDelete(observe("delete", "delete"))
+	return c
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prom.Desc) {
+	for _, d := range []*prom.Desc{c.hits, c.misses, c.evictions, c.staleServes, c.errors, c.retries, c.hitRatio,
+		c.poolHits, c.poolMisses, c.poolTimeout, c.poolConns} {
+		ch <- d
+	}
+	c.requests.Describe(ch)
+	c.latency.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prom.Metric) {
+	monitor := c.manager.Monitor()
+	metrics := monitor.GetMetrics()
+	counter := func(d *prom.Desc, v int64, labels ...string) {
+		ch <- prom.MustNewConstMetric(d, prom.CounterValue, float64(v), labels...)
+	}
+	counter(c.hits, metrics.HitCount)
+	counter(c.misses, metrics.MissCount)
+	counter(c.evictions, metrics.EvictionCount)
+	counter(c.staleServes, metrics.StaleServeCount)
+	counter(c.errors, metrics.GetErrorCount, "get")
+	counter(c.errors, metrics.SetErrorCount, "set")
+	counter(c.retries, metrics.RetryCount)
+	ch <- prom.MustNewConstMetric(c.hitRatio, prom.GaugeValue, monitor.HitRatio())
+	if pool, ok := c.manager.PoolStats(); ok {
+		counter(c.poolHits, int64(pool.Hits))
+		counter(c.poolMisses, int64(pool.Misses))
+		counter(c.poolTimeout, int64(pool.Timeouts))
+		gauge := func(v uint32, state string) {
+			ch <- prom.MustNewConstMetric(c.poolConns, prom.GaugeValue, float64(v), state)
+		}
+		gauge(pool.TotalConns, "total")
+		gauge(pool.IdleConns, "idle")
+		gauge(pool.StaleConns, "stale")
+	}
+	c.requests.Collect(ch)
+	c.latency.Collect(ch)
+}
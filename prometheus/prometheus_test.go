@@ -0,0 +1,62 @@
+package prometheus
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	eitcache "github.com/eit-cms/eit-cache"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	manager, err := eitcache.NewManager(&eitcache.CacheConfig{Type: eitcache.CacheTypeMemory, DefaultTTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+	collector := NewCollector(manager, WithNamespace("test"))
+
+	ctx := context.Background()
+	manager.Set(ctx, "articles:1", "v", 0)
+	var dest string
+	manager.Get(ctx, "articles:1", &dest)
+	manager.Get(ctx, "users:1", &dest)
+	manager.Get(ctx, "users:2", &dest)
+
+	expected := `
+# HELP test_hits_total Cache hits.
+# TYPE test_hits_total counter
+test_hits_total 1
+# HELP test_misses_total Cache misses.
+# TYPE test_misses_total counter
+test_misses_total 2
+# HELP test_errors_total Adapter errors by operation.
+# TYPE test_errors_total counter
+test_errors_total{op="get"} 0
+test_errors_total{op="set"} 0
+# HELP test_prefix_requests_total Cache operations by key prefix and result.
+# TYPE test_prefix_requests_total counter
+test_prefix_requests_total{prefix="articles",result="hit"} 1
+test_prefix_requests_total{prefix="articles",result="set"} 1
+test_prefix_requests_total{prefix="users",result="miss"} 2
+`
+	names := []string{"test_hits_total", "test_misses_total", "test_errors_total", "test_prefix_requests_total"}
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected), names...); err != nil {
+		t.Fatal(err)
+	}
+	if n := testutil.CollectAndCount(collector, "test_operation_duration_seconds"); n != 3 {
+		t.Fatalf("expected latency series for get/articles, get/users and set/articles, got %d", n)
+	}
+	if n := testutil.CollectAndCount(collector, "test_pool_connections"); n != 0 {
+		t.Fatalf("expected no pool metrics for the memory adapter, got %d", n)
+	}
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(`
+# HELP test_hit_ratio Hits divided by hits and misses.
+# TYPE test_hit_ratio gauge
+test_hit_ratio 0.3333333333333333
+`), "test_hit_ratio"); err != nil {
+		t.Fatal(err)
+	}
+}
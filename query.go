@@ -0,0 +1,512 @@
+package eitcache
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// QueryOptions controls Query behavior.
+type QueryOptions struct {
+	TTL             time.Duration
+	UseCache        bool
+	Ticket          *CacheTicket
+	Compression     CompressionAlgorithm
+	NoCompression   bool
+	StaleTTL        time.Duration
+	TTLJitter       float64
+	NegativeTTL     time.Duration
+	ErrorTTL        time.Duration
+	RefreshAhead    float64
+	DistributedLock bool
+	Codec           Codec
+	StaleOnError    bool
+	ServedStale     *bool
+	StrictCache     bool
+
+	prefetch func(ctx context.Context, manager *Manager, resource string, filters map[string]interface{}, params *PaginationParams, options *QueryOptions)
+}
+
+// QueryOption mutates QueryOptions.
+type QueryOption func(*QueryOptions)
+
+// WithTTL sets cache TTL for Query.
+func WithTTL(ttl time.Duration) QueryOption {
+	return func(o *QueryOptions) {
+		o.TTL = ttl
+	}
+}
+
+// WithNoCache disables cache for Query.
+func WithNoCache() QueryOption {
+	return func(o *QueryOptions) {
+		o.UseCache = false
+	}
+}
+
+// WithTicket validates ticket against the manager's clock before Query.
+func WithTicket(ticket *CacheTicket) QueryOption {
+	return func(o *QueryOptions) {
+		o.Ticket = ticket
+	}
+}
+
+// WithCompression compresses the Query result with algo regardless of
+// the manager compression threshold.
+func WithCompression(algo CompressionAlgorithm) QueryOption {
+	return func(o *QueryOptions) {
+		o.Compression = algo
+		o.NoCompression = false
+	}
+}
+
+// WithNoCompression stores the Query result uncompressed even when the
+// manager compresses payloads above its threshold.
+func WithNoCompression() QueryOption {
+	return func(o *QueryOptions) {
+		o.Compression = 0
+		o.NoCompression = true
+	}
+}
+
+// WithStaleWhileRevalidate keeps entries for staleTTL past their TTL.
+// A stale entry is returned immediately while it is refreshed in the background.
+func WithStaleWhileRevalidate(staleTTL time.Duration) QueryOption {
+	return func(o *QueryOptions) {
+		o.StaleTTL = staleTTL
+	}
+}
+
+// WithTTLJitter randomizes the Query TTL by ±fraction, overriding
+// the manager default.
+func WithTTLJitter(fraction float64) QueryOption {
+	return func(o *QueryOptions) {
+		o.TTLJitter = fraction
+	}
+}
+
+// WithNegativeCache caches an "absent" marker for ttl when the loader
+// returns ErrNotFound or an empty result. Later hits return ErrNotFoundCached.
+func WithNegativeCache(ttl time.Duration) QueryOption {
+	return func(o *QueryOptions) {
+		o.NegativeTTL = ttl
+	}
+}
+
+// WithErrorCache caches loader errors for ttl so a failing dependency
+// is not hit by every request. Hits return a *CachedError.
+func WithErrorCache(ttl time.Duration) QueryOption {
+	return func(o *QueryOptions) {
+		o.ErrorTTL = ttl
+	}
+}
+
+// WithRefreshAhead recomputes entries in the background when they are
+// read during the last fraction of their TTL, so hot keys never expire.
+func WithRefreshAhead(fraction float64) QueryOption {
+	return func(o *QueryOptions) {
+		o.RefreshAhead = fraction
+	}
+}
+
+// WithDistributedLock makes a miss acquire an adapter-level lock so that
+// only one process runs queryFunc; others wait briefly for its value.
+func WithDistributedLock() QueryOption {
+	return func(o *QueryOptions) {
+		o.DistributedLock = true
+	}
+}
+
+// WithCodec encodes the Query result with codec instead of the manager codec.
+func WithCodec(codec Codec) QueryOption {
+	return func(o *QueryOptions) {
+		o.Codec = codec
+	}
+}
+
+// WithStaleOnError retains entries for the manager stale grace window
+// past their TTL. If queryFunc fails while only an expired copy exists,
+// that copy is returned instead of the error. Loader errors are then not
+// cached, so WithErrorCache cannot overwrite the retained copy.
+func WithStaleOnError() QueryOption {
+	return func(o *QueryOptions) {
+		o.StaleOnError = true
+	}
+}
+
+// WithServedStale sets *served to report whether Query returned an
+// expired copy because queryFunc failed.
+func WithServedStale(served *bool) QueryOption {
+	return func(o *QueryOptions) {
+		o.ServedStale = served
+	}
+}
+
+// WithStrictCache makes Query return adapter read and write errors
+// instead of falling back to the loader or dropping the write.
+func WithStrictCache() QueryOption {
+	return func(o *QueryOptions) {
+		o.StrictCache = true
+	}
+}
+
+// newQueryOptions applies opts over the manager defaults.
+func newQueryOptions(manager *Manager, opts []QueryOption) *QueryOptions {
+	options := &QueryOptions{
+		UseCache:  true,
+		TTLJitter: manager.current().ttlJitter,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// codec returns the codec for decoding payloads without a registered one.
+func (o *QueryOptions) codec(manager *Manager) Codec {
+	if o.Codec != nil {
+		return o.Codec
+	}
+	return manager.current().codec
+}
+
+// encoding returns the encode options for a write.
+func (o *QueryOptions) encoding(manager *Manager) encodeOptions {
+	enc := encodeOptions{codec: o.Codec, compression: manager.current().compression}
+	if o.NoCompression {
+		enc.compression = nil
+	} else if o.Compression != 0 {
+		enc.compression = manager.compressionFor(o.Compression)
+	}
+	return enc
+}
+
+// Query runs a cached query with generic result.
+func Query[T any](ctx context.Context, manager *Manager, key string, queryFunc func() (T, error), opts ...QueryOption) (T, error) {
+	var zero T
+	if manager == nil {
+		return zero, ErrManagerNil
+	}
+	if manager.adapter == nil {
+		return zero, errors.New("cache adapter is nil")
+	}
+	ctx, span := manager.startSpan(ctx, "query", key)
+	result, err := query(ctx, manager, key, queryFunc, opts...)
+	span.finish(err)
+	return result, err
+}
+
+func query[T any](ctx context.Context, manager *Manager, key string, queryFunc func() (T, error), opts ...QueryOption) (T, error) {
+	var zero T
+
+	options := newQueryOptions(manager, opts)
+
+	if options.Ticket != nil {
+		if err := options.Ticket.ValidateWithClock(manager.clock); err != nil {
+			return zero, err
+		}
+	}
+
+	if !options.UseCache {
+		return queryFunc()
+	}
+
+	if options.ServedStale != nil {
+		*options.ServedStale = false
+	}
+
+	var (
+		stale     T
+		haveStale bool
+	)
+	var (
+		stored *storedValue
+		data   []byte
+		err    error
+	)
+	start := time.Now()
+	if manager.values != nil {
+		stored, data, err = manager.loadValue(ctx, key)
+	} else {
+		data, err = manager.load(ctx, key)
+	}
+	elapsed := time.Since(start)
+	if err == nil && (data != nil || stored != nil) {
+		var (
+			cached T
+			meta   entryMeta
+		)
+		if stored != nil {
+			meta, err = decodeValue(stored, &cached)
+		} else {
+			meta, err = manager.decodeEntryWith(data, &cached, options.codec(manager))
+		}
+		switch {
+		case err == nil && options.StaleOnError && options.StaleTTL <= 0 && options.RefreshAhead <= 0 && meta.stale(manager.clock.Now()):
+			stale, haveStale = cached, true
+			manager.recordMiss(ctx, key, elapsed)
+		case err == nil:
+			manager.recordHit(ctx, key, elapsed, len(data))
+			if (options.StaleTTL > 0 || options.RefreshAhead > 0) && meta.stale(manager.clock.Now()) {
+				manager.revalidate(ctx, key, func(ctx context.Context) error {
+					_, err := queryLoad(ctx, manager, key, queryFunc, options)
+					return err
+				})
+			}
+			return cached, nil
+		case errors.Is(err, ErrNotFoundCached) || errors.As(err, new(*CachedError)):
+			manager.recordHit(ctx, key, elapsed, len(data))
+			return zero, err
+		default:
+			manager.recordMiss(ctx, key, elapsed)
+			manager.recordSerializationError(err)
+		}
+	} else {
+		manager.recordMiss(ctx, key, elapsed)
+		if err != nil {
+			manager.reportError(ctx, OpGet, key, err)
+			if options.StrictCache {
+				return zero, err
+			}
+		}
+	}
+
+	load := func() (T, error) {
+		return queryLoad(ctx, manager, key, queryFunc, options)
+	}
+	var result T
+	if options.DistributedLock {
+		result, err = coalesce(manager, key, func() (T, error) {
+			return lockedLoad(ctx, manager, key, options.codec(manager), load)
+		})
+	} else {
+		result, err = coalesce(manager, key, load)
+	}
+	if err != nil && haveStale {
+		if manager.monitor != nil {
+			manager.monitor.RecordStaleServe()
+		}
+		if options.ServedStale != nil {
+			*options.ServedStale = true
+		}
+		return stale, nil
+	}
+	return result, err
+}
+
+// queryLoad runs queryFunc and stores its result.
+func queryLoad[T any](ctx context.Context, manager *Manager, key string, queryFunc func() (T, error), options *QueryOptions) (T, error) {
+	start := time.Now()
+	result, err := queryFunc()
+	manager.slow.observe("load", key, backendName(manager.adapter), time.Since(start))
+	if options.NegativeTTL > 0 && (errors.Is(err, ErrNotFound) || (err == nil && isEmptyResult(result))) {
+		if payload, err := manager.encodeMarker(envelopeFlagAbsent, nil); err == nil {
+			if err := manager.store(ctx, key, payload, options.NegativeTTL); err != nil {
+				manager.reportError(ctx, OpSet, key, err)
+			}
+		}
+		return result, err
+	}
+	if err != nil {
+		if options.ErrorTTL > 0 && !options.StaleOnError {
+			if payload, encErr := manager.encodeMarker(envelopeFlagError, encodeCachedError(err)); encErr == nil {
+				if setErr := manager.store(ctx, key, payload, options.ErrorTTL); setErr != nil {
+					manager.reportError(ctx, OpSet, key, setErr)
+				}
+			}
+		}
+		return result, err
+	}
+
+	ttl := options.TTL
+	if ttl == 0 {
+		ttl = manager.ttlFor(key)
+	}
+	ttl = jitterTTL(ttl, options.TTLJitter)
+	enc := options.encoding(manager)
+	now := manager.clock.Now()
+	if options.StaleTTL > 0 && ttl > 0 {
+		enc.freshUntil = now.Add(ttl)
+		ttl += options.StaleTTL
+	} else if options.StaleOnError && ttl > 0 {
+		enc.freshUntil = now.Add(ttl)
+		ttl += manager.current().staleGrace
+	} else if options.RefreshAhead > 0 && options.RefreshAhead < 1 && ttl > 0 {
+		enc.freshUntil = now.Add(time.Duration(float64(ttl) * (1 - options.RefreshAhead)))
+	}
+	if manager.values != nil {
+		if err := manager.storeValue(ctx, key, result, enc.freshUntil, ttl); err != nil {
+			manager.reportError(ctx, OpSet, key, err)
+			if options.StrictCache {
+				return result, err
+			}
+		}
+	} else if payload, err := manager.encodeWith(result, enc); err == nil {
+		if err := manager.store(ctx, key, payload, ttl); err != nil {
+			manager.reportError(ctx, OpSet, key, err)
+			if options.StrictCache {
+				return result, err
+			}
+		}
+	} else {
+		manager.recordSerializationError(err)
+	}
+	return result, nil
+}
+
+// isEmptyResult reports whether v is a zero value or an empty slice or map.
+func isEmptyResult(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return true
+	}
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Map:
+		return rv.Len() == 0
+	}
+	return rv.IsZero()
+}
+
+// coalesce runs fn once per key among concurrent callers and shares
+// its result, so a hot key miss triggers a single load.
+func coalesce[T any](m *Manager, key string, fn func() (T, error)) (T, error) {
+	v, err, _ := m.flight.Do(m.key(key), func() (interface{}, error) {
+		return fn()
+	})
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	result, ok := v.(T)
+	if !ok {
+		// Another caller loaded the same key with a different type.
+		return fn()
+	}
+	return result, nil
+}
+
+// GetOrSet returns the cached value for key, or runs loader and stores
+// its result with first-writer-wins semantics when the adapter supports
+// SetNX. If another writer won the race, its value is returned instead.
+func GetOrSet[T any](ctx context.Context, m *Manager, key string, ttl time.Duration, loader func() (T, error)) (T, error) {
+	var zero T
+	if m == nil {
+		return zero, ErrManagerNil
+	}
+	if m.adapter == nil {
+		return zero, errors.New("cache adapter is nil")
+	}
+
+	var cached T
+	if hit, err := m.Get(ctx, key, &cached); err == nil && hit {
+		return cached, nil
+	}
+
+	result, err := loader()
+	if err != nil {
+		return zero, err
+	}
+	if ttl == 0 {
+		ttl = m.ttlFor(key)
+	}
+	ttl, ok := m.clampTTL(key, jitterTTL(ttl, m.current().ttlJitter))
+	if !ok {
+		return result, nil
+	}
+	payload, err := m.encode(result)
+	if err != nil {
+		return zero, err
+	}
+
+	nx, ok := m.adapter.(SetNXAdapter)
+	if size := m.current().chunkSize; !ok || m.ReadOnly() || (size > 0 && len(payload) > size) {
+		return result, m.store(ctx, key, payload, ttl)
+	}
+	var stored bool
+	start := time.Now()
+	err = m.guard(ctx, m.writeTimeout, func(ctx context.Context) (err error) {
+		stored, err = nx.SetNX(ctx, m.key(key), payload, ttl)
+		return err
+	})
+	if err != nil {
+		return result, err
+	}
+	if stored {
+		m.recordSet(ctx, key, time.Since(start), len(payload))
+		return result, nil
+	}
+	var winner T
+	hit, err := m.Get(ctx, key, &winner)
+	if err != nil {
+		m.reportError(ctx, OpGet, key, err)
+	} else if hit {
+		return winner, nil
+	}
+	return result, nil
+}
+
+// QueryMany reads keys from cache in one batch, calls loader only for
+// the missing keys and backfills the cache with its results. Keys the
+// loader does not return are absent from the result.
+func QueryMany[T any](ctx context.Context, m *Manager, keys []string, loader func(missing []string) (map[string]T, error), opts ...QueryOption) (map[string]T, error) {
+	if m == nil {
+		return nil, ErrManagerNil
+	}
+	if m.adapter == nil {
+		return nil, errors.New("cache adapter is nil")
+	}
+
+	options := newQueryOptions(m, opts)
+
+	result := make(map[string]T, len(keys))
+	missing := keys
+	if options.UseCache {
+		start := time.Now()
+		found, err := m.loadMany(ctx, keys)
+		elapsed := time.Since(start)
+		if err != nil {
+			m.reportError(ctx, OpGet, strings.Join(keys, ","), err)
+			if options.StrictCache {
+				return nil, err
+			}
+			found = nil
+		}
+		missing = make([]string, 0, len(keys))
+		for _, key := range keys {
+			data, ok := found[key]
+			if ok {
+				var cached T
+				if _, err := m.decodeEntryWith(data, &cached, options.codec(m)); err == nil {
+					result[key] = cached
+					m.recordHit(ctx, key, elapsed, len(data))
+					continue
+				}
+			}
+			m.recordMiss(ctx, key, elapsed)
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	loaded, err := loader(missing)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range missing {
+		value, ok := loaded[key]
+		if !ok {
+			continue
+		}
+		result[key] = value
+		if options.UseCache {
+			if _, err := queryLoad(ctx, m, key, func() (T, error) { return value, nil }, options); err != nil {
+				return result, err
+			}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,108 @@
+package eitcache
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultRefreshWorkers is the background refresh pool size.
+const DefaultRefreshWorkers = 4
+
+type refreshTask struct {
+	ctx     context.Context
+	key     string
+	refresh func(context.Context) error
+}
+
+// refreshPool recomputes entries in the background with a bounded
+// number of workers. Each key is queued at most once at a time and
+// tasks are dropped when the queue is full.
+type refreshPool struct {
+	workers int
+	once    sync.Once
+	queue   chan refreshTask
+	done    chan struct{}
+	wg      sync.WaitGroup
+
+	mu       sync.Mutex
+	inflight map[string]struct{}
+	closed   bool
+}
+
+func newRefreshPool(workers int) *refreshPool {
+	if workers <= 0 {
+		workers = DefaultRefreshWorkers
+	}
+	return &refreshPool{
+		workers:  workers,
+		queue:    make(chan refreshTask, workers*64),
+		done:     make(chan struct{}),
+		inflight: make(map[string]struct{}),
+	}
+}
+
+func (p *refreshPool) start() {
+	p.once.Do(func() {
+		for i := 0; i < p.workers; i++ {
+			p.wg.Add(1)
+			go p.run()
+		}
+	})
+}
+
+func (p *refreshPool) run() {
+	defer p.wg.Done()
+	for {
+		select {
+		case task := <-p.queue:
+			_ = task.refresh(task.ctx)
+			p.mu.Lock()
+			delete(p.inflight, task.key)
+			p.mu.Unlock()
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// enqueue schedules a refresh and reports whether it was accepted.
+func (p *refreshPool) enqueue(task refreshTask) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return false
+	}
+	if _, ok := p.inflight[task.key]; ok {
+		return false
+	}
+	p.start()
+	select {
+	case p.queue <- task:
+		p.inflight[task.key] = struct{}{}
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *refreshPool) close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	p.mu.Unlock()
+	close(p.done)
+	p.wg.Wait()
+}
+
+// revalidate refreshes key in the background worker pool. The refresh
+// outlives the caller's request but keeps its context values.
+func (m *Manager) revalidate(ctx context.Context, key string, refresh func(context.Context) error) {
+	m.refresher.enqueue(refreshTask{
+		ctx:     context.WithoutCancel(ctx),
+		key:     m.key(key),
+		refresh: refresh,
+	})
+}
@@ -0,0 +1,71 @@
+package eitcache
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var managers = struct {
+	mu     sync.RWMutex
+	byName map[string]*Manager
+}{byName: make(map[string]*Manager)}
+
+// Register makes m available to other subsystems under name.
+func Register(name string, m *Manager) error {
+	if m == nil {
+		return ErrManagerNil
+	}
+	managers.mu.Lock()
+	defer managers.mu.Unlock()
+	if _, ok := managers.byName[name]; ok {
+		return fmt.Errorf("cache manager %q already registered", name)
+	}
+	managers.byName[name] = m
+	return nil
+}
+
+// Get returns the manager registered under name.
+func Get(name string) (*Manager, bool) {
+	managers.mu.RLock()
+	defer managers.mu.RUnlock()
+	m, ok := managers.byName[name]
+	return m, ok
+}
+
+// Unregister removes name from the registry without closing its manager.
+func Unregister(name string) {
+	managers.mu.Lock()
+	delete(managers.byName, name)
+	managers.mu.Unlock()
+}
+
+// Registered returns the registered manager names in sorted order.
+func Registered() []string {
+	managers.mu.RLock()
+	names := make([]string, 0, len(managers.byName))
+	for name := range managers.byName {
+		names = append(names, name)
+	}
+	managers.mu.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+// CloseAll closes and unregisters every registered manager, returning
+// all close errors joined.
+func CloseAll() error {
+	managers.mu.Lock()
+	registered := managers.byName
+	managers.byName = make(map[string]*Manager)
+	managers.mu.Unlock()
+
+	var errs []error
+	for name, m := range registered {
+		if err := m.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("close cache manager %q: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,92 @@
+package eitcache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy retries transient adapter errors at the Manager layer,
+// on top of any retries the adapter client performs itself.
+type RetryPolicy struct {
+	// MaxAttempts includes the first call; values below 2 disable retries.
+	MaxAttempts int
+	// Backoff is the delay before the first retry, doubled for each
+	// following one up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Retryable classifies errors; nil uses DefaultRetryable.
+	Retryable func(error) bool
+}
+
+// NewRetryPolicy creates a policy with exponential backoff starting at
+// backoff and capped at 20 times that.
+func NewRetryPolicy(attempts int, backoff time.Duration) *RetryPolicy {
+	return &RetryPolicy{MaxAttempts: attempts, Backoff: backoff, MaxBackoff: 20 * backoff}
+}
+
+// DefaultRetryable reports whether err looks transient: timeouts,
+// connection resets and Redis cluster redirections or loading states.
+func DefaultRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	msg := err.Error()
+	for _, prefix := range []string{"MOVED ", "ASK ", "TRYAGAIN", "LOADING", "CLUSTERDOWN", "READONLY"} {
+		if strings.HasPrefix(msg, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *RetryPolicy) attempts() int {
+	if p == nil || p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return DefaultRetryable(err)
+}
+
+// delay returns the jittered wait before retry n, starting at 1.
+func (p *RetryPolicy) delay(n int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < n && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// SetRetryPolicy replaces the retry policy. Nil disables retries.
+func (m *Manager) SetRetryPolicy(policy *RetryPolicy) {
+	m.update(func(s *managerSettings) { s.retry = policy })
+}
@@ -0,0 +1,32 @@
+package eitcache
+
+import (
+	"math"
+	"math/rand/v2"
+)
+
+// SetSampleRate records latency, per-prefix metrics and hot keys for only
+// a rate fraction of hits and misses, so busy services take the Monitor
+// lock less often. Hit and miss counts and rolling windows stay exact.
+// Rates of zero or one and above record every operation.
+func (m *Monitor) SetSampleRate(rate float64) {
+	if rate <= 0 || rate > 1 {
+		rate = 1
+	}
+	m.sampleRate.Store(math.Float64bits(rate))
+}
+
+// skip reports whether a hit or miss falls outside the sample, counting
+// it without taking the lock if so.
+func (m *Monitor) skip(hit bool) bool {
+	rate := math.Float64frombits(m.sampleRate.Load())
+	if rate <= 0 || rate >= 1 || rand.Float64() < rate {
+		return false
+	}
+	if hit {
+		m.unsampledHits.Add(1)
+	} else {
+		m.unsampledMisses.Add(1)
+	}
+	return true
+}
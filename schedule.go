@@ -0,0 +1,123 @@
+package eitcache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// invalidationSchedule tracks keys that must vanish at a fixed time.
+type invalidationSchedule struct {
+	mu        sync.Mutex
+	deadlines map[string]time.Time
+	timers    map[string]chan struct{}
+	closed    bool
+	// active mirrors len(deadlines) so writes skip the lock when empty.
+	active atomic.Int64
+}
+
+func newInvalidationSchedule() *invalidationSchedule {
+	return &invalidationSchedule{
+		deadlines: make(map[string]time.Time),
+		timers:    make(map[string]chan struct{}),
+	}
+}
+
+func (s *invalidationSchedule) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for key, stop := range s.timers {
+		close(stop)
+		delete(s.timers, key)
+	}
+}
+
+// clampTTL shortens ttl so an entry written now expires no later than
+// the scheduled invalidation of key. It reports false when the deadline
+// has already passed and the write should be skipped.
+func (m *Manager) clampTTL(key string, ttl time.Duration) (time.Duration, bool) {
+	if m.schedule.active.Load() == 0 {
+		return ttl, true
+	}
+	m.schedule.mu.Lock()
+	deadline, ok := m.schedule.deadlines[m.key(key)]
+	m.schedule.mu.Unlock()
+	if !ok {
+		return ttl, true
+	}
+	remaining := deadline.Sub(m.clock.Now())
+	if remaining <= 0 {
+		return 0, false
+	}
+	if ttl <= 0 || ttl > remaining {
+		return remaining, true
+	}
+	return ttl, true
+}
+
+// SetWithExpireAt writes value so that it expires exactly at at, without
+// TTL jitter. A time in the past deletes key instead.
+func (m *Manager) SetWithExpireAt(ctx context.Context, key string, value interface{}, at time.Time) error {
+	ttl := at.Sub(m.clock.Now())
+	if ttl <= 0 {
+		return m.Delete(ctx, key)
+	}
+	return m.set(ctx, key, value, ttl)
+}
+
+// ScheduleInvalidation deletes key at at, for embargoed content that must
+// disappear at publish or unpublish time. Until then, writes to key are
+// capped to expire by at, so a refill cannot outlive the deadline. The
+// timer runs on CacheConfig.Clock in this process and is dropped by
+// Close; scheduling a key again replaces its deadline.
+func (m *Manager) ScheduleInvalidation(ctx context.Context, key string, at time.Time) error {
+	delay := at.Sub(m.clock.Now())
+	if delay <= 0 {
+		return m.Delete(ctx, key)
+	}
+	full := m.key(key)
+	s := m.schedule
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errors.New("cache manager is closed")
+	}
+	if stop, ok := s.timers[full]; ok {
+		close(stop)
+	}
+	s.deadlines[full] = at
+	s.active.Store(int64(len(s.deadlines)))
+	stop := make(chan struct{})
+	fire := m.clock.After(delay)
+	go func() {
+		select {
+		case <-fire:
+		case <-stop:
+			return
+		}
+		s.mu.Lock()
+		current := s.timers[full] == stop
+		if current {
+			delete(s.timers, full)
+		}
+		s.mu.Unlock()
+		if !current {
+			return
+		}
+		ctx := context.WithoutCancel(ctx)
+		if err := m.Delete(ctx, key); err != nil {
+			m.reportError(ctx, OpInvalidate, key, err)
+		}
+		s.mu.Lock()
+		if s.deadlines[full].Equal(at) {
+			delete(s.deadlines, full)
+			s.active.Store(int64(len(s.deadlines)))
+		}
+		s.mu.Unlock()
+	}()
+	s.timers[full] = stop
+	return nil
+}
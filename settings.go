@@ -0,0 +1,57 @@
+package eitcache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// managerSettings holds the manager settings that can be replaced while
+// the cache is in use. A snapshot is never modified after it is stored;
+// setters store an updated copy.
+type managerSettings struct {
+	codec        Codec
+	compression  *CacheCompression
+	keyring      *Keyring
+	ttlJitter    float64
+	resourceTTLs map[string]time.Duration
+	onError      ErrorHandler
+	logger       Logger
+	staleGrace   time.Duration
+	chunkSize    int
+	doubleDelay  time.Duration
+	retry        *RetryPolicy
+	bigKeyThresh int
+	tracer       Tracer
+	sortFields   map[string][]string
+	pageDefaults map[string]PaginationDefaults
+	epochs       bool
+	tables       map[string][]string
+	keyHashOver  int
+	canon        KeyCanonicalization
+	countTTL     time.Duration
+	pageKeyIndex bool
+}
+
+func newSettingsPointer(s *managerSettings) *atomic.Pointer[managerSettings] {
+	p := &atomic.Pointer[managerSettings]{}
+	p.Store(s)
+	return p
+}
+
+// current returns the settings snapshot in effect.
+func (m *Manager) current() *managerSettings {
+	return m.settings.Load()
+}
+
+// update applies fn to a copy of the settings and stores it, retrying if
+// another setter ran concurrently.
+func (m *Manager) update(fn func(s *managerSettings)) {
+	for {
+		old := m.settings.Load()
+		s := *old
+		fn(&s)
+		if m.settings.CompareAndSwap(old, &s) {
+			return
+		}
+	}
+}
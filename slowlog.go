@@ -0,0 +1,94 @@
+package eitcache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultSlowLogSize is the number of slow operations kept when
+// CacheConfig.SlowLogSize is unset.
+const DefaultSlowLogSize = 128
+
+// SlowLogEntry describes a cache operation that exceeded the slow log
+// threshold. Op is a span operation (query, get, set, delete_pattern) or
+// "load" for a Query loader.
+type SlowLogEntry struct {
+	Time     time.Time     `json:"time"`
+	Op       string        `json:"op"`
+	Key      string        `json:"key"`
+	Backend  string        `json:"backend"`
+	Duration time.Duration `json:"duration"`
+}
+
+// slowLog keeps the most recent slow operations in a ring buffer.
+type slowLog struct {
+	threshold atomic.Int64
+	mu        sync.Mutex
+	entries   []SlowLogEntry
+	next      int
+	full      bool
+}
+
+func newSlowLog(size int, threshold time.Duration) *slowLog {
+	if size <= 0 {
+		size = DefaultSlowLogSize
+	}
+	l := &slowLog{entries: make([]SlowLogEntry, size)}
+	l.threshold.Store(int64(threshold))
+	return l
+}
+
+func (l *slowLog) enabled() bool {
+	return l.threshold.Load() > 0
+}
+
+// observe records the operation if it took longer than the threshold.
+func (l *slowLog) observe(op, key, backend string, elapsed time.Duration) {
+	threshold := time.Duration(l.threshold.Load())
+	if threshold <= 0 || elapsed < threshold {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries[l.next] = SlowLogEntry{Time: time.Now(), Op: op, Key: key, Backend: backend, Duration: elapsed}
+	l.next = (l.next + 1) % len(l.entries)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+func (l *slowLog) list() []SlowLogEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.full {
+		return append([]SlowLogEntry(nil), l.entries[:l.next]...)
+	}
+	out := make([]SlowLogEntry, 0, len(l.entries))
+	out = append(out, l.entries[l.next:]...)
+	return append(out, l.entries[:l.next]...)
+}
+
+func (l *slowLog) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = make([]SlowLogEntry, len(l.entries))
+	l.next = 0
+	l.full = false
+}
+
+// SetSlowLogThreshold records operations taking at least threshold in the
+// slow log. Zero disables it.
+func (m *Manager) SetSlowLogThreshold(threshold time.Duration) {
+	m.slow.threshold.Store(int64(threshold))
+}
+
+// SlowLog returns recent slow operations, oldest first.
+func (m *Manager) SlowLog() []SlowLogEntry {
+	return m.slow.list()
+}
+
+// ResetSlowLog clears the slow log.
+func (m *Manager) ResetSlowLog() {
+	m.slow.reset()
+}
@@ -0,0 +1,55 @@
+package eitcache
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+)
+
+// Sort orders accepted in PaginationParams.SortOrder.
+const (
+	SortAsc  = "asc"
+	SortDesc = "desc"
+)
+
+// ErrInvalidSort is returned by QueryWithPagination for a SortBy that is
+// not allowed for the resource or an unknown SortOrder.
+var ErrInvalidSort = errors.New("invalid sort")
+
+// SetSortFields sets the fields each resource may be sorted by, e.g.
+// "articles" to created_at and title. Requests sorting by any other field
+// are rejected, so SortBy can be passed to the database as is.
+func (m *Manager) SetSortFields(fields map[string][]string) {
+	fields = maps.Clone(fields)
+	m.update(func(s *managerSettings) { s.sortFields = fields })
+}
+
+// validateSort checks normalized params against the allow-list of
+// resource.
+func (m *Manager) validateSort(resource string, params *PaginationParams) error {
+	if params.SortBy == "" {
+		return nil
+	}
+	if params.SortOrder != SortAsc && params.SortOrder != SortDesc {
+		return fmt.Errorf("%w: order %q", ErrInvalidSort, params.SortOrder)
+	}
+	for _, field := range m.current().sortFields[resource] {
+		if field == params.SortBy {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s cannot be sorted by %q", ErrInvalidSort, resource, params.SortBy)
+}
+
+// OrderBy returns the ORDER BY clause for SortBy and SortOrder, e.g.
+// "created_at DESC", or an empty string when SortBy is unset. Validate
+// SortBy first; QueryWithPagination does so before calling its loader.
+func (p *PaginationParams) OrderBy() string {
+	if p.SortBy == "" {
+		return ""
+	}
+	if p.SortOrder == SortDesc {
+		return p.SortBy + " DESC"
+	}
+	return p.SortBy + " ASC"
+}
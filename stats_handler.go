@@ -0,0 +1,43 @@
+package eitcache
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// StatsHandler returns an http.Handler that reports adapter stats,
+// monitor metrics, a configuration summary and uptime as JSON, for
+// mounting at an internal path such as /internal/cache. Adapter errors
+// are reported in the "adapter_error" field with status 503.
+func (m *Manager) StatsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := http.StatusOK
+		body := map[string]interface{}{
+			"config": map[string]interface{}{
+				"backend":       backendName(m.adapter),
+				"namespace":     m.namespace,
+				"default_ttl":   m.defaultTTL.String(),
+				"read_only":     m.ReadOnly(),
+				"breaker_state": m.BreakerState().String(),
+				"node_id":       m.NodeID(),
+			},
+			"started_at":     m.started,
+			"uptime_seconds": time.Since(m.started).Seconds(),
+		}
+		if m.monitor != nil {
+			body["metrics"] = m.monitor.GetMetrics()
+			body["hit_ratio"] = m.monitor.HitRatio()
+		}
+		if stats, err := m.Stats(r.Context()); err != nil {
+			status = http.StatusServiceUnavailable
+			body["adapter_error"] = err.Error()
+		} else {
+			body["adapter"] = stats
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(body)
+	})
+}
@@ -2,7 +2,7 @@ package eitcache
 
 import (
 	"context"
-	"log"
+	"errors"
 	"sync"
 	"time"
 )
@@ -12,6 +12,7 @@ type SmartCacheStrategy struct {
 	ttl            time.Duration
 	updateInterval time.Duration
 	lastUpdate     time.Time
+	clock          Clock
 	mu             sync.RWMutex
 }
 
@@ -25,21 +26,30 @@ func NewSmartCacheStrategy(ttl time.Duration) *SmartCacheStrategy {
 		ttl:            ttl,
 		updateInterval: interval,
 		lastUpdate:     time.Now(),
+		clock:          SystemClock,
 	}
 }
 
+// SetClock replaces the time source and restarts the refresh interval.
+func (s *SmartCacheStrategy) SetClock(clock Clock) {
+	s.mu.Lock()
+	s.clock = clockOrSystem(clock)
+	s.lastUpdate = s.clock.Now()
+	s.mu.Unlock()
+}
+
 // ShouldRefresh returns true if cache should refresh.
 func (s *SmartCacheStrategy) ShouldRefresh() bool {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	return time.Since(s.lastUpdate) > s.updateInterval
+	return s.clock.Now().Sub(s.lastUpdate) > s.updateInterval
 }
 
 // MarkUpdated marks cache as refreshed.
 func (s *SmartCacheStrategy) MarkUpdated() {
 	s.mu.Lock()
-	s.lastUpdate = time.Now()
+	s.lastUpdate = s.clock.Now()
 	s.mu.Unlock()
 }
 
@@ -98,20 +108,42 @@ func Prefetch[T any](
 			Total:    int64(len(items)),
 			DataHash: GenerateDataHash(pageData),
 		}, ttl); err != nil {
-			log.Printf("[CACHE] prefetch failed (%s): %v", cacheKey, err)
+			manager.log().Warn("cache prefetch failed", "key", cacheKey, "error", err)
 		}
 	}
 
 	return nil
 }
 
-// CacheWarmer periodically refreshes cached data.
+// ErrWarmJobLoader is returned when a CacheWarmer job has no Loader.
+var ErrWarmJobLoader = errors.New("warm job has no loader")
+
+// WarmJobConfig configures a CacheWarmer job. Interval defaults to the
+// warmer's interval and a zero TTL uses the manager's default TTL.
+type WarmJobConfig struct {
+	Interval time.Duration
+	TTL      time.Duration
+	Loader   func(context.Context) (interface{}, error)
+}
+
+type warmJob struct {
+	config WarmJobConfig
+	cancel context.CancelFunc
+}
+
+// CacheWarmer periodically refreshes cached data. Each job runs on its
+// own schedule, so a slow job does not delay the others.
 type CacheWarmer struct {
-	manager *Manager
-	jobs    map[string]func(context.Context) (interface{}, error)
+	manager  *Manager
+	jobs     map[string]*warmJob
 	interval time.Duration
-	stopChan chan struct{}
+	clock    Clock
+	logger   Logger
 	mu       sync.RWMutex
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopped  bool
+	wg       sync.WaitGroup
 }
 
 // NewCacheWarmer creates a cache warmer.
@@ -121,73 +153,146 @@ func NewCacheWarmer(manager *Manager, interval time.Duration) *CacheWarmer {
 	}
 	return &CacheWarmer{
 		manager:  manager,
-		jobs:     make(map[string]func(context.Context) (interface{}, error)),
+		jobs:     make(map[string]*warmJob),
 		interval: interval,
-		stopChan: make(chan struct{}),
+		clock:    SystemClock,
 	}
 }
 
-// AddJob registers a warmup job.
-func (w *CacheWarmer) AddJob(key string, job func(context.Context) (interface{}, error)) {
+// SetClock replaces the time source; call it before Start.
+func (w *CacheWarmer) SetClock(clock Clock) {
+	w.clock = clockOrSystem(clock)
+}
+
+// SetLogger sets the logger for failed jobs; call it before Start. Nil
+// uses the manager's logger.
+func (w *CacheWarmer) SetLogger(logger Logger) {
+	w.logger = logger
+}
+
+func (w *CacheWarmer) log() Logger {
+	if w.logger != nil {
+		return w.logger
+	}
+	return w.manager.log()
+}
+
+// AddJob registers a warmup job run at the warmer's interval.
+func (w *CacheWarmer) AddJob(key string, job func(context.Context) (interface{}, error)) error {
+	return w.AddJobWithConfig(key, WarmJobConfig{Loader: job})
+}
+
+// AddJobWithConfig registers a warmup job with its own interval and TTL,
+// replacing any job for key. Jobs added to a running warmer start at once.
+// It returns ErrWarmJobLoader when config.Loader is nil.
+func (w *CacheWarmer) AddJobWithConfig(key string, config WarmJobConfig) error {
+	if config.Loader == nil {
+		return ErrWarmJobLoader
+	}
+	if config.Interval <= 0 {
+		config.Interval = w.interval
+	}
 	w.mu.Lock()
+	defer w.mu.Unlock()
+	if old, ok := w.jobs[key]; ok && old.cancel != nil {
+		old.cancel()
+	}
+	job := &warmJob{config: config}
 	w.jobs[key] = job
-	w.mu.Unlock()
+	if w.ctx != nil {
+		w.schedule(key, job)
+	}
+	return nil
 }
 
-// RemoveJob removes a warmup job.
+// RemoveJob removes a warmup job, canceling it if it is running.
 func (w *CacheWarmer) RemoveJob(key string) {
 	w.mu.Lock()
+	if job, ok := w.jobs[key]; ok && job.cancel != nil {
+		job.cancel()
+	}
 	delete(w.jobs, key)
 	w.mu.Unlock()
 }
 
-// Start begins warming.
-func (w *CacheWarmer) Start() {
+// Start begins warming until ctx is done or Stop is called, either of
+// which cancels the contexts of running jobs. Starting a running or
+// stopped warmer does nothing.
+func (w *CacheWarmer) Start(ctx context.Context) {
 	if w == nil {
 		return
 	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.cancel != nil || w.stopped {
+		return
+	}
+	w.ctx, w.cancel = context.WithCancel(ctx)
+	for key, job := range w.jobs {
+		w.schedule(key, job)
+	}
+}
+
+// schedule runs job every interval until the warmer stops or the job is
+// replaced or removed. w.mu must be held.
+func (w *CacheWarmer) schedule(key string, job *warmJob) {
+	var ctx context.Context
+	ctx, job.cancel = context.WithCancel(w.ctx)
+	w.wg.Add(1)
 	go func() {
-		ticker := time.NewTicker(w.interval)
-		defer ticker.Stop()
+		defer w.wg.Done()
 		for {
 			select {
-			case <-ticker.C:
-				w.warmup()
-			case <-w.stopChan:
+			case <-w.clock.After(job.config.Interval):
+				w.run(ctx, key, job.config)
+			case <-ctx.Done():
 				return
 			}
 		}
 	}()
 }
 
-func (w *CacheWarmer) warmup() {
+// run warms key with the job's loader under its own context.
+func (w *CacheWarmer) run(ctx context.Context, key string, config WarmJobConfig) {
 	if w.manager == nil {
 		return
 	}
-	w.mu.RLock()
-	jobs := make(map[string]func(context.Context) (interface{}, error), len(w.jobs))
-	for k, v := range w.jobs {
-		jobs[k] = v
-	}
-	w.mu.RUnlock()
-
-	ctx := context.Background()
-	for key, job := range jobs {
-		data, err := job(ctx)
-		if err != nil {
-			log.Printf("[CACHE] warmup job failed (%s): %v", key, err)
-			continue
-		}
-		if err := w.manager.Set(ctx, key, data, 0); err != nil {
-			log.Printf("[CACHE] warmup set failed (%s): %v", key, err)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	data, err := config.Loader(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			w.log().Warn("cache warmup job failed", "key", key, "error", err)
 		}
+		return
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	if err := w.manager.Set(ctx, key, data, config.TTL); err != nil {
+		w.log().Warn("cache warmup set failed", "key", key, "error", err)
 	}
 }
 
-// Stop stops warming.
+// Stop stops warming and cancels running jobs. It may be called more
+// than once; use Wait to block until the jobs have returned.
 func (w *CacheWarmer) Stop() {
 	if w == nil {
 		return
 	}
-	close(w.stopChan)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stopped = true
+	if w.cancel != nil {
+		w.cancel()
+	}
+}
+
+// Wait blocks until a stopped warmer, or one whose Start context is done,
+// has finished its running jobs.
+func (w *CacheWarmer) Wait() {
+	if w == nil {
+		return
+	}
+	w.wg.Wait()
 }
@@ -0,0 +1,155 @@
+package eitcache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// streamedPage is a cached page with its rows left encoded. Its JSON
+// matches paginationCacheItem, so QueryWithPagination can read pages
+// written by StreamPage and the other way round.
+type streamedPage struct {
+	Data        json.RawMessage `json:"data"`
+	Total       int64           `json:"total"`
+	DataHash    string          `json:"data_hash"`
+	OriginalKey string          `json:"original_key,omitempty"`
+}
+
+// StreamPage writes a page of resource to w as a PaginationResponse JSON
+// document without holding its rows as []T. On a hit the cached rows are
+// copied to w as stored; on a miss queryFunc passes each row to emit,
+// which encodes it to w and, when caching, to the stored payload. Pages
+// are always cached as JSON and share keys with QueryWithPagination.
+//
+// Unlike QueryWithPagination, concurrent misses are not coalesced and a
+// failing queryFunc leaves w partially written. It accepts the same
+// options as QueryWithPagination except WithCodec and WithPrefetchNext.
+func StreamPage[T any](
+	ctx context.Context,
+	manager *Manager,
+	resource string,
+	filters map[string]interface{},
+	params *PaginationParams,
+	w io.Writer,
+	queryFunc func(emit func(row T) error) (total int64, err error),
+	opts ...QueryOption,
+) error {
+	if manager == nil {
+		return ErrManagerNil
+	}
+	params = manager.NormalizePaginationParams(resource, params)
+	if err := manager.validateSort(resource, params); err != nil {
+		return err
+	}
+	options := newQueryOptions(manager, opts)
+	if options.Ticket != nil {
+		if err := options.Ticket.ValidateWithClock(manager.clock); err != nil {
+			return err
+		}
+	}
+	options.Codec = JSONCodec{}
+	useCache := params.UseCache && options.UseCache
+	key, original, err := manager.resourceKey(ctx, resource, filters, params)
+	if err != nil {
+		// Without the epoch a cached page may predate the last bump.
+		manager.reportError(ctx, OpGet, epochKey(resource), err)
+		useCache = false
+		key, original = manager.filterKey(filters, func(parts []string) string {
+			return pageKey(resource, parts, params)
+		})
+	}
+
+	if useCache {
+		start := time.Now()
+		data, err := manager.load(ctx, key)
+		elapsed := time.Since(start)
+		if err == nil && data != nil {
+			var cached streamedPage
+			if _, err := manager.decodeEntryWith(data, &cached, options.Codec); err == nil && cached.Data != nil {
+				manager.recordHit(ctx, key, elapsed, len(data))
+				if _, err := io.WriteString(w, `{"data":`); err != nil {
+					return err
+				}
+				if _, err := w.Write(cached.Data); err != nil {
+					return err
+				}
+				return writePageMeta(w, params, cached, key, true)
+			}
+		}
+		manager.recordMiss(ctx, key, elapsed)
+	}
+
+	if _, err := io.WriteString(w, `{"data":`); err != nil {
+		return err
+	}
+	var stored bytes.Buffer
+	hash := sha256.New()
+	rows := io.MultiWriter(w, hash)
+	if useCache {
+		rows = io.MultiWriter(w, hash, &stored)
+	}
+	total, err := streamRows(rows, queryFunc)
+	if err != nil {
+		return err
+	}
+	page := streamedPage{
+		Data:        stored.Bytes(),
+		Total:       total,
+		DataHash:    hex.EncodeToString(hash.Sum(nil)),
+		OriginalKey: original,
+	}
+	if useCache {
+		if err := storePaginationItem(ctx, manager, resource, key, filters, page, options); err != nil && options.StrictCache {
+			return err
+		}
+	}
+	return writePageMeta(w, params, page, key, false)
+}
+
+// streamRows writes the rows emitted by queryFunc to w as a JSON array,
+// encoding each row like json.Marshal of the whole slice would.
+func streamRows[T any](w io.Writer, queryFunc func(emit func(row T) error) (int64, error)) (int64, error) {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return 0, err
+	}
+	first := true
+	total, err := queryFunc(func(row T) error {
+		payload, err := json.Marshal(row)
+		if err != nil {
+			return err
+		}
+		if !first {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		first = false
+		_, err = w.Write(payload)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	_, err = io.WriteString(w, "]")
+	return total, err
+}
+
+// writePageMeta completes a document whose data field has been written
+// with the remaining PaginationResponse fields.
+func writePageMeta(w io.Writer, params *PaginationParams, page streamedPage, key string, fromCache bool) error {
+	resp := buildPaginationResponse[struct{}](nil, page.Total, params, key, fromCache)
+	resp.DataHash = page.DataHash
+	resp.OriginalKey = page.OriginalKey
+	meta, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	// Drop the leading {"data":null, keeping the comma.
+	_, err = w.Write(meta[len(`{"data":null`):])
+	return err
+}
@@ -23,7 +23,12 @@ type CacheTicket struct {
 
 // GenerateTicket creates a cache ticket.
 func GenerateTicket(userID string, ttl time.Duration) *CacheTicket {
-	now := time.Now()
+	return GenerateTicketWithClock(SystemClock, userID, ttl)
+}
+
+// GenerateTicketWithClock creates a cache ticket issued at clock.Now().
+func GenerateTicketWithClock(clock Clock, userID string, ttl time.Duration) *CacheTicket {
+	now := clockOrSystem(clock).Now()
 	src := fmt.Sprintf("%s:%d:%d", userID, now.Unix(), now.Nanosecond())
 	hash := sha256.Sum256([]byte(src))
 	return &CacheTicket{
@@ -36,10 +41,15 @@ func GenerateTicket(userID string, ttl time.Duration) *CacheTicket {
 
 // Validate checks ticket validity.
 func (t *CacheTicket) Validate() error {
+	return t.ValidateWithClock(SystemClock)
+}
+
+// ValidateWithClock checks ticket validity against clock.Now().
+func (t *CacheTicket) ValidateWithClock(clock Clock) error {
 	if t == nil || t.Token == "" {
 		return ErrInvalidTicket
 	}
-	if time.Now().After(t.ExpiresAt) {
+	if clockOrSystem(clock).Now().After(t.ExpiresAt) {
 		return ErrTicketExpired
 	}
 	return nil
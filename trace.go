@@ -0,0 +1,127 @@
+package eitcache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SpanInfo describes a finished cache operation. Hit is only meaningful
+// for get and query spans; Count is the number of keys a delete_pattern
+// removed.
+type SpanInfo struct {
+	Backend string
+	Hit     bool
+	Size    int
+	Count   int64
+	Err     error
+}
+
+// Tracer starts spans around cache operations: query, get, set and
+// delete_pattern. Start returns the context for the operation and a
+// function the manager calls once when it finishes. The otel sub-package
+// provides an OpenTelemetry implementation.
+type Tracer interface {
+	Start(ctx context.Context, op, key string) (context.Context, func(SpanInfo))
+}
+
+// SetTracer enables tracing of cache operations. Nil disables it.
+func (m *Manager) SetTracer(tracer Tracer) {
+	m.update(func(s *managerSettings) { s.tracer = tracer })
+}
+
+type spanKey struct{}
+
+// span collects SpanInfo while an operation runs. Background work
+// started by the operation may still carry its context, so observations
+// are locked and ignored once the span ends. Spans are also started
+// without a tracer while the slow log is enabled.
+type span struct {
+	mu    sync.Mutex
+	info  SpanInfo
+	end   func(SpanInfo)
+	done  bool
+	op    string
+	key   string
+	start time.Time
+	slow  *slowLog
+}
+
+func (m *Manager) startSpan(ctx context.Context, op, key string) (context.Context, *span) {
+	tracer := m.current().tracer
+	if tracer == nil && !m.slow.enabled() {
+		return ctx, nil
+	}
+	var end func(SpanInfo)
+	if tracer != nil {
+		ctx, end = tracer.Start(ctx, op, key)
+	}
+	s := &span{
+		info:  SpanInfo{Backend: backendName(m.adapter)},
+		end:   end,
+		op:    op,
+		key:   key,
+		start: time.Now(),
+		slow:  m.slow,
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+func (s *span) finish(err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.done = true
+	s.info.Err = err
+	info := s.info
+	s.mu.Unlock()
+	s.slow.observe(s.op, s.key, info.Backend, time.Since(s.start))
+	if s.end != nil {
+		s.end(info)
+	}
+}
+
+// observe records hit and size details from lifecycle events.
+func (s *span) observe(kind hookKind, event HookEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done {
+		return
+	}
+	switch kind {
+	case hookHit:
+		s.info.Hit = true
+		s.info.Size = event.Size
+	case hookSet:
+		s.info.Size = event.Size
+	}
+}
+
+func (s *span) count(n int64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.info.Count = n
+	s.mu.Unlock()
+}
+
+func spanFrom(ctx context.Context) *span {
+	s, _ := ctx.Value(spanKey{}).(*span)
+	return s
+}
+
+func backendName(adapter Adapter) string {
+	switch adapter.(type) {
+	case *MemoryCacheAdapter:
+		return CacheTypeMemory
+	case *RedisCacheAdapter:
+		return CacheTypeRedis
+	case *ArenaCacheAdapter:
+		return CacheTypeArena
+	default:
+		return fmt.Sprintf("%T", adapter)
+	}
+}
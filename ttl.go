@@ -0,0 +1,53 @@
+package eitcache
+
+import (
+	"maps"
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
+// jitterTTL randomizes ttl by ±fraction so entries written together
+// do not expire together. Non-positive TTLs are returned unchanged.
+func jitterTTL(ttl time.Duration, fraction float64) time.Duration {
+	if ttl <= 0 || fraction <= 0 {
+		return ttl
+	}
+	if fraction > 1 {
+		fraction = 1
+	}
+	delta := (rand.Float64()*2 - 1) * fraction * float64(ttl)
+	jittered := ttl + time.Duration(delta)
+	if jittered <= 0 {
+		return time.Millisecond
+	}
+	return jittered
+}
+
+// SetTTLJitter sets the default ±fraction applied to every TTL written
+// through the manager. Zero disables jitter.
+func (m *Manager) SetTTLJitter(fraction float64) {
+	if fraction < 0 {
+		fraction = 0
+	}
+	m.update(func(s *managerSettings) { s.ttlJitter = fraction })
+}
+
+// SetResourceTTLs sets default TTLs by key prefix, used by Query and
+// QueryWithPagination when no explicit TTL is given. The longest
+// matching prefix wins; unmatched keys use the manager default TTL.
+func (m *Manager) SetResourceTTLs(ttls map[string]time.Duration) {
+	ttls = maps.Clone(ttls)
+	m.update(func(s *managerSettings) { s.resourceTTLs = ttls })
+}
+
+// ttlFor returns the default TTL for key.
+func (m *Manager) ttlFor(key string) time.Duration {
+	ttl, longest := m.defaultTTL, -1
+	for prefix, d := range m.current().resourceTTLs {
+		if len(prefix) > longest && strings.HasPrefix(key, prefix) {
+			ttl, longest = d, len(prefix)
+		}
+	}
+	return ttl
+}
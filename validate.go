@@ -0,0 +1,195 @@
+package eitcache
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConfigError lists every problem found by CacheConfig.Validate.
+type ConfigError struct {
+	Problems []error
+}
+
+func (e *ConfigError) Error() string {
+	msgs := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		msgs[i] = p.Error()
+	}
+	return "invalid cache config: " + strings.Join(msgs, "; ")
+}
+
+// Unwrap allows errors.Is against the individual problems.
+func (e *ConfigError) Unwrap() []error {
+	return e.Problems
+}
+
+// Validate checks c for malformed or conflicting settings and reports
+// all of them at once as a *ConfigError.
+func (c *CacheConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	var problems []error
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+
+	switch c.Type {
+	case "", CacheTypeMemory, CacheTypeArena:
+	case CacheTypeRedis:
+		if c.Addr != "" {
+			if err := validateAddr(c.Addr); err != nil {
+				add("addr %q: %v", c.Addr, err)
+			}
+		}
+		if c.DB < 0 {
+			add("db must not be negative, got %d", c.DB)
+		}
+	default:
+		problems = append(problems, fmt.Errorf("%w %q", ErrInvalidType, c.Type))
+	}
+
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"default ttl", c.DefaultTTL},
+		{"lock ttl", c.LockTTL},
+		{"lock wait", c.LockWait},
+		{"stale grace", c.StaleGrace},
+		{"breaker cooldown", c.BreakerCooldown},
+		{"read timeout", c.ReadTimeout},
+		{"write timeout", c.WriteTimeout},
+		{"op timeout", c.OpTimeout},
+		{"cleanup interval", c.CleanupInterval},
+		{"snapshot interval", c.SnapshotInterval},
+		{"double delete", c.DoubleDelete},
+		{"audit persist ttl", c.AuditPersistTTL},
+		{"slow log threshold", c.SlowLogThreshold},
+		{"count ttl", c.CountTTL},
+	} {
+		if d.value < 0 {
+			add("%s must not be negative, got %s", d.name, d.value)
+		}
+	}
+	for _, n := range []struct {
+		name  string
+		value int
+	}{
+		{"pool size", c.PoolSize},
+		{"chunk size", c.ChunkSize},
+		{"refresh workers", c.RefreshWorkers},
+		{"breaker threshold", c.BreakerThreshold},
+		{"max entries", c.MaxEntries},
+		{"arena shards", c.ArenaShards},
+		{"audit log size", c.AuditLogSize},
+		{"hot keys", c.HotKeys},
+		{"big key threshold", c.BigKeyThreshold},
+		{"slow log size", c.SlowLogSize},
+		{"event buffer", c.EventBuffer},
+		{"key hash threshold", c.KeyHashThreshold},
+	} {
+		if n.value < 0 {
+			add("%s must not be negative, got %d", n.name, n.value)
+		}
+	}
+	if c.RestoreOnStart && c.SnapshotPath == "" {
+		add("restore on start requires a snapshot path")
+	}
+	if c.Type == CacheTypeRedis {
+		if c.SnapshotPath != "" {
+			add("snapshot path is only supported by the memory adapter")
+		}
+		if c.StoreValues {
+			add("store values is only supported by the memory adapter")
+		}
+	}
+	if inv := c.Invalidation; inv != nil && (inv.Transport == nil || inv.KeyspaceEvents) {
+		if err := validateAddr(inv.Addr); err != nil {
+			add("invalidation addr %q: %v", inv.Addr, err)
+		}
+		if inv.DB < 0 {
+			add("invalidation db must not be negative, got %d", inv.DB)
+		}
+	}
+	for prefix, ttl := range c.ResourceTTLs {
+		if ttl < 0 {
+			add("resource ttl %q must not be negative, got %s", prefix, ttl)
+		}
+	}
+	for resource, d := range c.PageDefaults {
+		if d.PageSize < 0 || d.MaxPageSize < 0 || d.TTL < 0 {
+			add("pagination defaults for %q must not be negative", resource)
+		}
+	}
+	if c.CopyOnRead && !c.StoreValues {
+		add("copy on read requires store values")
+	}
+	if c.ArenaSize < 0 {
+		add("arena size must not be negative, got %d", c.ArenaSize)
+	}
+	if c.MaxMemoryBytes < 0 {
+		add("max memory bytes must not be negative, got %d", c.MaxMemoryBytes)
+	}
+	if c.MaxRetries < -1 {
+		add("max retries must be -1 (disabled) or more, got %d", c.MaxRetries)
+	}
+	if c.TTLJitter < 0 || c.TTLJitter > 1 {
+		add("ttl jitter must be between 0 and 1, got %g", c.TTLJitter)
+	}
+	if c.HotKeySampleRate < 0 || c.HotKeySampleRate > 1 {
+		add("hot key sample rate must be between 0 and 1, got %g", c.HotKeySampleRate)
+	}
+	if c.MetricSampleRate < 0 || c.MetricSampleRate > 1 {
+		add("metric sample rate must be between 0 and 1, got %g", c.MetricSampleRate)
+	}
+
+	if _, err := newEvictionPolicy(c.EvictionPolicy); err != nil {
+		problems = append(problems, err)
+	}
+
+	if comp := c.Compression; comp != nil {
+		if comp.Threshold < 0 {
+			add("compression threshold must not be negative, got %d", comp.Threshold)
+		}
+		switch comp.Algorithm {
+		case 0, Gzip, Zstd, Snappy:
+		default:
+			add("unknown compression algorithm %s", comp.Algorithm)
+		}
+		if len(comp.Dictionary) > 0 && comp.algorithm() != Zstd {
+			add("compression dictionary requires zstd, got %s", comp.algorithm())
+		}
+	}
+	if r := c.Retry; r != nil {
+		if r.MaxAttempts < 0 {
+			add("retry attempts must not be negative, got %d", r.MaxAttempts)
+		}
+		if r.Backoff < 0 || r.MaxBackoff < 0 {
+			add("retry backoff must not be negative")
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return &ConfigError{Problems: problems}
+}
+
+func validateAddr(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if host == "" {
+		return fmt.Errorf("missing host")
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("invalid port %q", port)
+	}
+	return nil
+}
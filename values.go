@@ -0,0 +1,166 @@
+package eitcache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// storedValue wraps values kept by a ValueAdapter together with the
+// freshness deadline the envelope would otherwise carry.
+type storedValue struct {
+	Value      interface{}
+	FreshUntil time.Time
+}
+
+// MarshalJSON encodes only the wrapped value, so byte readers of a value
+// entry see the same JSON a JSON-codec Set would have written.
+func (v storedValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.Value)
+}
+
+// storeValue writes value through the ValueAdapter, skipping encoding.
+// It does nothing while the manager is read-only.
+func (m *Manager) storeValue(ctx context.Context, key string, value interface{}, freshUntil time.Time, ttl time.Duration) error {
+	if m.ReadOnly() {
+		return nil
+	}
+	ttl, ok := m.clampTTL(key, ttl)
+	if !ok {
+		return nil
+	}
+	start := time.Now()
+	err := m.guard(ctx, m.writeTimeout, func(ctx context.Context) error {
+		return m.values.SetValue(ctx, m.key(key), storedValue{Value: value, FreshUntil: freshUntil}, ttl)
+	})
+	if err == nil {
+		m.recordSet(ctx, key, time.Since(start), 0)
+	}
+	return err
+}
+
+// loadValue reads key through the ValueAdapter. Entries written as bytes,
+// such as negative-cache markers, are returned as data instead.
+func (m *Manager) loadValue(ctx context.Context, key string) (*storedValue, []byte, error) {
+	var (
+		stored *storedValue
+		data   []byte
+	)
+	err := m.guard(ctx, m.readTimeout, func(ctx context.Context) error {
+		v, err := m.values.GetValue(ctx, m.key(key))
+		if err != nil {
+			if errors.Is(err, ErrCacheMiss) {
+				return nil
+			}
+			return err
+		}
+		switch v := v.(type) {
+		case storedValue:
+			stored = &v
+		case RawValue:
+			data = v
+			if manifest, ok := parseChunkManifest(data); ok {
+				data, err = m.loadChunked(ctx, key, manifest)
+			}
+		default:
+			stored = &storedValue{Value: v}
+		}
+		return err
+	})
+	return stored, data, err
+}
+
+// decodeValue assigns a stored value to dest without decoding.
+func decodeValue[T any](stored *storedValue, dest *T) (entryMeta, error) {
+	meta := entryMeta{freshUntil: stored.FreshUntil}
+	value, ok := stored.Value.(T)
+	if !ok {
+		return meta, &PayloadFormatError{Reason: fmt.Sprintf("cached value is %T, want %T", stored.Value, *dest)}
+	}
+	*dest = value
+	return meta, nil
+}
+
+// assignValue stores value into the pointer dest, falling back to a
+// codec round trip when the types are not directly assignable.
+func (m *Manager) assignValue(value interface{}, dest interface{}) error {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() == reflect.Pointer && !rv.IsNil() && value != nil {
+		if vv := reflect.ValueOf(value); vv.Type().AssignableTo(rv.Elem().Type()) {
+			rv.Elem().Set(vv)
+			return nil
+		}
+	}
+	data, err := m.current().codec.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("marshal value failed: %w", err)
+	}
+	return m.current().codec.Unmarshal(data, dest)
+}
+
+// deepCopy returns a copy of v that shares no maps, slices or pointers
+// with it. Unexported struct fields are copied shallowly.
+func deepCopy(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	return copyValue(reflect.ValueOf(v)).Interface()
+}
+
+func copyValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(copyValue(v.Elem()))
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(copyValue(v.Elem()))
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(copyValue(v.Index(i)))
+		}
+		return c
+	case reflect.Array:
+		c := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(copyValue(v.Index(i)))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(copyValue(iter.Key()), copyValue(iter.Value()))
+		}
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if field := c.Field(i); field.CanSet() {
+				field.Set(copyValue(v.Field(i)))
+			}
+		}
+		return c
+	default:
+		return v
+	}
+}
@@ -0,0 +1,124 @@
+package eitcache
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// SignatureHeader carries "sha256=" followed by the hex HMAC-SHA256
+	// of the timestamp, a dot and the request body.
+	SignatureHeader = "X-Eitcache-Signature"
+	// TimestampHeader carries the Unix time the request was signed.
+	TimestampHeader = "X-Eitcache-Timestamp"
+
+	// webhookMaxSkew bounds how old a signed request may be, limiting replay.
+	webhookMaxSkew = 5 * time.Minute
+	// webhookMaxBody bounds the request body size.
+	webhookMaxBody = 1 << 20
+)
+
+// InvalidationRequest is the body accepted by InvalidationHandler. Tags
+// are resource names purged with InvalidateCacheOnUpdate.
+type InvalidationRequest struct {
+	Keys     []string `json:"keys,omitempty"`
+	Patterns []string `json:"patterns,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+}
+
+// SignInvalidation returns the SignatureHeader value for body signed at
+// timestamp with secret.
+func SignInvalidation(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// InvalidationHandler returns an http.Handler that purges the keys,
+// patterns and tags of signed POST requests, for admin panels and
+// publishing pipelines. Requests must carry TimestampHeader and a
+// matching SignatureHeader; see SignInvalidation. Timestamps are checked
+// against the manager's clock. An empty secret would let anyone sign
+// requests, so the handler then rejects every request.
+func InvalidationHandler(manager *Manager, secret string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if secret == "" {
+			http.Error(w, "invalidation secret is not configured", http.StatusUnauthorized)
+			return
+		}
+		if manager == nil {
+			http.Error(w, ErrManagerNil.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, webhookMaxBody+1))
+		if err != nil {
+			http.Error(w, "read body failed", http.StatusBadRequest)
+			return
+		}
+		if len(body) > webhookMaxBody {
+			http.Error(w, "body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err := verifyInvalidation(secret, r.Header, body, manager.clock.Now()); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		var req InvalidationRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, "invalid body", http.StatusBadRequest)
+			return
+		}
+
+		ctx := r.Context()
+		var errs []error
+		if len(req.Keys) > 0 {
+			errs = append(errs, manager.Delete(ctx, req.Keys...))
+		}
+		for _, pattern := range req.Patterns {
+			_, err := manager.DeletePattern(ctx, pattern)
+			errs = append(errs, err)
+		}
+		for _, tag := range req.Tags {
+			_, err := InvalidateCacheOnUpdate(ctx, manager, tag)
+			errs = append(errs, err)
+		}
+		if err := errors.Join(errs...); err != nil {
+			manager.reportError(ctx, OpInvalidate, "", err)
+			http.Error(w, "invalidation failed", http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func verifyInvalidation(secret string, header http.Header, body []byte, now time.Time) error {
+	unix, err := strconv.ParseInt(header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		return errors.New("missing or invalid timestamp")
+	}
+	signedAt := time.Unix(unix, 0)
+	if d := now.Sub(signedAt); d > webhookMaxSkew || d < -webhookMaxSkew {
+		return errors.New("timestamp outside allowed window")
+	}
+	signature := header.Get(SignatureHeader)
+	if !strings.HasPrefix(signature, "sha256=") ||
+		!hmac.Equal([]byte(signature), []byte(SignInvalidation(secret, signedAt, body))) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package eitcache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Rolling windows reported in CacheMetrics.Windows, keyed by name.
+var metricWindows = []struct {
+	name   string
+	window time.Duration
+}{
+	{"1m", time.Minute},
+	{"5m", 5 * time.Minute},
+	{"1h", time.Hour},
+}
+
+// windowSlots is the number of one-second slots kept, covering the
+// longest metric window.
+const windowSlots = 3600
+
+// WindowMetrics reports hits, misses and errors over a recent window.
+// Throughput is hits plus misses per second; ErrorRate is errors per hit
+// or miss.
+type WindowMetrics struct {
+	Window     time.Duration `json:"window"`
+	HitCount   int64         `json:"hit_count"`
+	MissCount  int64         `json:"miss_count"`
+	ErrorCount int64         `json:"error_count"`
+	HitRatio   float64       `json:"hit_ratio"`
+	ErrorRate  float64       `json:"error_rate"`
+	Throughput float64       `json:"throughput"`
+}
+
+type windowSlot struct {
+	sec    atomic.Int64
+	hits   atomic.Int64
+	misses atomic.Int64
+	errors atomic.Int64
+}
+
+// rollingWindow counts hits, misses and errors in per-second slots. The
+// counters are atomic, so recording into the current second takes no
+// lock; mu is only held to allocate the slots or start a new second in
+// one.
+type rollingWindow struct {
+	mu    sync.Mutex
+	slots atomic.Pointer[[windowSlots]windowSlot]
+	clock atomic.Pointer[Clock]
+}
+
+func (w *rollingWindow) record(hit bool) {
+	slot := w.slot(w.now())
+	if hit {
+		slot.hits.Add(1)
+	} else {
+		slot.misses.Add(1)
+	}
+}
+
+func (w *rollingWindow) recordError() {
+	w.slot(w.now()).errors.Add(1)
+}
+
+func (w *rollingWindow) setClock(clock Clock) {
+	w.clock.Store(&clock)
+}
+
+// clockOrSystem returns the window clock, or SystemClock when unset.
+func (w *rollingWindow) clockOrSystem() Clock {
+	if clock := w.clock.Load(); clock != nil {
+		return clockOrSystem(*clock)
+	}
+	return SystemClock
+}
+
+func (w *rollingWindow) reset() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.slots.Store(nil)
+}
+
+func (w *rollingWindow) now() time.Time {
+	return w.clockOrSystem().Now()
+}
+
+// slot returns the slot counting now's second. The slot is cleared under
+// mu before its second is published, so counts added after a caller sees
+// the current second are never lost to the reset.
+func (w *rollingWindow) slot(now time.Time) *windowSlot {
+	sec := now.Unix()
+	if slots := w.slots.Load(); slots != nil {
+		if slot := &slots[sec%windowSlots]; slot.sec.Load() == sec {
+			return slot
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	slots := w.slots.Load()
+	if slots == nil {
+		slots = new([windowSlots]windowSlot)
+		w.slots.Store(slots)
+	}
+	slot := &slots[sec%windowSlots]
+	if slot.sec.Load() != sec {
+		slot.hits.Store(0)
+		slot.misses.Store(0)
+		slot.errors.Store(0)
+		slot.sec.Store(sec)
+	}
+	return slot
+}
+
+// snapshot returns stats for every metric window, or nil if nothing has
+// been recorded.
+func (w *rollingWindow) snapshot() map[string]WindowMetrics {
+	if w.slots.Load() == nil {
+		return nil
+	}
+	now := w.now()
+	out := make(map[string]WindowMetrics, len(metricWindows))
+	for _, mw := range metricWindows {
+		out[mw.name] = w.stats(now, mw.window)
+	}
+	return out
+}
+
+func (w *rollingWindow) stats(now time.Time, window time.Duration) WindowMetrics {
+	out := WindowMetrics{Window: window}
+	secs := int64(window / time.Second)
+	if secs <= 0 {
+		return out
+	}
+	if secs > windowSlots {
+		secs = windowSlots
+	}
+	slots := w.slots.Load()
+	if slots == nil {
+		return out
+	}
+	newest := now.Unix()
+	for i := range slots {
+		slot := &slots[i]
+		if sec := slot.sec.Load(); sec > newest-secs && sec <= newest {
+			out.HitCount += slot.hits.Load()
+			out.MissCount += slot.misses.Load()
+			out.ErrorCount += slot.errors.Load()
+		}
+	}
+	if total := out.HitCount + out.MissCount; total > 0 {
+		out.HitRatio = float64(out.HitCount) / float64(total)
+		out.ErrorRate = float64(out.ErrorCount) / float64(total)
+		out.Throughput = float64(total) / float64(secs)
+	} else if out.ErrorCount > 0 {
+		out.ErrorRate = 1
+	}
+	return out
+}
+
+// SetClock sets the clock used for rolling window metrics. Nil selects
+// SystemClock.
+func (m *Monitor) SetClock(clock Clock) {
+	m.window.setClock(clockOrSystem(clock))
+}
+
+// WindowStats returns hits, misses, hit ratio and throughput over the last
+// window, at one-second resolution, up to one hour.
+func (m *Monitor) WindowStats(window time.Duration) WindowMetrics {
+	return m.window.stats(m.window.now(), window)
+}
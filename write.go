@@ -0,0 +1,55 @@
+package eitcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// WriteThroughOptions controls WriteThrough behavior.
+type WriteThroughOptions struct {
+	DeleteOnFailure bool
+}
+
+// WriteThroughOption mutates WriteThroughOptions.
+type WriteThroughOption func(*WriteThroughOptions)
+
+// WithDeleteOnFailure deletes the cached key when persist fails, so a
+// value that may be out of sync with the source of truth is not served.
+func WithDeleteOnFailure() WriteThroughOption {
+	return func(o *WriteThroughOptions) {
+		o.DeleteOnFailure = true
+	}
+}
+
+// WriteThrough persists value first and updates the cache only if that
+// succeeds. If the cache update fails the key is deleted so the previous
+// cached value is not served against the new source of truth.
+func WriteThrough[T any](ctx context.Context, m *Manager, key string, value T, ttl time.Duration, persist func(T) error, opts ...WriteThroughOption) error {
+	if m == nil {
+		return ErrManagerNil
+	}
+	if m.adapter == nil {
+		return errors.New("cache adapter is nil")
+	}
+	options := &WriteThroughOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	if err := persist(value); err != nil {
+		if options.DeleteOnFailure {
+			if delErr := m.Delete(ctx, key); delErr != nil {
+				return errors.Join(err, fmt.Errorf("delete cache after failed persist: %w", delErr))
+			}
+		}
+		return err
+	}
+
+	if err := m.Set(ctx, key, value, ttl); err != nil {
+		_ = m.Delete(ctx, key)
+		return fmt.Errorf("update cache after persist: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,362 @@
+package eitcache
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// OverflowPolicy decides what Set does when the write-behind queue is full.
+type OverflowPolicy int
+
+const (
+	// OverflowWriteThrough writes synchronously, as if write-behind were off.
+	OverflowWriteThrough OverflowPolicy = iota
+	// OverflowBlock waits for room in the queue or for ctx to end.
+	OverflowBlock
+	// OverflowDrop discards the write and counts it in the monitor.
+	OverflowDrop
+)
+
+// ErrWriteBehindClosed is returned by Set after the queue was drained on Close.
+var ErrWriteBehindClosed = errors.New("write-behind queue is closed")
+
+// WriteBehindConfig enables asynchronous Set. Writes are queued per key
+// shard, so writes to one key keep their order, and flushed in batches.
+// While a key has queued writes, synchronous writes to it go through the
+// queue too, and Delete, DeletePattern and invalidations wait for the
+// writes queued before them so a deleted value does not come back.
+type WriteBehindConfig struct {
+	QueueSize     int
+	Workers       int
+	BatchSize     int
+	FlushInterval time.Duration
+	Overflow      OverflowPolicy
+}
+
+type writeBehindEntry struct {
+	key     string
+	fullKey string
+	payload RawValue
+	ttl     time.Duration
+	// barrier, when set, marks a position in the queue rather than a
+	// write; the worker closes it once every earlier entry is stored.
+	barrier chan struct{}
+}
+
+// writeBehind batches Set calls in background workers.
+type writeBehind struct {
+	m       *Manager
+	config  WriteBehindConfig
+	shards  []chan writeBehindEntry
+	workers sync.WaitGroup
+
+	pendingMu sync.Mutex
+	pending   int
+	keys      map[string]int
+
+	mu       sync.RWMutex
+	closed   bool
+	shutdown chan struct{}
+}
+
+func newWriteBehind(m *Manager, config *WriteBehindConfig) *writeBehind {
+	if config == nil {
+		return nil
+	}
+	c := *config
+	if c.Workers <= 0 {
+		c.Workers = 4
+	}
+	if c.QueueSize <= 0 {
+		c.QueueSize = 1024
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 64
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = 5 * time.Millisecond
+	}
+	w := &writeBehind{
+		m:        m,
+		config:   c,
+		shards:   make([]chan writeBehindEntry, c.Workers),
+		keys:     make(map[string]int),
+		shutdown: make(chan struct{}),
+	}
+	perShard := (c.QueueSize + c.Workers - 1) / c.Workers
+	for i := range w.shards {
+		w.shards[i] = make(chan writeBehindEntry, perShard)
+		w.workers.Add(1)
+		go w.run(w.shards[i])
+	}
+	return w
+}
+
+// enqueue queues entry and reports whether it was accepted. A false
+// result with a nil error asks the caller to write synchronously.
+func (w *writeBehind) enqueue(ctx context.Context, entry writeBehindEntry) (bool, error) {
+	return w.push(ctx, entry, w.config.Overflow)
+}
+
+// enqueueOrdered queues entry behind the writes already pending for its
+// key, waiting for room whatever the overflow policy. ok is false when
+// the key has nothing pending, so writing synchronously keeps the order.
+func (w *writeBehind) enqueueOrdered(ctx context.Context, entry writeBehindEntry) (bool, error) {
+	if !w.has(entry.fullKey) {
+		return false, nil
+	}
+	queued, err := w.push(ctx, entry, OverflowBlock)
+	if errors.Is(err, ErrWriteBehindClosed) {
+		return false, nil
+	}
+	return queued, err
+}
+
+func (w *writeBehind) push(ctx context.Context, entry writeBehindEntry, overflow OverflowPolicy) (bool, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return false, ErrWriteBehindClosed
+	}
+	shard := w.shard(entry.fullKey)
+
+	w.track(entry.fullKey, 1)
+	select {
+	case shard <- entry:
+		return true, nil
+	default:
+	}
+	switch overflow {
+	case OverflowBlock:
+		select {
+		case shard <- entry:
+			return true, nil
+		case <-ctx.Done():
+			w.track(entry.fullKey, -1)
+			return false, ctx.Err()
+		}
+	case OverflowDrop:
+		w.track(entry.fullKey, -1)
+		if w.m.monitor != nil {
+			w.m.monitor.RecordWriteBehindDrop()
+		}
+		return true, nil
+	default:
+		w.track(entry.fullKey, -1)
+		return false, nil
+	}
+}
+
+func (w *writeBehind) shard(fullKey string) chan writeBehindEntry {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(fullKey))
+	return w.shards[h.Sum32()%uint32(len(w.shards))]
+}
+
+// await waits until the writes queued before it for fullKeys, or for
+// every key when fullKeys is nil, have been stored. Writes queued later
+// do not delay it.
+func (w *writeBehind) await(ctx context.Context, fullKeys []string) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return nil
+	}
+	shards := make(map[chan writeBehindEntry]bool)
+	if fullKeys == nil {
+		if w.count() == 0 {
+			return nil
+		}
+		for _, shard := range w.shards {
+			shards[shard] = true
+		}
+	}
+	for _, key := range fullKeys {
+		if w.has(key) {
+			shards[w.shard(key)] = true
+		}
+	}
+
+	barriers := make([]chan struct{}, 0, len(shards))
+	for shard := range shards {
+		barrier := make(chan struct{})
+		select {
+		case shard <- writeBehindEntry{barrier: barrier}:
+			barriers = append(barriers, barrier)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	for _, barrier := range barriers {
+		select {
+		case <-barrier:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (w *writeBehind) run(queue chan writeBehindEntry) {
+	defer w.workers.Done()
+	batch := make([]writeBehindEntry, 0, w.config.BatchSize)
+	timer := time.NewTimer(w.config.FlushInterval)
+	defer timer.Stop()
+	for {
+		select {
+		case entry := <-queue:
+			if entry.barrier != nil {
+				w.write(batch)
+				batch = batch[:0]
+				close(entry.barrier)
+				continue
+			}
+			batch = append(batch, entry)
+			if len(batch) < w.config.BatchSize {
+				continue
+			}
+		case <-timer.C:
+			timer.Reset(w.config.FlushInterval)
+		case <-w.shutdown:
+			for {
+				select {
+				case entry := <-queue:
+					if entry.barrier != nil {
+						close(entry.barrier)
+						continue
+					}
+					batch = append(batch, entry)
+				default:
+					w.write(batch)
+					return
+				}
+			}
+		}
+		w.write(batch)
+		batch = batch[:0]
+	}
+}
+
+// write stores batch, pipelining it when the adapter supports SetBatch
+// and chunking is off; chunked writes go one by one so overwrites can
+// delete stale chunks.
+func (w *writeBehind) write(batch []writeBehindEntry) {
+	if len(batch) == 0 {
+		return
+	}
+	m := w.m
+	ctx := context.Background()
+	defer func() {
+		for _, e := range batch {
+			w.track(e.fullKey, -1)
+		}
+	}()
+
+	bs, ok := m.adapter.(BatchSetAdapter)
+	ok = ok && m.current().chunkSize <= 0
+	entries := make([]BatchEntry, 0, len(batch))
+	for _, e := range batch {
+		if !ok {
+			w.writeOne(ctx, e)
+			continue
+		}
+		entries = append(entries, BatchEntry{Key: e.fullKey, Value: e.payload, TTL: e.ttl})
+	}
+	if len(entries) == 0 {
+		return
+	}
+	start := time.Now()
+	err := m.guard(ctx, m.writeTimeout, func(ctx context.Context) error {
+		return bs.SetBatch(ctx, entries)
+	})
+	elapsed := time.Since(start)
+	for _, e := range batch {
+		if !ok {
+			continue
+		}
+		if err != nil {
+			m.reportError(ctx, OpSet, e.key, err)
+			continue
+		}
+		m.recordSet(ctx, e.key, elapsed, len(e.payload))
+	}
+}
+
+func (w *writeBehind) writeOne(ctx context.Context, e writeBehindEntry) {
+	m := w.m
+	start := time.Now()
+	err := m.guard(ctx, m.writeTimeout, func(ctx context.Context) error {
+		return m.storePayload(ctx, e.fullKey, e.payload, e.ttl)
+	})
+	if err != nil {
+		m.reportError(ctx, OpSet, e.key, err)
+		return
+	}
+	m.recordSet(ctx, e.key, time.Since(start), len(e.payload))
+}
+
+// track adjusts the number of queued writes for fullKey not yet stored.
+func (w *writeBehind) track(fullKey string, delta int) {
+	w.pendingMu.Lock()
+	w.pending += delta
+	if n := w.keys[fullKey] + delta; n > 0 {
+		w.keys[fullKey] = n
+	} else {
+		delete(w.keys, fullKey)
+	}
+	w.pendingMu.Unlock()
+}
+
+// has reports whether fullKey has queued writes not yet stored.
+func (w *writeBehind) has(fullKey string) bool {
+	w.pendingMu.Lock()
+	defer w.pendingMu.Unlock()
+	return w.keys[fullKey] > 0
+}
+
+func (w *writeBehind) count() int {
+	w.pendingMu.Lock()
+	defer w.pendingMu.Unlock()
+	return w.pending
+}
+
+// flush waits until every write queued before it has been stored.
+func (w *writeBehind) flush(ctx context.Context) error {
+	return w.await(ctx, nil)
+}
+
+// close drains the queue and stops the workers.
+func (w *writeBehind) close() {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+	w.closed = true
+	w.mu.Unlock()
+	close(w.shutdown)
+	w.workers.Wait()
+}
+
+// Flush stores every write queued by write-behind Set before the call,
+// without waiting for FlushInterval, and blocks until they are stored or
+// ctx ends. It returns immediately when write-behind is off.
+func (m *Manager) Flush(ctx context.Context) error {
+	if m.writeBehind == nil {
+		return nil
+	}
+	return m.writeBehind.flush(ctx)
+}
+
+// awaitWrites waits for the write-behind writes queued before it for
+// fullKeys, or for every key when fullKeys is nil, so a deletion that
+// follows cannot be overwritten by them.
+func (m *Manager) awaitWrites(ctx context.Context, fullKeys []string) error {
+	if m.writeBehind == nil {
+		return nil
+	}
+	return m.writeBehind.await(ctx, fullKeys)
+}